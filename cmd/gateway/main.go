@@ -8,6 +8,7 @@ import (
 	"github.com/SkynetNext/unified-access-gateway/internal/config"
 	"github.com/SkynetNext/unified-access-gateway/internal/core"
 	"github.com/SkynetNext/unified-access-gateway/internal/discovery"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
 	"github.com/SkynetNext/unified-access-gateway/internal/observability"
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 )
@@ -23,17 +24,7 @@ func main() {
 			discovery.GetNodeName())
 	}
 
-	// 2. Initialize Distributed Tracing (OpenTelemetry)
-	jaegerEndpoint := os.Getenv("JAEGER_ENDPOINT")
-	if jaegerEndpoint != "" {
-		if err := observability.InitTracing("unified-access-gateway", jaegerEndpoint); err != nil {
-			xlog.Errorf("Failed to initialize tracing: %v", err)
-		} else {
-			xlog.Infof("Distributed tracing enabled: %s", jaegerEndpoint)
-		}
-	}
-
-	// 3. Load Infrastructure Configuration (env vars or ConfigMap)
+	// 2. Load Infrastructure Configuration (env vars or ConfigMap)
 	// Infrastructure config: Metrics, Redis connection settings
 	cfg := config.LoadConfig()
 	if discovery.IsRunningInK8s() {
@@ -45,26 +36,27 @@ func main() {
 	}
 	xlog.Infof("Infrastructure config loaded: metrics=%s, redis=%v", cfg.Metrics.ListenAddr, cfg.Security.Redis.Enabled)
 
-	// 4. Initialize Service Discovery (K8s DNS)
-	svcDiscovery := discovery.NewK8sServiceDiscovery()
-	if discovery.IsRunningInK8s() {
-		// Resolve backend services using K8s DNS
-		if httpBackend := os.Getenv("HTTP_BACKEND_SERVICE"); httpBackend != "" {
-			addr, err := svcDiscovery.ResolveServiceWithPort(httpBackend, 5000)
-			if err == nil {
-				os.Setenv("HTTP_BACKEND_URL", "http://"+addr)
-				xlog.Infof("Resolved HTTP backend: %s -> %s", httpBackend, addr)
-			}
-		}
-		if tcpBackend := os.Getenv("TCP_BACKEND_SERVICE"); tcpBackend != "" {
-			addr, err := svcDiscovery.ResolveServiceWithPort(tcpBackend, 6000)
-			if err == nil {
-				os.Setenv("TCP_BACKEND_ADDR", addr)
-				xlog.Infof("Resolved TCP backend: %s -> %s", tcpBackend, addr)
-			}
+	// Register the latency histograms with the configured buckets
+	middleware.InitMetrics(cfg.Metrics)
+
+	// 3. Initialize Distributed Tracing (OpenTelemetry)
+	if cfg.Tracing.Endpoint != "" {
+		if err := observability.InitTracing("unified-access-gateway", cfg.Tracing); err != nil {
+			xlog.Errorf("Failed to initialize tracing: %v", err)
+		} else {
+			xlog.Infof("Distributed tracing enabled: exporter=%s, endpoint=%s, sample_ratio=%.2f",
+				cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
 		}
 	}
 
+	// Access logs to Kafka (optional)
+	if err := middleware.InitLogger(cfg.AccessLog); err != nil {
+		xlog.Errorf("Failed to initialize access log shipping: %v", err)
+	}
+
+	// 4. Initialize Service Discovery (K8s DNS)
+	svcDiscovery := discovery.NewK8sServiceDiscovery()
+
 	// 5. Initialize Redis config store (REQUIRED for business config)
 	var redisStore *config.RedisStore
 	if cfg.Security.Redis.Enabled {
@@ -75,6 +67,7 @@ func main() {
 			os.Exit(1)
 		}
 		redisStore = store
+		redisStore.OnReconnect(middleware.RecordRedisReconnect)
 
 		// 6. Load Business Configuration from Redis (READ-ONLY)
 		businessCfg, err := redisStore.LoadBusinessConfig()
@@ -110,6 +103,38 @@ func main() {
 	// 8. Initialize Server with configuration
 	server := core.NewServer(cfg, redisStore)
 
+	// Spread the default backends across every endpoint of their K8s services,
+	// kept up to date by an EndpointSlice watch. Ports come from the services'
+	// named "http"/"tcp" ports (SRV records), falling back to 5000/6000
+	var stopWatches []func()
+	if discovery.IsRunningInK8s() {
+		for _, b := range []struct {
+			env      string
+			portName string
+			fallback int
+			proto    core.ProtocolType
+		}{
+			{"HTTP_BACKEND_SERVICE", "http", 5000, core.ProtocolHTTP},
+			{"TCP_BACKEND_SERVICE", "tcp", 6000, core.ProtocolTCP},
+		} {
+			service := os.Getenv(b.env)
+			if service == "" {
+				continue
+			}
+			if addrs, err := svcDiscovery.ResolveServiceSRV(service, b.portName, b.fallback); err != nil {
+				xlog.Warnf("Failed to resolve %s backend service %s: %v", b.proto, service, err)
+			} else {
+				server.SetDiscoveredTargets(b.proto, addrs)
+				xlog.Infof("Resolved %s backend: %s -> %v", b.proto, service, addrs)
+			}
+			proto := b.proto
+			stopWatches = append(stopWatches, svcDiscovery.WatchServiceEndpoints(service, b.portName, b.fallback, func(addrs []string) {
+				server.SetDiscoveredTargets(proto, addrs)
+				xlog.Infof("%s backend %s endpoints: %v", proto, service, addrs)
+			}))
+		}
+	}
+
 	// 9. Start Server (Non-blocking)
 	server.Start()
 
@@ -121,6 +146,9 @@ func main() {
 	xlog.Infof("Received signal: %v. Initiating graceful shutdown...", sig)
 
 	// 11. Execute Graceful Shutdown (Drain Mode)
+	for _, stop := range stopWatches {
+		stop()
+	}
 	server.GracefulShutdown(cfg.Lifecycle.ShutdownTimeout)
 
 	xlog.Infof("Server exited successfully.")
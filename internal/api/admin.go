@@ -0,0 +1,370 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/healthcheck"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/internal/security"
+	"github.com/SkynetNext/unified-access-gateway/pkg/ebpf"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// maxAdminBodyBytes bounds request bodies accepted by admin endpoints
+const maxAdminBodyBytes = 1 << 20
+
+// AdminAPI exposes operational endpoints under /admin/ on the metrics server.
+// Most report live state. Those that change configuration (WAF lists, API keys,
+// snapshot import and rollback) write to Redis only through versioned writes
+// (see config.RedisStore writeVersioned): each bumps config:version, honors
+// If-Match and publishes the change to every gateway.
+type AdminAPI struct {
+	cfg      *config.Config
+	security *security.Manager
+	store    *config.RedisStore
+	health   *healthcheck.UpstreamHealthChecker
+	listener ListenerStats
+	drain    Drainer
+	auth     *adminAuth
+}
+
+// ListenerStats is the live connection state reported by /admin/stats and
+// /admin/connections, plus the eBPF switch of /admin/ebpf and the connection
+// limit of /admin/limits/max_connections (implemented by the gateway listener)
+type ListenerStats interface {
+	ActiveConnections() int64
+	MaxConnections() int64
+	SetMaxConnections(n int64)
+	ListenerConnections() map[string]int64
+	InflightHandlers() int64
+	SockMapStats() ebpf.SockMapStats
+	SetEBPFEnabled(enabled bool, cgroupPath string) error
+	Connections(protocol string, limit int) ([]ConnectionInfo, int)
+}
+
+// Drainer is the maintenance drain switch of /admin/drain and /admin/undrain
+// (implemented by the gateway server)
+type Drainer interface {
+	SetMaintenanceDrain(drain bool) error
+	DrainState() string
+}
+
+// NewAdminAPI creates the admin API. store may be nil (in-memory state is reported).
+func NewAdminAPI(cfg *config.Config, sec *security.Manager, store *config.RedisStore, health *healthcheck.UpstreamHealthChecker, listener ListenerStats, drain Drainer) *AdminAPI {
+	return &AdminAPI{
+		cfg:      cfg,
+		security: sec,
+		store:    store,
+		health:   health,
+		listener: listener,
+		drain:    drain,
+		auth:     newAdminAuth(cfg.Admin, store),
+	}
+}
+
+// RegisterRoutes mounts the admin endpoints on mux
+// All routes except /admin/health require authentication
+func (a *AdminAPI) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/health", a.handleHealth)
+	mux.HandleFunc("/admin/stats", a.auth.wrap(a.handleStats))
+	mux.HandleFunc("/admin/connections", a.auth.wrap(a.handleConnections))
+	mux.HandleFunc("/admin/ebpf", a.auth.wrap(a.handleEBPF))
+	mux.HandleFunc("/admin/limits/max_connections", a.auth.wrap(a.handleMaxConnections))
+	mux.HandleFunc("/admin/drain", a.auth.wrap(a.handleDrain(true)))
+	mux.HandleFunc("/admin/undrain", a.auth.wrap(a.handleDrain(false)))
+	mux.HandleFunc("/admin/security/waf/ips", a.auth.wrap(a.handleWAFIPs))
+	mux.HandleFunc("/admin/security/waf/allowlist", a.auth.wrap(a.handleWAFAllowlist))
+	mux.HandleFunc("/admin/security/waf/patterns", a.auth.wrap(a.handleWAFPatterns))
+	mux.HandleFunc("/admin/auth/api-keys", a.auth.wrap(a.handleAPIKeys))
+	mux.HandleFunc("/admin/config/export", a.auth.wrap(a.handleConfigExport))
+	mux.HandleFunc("/admin/config/import", a.auth.wrap(a.handleConfigImport))
+	mux.HandleFunc("/admin/config/versions", a.auth.wrap(a.handleConfigVersions))
+	mux.HandleFunc("/admin/config/rollback", a.auth.wrap(a.handleConfigRollback))
+}
+
+// handleHealth is an unauthenticated liveness check for the admin API. It also
+// reports the drain state, which /ready only exposes as a 503.
+func (a *AdminAPI) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]string{"status": "ok"}
+	if a.drain != nil {
+		resp["drain"] = a.drain.DrainState()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleStats reports connection counts, eBPF sockmap counters and upstream
+// health. It only reads in-memory counters, so it is cheap to poll.
+func (a *AdminAPI) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	type connectionStats struct {
+		Active     int64            `json:"active"`
+		Max        int64            `json:"max"`
+		ByProtocol map[string]int64 `json:"by_protocol"`
+		ByListener map[string]int64 `json:"by_listener"`
+		Handlers   int64            `json:"handlers_inflight"`
+	}
+	stats := struct {
+		Connections connectionStats                       `json:"connections"`
+		SockMap     ebpf.SockMapStats                     `json:"ebpf_sockmap"`
+		Upstreams   map[string]healthcheck.UpstreamStatus `json:"upstreams"`
+	}{
+		Connections: connectionStats{ByProtocol: middleware.ActiveConnectionCounts()},
+		Upstreams:   a.health.Snapshot(),
+	}
+	if a.listener != nil {
+		stats.Connections.Active = a.listener.ActiveConnections()
+		stats.Connections.Max = a.listener.MaxConnections()
+		stats.Connections.ByListener = a.listener.ListenerConnections()
+		stats.Connections.Handlers = a.listener.InflightHandlers()
+		stats.SockMap = a.listener.SockMapStats()
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleWAFIPs returns the blocked IP list as a sorted JSON array.
+// POST adds and DELETE removes a JSON array of IPs/CIDRs (see addToSet and
+// removeFromSet); DELETE ?all=true clears the list.
+func (a *AdminAPI) handleWAFIPs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.writeList(w, a.store.GetBlockedIPs, a.security.BlockedIPs)
+	case http.MethodPost:
+		a.addToSet(w, r, validateIPs, a.store.AddBlockedIPs)
+	case http.MethodDelete:
+		a.removeFromSet(w, r, a.store.RemoveBlockedIPs, a.store.ClearBlockedIPs)
+	default:
+		methodNotAllowed(w, wafListMethods)
+	}
+}
+
+// handleWAFAllowlist returns the allowlisted IPs/CIDRs as a sorted JSON array.
+// POST adds and DELETE removes a JSON array of IPs/CIDRs; DELETE ?all=true
+// clears the list (in allowlist mode this denies every client).
+func (a *AdminAPI) handleWAFAllowlist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.writeList(w, a.store.GetAllowedIPs, a.security.AllowedIPs)
+	case http.MethodPost:
+		a.addToSet(w, r, validateIPs, a.store.AddAllowedIPs)
+	case http.MethodDelete:
+		a.removeFromSet(w, r, a.store.RemoveAllowedIPs, a.store.ClearAllowedIPs)
+	default:
+		methodNotAllowed(w, wafListMethods)
+	}
+}
+
+// handleWAFPatterns returns the blocked pattern list as a sorted JSON array.
+// POST adds a JSON array of patterns; with ?dry_run=true it only compiles them,
+// so admin tools can reject bad regexes before writing them. DELETE removes a
+// JSON array of patterns, or clears the list with ?all=true.
+func (a *AdminAPI) handleWAFPatterns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.writeList(w, a.store.GetBlockedPatterns, a.security.BlockedPatterns)
+	case http.MethodPost:
+		if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+			a.validatePatterns(w, r)
+			return
+		}
+		a.addToSet(w, r, validatePatterns, a.store.AddBlockedPatterns)
+	case http.MethodDelete:
+		a.removeFromSet(w, r, a.store.RemoveBlockedPatterns, a.store.ClearBlockedPatterns)
+	default:
+		methodNotAllowed(w, wafListMethods)
+	}
+}
+
+// validatePatterns accepts a JSON array of patterns and reports every pattern
+// that fails to compile. Nothing is applied or persisted.
+func (a *AdminAPI) validatePatterns(w http.ResponseWriter, r *http.Request) {
+	var patterns []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes)).Decode(&patterns); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a JSON array of patterns: "+err.Error())
+		return
+	}
+	if invalid := security.ValidatePatterns(patterns); len(invalid) > 0 {
+		writeErrorDetails(w, http.StatusBadRequest, codeValidation, "invalid patterns",
+			map[string]interface{}{"invalid": invalid})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"valid":   true,
+		"count":   len(patterns),
+		"dry_run": true,
+	})
+}
+
+// addToSet decodes a JSON array, validates it and adds it to a WAF set in
+// Redis. Pass the ETag of the last read as If-Match to fail with 409 instead
+// of overwriting a concurrent edit; the response carries the new ETag.
+func (a *AdminAPI) addToSet(w http.ResponseWriter, r *http.Request, validate func([]string) interface{}, add func(int64, ...string) (int64, error)) {
+	if a.store == nil {
+		writeStoreNotConfigured(w)
+		return
+	}
+	ifVersion, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	var entries []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes)).Decode(&entries); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a JSON array: "+err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a non-empty JSON array")
+		return
+	}
+	if invalid := validate(entries); invalid != nil {
+		writeErrorDetails(w, http.StatusBadRequest, codeValidation, "invalid entries",
+			map[string]interface{}{"invalid": invalid})
+		return
+	}
+	version, err := add(ifVersion, entries...)
+	if err != nil {
+		xlog.Warnf("Admin API: WAF update failed: %v", err)
+		writeConfigError(w, err)
+		return
+	}
+	setVersionETag(w, version)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"added":   len(entries),
+		"version": version,
+	})
+}
+
+// removeFromSet removes a JSON array of entries from a WAF set in Redis, or
+// every entry with ?all=true (an explicit flag, so an empty body never wipes a
+// list). Both are idempotent: absent entries and empty sets are not errors.
+// If-Match and the ETag work as in addToSet.
+func (a *AdminAPI) removeFromSet(w http.ResponseWriter, r *http.Request, remove func(int64, ...string) (int64, error), clear func(int64) (int64, error)) {
+	if a.store == nil {
+		writeStoreNotConfigured(w)
+		return
+	}
+	ifVersion, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if all, _ := strconv.ParseBool(r.URL.Query().Get("all")); all {
+		version, err := clear(ifVersion)
+		if err != nil {
+			xlog.Warnf("Admin API: WAF clear failed: %v", err)
+			writeConfigError(w, err)
+			return
+		}
+		xlog.Infof("Admin API: %s cleared by %s", r.URL.Path, r.RemoteAddr)
+		setVersionETag(w, version)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"cleared": true,
+			"version": version,
+		})
+		return
+	}
+	var entries []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes)).Decode(&entries); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a JSON array (or ?all=true to clear): "+err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a non-empty JSON array (or ?all=true to clear)")
+		return
+	}
+	version, err := remove(ifVersion, entries...)
+	if err != nil {
+		xlog.Warnf("Admin API: WAF update failed: %v", err)
+		writeConfigError(w, err)
+		return
+	}
+	setVersionETag(w, version)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"removed": len(entries),
+		"version": version,
+	})
+}
+
+func validateIPs(entries []string) interface{} {
+	if invalid := security.ValidateIPs(entries); len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+func validatePatterns(entries []string) interface{} {
+	if invalid := security.ValidatePatterns(entries); len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// writeList reads from Redis when configured (source of truth shared by all
+// replicas), otherwise from the in-memory security manager. The ETag is the
+// config version, read first so it never claims newer data than returned.
+func (a *AdminAPI) writeList(w http.ResponseWriter, fromStore func() ([]string, error), fromMemory func() []string) {
+	var list []string
+	if a.store != nil {
+		version, err := a.store.GetConfigVersion()
+		if err == nil {
+			setVersionETag(w, version)
+		}
+		items, err := fromStore()
+		if err != nil {
+			xlog.Warnf("Admin API: failed to read from Redis: %v", err)
+			writeStoreReadError(w, err)
+			return
+		}
+		list = items
+	} else {
+		list = fromMemory()
+	}
+	if list == nil {
+		list = []string{}
+	}
+	sort.Strings(list)
+	writeJSON(w, http.StatusOK, list)
+}
+
+// ifMatchVersion parses If-Match as a config version; absent or "*" means any version
+func ifMatchVersion(r *http.Request) (int64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return config.AnyVersion, nil
+	}
+	v = strings.Trim(strings.TrimPrefix(v, "W/"), `"`)
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("If-Match must be a config version ETag, got %q", r.Header.Get("If-Match"))
+	}
+	return version, nil
+}
+
+func setVersionETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// wafListMethods are the methods of the WAF list endpoints
+const wafListMethods = http.MethodGet + ", " + http.MethodPost + ", " + http.MethodDelete
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed, use "+allowed)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		xlog.Warnf("Admin API: failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/security"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// apiKeyInfo describes a stored API key; the key itself is never stored or returned
+type apiKeyInfo struct {
+	ID   string `json:"id"` // hex SHA-256 of the key
+	Name string `json:"name"`
+}
+
+// handleAPIKeys manages the keys accepted in apikey auth mode.
+// GET lists {id, name}; POST {"name": ..., "key": optional} stores a key and
+// returns it once (a random key is generated when none is given); DELETE ?id=
+// revokes a key on every gateway via config:changed. Writes honour If-Match.
+func (a *AdminAPI) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if a.store == nil {
+		writeStoreNotConfigured(w)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		a.listAPIKeys(w)
+	case http.MethodPost:
+		a.addAPIKey(w, r)
+	case http.MethodDelete:
+		a.removeAPIKey(w, r)
+	default:
+		methodNotAllowed(w, http.MethodGet+", "+http.MethodPost+", "+http.MethodDelete)
+	}
+}
+
+func (a *AdminAPI) listAPIKeys(w http.ResponseWriter) {
+	version, err := a.store.GetConfigVersion()
+	if err == nil {
+		setVersionETag(w, version)
+	}
+	keys, err := a.store.LoadAPIKeys()
+	if err != nil {
+		writeStoreReadError(w, err)
+		return
+	}
+	list := make([]apiKeyInfo, 0, len(keys))
+	for digest, name := range keys {
+		list = append(list, apiKeyInfo{ID: digest, Name: name})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (a *AdminAPI) addAPIKey(w http.ResponseWriter, r *http.Request) {
+	ifVersion, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+		Key  string `json:"key"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a JSON object {\"name\": ..., \"key\": ...}: "+err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeErrorDetails(w, http.StatusBadRequest, codeValidation, "name is required", map[string]string{"field": "name"})
+		return
+	}
+	if req.Key == "" {
+		if req.Key, err = security.GenerateAPIKey(); err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+	} else if len(req.Key) < 16 {
+		writeErrorDetails(w, http.StatusBadRequest, codeValidation, "key must be at least 16 characters", map[string]string{"field": "key"})
+		return
+	}
+
+	digest := security.HashAPIKey(req.Key)
+	version, err := a.store.AddAPIKey(ifVersion, digest, req.Name)
+	if err != nil {
+		xlog.Warnf("Admin API: API key update failed: %v", err)
+		writeConfigError(w, err)
+		return
+	}
+	setVersionETag(w, version)
+	xlog.Infof("Admin API: API key %q added by %s", req.Name, r.RemoteAddr)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":      digest,
+		"name":    req.Name,
+		"key":     req.Key,
+		"version": version,
+	})
+}
+
+func (a *AdminAPI) removeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ifVersion, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	id := strings.ToLower(r.URL.Query().Get("id"))
+	if raw, err := hex.DecodeString(id); err != nil || len(raw) != 32 {
+		writeErrorDetails(w, http.StatusBadRequest, codeValidation, "id must be the hex SHA-256 of the key", map[string]string{"field": "id"})
+		return
+	}
+	version, err := a.store.RemoveAPIKey(ifVersion, id)
+	if err != nil {
+		xlog.Warnf("Admin API: API key update failed: %v", err)
+		writeConfigError(w, err)
+		return
+	}
+	setVersionETag(w, version)
+	xlog.Infof("Admin API: API key %.12s revoked by %s", id, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"revoked": id,
+		"version": version,
+	})
+}
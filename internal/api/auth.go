@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// adminAuth authorizes admin requests by static bearer token or mTLS subject
+type adminAuth struct {
+	mu              sync.RWMutex
+	token           string
+	staticToken     string
+	allowedSubjects map[string]struct{}
+}
+
+func newAdminAuth(cfg config.AdminConfig, store *config.RedisStore) *adminAuth {
+	a := &adminAuth{
+		token:           cfg.Token,
+		staticToken:     cfg.Token,
+		allowedSubjects: make(map[string]struct{}, len(cfg.AllowedSubjects)),
+	}
+	// Subjects can only be verified on the mTLS listener
+	if cfg.MTLSEnabled() {
+		for _, subj := range cfg.AllowedSubjects {
+			a.allowedSubjects[subj] = struct{}{}
+		}
+	} else if len(cfg.AllowedSubjects) > 0 {
+		xlog.Warnf("ADMIN_ALLOWED_SUBJECTS ignored: admin mTLS needs ADMIN_TLS_CERT_FILE, ADMIN_TLS_KEY_FILE and ADMIN_TLS_CLIENT_CA_FILE")
+	}
+
+	if store != nil {
+		a.reloadToken(store)
+		// Token rotation via Redis pub/sub
+		go func() {
+			for update := range store.Subscribe() {
+				if update.Is("admin") {
+					a.reloadToken(store)
+				}
+			}
+		}()
+	}
+
+	if a.getToken() == "" && len(a.allowedSubjects) == 0 {
+		xlog.Warnf("Admin API auth not configured (ADMIN_TOKEN / ADMIN_ALLOWED_SUBJECTS), admin endpoints will reject all requests")
+	}
+	return a
+}
+
+// reloadToken replaces the token with the one stored in Redis. A missing or
+// empty key falls back to the static token (or denies all if there is none),
+// so deleting the key revokes a rotated token; read errors keep the current one.
+func (a *adminAuth) reloadToken(store *config.RedisStore) {
+	token, err := store.LoadAdminToken()
+	if err != nil {
+		xlog.Warnf("Failed to load admin token from Redis, keeping current token: %v", err)
+		return
+	}
+	if token == "" {
+		a.mu.Lock()
+		a.token = a.staticToken
+		a.mu.Unlock()
+		xlog.Infof("Admin API token not set in Redis, using static token")
+		return
+	}
+	a.mu.Lock()
+	a.token = token
+	a.mu.Unlock()
+	xlog.Infof("Admin API token loaded from Redis")
+}
+
+func (a *adminAuth) getToken() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.token
+}
+
+// authorize returns true if the request carries a valid token or an allowed,
+// verified client certificate
+func (a *adminAuth) authorize(r *http.Request) bool {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if _, ok := a.allowedSubjects[r.TLS.PeerCertificates[0].Subject.String()]; ok {
+			return true
+		}
+	}
+
+	token := a.getToken()
+	if token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(auth[7:])), []byte(token)) == 1
+}
+
+// wrap rejects unauthorized requests with 401
+func (a *adminAuth) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorize(r) {
+			middleware.RecordSecurityBlock("admin_unauthorized")
+			xlog.Warnf("Admin API: unauthorized %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="uag-admin"`)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
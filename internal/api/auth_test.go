@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testCA signs the server and client certificates of the mTLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "uag test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate for subject, as a server cert for 127.0.0.1 or a client cert
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, server bool) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestAdminAuthClientCertSubject(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, ca.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mr := miniredis.RunT(t)
+	store, err := config.NewRedisStore(&config.RedisConfig{Enabled: true, Addr: mr.Addr(), KeyPrefix: "uag:"})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	// Subjects only count with the admin TLS files configured; the server
+	// mirrors the admin listener's RequireAndVerifyClientCert
+	cfg := &config.Config{Admin: config.AdminConfig{
+		Token:           testAdminToken,
+		AllowedSubjects: []string{"CN=ops,O=uag"},
+		CertFile:        filepath.Join(dir, "server.pem"),
+		KeyFile:         filepath.Join(dir, "server-key.pem"),
+		ClientCAFile:    caFile,
+	}}
+	mux := http.NewServeMux()
+	NewAdminAPI(cfg, nil, store, nil, nil, nil).RegisterRoutes(mux)
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, pkix.Name{CommonName: "gateway"}, true)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    x509.NewCertPool(),
+	}
+	srv.TLS.ClientCAs.AddCert(ca.cert)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name       string
+		subject    pkix.Name
+		token      bool
+		wantStatus int
+	}{
+		{"allowed subject", pkix.Name{CommonName: "ops", Organization: []string{"uag"}}, false, http.StatusOK},
+		{"other subject", pkix.Name{CommonName: "dev", Organization: []string{"uag"}}, false, http.StatusUnauthorized},
+		{"other subject with token", pkix.Name{CommonName: "dev", Organization: []string{"uag"}}, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.cert)
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: []tls.Certificate{ca.issue(t, tt.subject, false)},
+			}}}
+			defer client.CloseIdleConnections()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/admin/config/versions", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token {
+				req.Header.Set("Authorization", "Bearer "+testAdminToken)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAdminAuthSubjectsNeedMTLS(t *testing.T) {
+	// Without the admin TLS files the subject list must not authorize anything
+	a := newAdminAuth(config.AdminConfig{AllowedSubjects: []string{"CN=ops,O=uag"}}, nil)
+	if len(a.allowedSubjects) != 0 {
+		t.Errorf("allowedSubjects = %v, want none without admin mTLS", a.allowedSubjects)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultConnectionsLimit and maxConnectionsLimit bound /admin/connections responses
+	defaultConnectionsLimit = 1000
+	maxConnectionsLimit     = 10000
+)
+
+// ConnectionInfo describes one open client connection
+type ConnectionInfo struct {
+	Listener string    `json:"listener"`
+	Client   string    `json:"client"`            // PROXY protocol address when enabled
+	Backend  string    `json:"backend,omitempty"` // Last upstream for HTTP, "" until dialed
+	Protocol string    `json:"protocol"`          // http, h2c, tcp, tls (passthrough) or unknown (sniffing)
+	TLS      bool      `json:"tls,omitempty"`     // Terminated by the gateway
+	Start    time.Time `json:"start"`
+	BytesIn  int64     `json:"bytes_in"`  // Received from the client
+	BytesOut int64     `json:"bytes_out"` // Sent to the client
+}
+
+// handleConnections lists open client connections, oldest first, to diagnose
+// stuck connections. ?protocol= filters by protocol and ?limit= caps the list
+// (default 1000, at most 10000); "total" counts every match. Bytes moved by
+// eBPF redirection bypass the gateway and are not counted.
+func (a *AdminAPI) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	if a.listener == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "listener not running")
+		return
+	}
+	limit := defaultConnectionsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxConnectionsLimit {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "limit must be between 1 and "+strconv.Itoa(maxConnectionsLimit))
+			return
+		}
+		limit = n
+	}
+	conns, total := a.listener.Connections(r.URL.Query().Get("protocol"), limit)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":       total,
+		"connections": conns,
+	})
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// handleDrain returns the handler of POST /admin/drain (drain = true) and
+// POST /admin/undrain. Draining for maintenance fails /ready so Kubernetes
+// removes the gateway from endpoints, while open and new connections are still
+// served and the process keeps running for inspection. Unlike a shutdown it is
+// undone by /admin/undrain. The state is local to this gateway and is not
+// persisted: a restart starts running.
+func (a *AdminAPI) handleDrain(drain bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if a.drain == nil {
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "drain control not available")
+			return
+		}
+		if err := a.drain.SetMaintenanceDrain(drain); err != nil {
+			writeError(w, http.StatusConflict, codeConflict, err.Error())
+			return
+		}
+		xlog.Infof("Admin API: maintenance drain set to %t by %s", drain, r.RemoteAddr)
+		writeJSON(w, http.StatusOK, map[string]string{"drain": a.drain.DrainState()})
+	}
+}
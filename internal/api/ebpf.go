@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// handleEBPF switches eBPF acceleration at runtime. GET reports the sockmap
+// state. POST {"enabled": false} detaches the sockops program: new connections
+// are not registered and registered pairs fall back to the userspace copy.
+// POST {"enabled": true, "cgroup_path": optional} loads and attaches it again
+// ("" = the cgroup used before, or auto-detection). The switch is local to
+// this gateway and is not persisted: a restart honours EBPF_ENABLED.
+func (a *AdminAPI) handleEBPF(w http.ResponseWriter, r *http.Request) {
+	if a.listener == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "listener not running")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.listener.SockMapStats())
+	case http.MethodPost:
+		var req struct {
+			Enabled    *bool  `json:"enabled"`
+			CgroupPath string `json:"cgroup_path"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a JSON object {\"enabled\": true|false, \"cgroup_path\": ...}: "+err.Error())
+			return
+		}
+		if req.Enabled == nil {
+			writeErrorDetails(w, http.StatusBadRequest, codeValidation, "enabled is required", map[string]string{"field": "enabled"})
+			return
+		}
+		if req.CgroupPath != "" && (!filepath.IsAbs(req.CgroupPath) || !*req.Enabled) {
+			writeErrorDetails(w, http.StatusBadRequest, codeValidation, "cgroup_path must be an absolute path and only applies when enabling", map[string]string{"field": "cgroup_path"})
+			return
+		}
+		if err := a.listener.SetEBPFEnabled(*req.Enabled, req.CgroupPath); err != nil {
+			xlog.Warnf("Admin API: eBPF switch failed: %v", err)
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+			return
+		}
+		xlog.Infof("Admin API: eBPF acceleration set to enabled=%t by %s", *req.Enabled, r.RemoteAddr)
+		writeJSON(w, http.StatusOK, a.listener.SockMapStats())
+	default:
+		methodNotAllowed(w, http.MethodGet+", "+http.MethodPost)
+	}
+}
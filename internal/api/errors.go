@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+)
+
+// Error codes of admin API error responses. They are part of the API: match
+// on the code, not the message.
+const (
+	codeBadRequest       = "bad_request"        // Malformed query parameter or header
+	codeInvalidBody      = "invalid_body"       // Body is not the expected JSON
+	codeValidation       = "validation_failed"  // Well-formed, but some entries are invalid (see details)
+	codeUnauthorized     = "unauthorized"       // Missing or wrong admin credentials
+	codeNotFound         = "not_found"          // Referenced config version does not exist
+	codeMethodNotAllowed = "method_not_allowed" // See the Allow header
+	codeVersionConflict  = "version_conflict"   // If-Match did not match, or a concurrent write won
+	codeUnavailable      = "unavailable"        // Redis or eBPF not configured or unavailable
+	codeConflict         = "conflict"           // The gateway state does not allow the change (e.g. shutting down)
+	codeInternal         = "internal"
+)
+
+// apiError is the body of every admin API error response:
+// {"error": {"code": ..., "message": ..., "details": ...}}
+type apiError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorDetails(w, status, code, message, nil)
+}
+
+// writeErrorDetails writes a JSON error response with machine-readable
+// details, such as the entries that failed validation
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	writeJSON(w, status, map[string]apiError{
+		"error": {Code: code, Message: message, Details: details},
+	})
+}
+
+// writeStoreNotConfigured rejects requests that need Redis when it is disabled
+func writeStoreNotConfigured(w http.ResponseWriter) {
+	writeError(w, http.StatusServiceUnavailable, codeUnavailable, "redis store not configured")
+}
+
+// writeStoreReadError reports a failed read from Redis
+func writeStoreReadError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusServiceUnavailable, codeUnavailable, "failed to read from redis: "+err.Error())
+}
+
+// writeConfigError maps RedisStore write errors to a status and error code
+func writeConfigError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, config.ErrInvalidSnapshot), errors.Is(err, config.ErrInvalidEntries):
+		writeError(w, http.StatusBadRequest, codeValidation, err.Error())
+	case errors.Is(err, config.ErrVersionNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, config.ErrVersionConflict), errors.Is(err, config.ErrConcurrentUpdate):
+		writeError(w, http.StatusConflict, codeVersionConflict, err.Error())
+	default:
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+	}
+}
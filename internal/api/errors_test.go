@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/alicebob/miniredis/v2"
+)
+
+const testAdminToken = "test-admin-token"
+
+// newTestServer serves the admin routes with a miniredis-backed store and the
+// static token testAdminToken
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	store, err := config.NewRedisStore(&config.RedisConfig{Enabled: true, Addr: mr.Addr(), KeyPrefix: "uag:"})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{Admin: config.AdminConfig{Token: testAdminToken}}
+	mux := http.NewServeMux()
+	NewAdminAPI(cfg, nil, store, nil, nil, nil).RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAdminErrorResponses(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		noToken    bool
+		wantStatus int
+		wantCode   string
+		wantDetail map[string]interface{}
+	}{
+		{
+			name:       "unauthorized",
+			method:     http.MethodGet,
+			path:       "/admin/stats",
+			noToken:    true,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   codeUnauthorized,
+		},
+		{
+			name:       "invalid body",
+			method:     http.MethodPost,
+			path:       "/admin/security/waf/ips",
+			body:       `{"ip": "203.0.113.7"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   codeInvalidBody,
+		},
+		{
+			name:       "invalid entries",
+			method:     http.MethodPost,
+			path:       "/admin/security/waf/ips",
+			body:       `["203.0.113.7", "10.0.0.300"]`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   codeValidation,
+			wantDetail: map[string]interface{}{"invalid": []interface{}{"10.0.0.300"}},
+		},
+		{
+			name:       "bad query parameter",
+			method:     http.MethodPost,
+			path:       "/admin/config/rollback?version=latest",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   codeBadRequest,
+		},
+		{
+			name:       "version not found",
+			method:     http.MethodPost,
+			path:       "/admin/config/rollback?version=42",
+			wantStatus: http.StatusNotFound,
+			wantCode:   codeNotFound,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodPut,
+			path:       "/admin/security/waf/ips",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantCode:   codeMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.noToken {
+				req.Header.Set("Authorization", "Bearer "+testAdminToken)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var body struct {
+				Error *struct {
+					Code    string                 `json:"code"`
+					Message string                 `json:"message"`
+					Details map[string]interface{} `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding error body: %v", err)
+			}
+			if body.Error == nil {
+				t.Fatal(`body has no "error" object`)
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Error.Code, tt.wantCode)
+			}
+			if body.Error.Message == "" {
+				t.Error("message is empty")
+			}
+			if !reflect.DeepEqual(body.Error.Details, tt.wantDetail) {
+				t.Errorf("details = %v, want %v", body.Error.Details, tt.wantDetail)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// handleMaxConnections adjusts the connection limit at runtime. GET reports it.
+// PUT {"max_connections": n} sets it (0 = unlimited); connections already open
+// above a lowered limit are not closed. The value is local to this gateway and
+// is not persisted: the next change of server.max_connections in Redis, or a
+// restart, replaces it.
+func (a *AdminAPI) handleMaxConnections(w http.ResponseWriter, r *http.Request) {
+	if a.listener == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "listener not running")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]int64{"max_connections": a.listener.MaxConnections()})
+	case http.MethodPut:
+		var req struct {
+			MaxConnections *int64 `json:"max_connections"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a JSON object {\"max_connections\": n}: "+err.Error())
+			return
+		}
+		if req.MaxConnections == nil || *req.MaxConnections < 0 {
+			writeErrorDetails(w, http.StatusBadRequest, codeValidation, "max_connections must be 0 (unlimited) or more", map[string]string{"field": "max_connections"})
+			return
+		}
+		a.listener.SetMaxConnections(*req.MaxConnections)
+		xlog.Infof("Admin API: max connections set to %d by %s", *req.MaxConnections, r.RemoteAddr)
+		writeJSON(w, http.StatusOK, map[string]int64{"max_connections": a.listener.MaxConnections()})
+	default:
+		methodNotAllowed(w, http.MethodGet+", "+http.MethodPut)
+	}
+}
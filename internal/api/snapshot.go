@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/security"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// maxSnapshotBytes bounds an imported snapshot (large WAF lists are expected)
+const maxSnapshotBytes = 32 << 20
+
+// handleConfigExport returns the dynamic configuration stored in Redis as one
+// JSON document, suitable for POST /admin/config/import
+func (a *AdminAPI) handleConfigExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	if a.store == nil {
+		writeStoreNotConfigured(w)
+		return
+	}
+	// Version first: a write in between makes the ETag stale, which only causes a 409
+	version, err := a.store.GetConfigVersion()
+	if err != nil {
+		writeStoreReadError(w, err)
+		return
+	}
+	snap, err := a.store.ExportSnapshot()
+	if err != nil {
+		xlog.Warnf("Admin API: config export failed: %v", err)
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+		return
+	}
+	setVersionETag(w, version)
+	w.Header().Set("Content-Disposition", `attachment; filename="gateway-config.json"`)
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// handleConfigImport validates a snapshot and writes it to Redis atomically.
+// An invalid snapshot is rejected as a whole; ?dry_run=true only validates.
+func (a *AdminAPI) handleConfigImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if a.store == nil {
+		writeStoreNotConfigured(w)
+		return
+	}
+
+	ifVersion, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	var snap config.ConfigSnapshot
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSnapshotBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&snap); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "expected a config snapshot: "+err.Error())
+		return
+	}
+
+	if problems := validateSnapshot(&snap); len(problems) > 0 {
+		writeErrorDetails(w, http.StatusBadRequest, codeValidation, "invalid config snapshot",
+			map[string]interface{}{"problems": problems})
+		return
+	}
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"valid":   true,
+			"dry_run": true,
+		})
+		return
+	}
+
+	version, err := a.store.ImportSnapshot(&snap, ifVersion)
+	if err != nil {
+		xlog.Warnf("Admin API: config import failed: %v", err)
+		writeConfigError(w, err)
+		return
+	}
+	setVersionETag(w, version)
+	xlog.Infof("Admin API: config snapshot imported from %s as version %d", r.RemoteAddr, version)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"imported": true,
+		"version":  version,
+		"hashes":   len(snap.Hashes),
+		"sets":     len(snap.Sets),
+	})
+}
+
+// handleConfigVersions reports the current config version in Redis, the version
+// this gateway last applied and the rollback history
+func (a *AdminAPI) handleConfigVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	if a.store == nil {
+		writeStoreNotConfigured(w)
+		return
+	}
+	current, err := a.store.GetConfigVersion()
+	if err != nil {
+		writeStoreReadError(w, err)
+		return
+	}
+	setVersionETag(w, current)
+	history, err := a.store.ListConfigVersions()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"current": current,
+		"applied": a.store.AppliedVersion(),
+		"history": history,
+	})
+}
+
+// handleConfigRollback restores the config of a version from the history (POST ?version=N)
+func (a *AdminAPI) handleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if a.store == nil {
+		writeStoreNotConfigured(w)
+		return
+	}
+	target, err := strconv.ParseInt(r.URL.Query().Get("version"), 10, 64)
+	if err != nil || target < 0 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "version query parameter must be a non-negative integer")
+		return
+	}
+	ifVersion, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	version, err := a.store.RollbackTo(target, ifVersion)
+	if err != nil {
+		xlog.Warnf("Admin API: config rollback to version %d failed: %v", target, err)
+		writeConfigError(w, err)
+		return
+	}
+	setVersionETag(w, version)
+	xlog.Infof("Admin API: config rolled back to version %d by %s", target, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rolled_back_to": target,
+		"version":        version,
+	})
+}
+
+// validateSnapshot adds the WAF checks (regexes, IPs) to the snapshot's own validation
+func validateSnapshot(snap *config.ConfigSnapshot) []string {
+	problems := snap.Validate()
+	for _, invalid := range security.ValidatePatterns(snap.Sets["waf:blocked_patterns"]) {
+		problems = append(problems, "waf:blocked_patterns: "+strconv.Quote(invalid.Pattern)+": "+invalid.Error)
+	}
+	for _, key := range []string{"waf:blocked_ips", "waf:allowed_ips"} {
+		for _, ip := range security.ValidateIPs(snap.Sets[key]) {
+			problems = append(problems, key+": invalid IP or CIDR "+strconv.Quote(ip))
+		}
+	}
+	return problems
+}
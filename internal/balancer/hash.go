@@ -0,0 +1,47 @@
+package balancer
+
+import (
+	"hash/fnv"
+	"math"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+)
+
+// PickKey returns the index of the target selected for key by weighted
+// rendezvous (highest random weight) hashing, or -1 when no available target
+// has a weight. Each target scores weight / -ln(h) for a hash h of key and
+// target in (0, 1), and the highest score wins: the same key keeps its target
+// while the available set is unchanged, and when a target is added or removed
+// only the keys that move to or from it are remapped.
+func (g *Weighted) PickKey(key string, available func(addr string) bool) int {
+	best, bestScore := -1, 0.0
+	for i, t := range g.Targets {
+		if t.Weight <= 0 || (available != nil && !available(t.Addr)) {
+			continue
+		}
+		score := float64(t.Weight) / -math.Log(unitHash(key, t.Addr))
+		if best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best >= 0 {
+		middleware.RecordBackendSelection(g.Backend, g.Targets[best].Addr)
+	}
+	return best
+}
+
+// unitHash maps key and target to a float in the open interval (0, 1)
+func unitHash(key, target string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(target))
+	// FNV's low bits mix poorly for short inputs; finish with a 64-bit mixer
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return (float64(x>>11) + 0.5) / (1 << 53)
+}
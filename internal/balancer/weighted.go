@@ -0,0 +1,77 @@
+package balancer
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+)
+
+// Target is one backend of a weighted group
+type Target struct {
+	Addr   string // Target URL or host:port
+	Weight int
+}
+
+// Weighted picks the target of each connection or request in proportion to
+// the weights: at random, or by hashing a client key (see PickKey). Targets
+// reported unavailable are left out and the weights renormalized over the
+// rest, so a failing canary stops taking traffic without sending its share to
+// an arbitrary target.
+type Weighted struct {
+	Backend    string   // Configured backend the group replaces
+	Targets    []Target // Sorted by address
+	Hash       bool     // Select by PickKey (hash strategy)
+	HashHeader string   // HTTP header keyed on instead of the client IP ("" = client IP)
+}
+
+// NewWeighted builds the group of a business:backend_weights entry
+func NewWeighted(w config.BackendWeights) *Weighted {
+	g := &Weighted{
+		Backend:    w.Backend,
+		Targets:    make([]Target, 0, len(w.Targets)),
+		Hash:       w.Strategy == config.SelectHash,
+		HashHeader: w.HashHeader,
+	}
+	for addr, weight := range w.Targets {
+		g.Targets = append(g.Targets, Target{Addr: addr, Weight: weight})
+	}
+	sort.Slice(g.Targets, func(i, j int) bool { return g.Targets[i].Addr < g.Targets[j].Addr })
+	return g
+}
+
+// Select picks the target for a client key with the group's strategy
+func (g *Weighted) Select(key string, available func(addr string) bool) int {
+	if g.Hash {
+		return g.PickKey(key, available)
+	}
+	return g.Pick(available)
+}
+
+// Pick returns the index of a target selected at random, or -1 when no
+// available target has a weight. available may be nil (every target is
+// available).
+func (g *Weighted) Pick(available func(addr string) bool) int {
+	// Availability is checked once, so both passes see the same set
+	weights := make([]int, len(g.Targets))
+	total := 0
+	for i, t := range g.Targets {
+		if t.Weight > 0 && (available == nil || available(t.Addr)) {
+			weights[i] = t.Weight
+			total += t.Weight
+		}
+	}
+	if total == 0 {
+		return -1
+	}
+	n := rand.Intn(total)
+	for i, weight := range weights {
+		if n < weight {
+			middleware.RecordBackendSelection(g.Backend, g.Targets[i].Addr)
+			return i
+		}
+		n -= weight
+	}
+	return -1
+}
@@ -0,0 +1,189 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// State of a circuit breaker (values are exported as the Prometheus gauge)
+type State int
+
+const (
+	StateClosed   State = 0 // Requests flow, failures are counted
+	StateOpen     State = 1 // Requests are rejected until the cooldown elapses
+	StateHalfOpen State = 2 // A limited number of probe requests are let through
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Defaults used when backends.circuit_breaker.* is partially configured
+const (
+	defaultFailureRate      = 0.5
+	defaultMinRequests      = 20
+	defaultWindow           = 10 * time.Second
+	defaultCooldown         = 30 * time.Second
+	defaultHalfOpenRequests = 1
+)
+
+// Group holds one breaker per upstream. A nil *Group allows everything.
+type Group struct {
+	cfg config.CircuitBreakerConfig
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// breaker tracks outcomes in fixed windows while closed
+type breaker struct {
+	state       State
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int // In-flight half-open probes
+}
+
+// NewGroup creates a breaker group. Returns nil if circuit breaking is disabled.
+func NewGroup(cfg config.CircuitBreakerConfig) *Group {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.FailureRate <= 0 || cfg.FailureRate > 1 {
+		cfg.FailureRate = defaultFailureRate
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultMinRequests
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWindow
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = defaultHalfOpenRequests
+	}
+	xlog.Infof("Circuit breaker enabled: failure_rate=%.2f, min_requests=%d, window=%v, cooldown=%v",
+		cfg.FailureRate, cfg.MinRequests, cfg.Window, cfg.Cooldown)
+	return &Group{cfg: cfg, breakers: make(map[string]*breaker)}
+}
+
+// Allow reports whether a request to upstream may proceed. Every allowed
+// request must be followed by exactly one Record call.
+func (g *Group) Allow(upstream string) bool {
+	if g == nil {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b := g.get(upstream)
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < g.cfg.Cooldown {
+			return false
+		}
+		g.transition(upstream, b, StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.probes >= g.cfg.HalfOpenRequests {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// Record reports the outcome of a request previously allowed by Allow
+func (g *Group) Record(upstream string, success bool) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b := g.get(upstream)
+	switch b.state {
+	case StateHalfOpen:
+		b.probes--
+		if success {
+			g.transition(upstream, b, StateClosed)
+		} else {
+			g.transition(upstream, b, StateOpen)
+		}
+	case StateClosed:
+		now := time.Now()
+		if now.Sub(b.windowStart) >= g.cfg.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if !success {
+			b.failures++
+		}
+		if b.requests >= g.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= g.cfg.FailureRate {
+			g.transition(upstream, b, StateOpen)
+		}
+	default:
+		// Open: outcome of a request allowed before the breaker tripped, ignore
+	}
+}
+
+// State returns the current state of upstream's breaker
+func (g *Group) State(upstream string) State {
+	if g == nil {
+		return StateClosed
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, ok := g.breakers[upstream]; ok {
+		return b.state
+	}
+	return StateClosed
+}
+
+// get returns upstream's breaker, creating it closed. Caller must hold g.mu.
+func (g *Group) get(upstream string) *breaker {
+	b, ok := g.breakers[upstream]
+	if !ok {
+		b = &breaker{state: StateClosed, windowStart: time.Now()}
+		g.breakers[upstream] = b
+		middleware.SetCircuitBreakerState(upstream, int(StateClosed))
+	}
+	return b
+}
+
+// transition changes state and resets counters. Caller must hold g.mu.
+func (g *Group) transition(upstream string, b *breaker, to State) {
+	from := b.state
+	b.state = to
+	b.windowStart, b.requests, b.failures = time.Now(), 0, 0
+	if to == StateOpen {
+		b.openedAt = time.Now()
+	}
+	if to != StateHalfOpen {
+		b.probes = 0
+	}
+	middleware.SetCircuitBreakerState(upstream, int(to))
+
+	switch to {
+	case StateOpen:
+		xlog.Warnf("Circuit breaker for %s: %s -> open (cooldown %v)", upstream, from, g.cfg.Cooldown)
+	default:
+		xlog.Infof("Circuit breaker for %s: %s -> %s", upstream, from, to)
+	}
+}
@@ -1,10 +1,17 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/net/http/httpguts"
 )
 
 // Config holds all gateway configuration
@@ -20,6 +27,11 @@ type Config struct {
 	// Infrastructure Configuration
 	Metrics  MetricsConfig  `yaml:"metrics"`  // Prometheus metrics server
 	Security SecurityConfig `yaml:"security"` // Redis, Auth, WAF (affects readiness)
+	Admin    AdminConfig    `yaml:"admin"`    // Admin API authentication
+	Tracing  TracingConfig  `yaml:"tracing"`  // OpenTelemetry exporter and sampling
+	EBPF     EBPFConfig     `yaml:"ebpf"`     // Sockmap acceleration requirements (affects readiness)
+
+	AccessLog AccessLogConfig `yaml:"access_log"` // Access log shipping to Kafka
 }
 
 // ServerConfig - Business Configuration
@@ -28,6 +40,107 @@ type ServerConfig struct {
 	ListenAddr string `yaml:"listen_addr" env:"GATEWAY_LISTEN_ADDR"` // Business: Listening port
 	// Maximum concurrent connections
 	MaxConnections int `yaml:"max_connections" env:"GATEWAY_MAX_CONNECTIONS"` // Business: Max online connections
+	// Maximum concurrent connection handler goroutines (0 = unlimited). When
+	// saturated, accept pauses so new connections wait in the kernel backlog.
+	MaxHandlers int `yaml:"max_handlers"`
+	// SO_REUSEPORT sockets (each with its own accept loop) per listen address.
+	// 0 = GOMAXPROCS, 1 = a single socket; always 1 on non-Linux platforms.
+	AcceptShards int `yaml:"accept_shards"`
+	// Expect a PROXY protocol v1/v2 header on every connection.
+	// Only enable when all traffic arrives through a trusted L4 LB (NLB, HAProxy).
+	ProxyProtocol bool `yaml:"proxy_protocol" env:"GATEWAY_PROXY_PROTOCOL"`
+	// Fixed protocol for server.listen_addr: http, h2c, tcp or tls ("" = sniff each connection)
+	Protocol string `yaml:"protocol"`
+	// How long to wait for a client's first bytes before classifying the connection.
+	// Clients that send nothing in this window are treated as TCP. 0 = 500ms.
+	SniffTimeout time.Duration `yaml:"sniff_timeout"`
+	// Read buffer of client connections and socket buffers (read at startup)
+	Buffers BufferConfig `yaml:"buffers"`
+	// TCP keep-alive of client and TCP backend connections (read at startup)
+	KeepAlive KeepAliveConfig `yaml:"keepalive"`
+	// TLS termination for incoming TLS connections (plaintext is still accepted)
+	TLS TLSConfig `yaml:"tls"`
+	// Client-facing HTTP server timeouts (upstream timeouts are in backends.http)
+	HTTP HTTPServerTimeouts `yaml:"http"`
+	// Sampling and redaction of HTTP audit entries and access logs
+	AccessLog HTTPLogConfig `yaml:"access_log"`
+	// Additional listen addresses (server.listeners.<name>.*), served alongside ListenAddr
+	Listeners []ListenerSpec `yaml:"listeners"`
+}
+
+// BufferConfig - Business Configuration
+// Buffer sizes in bytes for high-throughput binary protocols; 0 keeps the default
+type BufferConfig struct {
+	ReadSize   int `yaml:"read_size"`   // Business: bufio reader of each client connection (default 4096, at least 16)
+	SocketRecv int `yaml:"socket_recv"` // Business: SO_RCVBUF of listen/accepted and TCP backend sockets (Linux only)
+	SocketSend int `yaml:"socket_send"` // Business: SO_SNDBUF of listen/accepted and TCP backend sockets (Linux only)
+}
+
+// KeepAliveConfig - Business Configuration
+// Detects dead peers (e.g. behind a NAT or firewall that dropped the flow)
+// instead of holding half-open connections until TCP gives up
+type KeepAliveConfig struct {
+	Idle     time.Duration `yaml:"idle"`     // Business: Idle time before the first probe (default 30s, negative disables keep-alive)
+	Interval time.Duration `yaml:"interval"` // Business: Time between unanswered probes (default 10s, Linux only)
+}
+
+// HTTPLogConfig - Business Configuration
+// Applies to HTTP audit entries and access logs; reloaded on business updates
+type HTTPLogConfig struct {
+	SampleRate int      `yaml:"sample_rate"` // Business: Log 1 in N allowed requests (<=1 = all); denies and errors are always logged
+	Redact     []string `yaml:"redact"`      // Business: Query parameters and headers masked in entries (empty = built-in list)
+	Headers    []string `yaml:"headers"`     // Business: Request headers recorded in entries (default none)
+}
+
+// ListenerSpec - Business Configuration
+// One listen address with its own accept loop. Options not set for a named
+// listener inherit the server-wide setting.
+type ListenerSpec struct {
+	Name          string `yaml:"name"`
+	Addr          string `yaml:"addr"`           // Business: Listen address, e.g. :8443
+	ProxyProtocol bool   `yaml:"proxy_protocol"` // Business: Expect PROXY v1/v2 headers (default server.proxy_protocol)
+	TLS           bool   `yaml:"tls"`            // Business: Terminate TLS with the server.tls certificate (default server.tls.enabled)
+	Protocol      string `yaml:"protocol"`       // Business: http, h2c, tcp or tls to skip sniffing ("" = sniff, not inherited)
+}
+
+// DefaultListenerName names the listener built from server.listen_addr
+const DefaultListenerName = "default"
+
+// ListenSpecs returns every listen address: server.listen_addr (if set)
+// followed by the named listeners
+func (s ServerConfig) ListenSpecs() []ListenerSpec {
+	specs := make([]ListenerSpec, 0, len(s.Listeners)+1)
+	if s.ListenAddr != "" {
+		specs = append(specs, ListenerSpec{
+			Name:          DefaultListenerName,
+			Addr:          s.ListenAddr,
+			ProxyProtocol: s.ProxyProtocol,
+			TLS:           s.TLS.Enabled,
+			Protocol:      s.Protocol,
+		})
+	}
+	return append(specs, s.Listeners...)
+}
+
+// HTTPServerTimeouts - Business Configuration
+// Timeouts on connections from HTTP clients, applied to new connections on reload
+type HTTPServerTimeouts struct {
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"` // Business: Time to read request headers (0 = read_timeout)
+	ReadTimeout       time.Duration `yaml:"read_timeout"`        // Business: Time to read a whole request (0 = 30s)
+	WriteTimeout      time.Duration `yaml:"write_timeout"`       // Business: Time to write a response (0 = 30s)
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`        // Business: Keep-alive idle time between requests (0 = read_timeout)
+}
+
+// TLSConfig - Business Configuration
+// Certificate files are re-read when they change on disk
+type TLSConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	CertFile     string   `yaml:"cert_file"`      // Business: PEM certificate chain
+	KeyFile      string   `yaml:"key_file"`       // Business: PEM private key
+	ClientCAFile string   `yaml:"client_ca_file"` // Business: PEM CA bundle for client certificates (enables mTLS)
+	ClientAuth   string   `yaml:"client_auth"`    // Business: none, request or require (default require with a CA, else none)
+	MinVersion   string   `yaml:"min_version"`    // Business: 1.0, 1.1, 1.2 or 1.3 (default 1.2)
+	CipherSuites []string `yaml:"cipher_suites"`  // Business: crypto/tls suite names, TLS 1.2 and below (empty = Go defaults)
 }
 
 // MetricsConfig - Infrastructure Configuration
@@ -36,27 +149,448 @@ type ServerConfig struct {
 type MetricsConfig struct {
 	Enabled    bool   `yaml:"enabled" env:"METRICS_ENABLED"`           // Infrastructure: Enable metrics
 	ListenAddr string `yaml:"listen_addr" env:"METRICS_LISTEN_ADDR"`    // Infrastructure: Metrics port
+	// Bearer token required on /metrics (empty = open). /health and /ready
+	// stay open for K8s probes; /admin/* has its own auth.
+	Token string `yaml:"token" env:"METRICS_TOKEN"`
+	// Serve /metrics on this address instead of ListenAddr, which then only
+	// serves the probes and /admin/* (empty = same address)
+	ScrapeListenAddr string `yaml:"scrape_listen_addr" env:"METRICS_SCRAPE_LISTEN_ADDR"`
+	// Latency histogram bucket upper bounds in seconds, strictly increasing
+	// (empty = the defaults, 1ms to 10s). Applied at startup only.
+	RequestDurationBuckets  []float64 `yaml:"request_duration_buckets" env:"METRICS_REQUEST_DURATION_BUCKETS"`
+	UpstreamDurationBuckets []float64 `yaml:"upstream_duration_buckets" env:"METRICS_UPSTREAM_DURATION_BUCKETS"`
+}
+
+// TracingConfig - Infrastructure Configuration
+// Distributed tracing exporter and sampling. Tracing is disabled if Endpoint is empty.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER"`         // otlp-grpc, otlp-http or jaeger (legacy)
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`         // Collector endpoint (host:port for OTLP, URL for Jaeger)
+	Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE"`         // Disable TLS to the OTLP collector
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"` // Fraction of new traces sampled (0.0-1.0)
+	ParentBased bool    `yaml:"parent_based" env:"TRACING_PARENT_BASED"` // Follow the caller's sampling decision if present
+}
+
+// AccessLogConfig - Infrastructure Configuration
+// Access logs are produced to Kafka as JSON. Disabled if KafkaBrokers is empty.
+type AccessLogConfig struct {
+	KafkaBrokers     []string `yaml:"kafka_brokers" env:"ACCESS_LOG_KAFKA_BROKERS"`         // Bootstrap brokers (host:port)
+	KafkaTopic       string   `yaml:"kafka_topic" env:"ACCESS_LOG_KAFKA_TOPIC"`             // Destination topic
+	KafkaCompression string   `yaml:"kafka_compression" env:"ACCESS_LOG_KAFKA_COMPRESSION"` // none, gzip, snappy, lz4 or zstd
+	BufferSize       int      `yaml:"buffer_size" env:"ACCESS_LOG_BUFFER_SIZE"`             // Entries queued before logs are dropped
+}
+
+// AdminConfig - Infrastructure Configuration
+// Authentication for /admin/* endpoints. They are served on the metrics server
+// unless CertFile, KeyFile and ClientCAFile are set: then they are served only
+// on an mTLS listener at ListenAddr that requires a client certificate signed
+// by ClientCAFile, and AllowedSubjects are accepted there.
+// If neither a token nor subjects are configured, admin endpoints reject all requests
+type AdminConfig struct {
+	Token           string   `yaml:"token" env:"ADMIN_TOKEN"`                       // Static bearer token (can be rotated via Redis)
+	AllowedSubjects []string `yaml:"allowed_subjects" env:"ADMIN_ALLOWED_SUBJECTS"` // mTLS client certificate subjects (needs the TLS files)
+	ListenAddr      string   `yaml:"listen_addr" env:"ADMIN_LISTEN_ADDR"`           // mTLS listen address
+	CertFile        string   `yaml:"cert_file" env:"ADMIN_TLS_CERT_FILE"`           // PEM certificate chain
+	KeyFile         string   `yaml:"key_file" env:"ADMIN_TLS_KEY_FILE"`             // PEM private key
+	ClientCAFile    string   `yaml:"client_ca_file" env:"ADMIN_TLS_CLIENT_CA_FILE"` // PEM CA bundle for admin client certificates
+}
+
+// MTLSEnabled reports whether /admin/* is served on its own mTLS listener
+func (a AdminConfig) MTLSEnabled() bool {
+	return a.CertFile != "" && a.KeyFile != "" && a.ClientCAFile != ""
+}
+
+// EBPFConfig - Infrastructure Configuration
+// Whether the TCP proxy may run without eBPF sockmap acceleration
+type EBPFConfig struct {
+	// Report not ready until the sockops program is attached to a cgroup v2
+	// (default false: the userspace proxy is an accepted fallback)
+	Required bool `yaml:"require_ebpf" env:"EBPF_REQUIRED"`
 }
 
 // BackendsConfig - Business Configuration
-// Forwarding rules for HTTP and TCP traffic
+// Forwarding rules for HTTP, TCP and UDP traffic
 type BackendsConfig struct {
 	HTTP HTTPBackend `yaml:"http"` // Business: HTTP forwarding rules
 	TCP  TCPBackend  `yaml:"tcp"`  // Business: TCP forwarding rules
+	UDP  UDPBackend  `yaml:"udp"`  // Business: UDP forwarding rules (optional)
+
+	TLSPassthrough []SNIRoute `yaml:"tls_passthrough"` // Business: TLS forwarded undecrypted by SNI (optional)
+
+	HealthCheck    HealthCheckConfig    `yaml:"health_check"`    // Business: Active upstream probing
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"` // Business: Per-upstream circuit breaking
+
+	// Business: Weighted traffic splits of configured backends (business:backend_weights)
+	Weights []BackendWeights `yaml:"weights"`
+}
+
+// Backend selection strategies of a weighted backend
+const (
+	SelectWeighted = "weighted" // Random in proportion to the weights (default)
+	SelectHash     = "hash"     // Rendezvous hashing of the client IP or HashHeader
+)
+
+// BackendWeights - Business Configuration
+// Splits the traffic of one configured backend (backends.tcp.target_addr, a
+// TLS passthrough target, backends.http.target_url or an HTTP route target)
+// across Targets in proportion to their weights, e.g. 95/5 for a canary.
+// List the configured backend itself to keep part of the traffic on it.
+// With the hash strategy each client sticks to one target while the set of
+// available targets is unchanged.
+type BackendWeights struct {
+	Backend    string         `yaml:"backend" json:"-"`                         // business:backend_weights field
+	Strategy   string         `yaml:"strategy" json:"strategy,omitempty"`       // weighted (default) or hash
+	HashHeader string         `yaml:"hash_header" json:"hash_header,omitempty"` // HTTP only: hash this header instead of the client IP
+	Targets    map[string]int `yaml:"targets" json:"targets"`                   // Target -> weight (0 = drained)
+}
+
+// IsHTTP reports whether the split applies to an HTTP backend (a URL)
+func (w BackendWeights) IsHTTP() bool {
+	return strings.Contains(w.Backend, "://")
+}
+
+// ParseBackendWeights decodes a business:backend_weights field: a JSON object
+// of target -> weight, or {"strategy": ..., "hash_header": ..., "targets":
+// {target -> weight}}. Targets of an HTTP backend must be URLs, those of a TCP
+// backend host:port.
+func ParseBackendWeights(backend, raw string) (BackendWeights, error) {
+	w := BackendWeights{Backend: backend}
+	if backend == "" {
+		return w, fmt.Errorf("backend weights: backend is empty")
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return w, fmt.Errorf("backend weights %s: %w", backend, err)
+	}
+	if _, ok := fields["targets"]; ok {
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&w); err != nil {
+			return w, fmt.Errorf("backend weights %s: %w", backend, err)
+		}
+		w.Backend = backend
+	} else if err := json.Unmarshal([]byte(raw), &w.Targets); err != nil {
+		return w, fmt.Errorf("backend weights %s: %w", backend, err)
+	}
+	switch w.Strategy {
+	case "":
+		w.Strategy = SelectWeighted
+	case SelectWeighted, SelectHash:
+	default:
+		return w, fmt.Errorf("backend weights %s: strategy %q must be weighted or hash", backend, w.Strategy)
+	}
+	if w.HashHeader != "" {
+		if w.Strategy != SelectHash || !w.IsHTTP() {
+			return w, fmt.Errorf("backend weights %s: hash_header needs the hash strategy on an HTTP backend", backend)
+		}
+		if !httpguts.ValidHeaderFieldName(w.HashHeader) {
+			return w, fmt.Errorf("backend weights %s: invalid hash_header %q", backend, w.HashHeader)
+		}
+	}
+	total := 0
+	for target, weight := range w.Targets {
+		if weight < 0 {
+			return w, fmt.Errorf("backend weights %s: negative weight for %s", backend, target)
+		}
+		if w.IsHTTP() {
+			if u, err := url.Parse(target); err != nil || u.Scheme == "" || u.Host == "" {
+				return w, fmt.Errorf("backend weights %s: target %q is not a URL", backend, target)
+			}
+		} else if _, _, err := net.SplitHostPort(target); err != nil {
+			return w, fmt.Errorf("backend weights %s: target %q is not host:port", backend, target)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return w, fmt.Errorf("backend weights %s: no target has a weight", backend)
+	}
+	return w, nil
+}
+
+// WithDiscoveredTargets returns weights plus an equal split of backend across
+// targets, the endpoints service discovery found for it. Weights configured
+// for backend take precedence; weights is returned as is when targets is empty.
+func WithDiscoveredTargets(weights []BackendWeights, backend string, targets []string) []BackendWeights {
+	if backend == "" || len(targets) == 0 {
+		return weights
+	}
+	for _, w := range weights {
+		if w.Backend == backend {
+			return weights
+		}
+	}
+	split := BackendWeights{Backend: backend, Strategy: SelectWeighted, Targets: make(map[string]int, len(targets))}
+	for _, target := range targets {
+		split.Targets[target] = 1
+	}
+	return append(append([]BackendWeights(nil), weights...), split)
+}
+
+// CircuitBreakerConfig - Business Configuration
+// Once FailureRate of at least MinRequests requests within Window fail, the
+// upstream's breaker opens and requests fail fast for Cooldown.
+type CircuitBreakerConfig struct {
+	Enabled          bool          `yaml:"enabled"`            // Business: Enable circuit breaking
+	FailureRate      float64       `yaml:"failure_rate"`       // Business: Failure ratio that opens the breaker (0-1)
+	MinRequests      int           `yaml:"min_requests"`       // Business: Requests per window before the rate is evaluated
+	Window           time.Duration `yaml:"window"`             // Business: Failure counting window
+	Cooldown         time.Duration `yaml:"cooldown"`           // Business: Time open before probing (half-open)
+	HalfOpenRequests int           `yaml:"half_open_requests"` // Business: Concurrent probe requests when half-open
+}
+
+// HealthCheckConfig - Business Configuration
+// Active health probing of the HTTP/TCP backends. Zero values use the checker's defaults.
+type HealthCheckConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // Business: Time between probes
+	Timeout   time.Duration `yaml:"timeout"`    // Business: Per-probe timeout
+	Path      string        `yaml:"path"`       // Business: Appended to the HTTP TargetURL (e.g. /healthz)
+	StatusMin int           `yaml:"status_min"` // Business: Lowest HTTP status considered healthy
+	StatusMax int           `yaml:"status_max"` // Business: Highest HTTP status considered healthy
+
+	// Flap suppression: state changes only after this many consecutive results
+	UnhealthyThreshold int `yaml:"unhealthy_threshold"` // Business: Failures before marking unhealthy
+	HealthyThreshold   int `yaml:"healthy_threshold"`   // Business: Successes before marking healthy again
+
+	// Passive checking: proxy errors within EjectionWindow eject the upstream for EjectionWindow
+	PassiveFailures int           `yaml:"passive_failures"` // Business: Proxy errors that trigger ejection
+	EjectionWindow  time.Duration `yaml:"ejection_window"`  // Business: Failure counting window and ejection duration
+
+	// Probe type per backend: "http" (HTTP only), "tcp" or "grpc" (grpc.health.v1)
+	HTTPType    string `yaml:"http_type"`    // Business: HTTP backend probe type, default "http"
+	TCPType     string `yaml:"tcp_type"`     // Business: TCP backend probe type, default "tcp"
+	GRPCService string `yaml:"grpc_service"` // Business: Service name sent in gRPC health checks ("" = whole server)
+
+	UserAgent string `yaml:"user_agent"` // Business: User-Agent of HTTP probes, default "uag-healthcheck/1.0"
 }
 
 // HTTPBackend - Business Configuration
 // HTTP backend service forwarding configuration
 type HTTPBackend struct {
-	TargetURL string        `yaml:"target_url" env:"HTTP_BACKEND_URL"`       // Business: Backend URL
-	Timeout   time.Duration `yaml:"timeout" env:"HTTP_BACKEND_TIMEOUT"`      // Business: Request timeout
+	TargetURL string        `yaml:"target_url" env:"HTTP_BACKEND_URL"`  // Business: Backend URL (default route)
+	Timeout   time.Duration `yaml:"timeout" env:"HTTP_BACKEND_TIMEOUT"` // Business: Default for the dial, TLS handshake and response header timeouts
+	Routes    []HTTPRoute   `yaml:"routes"`                             // Business: Path prefix routing table
+	Retry     RetryConfig   `yaml:"retry"`                              // Business: Upstream retry policy
+	// Business: Request/response header rules (business:header_rules), applied in name order
+	HeaderRules []HeaderRule `yaml:"header_rules"`
+	// Business: Method allow/deny rules by path prefix (business:method_rules)
+	MethodRules []MethodRule `yaml:"method_rules"`
+
+	MaxRequestBytes  int64 `yaml:"max_request_bytes"`  // Business: Request body limit, 413 above it (0 = unlimited)
+	MaxResponseBytes int64 `yaml:"max_response_bytes"` // Business: Response body limit, aborted above it (0 = unlimited)
+
+	Compression CompressionConfig `yaml:"compression"` // Business: gzip/deflate of uncompressed responses
+
+	// Upstream transport; zero durations fall back to Timeout, then to the defaults
+	DialTimeout           time.Duration `yaml:"dial_timeout"`            // Business: TCP connect timeout (default 30s)
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`   // Business: TLS handshake timeout for https upstreams (default 10s)
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"` // Business: Wait for response headers after sending the request (default none)
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout"`       // Business: Close pooled upstream connections idle this long (default 90s)
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host"` // Business: Pooled idle connections per upstream (default 32)
+}
+
+// CompressionConfig - Business Configuration
+// Compresses responses the backend sent uncompressed, for clients that accept
+// gzip or deflate, when the content type is compressible
+type CompressionConfig struct {
+	Enabled  bool  `yaml:"enabled"`   // Business: Off by default
+	MinBytes int64 `yaml:"min_bytes"` // Business: Smaller responses are sent as is (0 = default 1024)
+}
+
+// RetryConfig - Business Configuration
+// Retries of failed upstream requests. Only requests without a body are ever
+// replayed, so non-idempotent methods should not be listed in Methods.
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // Business: Total attempts including the first (<=1 disables retries)
+	Backoff     time.Duration `yaml:"backoff"`      // Business: Base delay, doubled after each attempt
+	Statuses    []int         `yaml:"statuses"`     // Business: Upstream status codes that trigger a retry
+	Methods     []string      `yaml:"methods"`      // Business: Methods eligible for retry
+}
+
+// HTTPRoute - Business Configuration
+// Requests whose path matches Prefix are proxied to TargetURL (longest prefix wins)
+type HTTPRoute struct {
+	Prefix    string `yaml:"prefix" json:"prefix"`
+	TargetURL string `yaml:"target_url" json:"target_url"`
+}
+
+// Header rule directions and actions
+const (
+	HeaderRuleRequest  = "request"  // Toward the backend
+	HeaderRuleResponse = "response" // Toward the client
+
+	HeaderActionSet    = "set"    // Replace every value with Value
+	HeaderActionAdd    = "add"    // Append Value to the existing values
+	HeaderActionRemove = "remove" // Delete every value
+)
+
+// HeaderRule - Business Configuration
+// Sets, adds or removes one header on proxied requests or responses, for
+// requests whose path matches Prefix (segment-wise; empty = every path)
+type HeaderRule struct {
+	Name      string `yaml:"name" json:"-"`                  // business:header_rules field
+	Direction string `yaml:"direction" json:"direction"`     // request or response
+	Action    string `yaml:"action" json:"action"`           // set, add or remove
+	Header    string `yaml:"header" json:"header"`           // Header name (canonicalized)
+	Value     string `yaml:"value" json:"value,omitempty"`   // set and add only
+	Prefix    string `yaml:"prefix" json:"prefix,omitempty"` // Client request path prefix
+}
+
+// protectedHeaders are framed or routed by the proxy itself and cannot be rewritten
+var protectedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Trailer":           true,
+	"Te":                true,
+	"Host":              true,
+}
+
+// ParseHeaderRule decodes a business:header_rules field (JSON) named name
+func ParseHeaderRule(name, raw string) (HeaderRule, error) {
+	var rule HeaderRule
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rule); err != nil {
+		return rule, fmt.Errorf("header rule %s: %w", name, err)
+	}
+	rule.Name = name
+	if name == "" {
+		return rule, fmt.Errorf("header rule name is empty")
+	}
+	if rule.Direction != HeaderRuleRequest && rule.Direction != HeaderRuleResponse {
+		return rule, fmt.Errorf("header rule %s: direction %q must be request or response", name, rule.Direction)
+	}
+	switch rule.Action {
+	case HeaderActionSet, HeaderActionAdd:
+		if !httpguts.ValidHeaderFieldValue(rule.Value) {
+			return rule, fmt.Errorf("header rule %s: invalid value %q", name, rule.Value)
+		}
+	case HeaderActionRemove:
+		if rule.Value != "" {
+			return rule, fmt.Errorf("header rule %s: remove takes no value", name)
+		}
+	default:
+		return rule, fmt.Errorf("header rule %s: action %q must be set, add or remove", name, rule.Action)
+	}
+	if !httpguts.ValidHeaderFieldName(rule.Header) {
+		return rule, fmt.Errorf("header rule %s: invalid header name %q", name, rule.Header)
+	}
+	rule.Header = textproto.CanonicalMIMEHeaderKey(rule.Header)
+	if protectedHeaders[rule.Header] {
+		return rule, fmt.Errorf("header rule %s: %s is managed by the proxy", name, rule.Header)
+	}
+	if rule.Prefix != "" && !strings.HasPrefix(rule.Prefix, "/") {
+		return rule, fmt.Errorf("header rule %s: prefix %q must start with /", name, rule.Prefix)
+	}
+	return rule, nil
+}
+
+// MethodRule - Business Configuration
+// Restricts the methods of requests whose path matches Prefix (segment-wise;
+// "/" matches every path). Every matching rule must allow the method: it must
+// not be in Deny and, when Allow is set, must be in Allow.
+type MethodRule struct {
+	Prefix string   `yaml:"prefix" json:"-"`              // business:method_rules field
+	Allow  []string `yaml:"allow" json:"allow,omitempty"` // Only these methods (empty = any)
+	Deny   []string `yaml:"deny" json:"deny,omitempty"`   // Never these methods
+}
+
+// Allows reports whether the rule permits method. Deny ignores case, so a
+// lower-case "trace" cannot slip past a TRACE deny; Allow is exact.
+func (r MethodRule) Allows(method string) bool {
+	for _, m := range r.Deny {
+		if strings.EqualFold(m, method) {
+			return false
+		}
+	}
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, m := range r.Allow {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMethodRule decodes a business:method_rules field (JSON) for prefix.
+// Methods are case-sensitive tokens and are upper-cased, as clients send them.
+func ParseMethodRule(prefix, raw string) (MethodRule, error) {
+	var rule MethodRule
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rule); err != nil {
+		return rule, fmt.Errorf("method rule %s: %w", prefix, err)
+	}
+	rule.Prefix = prefix
+	if !strings.HasPrefix(prefix, "/") {
+		return rule, fmt.Errorf("method rule %q: prefix must start with /", prefix)
+	}
+	if len(rule.Allow) == 0 && len(rule.Deny) == 0 {
+		return rule, fmt.Errorf("method rule %s: allow or deny is required", prefix)
+	}
+	for _, methods := range [][]string{rule.Allow, rule.Deny} {
+		for i, m := range methods {
+			if !httpguts.ValidHeaderFieldName(m) {
+				return rule, fmt.Errorf("method rule %s: invalid method %q", prefix, m)
+			}
+			methods[i] = strings.ToUpper(m)
+		}
+	}
+	return rule, nil
+}
+
+// SNIRoute - Business Configuration
+// TLS connections whose SNI matches ServerName are forwarded to TargetAddr
+// without termination. ServerName may be a wildcard such as *.example.com.
+type SNIRoute struct {
+	ServerName string `yaml:"server_name" json:"server_name"`
+	TargetAddr string `yaml:"target_addr" json:"target_addr"`
 }
 
 // TCPBackend - Business Configuration
 // TCP backend service forwarding configuration
 type TCPBackend struct {
-	TargetAddr string        `yaml:"target_addr" env:"TCP_BACKEND_ADDR"`    // Business: Backend address
-	Timeout    time.Duration `yaml:"timeout" env:"TCP_BACKEND_TIMEOUT"`       // Business: Connection timeout
+	TargetAddr string        `yaml:"target_addr" env:"TCP_BACKEND_ADDR"` // Business: Backend address
+	Timeout    time.Duration `yaml:"timeout" env:"TCP_BACKEND_TIMEOUT"`  // Business: Connection timeout
+	Pool       TCPPoolConfig `yaml:"pool"`                               // Business: Warm backend connection pool
+	// Business: Close proxied connections with no bytes in either direction for this long (0 disables)
+	IdleTimeout time.Duration    `yaml:"idle_timeout"`
+	TLS         BackendTLSConfig `yaml:"tls"` // Business: TLS to the backend for plaintext clients
+	// Business: Backend addresses (target_addr or TLS passthrough targets) sent a PROXY v2 header
+	SendProxyProtocol []string `yaml:"send_proxy_protocol"`
+}
+
+// BackendTLSConfig - Business Configuration
+// TLS originated by the gateway toward a backend. The backend certificate is
+// verified against CAFile (system roots if empty) for ServerName (the host of
+// the backend address if empty). Read at startup.
+type BackendTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	ServerName         string `yaml:"server_name"`          // Business: SNI and verified name
+	CAFile             string `yaml:"ca_file"`              // Business: PEM CA bundle for the backend certificate
+	CertFile           string `yaml:"cert_file"`            // Business: PEM client certificate chain (optional)
+	KeyFile            string `yaml:"key_file"`             // Business: PEM client private key (with cert_file)
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // Business: Skip backend verification (testing only)
+}
+
+// TCPPoolConfig - Business Configuration
+// Pre-dialed backend connections (only never-used connections are reused)
+type TCPPoolConfig struct {
+	MaxIdle     int           `yaml:"max_idle"`     // Business: Idle connections kept ready (0 disables pooling)
+	MaxLifetime time.Duration `yaml:"max_lifetime"` // Business: Maximum age of a pooled connection
+	IdleTimeout time.Duration `yaml:"idle_timeout"` // Business: Maximum time a connection sits idle in the pool
+}
+
+// UDPBackend - Business Configuration
+// UDP datagram relay configuration. UDP cannot be sniffed on the shared TCP
+// listener, so it has its own listen address.
+type UDPBackend struct {
+	ListenAddr string        `yaml:"listen_addr" env:"UDP_LISTEN_ADDR"`  // Business: UDP listening port
+	TargetAddr string        `yaml:"target_addr" env:"UDP_BACKEND_ADDR"` // Business: Backend address
+	Timeout    time.Duration `yaml:"timeout" env:"UDP_BACKEND_TIMEOUT"`  // Business: Session idle timeout
 }
 
 // LifecycleConfig - Business Configuration
@@ -86,34 +620,112 @@ type SecurityConfig struct {
 // - /health returns 200 OK (gateway is still alive)
 // - K8s removes pod from service endpoints (no traffic routed)
 type RedisConfig struct {
-	Enabled   bool   `yaml:"enabled" env:"REDIS_ENABLED"`         // Infrastructure: Enable Redis
-	Addr      string `yaml:"addr" env:"REDIS_ADDR"`               // Infrastructure: Redis address
-	Password  string `yaml:"password" env:"REDIS_PASSWORD"`       // Infrastructure: Redis password
-	DB        int    `yaml:"db" env:"REDIS_DB"`                    // Infrastructure: Redis database
-	KeyPrefix string `yaml:"key_prefix" env:"REDIS_KEY_PREFIX"`   // Infrastructure: Redis key prefix
+	Enabled   bool   `yaml:"enabled" env:"REDIS_ENABLED"`       // Infrastructure: Enable Redis
+	Mode      string `yaml:"mode" env:"REDIS_MODE"`             // Infrastructure: single, sentinel or cluster
+	Addr      string `yaml:"addr" env:"REDIS_ADDR"`             // Infrastructure: Redis address (single mode)
+	Password  string `yaml:"password" env:"REDIS_PASSWORD"`     // Infrastructure: Redis password
+	DB        int    `yaml:"db" env:"REDIS_DB"`                 // Infrastructure: Redis database (not in cluster mode)
+	KeyPrefix string `yaml:"key_prefix" env:"REDIS_KEY_PREFIX"` // Infrastructure: Redis key prefix
+
+	MasterName       string   `yaml:"master_name" env:"REDIS_MASTER_NAME"`             // Sentinel: monitored master name
+	SentinelAddrs    []string `yaml:"sentinel_addrs" env:"REDIS_SENTINEL_ADDRS"`       // Sentinel: sentinel host:port list
+	SentinelPassword string   `yaml:"sentinel_password" env:"REDIS_SENTINEL_PASSWORD"` // Sentinel: password of the sentinels themselves
+	ClusterAddrs     []string `yaml:"cluster_addrs" env:"REDIS_CLUSTER_ADDRS"`         // Cluster: seed node host:port list
 }
 
 type AuthConfig struct {
-	Enabled         bool     `yaml:"enabled"`
-	HeaderSubject   string   `yaml:"header_subject"`
-	AllowedSubjects []string `yaml:"allowed_subjects"`
+	Enabled         bool      `yaml:"enabled"`
+	Mode            string    `yaml:"mode"` // "" (certificate, JWT or header subject) or "apikey"
+	HeaderSubject   string    `yaml:"header_subject"`
+	AllowedSubjects []string  `yaml:"allowed_subjects"`
+	JWT             JWTConfig `yaml:"jwt"`
+	// Accept HeaderSubject even when JWT is enabled. Only safe behind a trusted
+	// proxy that sets the header itself and drops any client-supplied copy.
+	TrustHeaderSubject bool `yaml:"trust_header_subject"`
+	// API keys (apikey mode). Only SHA-256 digests are stored: hex digest -> client name
+	APIKeyHeader string            `yaml:"api_key_header"`
+	APIKeys      map[string]string `yaml:"api_keys"`
+}
+
+// AuthModeAPIKey identifies clients by a static API key header
+const AuthModeAPIKey = "apikey"
+
+// JWTConfig - Security Configuration
+// Bearer token validation against the IdP's published JWKS
+type JWTConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Issuer          string        `yaml:"issuer"`           // Expected "iss" claim (empty = not checked)
+	Audience        string        `yaml:"audience"`         // Expected "aud" claim (empty = not checked)
+	JWKSURL         string        `yaml:"jwks_url"`         // IdP JWKS endpoint
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // JWKS cache refresh interval
 }
 
 type RateLimitConfig struct {
-	Enabled           bool    `yaml:"enabled"`
-	RequestsPerSecond float64 `yaml:"requests_per_second"`
-	Burst             int     `yaml:"burst"`
+	Enabled           bool              `yaml:"enabled"`
+	RequestsPerSecond float64           `yaml:"requests_per_second"`
+	Burst             int               `yaml:"burst"`
+	Policies          []RateLimitPolicy `yaml:"policies"` // Per-subject/per-route HTTP request limits
+}
+
+// RateLimitPolicy limits HTTP requests of clients matching Subject on paths under
+// Route. Each client (authenticated subject) gets its own bucket per policy.
+type RateLimitPolicy struct {
+	Name    string  `yaml:"name" json:"-"`
+	Subject string  `yaml:"subject" json:"subject,omitempty"` // Exact, glob or regex: subject; empty matches any client
+	Route   string  `yaml:"route" json:"route,omitempty"`     // Path prefix; empty matches any path
+	RPS     float64 `yaml:"rps" json:"rps"`
+	Burst   int     `yaml:"burst" json:"burst"`
+}
+
+// ParseRateLimitPolicy decodes a rate_limit:policies field (JSON) named name
+func ParseRateLimitPolicy(name, raw string) (RateLimitPolicy, error) {
+	var p RateLimitPolicy
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, fmt.Errorf("rate limit policy %s: %w", name, err)
+	}
+	p.Name = name
+	if name == "" || name == "global" {
+		return p, fmt.Errorf("rate limit policy name %q is reserved", name)
+	}
+	if p.RPS <= 0 || p.Burst <= 0 {
+		return p, fmt.Errorf("rate limit policy %s: rps and burst must be positive", name)
+	}
+	if p.Route != "" && !strings.HasPrefix(p.Route, "/") {
+		return p, fmt.Errorf("rate limit policy %s: route %q must start with /", name, p.Route)
+	}
+	return p, nil
 }
 
 type AuditConfig struct {
 	Enabled bool   `yaml:"enabled" env:"AUDIT_ENABLED"`
 	Sink    string `yaml:"sink" env:"AUDIT_SINK"`
+	// Rotation of file:// sinks (stdout/stderr are never rotated)
+	MaxSizeMB  int           `yaml:"max_size_mb" env:"AUDIT_MAX_SIZE_MB"` // Rotate when the file exceeds this size (0 = no size limit)
+	MaxAge     time.Duration `yaml:"max_age" env:"AUDIT_MAX_AGE"`         // Rotate when the file is older than this (0 = no age limit)
+	MaxBackups int           `yaml:"max_backups" env:"AUDIT_MAX_BACKUPS"` // Rotated files kept (0 = keep all)
+	// Priority of syslog:// sinks
+	SyslogFacility string `yaml:"syslog_facility" env:"AUDIT_SYSLOG_FACILITY"` // e.g. local0, auth
+	SyslogSeverity string `yaml:"syslog_severity" env:"AUDIT_SYSLOG_SEVERITY"` // e.g. info, notice
 }
 
 type WAFConfig struct {
-	Enabled         bool     `yaml:"enabled"`
+	Enabled bool `yaml:"enabled"`
+	// "blocklist" (default): deny BlockedIPs. "allowlist": additionally deny
+	// every client not in AllowedIPs. BlockedIPs always deny in both modes.
+	Mode            string   `yaml:"mode"`
+	AllowedIPs      []string `yaml:"allowed_ips"`
 	BlockedIPs      []string `yaml:"blocked_ips"`
 	BlockedPatterns []string `yaml:"blocked_patterns"`
+	// Request headers whose values are matched against BlockedPatterns (off when empty)
+	InspectHeaders []string `yaml:"inspect_headers"`
+	// Request body inspection (off by default, buffers up to MaxBodyScanBytes)
+	InspectBody      bool  `yaml:"inspect_body"`
+	MaxBodyScanBytes int64 `yaml:"max_body_scan_bytes"`
+	// Country blocking (no-op without a database). Reloaded when the file changes.
+	GeoIPDatabase    string   `yaml:"geoip_db"`          // MaxMind mmdb path (GeoLite2/GeoIP2 Country or City)
+	BlockedCountries []string `yaml:"blocked_countries"` // ISO 3166-1 alpha-2 codes, e.g. "KP"
 }
 
 // DefaultSecurityState returns the built-in security configuration used before Redis hydrate.
@@ -123,6 +735,7 @@ func DefaultSecurityState() SecurityConfig {
 			Enabled:         false,
 			HeaderSubject:   "X-Client-Subject",
 			AllowedSubjects: nil,
+			APIKeyHeader:    "X-API-Key",
 		},
 		RateLimit: RateLimitConfig{
 			Enabled:           true,
@@ -130,13 +743,21 @@ func DefaultSecurityState() SecurityConfig {
 			Burst:             200,
 		},
 		Audit: AuditConfig{
-			Enabled: true,
-			Sink:    "stdout",
+			Enabled:    true,
+			Sink:       "stdout",
+			MaxSizeMB:  100,
+			MaxAge:     24 * time.Hour,
+			MaxBackups: 7,
+
+			SyslogFacility: "local0",
+			SyslogSeverity: "info",
 		},
 		WAF: WAFConfig{
-			Enabled:         false,
-			BlockedIPs:      nil,
-			BlockedPatterns: nil,
+			Enabled:          false,
+			BlockedIPs:       nil,
+			BlockedPatterns:  nil,
+			InspectBody:      false,
+			MaxBodyScanBytes: 64 * 1024,
 		},
 	}
 }
@@ -156,26 +777,80 @@ func LoadConfig() *Config {
 		Metrics: MetricsConfig{
 			Enabled:    getEnvBool("METRICS_ENABLED", true),
 			ListenAddr: getEnv("METRICS_LISTEN_ADDR", ":9090"),
+			Token:      getEnv("METRICS_TOKEN", ""),
+
+			ScrapeListenAddr: getEnv("METRICS_SCRAPE_LISTEN_ADDR", ""),
+
+			RequestDurationBuckets:  getEnvFloatSlice("METRICS_REQUEST_DURATION_BUCKETS"),
+			UpstreamDurationBuckets: getEnvFloatSlice("METRICS_UPSTREAM_DURATION_BUCKETS"),
+		},
+		Tracing: loadTracingConfig(),
+		AccessLog: AccessLogConfig{
+			KafkaBrokers:     getEnvSlice("ACCESS_LOG_KAFKA_BROKERS"),
+			KafkaTopic:       getEnv("ACCESS_LOG_KAFKA_TOPIC", "gateway-access-logs"),
+			KafkaCompression: getEnv("ACCESS_LOG_KAFKA_COMPRESSION", "snappy"),
+			BufferSize:       getEnvInt("ACCESS_LOG_BUFFER_SIZE", 10000),
+		},
+		EBPF: EBPFConfig{
+			Required: getEnvBool("EBPF_REQUIRED", false),
+		},
+		Admin: AdminConfig{
+			Token:           getEnv("ADMIN_TOKEN", ""),
+			AllowedSubjects: getEnvSlice("ADMIN_ALLOWED_SUBJECTS"),
+			ListenAddr:      getEnv("ADMIN_LISTEN_ADDR", ":9443"),
+			CertFile:        getEnv("ADMIN_TLS_CERT_FILE", ""),
+			KeyFile:         getEnv("ADMIN_TLS_KEY_FILE", ""),
+			ClientCAFile:    getEnv("ADMIN_TLS_CLIENT_CA_FILE", ""),
 		},
 		Security: SecurityConfig{
 			Auth:      defaultSecurity.Auth,
 			RateLimit: defaultSecurity.RateLimit,
 			Audit: AuditConfig{
-				Enabled: getEnvBool("AUDIT_ENABLED", defaultSecurity.Audit.Enabled),
-				Sink:    getEnv("AUDIT_SINK", defaultSecurity.Audit.Sink),
+				Enabled:    getEnvBool("AUDIT_ENABLED", defaultSecurity.Audit.Enabled),
+				Sink:       getEnv("AUDIT_SINK", defaultSecurity.Audit.Sink),
+				MaxSizeMB:  getEnvInt("AUDIT_MAX_SIZE_MB", defaultSecurity.Audit.MaxSizeMB),
+				MaxAge:     getEnvDuration("AUDIT_MAX_AGE", defaultSecurity.Audit.MaxAge),
+				MaxBackups: getEnvInt("AUDIT_MAX_BACKUPS", defaultSecurity.Audit.MaxBackups),
+
+				SyslogFacility: getEnv("AUDIT_SYSLOG_FACILITY", defaultSecurity.Audit.SyslogFacility),
+				SyslogSeverity: getEnv("AUDIT_SYSLOG_SEVERITY", defaultSecurity.Audit.SyslogSeverity),
 			},
 			WAF: defaultSecurity.WAF,
 			Redis: RedisConfig{
 				Enabled:   getEnvBool("REDIS_ENABLED", true),
+				Mode:      getEnv("REDIS_MODE", "single"),
 				Addr:      getEnv("REDIS_ADDR", "localhost:6379"),
 				Password:  getEnv("REDIS_PASSWORD", ""),
 				DB:        getEnvInt("REDIS_DB", 0),
 				KeyPrefix: getEnv("REDIS_KEY_PREFIX", "gateway:"),
+
+				MasterName:       getEnv("REDIS_MASTER_NAME", ""),
+				SentinelAddrs:    getEnvSlice("REDIS_SENTINEL_ADDRS"),
+				SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+				ClusterAddrs:     getEnvSlice("REDIS_CLUSTER_ADDRS"),
 			},
 		},
 	}
 }
 
+// loadTracingConfig reads tracing settings, keeping JAEGER_ENDPOINT working for existing deployments
+func loadTracingConfig() TracingConfig {
+	cfg := TracingConfig{
+		Exporter:    getEnv("TRACING_EXPORTER", "otlp-grpc"),
+		Endpoint:    getEnv("TRACING_ENDPOINT", ""),
+		Insecure:    getEnvBool("TRACING_INSECURE", true),
+		SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1.0),
+		ParentBased: getEnvBool("TRACING_PARENT_BASED", true),
+	}
+	if cfg.Endpoint == "" {
+		if jaegerEndpoint := os.Getenv("JAEGER_ENDPOINT"); jaegerEndpoint != "" {
+			cfg.Exporter = "jaeger"
+			cfg.Endpoint = jaegerEndpoint
+		}
+	}
+	return cfg
+}
+
 func getEnv(key, defaultValue string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -219,14 +894,43 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 
 func getEnvSlice(key string) []string {
 	if v := os.Getenv(key); v != "" {
-		parts := strings.Split(v, ",")
-		out := make([]string, 0, len(parts))
-		for _, part := range parts {
-			if trimmed := strings.TrimSpace(part); trimmed != "" {
-				out = append(out, trimmed)
-			}
+		return getEnvSliceValue(v)
+	}
+	return nil
+}
+
+// getEnvFloatSlice parses a comma-separated list of numbers (nil if unset or
+// an entry is not a number)
+func getEnvFloatSlice(key string) []float64 {
+	if v := os.Getenv(key); v != "" {
+		if out, err := parseFloatSlice(v); err == nil {
+			return out
 		}
-		return out
 	}
 	return nil
 }
+
+func parseFloatSlice(v string) ([]float64, error) {
+	parts := getEnvSliceValue(v)
+	out := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// getEnvSliceValue splits a comma-separated value, dropping empty entries
+func getEnvSliceValue(v string) []string {
+	parts := strings.Split(v, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
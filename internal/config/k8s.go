@@ -2,10 +2,13 @@ package config
 
 import (
 	"os"
+	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"gopkg.in/yaml.v3"
 )
 
 // K8sConfigWatcher watches for ConfigMap changes
@@ -68,11 +71,93 @@ func (w *K8sConfigWatcher) watch() {
 }
 
 // LoadConfigFromFile loads config from a YAML file
+// Precedence: environment variables > file values > built-in defaults.
+// Returns nil on read/parse error so the watcher can skip a bad reload.
 func LoadConfigFromFile(path string) *Config {
-	// For MVP, we still use env vars
-	// In production, use viper or similar to load YAML
-	// This is a placeholder for ConfigMap hot-reload
-	return LoadConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		xlog.Errorf("Failed to read config file %s: %v", path, err)
+		return nil
+	}
+
+	// Start from defaults so sections missing in the file keep sane values
+	cfg := LoadConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		xlog.Errorf("Failed to parse config file %s: %v", path, err)
+		return nil
+	}
+
+	// Environment variables override file values when both are present
+	applyEnvOverrides(reflect.ValueOf(cfg).Elem())
+	return cfg
+}
+
+// applyEnvOverrides walks the config struct and applies every field's `env` tag
+// whose environment variable is set
+func applyEnvOverrides(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			applyEnvOverrides(field)
+			continue
+		}
+
+		key := t.Field(i).Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(key)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setFieldFromString(field, raw); err != nil {
+			xlog.Warnf("Ignoring invalid value for %s=%q: %v", key, raw, err)
+		}
+	}
+}
+
+// setFieldFromString parses raw into field according to its type
+func setFieldFromString(field reflect.Value, raw string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	case []string:
+		field.Set(reflect.ValueOf(getEnvSliceValue(raw)))
+		return nil
+	case []float64:
+		values, err := parseFloatSlice(raw)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(values))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		field.SetBool(raw == "true" || raw == "1")
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64, reflect.Float32:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	}
+	return nil
 }
 
 // LoadConfigFromConfigMap loads config from K8s ConfigMap mount point
@@ -94,4 +179,3 @@ func LoadConfigFromConfigMap() *Config {
 	// Fallback to env vars
 	return LoadConfig()
 }
-
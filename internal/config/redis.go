@@ -5,86 +5,378 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// healthCheckTimeout bounds CheckHealth so a hung Redis cannot block the readiness probe
+	healthCheckTimeout = 2 * time.Second
+
+	// Resubscribe backoff after the config pub/sub connection is lost
+	resubscribeMinBackoff = 100 * time.Millisecond
+	resubscribeMaxBackoff = 30 * time.Second
+
+	// UpdateTypeReload is published locally after a pub/sub reconnect: updates
+	// may have been missed, so every consumer reloads
+	UpdateTypeReload = "reload"
+)
+
 var (
-	ErrRedisNotEnabled        = errors.New("redis store not enabled")
-	ErrBusinessConfigNotFound = errors.New("business config not found in redis")
-	ErrSecurityConfigNotFound = errors.New("security config not found in redis")
+	ErrRedisNotEnabled          = errors.New("redis store not enabled")
+	ErrSubscriptionDead         = errors.New("redis pub/sub subscription closed, config hot-reload unavailable")
+	ErrBusinessConfigNotFound   = errors.New("business config not found in redis")
+	ErrBusinessConfigIncomplete = errors.New("business config in redis is incomplete")
+	ErrSecurityConfigNotFound   = errors.New("security config not found in redis")
+	ErrInvalidEntries           = errors.New("invalid entries")
 )
 
 // RedisStore manages configuration loaded from Redis
-// IMPORTANT: Gateway is READ-ONLY. All configuration writes are done by external admin tools.
+// IMPORTANT: Configuration is written by external admin tools; the gateway itself only
+// writes through versioned writes requested by the admin API (snapshot import and
+// rollback, WAF list edits, API keys), see writeVersioned.
 type RedisStore struct {
-	client  *redis.Client
+	client  redis.UniversalClient
 	prefix  string
 	ctx     context.Context
-	pubsub  *redis.PubSub
 	updates chan ConfigUpdate
+
+	pubsubMu    sync.Mutex
+	pubsub      *redis.PubSub
+	stopChan    chan struct{} // Closed by Close, interrupts resubscribe backoff
+	onReconnect func()        // Called after each pub/sub reconnection (see OnReconnect)
+
+	subMu       sync.RWMutex
+	subscribers []chan ConfigUpdate // Additional consumers registered via Subscribe
+
+	dispatchMu sync.Mutex
+	missed     map[chan ConfigUpdate]bool // Consumers that dropped an update, sent a reload next
+
+	listening  int32 // Atomic: 1 while listenUpdates is consuming the pub/sub channel
+	closed     int32 // Atomic: 1 after Close (subscription end is expected)
+	reconnects int64 // Atomic: pub/sub reconnections since startup
+	version    int64 // Atomic: latest config version seen (see AppliedVersion)
 }
 
 // ConfigUpdate represents a configuration change notification from Redis pub/sub
 type ConfigUpdate struct {
-	Type string          `json:"type"` // "business", "security", "rate_limit", "waf", etc.
-	Data json.RawMessage `json:"data"`
+	Type    string          `json:"type"`              // "business", "security", "rate_limit", "waf", etc.
+	Version int64           `json:"version,omitempty"` // Config version after the change (versioned writes only)
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Incremental update types: Data is a SetChange for the matching set
+const (
+	UpdateTypeBlockedIPs      = "blocked_ips"
+	UpdateTypeAllowedIPs      = "allowed_ips"
+	UpdateTypeBlockedPatterns = "blocked_patterns"
+	UpdateTypeAllowedSubjects = "allowed_subjects"
+)
+
+// UpdateTypeAPIKeys reloads the API keys (revocations apply immediately)
+const UpdateTypeAPIKeys = "api_keys"
+
+// SetChange is the Data of an incremental update: members added to or removed from a set
+type SetChange struct {
+	Action string   `json:"action"` // "add", "remove" or "clear" (Items unused)
+	Items  []string `json:"items,omitempty"`
 }
 
-// NewRedisStore creates a new Redis configuration store (READ-ONLY)
+// Is reports whether the update concerns any of types. A reload concerns everything.
+func (u ConfigUpdate) Is(types ...string) bool {
+	if u.Type == UpdateTypeReload {
+		return true
+	}
+	for _, t := range types {
+		if u.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRedisStore creates a new Redis configuration store
 func NewRedisStore(cfg *RedisConfig) (*RedisStore, error) {
 	if !cfg.Enabled {
 		return nil, nil
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx := context.Background()
 	// Test connection
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis (%s): %w", redisMode(cfg), err)
 	}
 
 	store := &RedisStore{
-		client:  client,
-		prefix:  cfg.KeyPrefix,
-		ctx:     ctx,
-		updates: make(chan ConfigUpdate, 10),
+		client:   client,
+		prefix:   cfg.KeyPrefix,
+		ctx:      ctx,
+		updates:  make(chan ConfigUpdate, 10),
+		stopChan: make(chan struct{}),
+		missed:   make(map[chan ConfigUpdate]bool),
+	}
+
+	version, err := store.GetConfigVersion()
+	if err != nil {
+		xlog.Warnf("Failed to read config version: %v", err)
 	}
+	atomic.StoreInt64(&store.version, version)
 
 	// Subscribe to configuration changes (for hot-reload)
-	pubsub := client.Subscribe(ctx, store.prefix+"config:changed")
+	pubsub, err := store.subscribe()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to subscribe to config changes: %w", err)
+	}
 	store.pubsub = pubsub
 
 	// Start listening for updates in background
+	atomic.StoreInt32(&store.listening, 1)
 	go store.listenUpdates()
 
-	xlog.Infof("Redis config store initialized (READ-ONLY): addr=%s, prefix=%s", cfg.Addr, cfg.KeyPrefix)
+	xlog.Infof("Redis config store initialized: mode=%s, addrs=%v, prefix=%s, config_version=%d",
+		redisMode(cfg), redisAddrs(cfg), cfg.KeyPrefix, version)
 	return store, nil
 }
 
-// listenUpdates listens for Redis pub/sub messages for config hot-reload
+// newRedisClient builds the client for the configured topology. Sentinel
+// clients follow master failover; cluster clients route keys by hash slot.
+func newRedisClient(cfg *RedisConfig) (redis.UniversalClient, error) {
+	switch redisMode(cfg) {
+	case "single":
+		if cfg.Addr == "" {
+			return nil, errors.New("redis single mode requires REDIS_ADDR")
+		}
+		return redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+		}), nil
+	case "sentinel":
+		if cfg.MasterName == "" || len(cfg.SentinelAddrs) == 0 {
+			return nil, errors.New("redis sentinel mode requires REDIS_MASTER_NAME and REDIS_SENTINEL_ADDRS")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.SentinelAddrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+		}), nil
+	case "cluster":
+		if len(cfg.ClusterAddrs) == 0 {
+			return nil, errors.New("redis cluster mode requires REDIS_CLUSTER_ADDRS")
+		}
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("redis cluster mode only supports database 0, got REDIS_DB=%d", cfg.DB)
+		}
+		// Versioned writes WATCH and MULTI across keys, which must share a hash slot
+		if !strings.Contains(cfg.KeyPrefix, "{") || !strings.Contains(cfg.KeyPrefix, "}") {
+			xlog.Warnf("REDIS_KEY_PREFIX %q has no hash tag (e.g. \"{gateway}:\"); admin config writes will fail in cluster mode", cfg.KeyPrefix)
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    cfg.ClusterAddrs,
+			Password: cfg.Password,
+		}), nil
+	default:
+		return nil, fmt.Errorf("invalid REDIS_MODE %q (expected single, sentinel or cluster)", cfg.Mode)
+	}
+}
+
+func redisMode(cfg *RedisConfig) string {
+	if cfg.Mode == "" {
+		return "single"
+	}
+	return strings.ToLower(cfg.Mode)
+}
+
+func redisAddrs(cfg *RedisConfig) []string {
+	switch redisMode(cfg) {
+	case "sentinel":
+		return cfg.SentinelAddrs
+	case "cluster":
+		return cfg.ClusterAddrs
+	default:
+		return []string{cfg.Addr}
+	}
+}
+
+// subscribe subscribes to config:changed and waits for the confirmation
+func (r *RedisStore) subscribe() (*redis.PubSub, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, healthCheckTimeout)
+	defer cancel()
+	pubsub := r.client.Subscribe(r.ctx, r.prefix+"config:changed")
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// listenUpdates listens for Redis pub/sub messages for config hot-reload.
+// If the channel closes before Close, it resubscribes with exponential backoff.
 func (r *RedisStore) listenUpdates() {
-	ch := r.pubsub.Channel()
-	for msg := range ch {
-		var update ConfigUpdate
-		if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
-			xlog.Warnf("Failed to parse config update: %v", err)
-			continue
+	defer atomic.StoreInt32(&r.listening, 0)
+	for {
+		r.consume()
+		if atomic.LoadInt32(&r.closed) == 1 {
+			return
+		}
+		atomic.StoreInt32(&r.listening, 0)
+		xlog.Errorf("Redis pub/sub channel closed unexpectedly, resubscribing")
+		if !r.resubscribe() {
+			return
 		}
+		atomic.StoreInt32(&r.listening, 1)
+		r.reconnected()
+	}
+}
+
+// consume dispatches messages until the pub/sub channel closes. go-redis
+// reconnects dropped connections on its own; the resulting subscribe
+// confirmation is treated as a reconnection too, since messages may be lost.
+func (r *RedisStore) consume() {
+	r.pubsubMu.Lock()
+	pubsub := r.pubsub
+	r.pubsubMu.Unlock()
+
+	for msg := range pubsub.ChannelWithSubscriptions() {
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				r.reconnected()
+			}
+		case *redis.Message:
+			var update ConfigUpdate
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+				xlog.Warnf("Failed to parse config update: %v", err)
+				continue
+			}
+			if update.Version > 0 {
+				atomic.StoreInt64(&r.version, update.Version)
+			}
+			r.dispatch(update)
+		}
+	}
+}
+
+// resubscribe retries the subscription until it succeeds or the store is closed
+func (r *RedisStore) resubscribe() bool {
+	backoff := resubscribeMinBackoff
+	for attempt := 1; ; attempt++ {
 		select {
-		case r.updates <- update:
-			xlog.Infof("Received config update: type=%s", update.Type)
-		default:
-			xlog.Warnf("Config update channel full, dropping update")
+		case <-time.After(backoff):
+		case <-r.stopChan:
+			return false
+		}
+		pubsub, err := r.subscribe()
+		if err != nil {
+			xlog.Warnf("Redis pub/sub resubscribe attempt %d failed, retrying in %v: %v", attempt, backoff*2, err)
+			if backoff *= 2; backoff > resubscribeMaxBackoff {
+				backoff = resubscribeMaxBackoff
+			}
+			continue
+		}
+
+		r.pubsubMu.Lock()
+		if atomic.LoadInt32(&r.closed) == 1 {
+			r.pubsubMu.Unlock()
+			pubsub.Close()
+			return false
+		}
+		r.pubsub = pubsub
+		r.pubsubMu.Unlock()
+		xlog.Infof("Redis pub/sub resubscribed after %d attempt(s)", attempt)
+		return true
+	}
+}
+
+// reconnected counts a reconnection and asks every consumer to reload, since
+// updates published while disconnected were lost
+func (r *RedisStore) reconnected() {
+	n := atomic.AddInt64(&r.reconnects, 1)
+	xlog.Warnf("Redis pub/sub reconnected (total=%d), reloading all config", n)
+	if version, err := r.GetConfigVersion(); err == nil {
+		atomic.StoreInt64(&r.version, version)
+	}
+	r.subMu.RLock()
+	hook := r.onReconnect
+	r.subMu.RUnlock()
+	if hook != nil {
+		hook()
+	}
+	r.dispatch(ConfigUpdate{Type: UpdateTypeReload})
+}
+
+// dispatch fans an update out to Updates() and every Subscribe() channel
+func (r *RedisStore) dispatch(update ConfigUpdate) {
+	r.dispatchMu.Lock()
+	defer r.dispatchMu.Unlock()
+
+	if r.send(r.updates, update) {
+		xlog.Infof("Received config update: type=%s, version=%d", update.Type, r.AppliedVersion())
+	} else {
+		xlog.Warnf("Config update channel full, dropping update: type=%s (reload queued)", update.Type)
+	}
+
+	r.subMu.RLock()
+	for _, sub := range r.subscribers {
+		if !r.send(sub, update) {
+			xlog.Warnf("Config subscriber channel full, dropping update: type=%s (reload queued)", update.Type)
 		}
 	}
+	r.subMu.RUnlock()
+}
+
+// send delivers update to ch without blocking. A consumer that dropped an
+// earlier update gets a reload in its place: incremental updates (added or
+// removed set members) cannot be repaired by a later one. It returns false
+// if ch is full.
+func (r *RedisStore) send(ch chan ConfigUpdate, update ConfigUpdate) bool {
+	if r.missed[ch] {
+		update = ConfigUpdate{Type: UpdateTypeReload, Version: update.Version}
+	}
+	select {
+	case ch <- update:
+		delete(r.missed, ch)
+		return true
+	default:
+		r.missed[ch] = true
+		return false
+	}
+}
+
+// OnReconnect registers fn to be called after each pub/sub reconnection
+// (e.g. to count them in a metric)
+func (r *RedisStore) OnReconnect(fn func()) {
+	if r == nil {
+		return
+	}
+	r.subMu.Lock()
+	r.onReconnect = fn
+	r.subMu.Unlock()
+}
+
+// Reconnects returns the number of pub/sub reconnections since startup
+func (r *RedisStore) Reconnects() int64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.reconnects)
 }
 
 // Updates returns a channel for receiving configuration updates
@@ -95,23 +387,59 @@ func (r *RedisStore) Updates() <-chan ConfigUpdate {
 	return r.updates
 }
 
+// Subscribe returns a dedicated channel receiving every configuration update.
+// Use this when a component other than the security manager needs hot-reload,
+// since Updates() is a single shared channel.
+func (r *RedisStore) Subscribe() <-chan ConfigUpdate {
+	if r == nil {
+		return nil
+	}
+	ch := make(chan ConfigUpdate, 10)
+	r.subMu.Lock()
+	r.subscribers = append(r.subscribers, ch)
+	r.subMu.Unlock()
+	return ch
+}
+
 // Close closes the Redis connection
 func (r *RedisStore) Close() error {
 	if r == nil {
 		return nil
 	}
+	if atomic.CompareAndSwapInt32(&r.closed, 0, 1) {
+		close(r.stopChan)
+	}
+	r.pubsubMu.Lock()
 	if r.pubsub != nil {
 		r.pubsub.Close()
 	}
+	r.pubsubMu.Unlock()
 	return r.client.Close()
 }
 
 // CheckHealth checks if Redis connection is healthy
+// Performs a bounded PING and verifies the config pub/sub subscription is still alive.
+// The returned error is shown in the /ready body, so it describes the failure.
 func (r *RedisStore) CheckHealth() error {
 	if r == nil {
 		return ErrRedisNotEnabled
 	}
-	return r.client.Ping(r.ctx).Err()
+
+	ctx, cancel := context.WithTimeout(r.ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("redis PING timed out after %v", healthCheckTimeout)
+		}
+		return fmt.Errorf("redis PING failed after %v: %w", time.Since(start).Round(time.Millisecond), err)
+	}
+
+	if atomic.LoadInt32(&r.listening) == 0 {
+		return ErrSubscriptionDead
+	}
+	return nil
 }
 
 // =============================================================================
@@ -149,6 +477,54 @@ func (r *RedisStore) LoadBusinessConfig() (*BusinessConfig, error) {
 		return nil, fmt.Errorf("failed to load business config: %w", err)
 	}
 
+	cfg := parseBusinessConfig(result)
+
+	// HTTP routing table (optional). A bad key leaves it empty rather than
+	// holding back the backends and limits of every reload.
+	routes, err := r.LoadHTTPRoutes()
+	if err != nil {
+		xlog.Warnf("Ignoring HTTP routes: %v", err)
+	}
+	cfg.Backends.HTTP.Routes = routes
+
+	// Header rules (optional)
+	headerRules, err := r.LoadHeaderRules()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Backends.HTTP.HeaderRules = headerRules
+
+	// Method rules (optional)
+	methodRules, err := r.LoadMethodRules()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Backends.HTTP.MethodRules = methodRules
+
+	// Weighted traffic splits (optional)
+	weights, err := r.LoadBackendWeights()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Backends.Weights = weights
+
+	// TLS passthrough table (optional)
+	sniRoutes, err := r.LoadSNIRoutes()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Backends.TLSPassthrough = sniRoutes
+
+	if missing := missingBusinessKeys(cfg); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing %s in %s", ErrBusinessConfigIncomplete, strings.Join(missing, ", "), key)
+	}
+
+	return cfg, nil
+}
+
+// parseBusinessConfig maps business:config hash fields onto a BusinessConfig.
+// Routing tables live in their own hashes and are not set here.
+func parseBusinessConfig(result map[string]string) *BusinessConfig {
 	cfg := &BusinessConfig{}
 
 	// Server config
@@ -158,6 +534,68 @@ func (r *RedisStore) LoadBusinessConfig() (*BusinessConfig, error) {
 	if v, ok := result["server.max_connections"]; ok && v != "" {
 		fmt.Sscanf(v, "%d", &cfg.Server.MaxConnections)
 	}
+	if v, ok := result["server.max_handlers"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Server.MaxHandlers)
+	}
+	if v, ok := result["server.accept_shards"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Server.AcceptShards)
+	}
+	if v, ok := result["server.proxy_protocol"]; ok && v != "" {
+		cfg.Server.ProxyProtocol = v == "true" || v == "1"
+	}
+	cfg.Server.Protocol = strings.ToLower(strings.TrimSpace(result["server.protocol"]))
+	if v, ok := result["server.sniff_timeout"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Server.SniffTimeout = d
+		}
+	}
+	for key, dst := range map[string]*int{
+		"server.buffers.read_size":   &cfg.Server.Buffers.ReadSize,
+		"server.buffers.socket_recv": &cfg.Server.Buffers.SocketRecv,
+		"server.buffers.socket_send": &cfg.Server.Buffers.SocketSend,
+	} {
+		if v, ok := result[key]; ok && v != "" {
+			fmt.Sscanf(v, "%d", dst)
+		}
+	}
+	for key, dst := range map[string]*time.Duration{
+		"server.keepalive.idle":           &cfg.Server.KeepAlive.Idle,
+		"server.keepalive.interval":       &cfg.Server.KeepAlive.Interval,
+		"server.http.read_header_timeout": &cfg.Server.HTTP.ReadHeaderTimeout,
+		"server.http.read_timeout":        &cfg.Server.HTTP.ReadTimeout,
+		"server.http.write_timeout":       &cfg.Server.HTTP.WriteTimeout,
+		"server.http.idle_timeout":        &cfg.Server.HTTP.IdleTimeout,
+	} {
+		if v, ok := result[key]; ok && v != "" {
+			if d, err := time.ParseDuration(v); err == nil {
+				*dst = d
+			}
+		}
+	}
+	if v, ok := result["server.tls.enabled"]; ok && v != "" {
+		cfg.Server.TLS.Enabled = v == "true" || v == "1"
+	}
+	cfg.Server.TLS.CertFile = result["server.tls.cert_file"]
+	cfg.Server.TLS.KeyFile = result["server.tls.key_file"]
+	cfg.Server.TLS.ClientCAFile = result["server.tls.client_ca_file"]
+	cfg.Server.TLS.ClientAuth = result["server.tls.client_auth"]
+	cfg.Server.TLS.MinVersion = result["server.tls.min_version"]
+	if v, ok := result["server.tls.cipher_suites"]; ok && v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.Server.TLS.CipherSuites = append(cfg.Server.TLS.CipherSuites, name)
+			}
+		}
+	}
+
+	cfg.Server.Listeners = parseListenerSpecs(result, cfg.Server)
+
+	// HTTP log sampling and redaction
+	if v, ok := result["server.access_log.sample_rate"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Server.AccessLog.SampleRate)
+	}
+	cfg.Server.AccessLog.Redact = splitList(result["server.access_log.redact"])
+	cfg.Server.AccessLog.Headers = splitList(result["server.access_log.headers"])
 
 	// HTTP Backend
 	if v, ok := result["backends.http.target_url"]; ok && v != "" {
@@ -168,6 +606,58 @@ func (r *RedisStore) LoadBusinessConfig() (*BusinessConfig, error) {
 			cfg.Backends.HTTP.Timeout = d
 		}
 	}
+	for key, dst := range map[string]*time.Duration{
+		"backends.http.dial_timeout":            &cfg.Backends.HTTP.DialTimeout,
+		"backends.http.tls_handshake_timeout":   &cfg.Backends.HTTP.TLSHandshakeTimeout,
+		"backends.http.response_header_timeout": &cfg.Backends.HTTP.ResponseHeaderTimeout,
+		"backends.http.idle_conn_timeout":       &cfg.Backends.HTTP.IdleConnTimeout,
+	} {
+		if v, ok := result[key]; ok && v != "" {
+			if d, err := time.ParseDuration(v); err == nil {
+				*dst = d
+			}
+		}
+	}
+	if v, ok := result["backends.http.max_idle_conns_per_host"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HTTP.MaxIdleConnsPerHost)
+	}
+
+	if v, ok := result["backends.http.max_request_bytes"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HTTP.MaxRequestBytes)
+	}
+	if v, ok := result["backends.http.max_response_bytes"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HTTP.MaxResponseBytes)
+	}
+	if v, ok := result["backends.http.compression.enabled"]; ok && v != "" {
+		cfg.Backends.HTTP.Compression.Enabled = v == "true" || v == "1"
+	}
+	if v, ok := result["backends.http.compression.min_bytes"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HTTP.Compression.MinBytes)
+	}
+
+	// HTTP retry policy (optional)
+	if v, ok := result["backends.http.retry.max_attempts"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HTTP.Retry.MaxAttempts)
+	}
+	if v, ok := result["backends.http.retry.backoff"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.HTTP.Retry.Backoff = d
+		}
+	}
+	if v, ok := result["backends.http.retry.statuses"]; ok && v != "" {
+		for _, code := range strings.Split(v, ",") {
+			if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+				cfg.Backends.HTTP.Retry.Statuses = append(cfg.Backends.HTTP.Retry.Statuses, n)
+			}
+		}
+	}
+	if v, ok := result["backends.http.retry.methods"]; ok && v != "" {
+		for _, method := range strings.Split(v, ",") {
+			if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+				cfg.Backends.HTTP.Retry.Methods = append(cfg.Backends.HTTP.Retry.Methods, method)
+			}
+		}
+	}
 
 	// TCP Backend
 	if v, ok := result["backends.tcp.target_addr"]; ok && v != "" {
@@ -178,6 +668,150 @@ func (r *RedisStore) LoadBusinessConfig() (*BusinessConfig, error) {
 			cfg.Backends.TCP.Timeout = d
 		}
 	}
+	if v, ok := result["backends.tcp.idle_timeout"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.Backends.TCP.IdleTimeout = d
+		} else {
+			xlog.Warnf("Invalid backends.tcp.idle_timeout %q, idle timeout disabled", v)
+		}
+	}
+	cfg.Backends.TCP.SendProxyProtocol = splitList(result["backends.tcp.send_proxy_protocol"])
+	if v, ok := result["backends.tcp.tls.enabled"]; ok && v != "" {
+		cfg.Backends.TCP.TLS.Enabled = v == "true" || v == "1"
+	}
+	if v, ok := result["backends.tcp.tls.server_name"]; ok && v != "" {
+		cfg.Backends.TCP.TLS.ServerName = v
+	}
+	if v, ok := result["backends.tcp.tls.ca_file"]; ok && v != "" {
+		cfg.Backends.TCP.TLS.CAFile = v
+	}
+	if v, ok := result["backends.tcp.tls.cert_file"]; ok && v != "" {
+		cfg.Backends.TCP.TLS.CertFile = v
+	}
+	if v, ok := result["backends.tcp.tls.key_file"]; ok && v != "" {
+		cfg.Backends.TCP.TLS.KeyFile = v
+	}
+	if v, ok := result["backends.tcp.tls.insecure_skip_verify"]; ok && v != "" {
+		cfg.Backends.TCP.TLS.InsecureSkipVerify = v == "true" || v == "1"
+	}
+	if v, ok := result["backends.tcp.pool.max_idle"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.TCP.Pool.MaxIdle)
+	}
+	if v, ok := result["backends.tcp.pool.max_lifetime"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.TCP.Pool.MaxLifetime = d
+		}
+	}
+	if v, ok := result["backends.tcp.pool.idle_timeout"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.TCP.Pool.IdleTimeout = d
+		}
+	}
+
+	// UDP Backend (optional)
+	if v, ok := result["backends.udp.listen_addr"]; ok && v != "" {
+		cfg.Backends.UDP.ListenAddr = v
+	}
+	if v, ok := result["backends.udp.target_addr"]; ok && v != "" {
+		cfg.Backends.UDP.TargetAddr = v
+	}
+	if v, ok := result["backends.udp.timeout"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.UDP.Timeout = d
+		}
+	}
+
+	// Health check (optional, checker applies defaults)
+	if v, ok := result["backends.health_check.interval"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.HealthCheck.Interval = d
+		}
+	}
+	if v, ok := result["backends.health_check.timeout"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.HealthCheck.Timeout = d
+		}
+	}
+	if v, ok := result["backends.health_check.path"]; ok && v != "" {
+		cfg.Backends.HealthCheck.Path = v
+	}
+	if v, ok := result["backends.health_check.expected_status"]; ok && v != "" {
+		// "200-299" or a single code "200"
+		lo, hi, found := strings.Cut(v, "-")
+		if !found {
+			hi = lo
+		}
+		min, errMin := strconv.Atoi(strings.TrimSpace(lo))
+		max, errMax := strconv.Atoi(strings.TrimSpace(hi))
+		if errMin == nil && errMax == nil && min <= max {
+			cfg.Backends.HealthCheck.StatusMin = min
+			cfg.Backends.HealthCheck.StatusMax = max
+		} else {
+			xlog.Warnf("Invalid backends.health_check.expected_status %q, using default", v)
+		}
+	}
+
+	if v, ok := result["backends.health_check.unhealthy_threshold"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HealthCheck.UnhealthyThreshold)
+	}
+	if v, ok := result["backends.health_check.healthy_threshold"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HealthCheck.HealthyThreshold)
+	}
+	if v, ok := result["backends.health_check.passive_failures"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.HealthCheck.PassiveFailures)
+	}
+	if v, ok := result["backends.health_check.ejection_window"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.HealthCheck.EjectionWindow = d
+		}
+	}
+
+	if v, ok := result["backends.health_check.http_type"]; ok && v != "" {
+		switch v {
+		case "http", "tcp", "grpc":
+			cfg.Backends.HealthCheck.HTTPType = v
+		default:
+			xlog.Warnf("Invalid backends.health_check.http_type %q, using default", v)
+		}
+	}
+	if v, ok := result["backends.health_check.tcp_type"]; ok && v != "" {
+		switch v {
+		case "tcp", "grpc":
+			cfg.Backends.HealthCheck.TCPType = v
+		default:
+			xlog.Warnf("Invalid backends.health_check.tcp_type %q, using default", v)
+		}
+	}
+	if v, ok := result["backends.health_check.grpc_service"]; ok {
+		cfg.Backends.HealthCheck.GRPCService = v
+	}
+	if v, ok := result["backends.health_check.user_agent"]; ok && v != "" {
+		cfg.Backends.HealthCheck.UserAgent = v
+	}
+
+	// Circuit breaker (optional)
+	if v, ok := result["backends.circuit_breaker.enabled"]; ok && v != "" {
+		cfg.Backends.CircuitBreaker.Enabled = v == "true" || v == "1"
+	}
+	if v, ok := result["backends.circuit_breaker.failure_rate"]; ok && v != "" {
+		fmt.Sscanf(v, "%f", &cfg.Backends.CircuitBreaker.FailureRate)
+	}
+	if v, ok := result["backends.circuit_breaker.min_requests"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.CircuitBreaker.MinRequests)
+	}
+	if v, ok := result["backends.circuit_breaker.window"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.CircuitBreaker.Window = d
+		}
+	}
+	if v, ok := result["backends.circuit_breaker.cooldown"]; ok && v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Backends.CircuitBreaker.Cooldown = d
+		}
+	}
+	if v, ok := result["backends.circuit_breaker.half_open_requests"]; ok && v != "" {
+		fmt.Sscanf(v, "%d", &cfg.Backends.CircuitBreaker.HalfOpenRequests)
+	}
 
 	// Lifecycle config
 	if v, ok := result["lifecycle.shutdown_timeout"]; ok && v != "" {
@@ -191,13 +825,434 @@ func (r *RedisStore) LoadBusinessConfig() (*BusinessConfig, error) {
 		}
 	}
 
-	return cfg, nil
+	return cfg
+}
+
+// splitList splits a comma-separated field, dropping empty items
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseListenerSpecs collects server.listeners.<name>.{addr,proxy_protocol,tls,protocol}
+// fields, sorted by name. Listeners without an address are skipped; unset
+// options inherit the server-wide values.
+func parseListenerSpecs(result map[string]string, server ServerConfig) []ListenerSpec {
+	const prefix = "server.listeners."
+	byName := make(map[string]*ListenerSpec)
+	for key, v := range result {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		dot := strings.LastIndexByte(key, '.')
+		if dot <= len(prefix) {
+			continue
+		}
+		name, field := key[len(prefix):dot], key[dot+1:]
+		spec, ok := byName[name]
+		if !ok {
+			spec = &ListenerSpec{Name: name, ProxyProtocol: server.ProxyProtocol, TLS: server.TLS.Enabled}
+			byName[name] = spec
+		}
+		switch field {
+		case "addr":
+			spec.Addr = v
+		case "proxy_protocol":
+			spec.ProxyProtocol = v == "true" || v == "1"
+		case "tls":
+			spec.TLS = v == "true" || v == "1"
+		case "protocol":
+			spec.Protocol = strings.ToLower(strings.TrimSpace(v))
+		}
+	}
+
+	specs := make([]ListenerSpec, 0, len(byName))
+	for name, spec := range byName {
+		if spec.Addr == "" {
+			xlog.Warnf("Listener %s has no server.listeners.%s.addr, skipped", name, name)
+			continue
+		}
+		if name == DefaultListenerName {
+			xlog.Warnf("Listener name %q is reserved for server.listen_addr, skipped", name)
+			continue
+		}
+		specs = append(specs, *spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// missingBusinessKeys reports the required business keys absent from cfg.
+// A listen address (server.listen_addr or a named listener) and at least one backend (HTTP target or route, TCP target or SNI route) are required.
+func missingBusinessKeys(cfg *BusinessConfig) []string {
+	var missing []string
+	if len(cfg.Server.ListenSpecs()) == 0 {
+		missing = append(missing, "server.listen_addr or server.listeners.<name>.addr")
+	}
+	if cfg.Backends.HTTP.TargetURL == "" && len(cfg.Backends.HTTP.Routes) == 0 &&
+		cfg.Backends.TCP.TargetAddr == "" && len(cfg.Backends.TLSPassthrough) == 0 {
+		missing = append(missing, "backends.http.target_url or backends.tcp.target_addr")
+	}
+	return missing
+}
+
+// LoadHTTPRoutes loads the HTTP path-prefix routing table
+// Stored as a hash: field = path prefix, value = target URL
+// Order is irrelevant since routes are matched by longest prefix
+func (r *RedisStore) LoadHTTPRoutes() ([]HTTPRoute, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+
+	result, err := r.client.HGetAll(r.ctx, r.prefix+"business:http_routes").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load http routes: %w", err)
+	}
+
+	routes := make([]HTTPRoute, 0, len(result))
+	for prefix, target := range result {
+		if prefix == "" || target == "" {
+			continue
+		}
+		routes = append(routes, HTTPRoute{Prefix: prefix, TargetURL: target})
+	}
+	// Stable order for logging and comparison
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Prefix < routes[j].Prefix })
+	return routes, nil
+}
+
+// LoadHeaderRules loads the HTTP header rules
+// Stored as a hash: field = rule name, value = JSON rule. Invalid rules are
+// skipped; the rest are returned in name order, the order they apply in.
+func (r *RedisStore) LoadHeaderRules() ([]HeaderRule, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+
+	result, err := r.client.HGetAll(r.ctx, r.prefix+"business:header_rules").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load header rules: %w", err)
+	}
+
+	rules := make([]HeaderRule, 0, len(result))
+	for name, raw := range result {
+		rule, err := ParseHeaderRule(name, raw)
+		if err != nil {
+			xlog.Warnf("Skipping invalid %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules, nil
+}
+
+// LoadMethodRules loads the HTTP method rules
+// Stored as a hash: field = path prefix, value = JSON {"allow": [...], "deny": [...]}.
+// Invalid rules are skipped; the rest are returned in prefix order.
+func (r *RedisStore) LoadMethodRules() ([]MethodRule, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+
+	result, err := r.client.HGetAll(r.ctx, r.prefix+"business:method_rules").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load method rules: %w", err)
+	}
+
+	rules := make([]MethodRule, 0, len(result))
+	for prefix, raw := range result {
+		rule, err := ParseMethodRule(prefix, raw)
+		if err != nil {
+			xlog.Warnf("Skipping invalid %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Prefix < rules[j].Prefix })
+	return rules, nil
+}
+
+// LoadBackendWeights loads the weighted traffic splits
+// Stored as a hash: field = configured backend, value = JSON {"target": weight, ...}.
+// Invalid splits are skipped; the rest are returned in backend order.
+func (r *RedisStore) LoadBackendWeights() ([]BackendWeights, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+
+	result, err := r.client.HGetAll(r.ctx, r.prefix+"business:backend_weights").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backend weights: %w", err)
+	}
+
+	weights := make([]BackendWeights, 0, len(result))
+	for backend, raw := range result {
+		w, err := ParseBackendWeights(backend, raw)
+		if err != nil {
+			xlog.Warnf("Skipping invalid %v", err)
+			continue
+		}
+		weights = append(weights, w)
+	}
+	sort.Slice(weights, func(i, j int) bool { return weights[i].Backend < weights[j].Backend })
+	return weights, nil
+}
+
+// LoadSNIRoutes loads the TLS passthrough table
+// Stored as a hash: field = server name (exact or *.domain), value = host:port
+func (r *RedisStore) LoadSNIRoutes() ([]SNIRoute, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+
+	result, err := r.client.HGetAll(r.ctx, r.prefix+"business:sni_routes").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sni routes: %w", err)
+	}
+
+	routes := make([]SNIRoute, 0, len(result))
+	for name, target := range result {
+		if name == "" || target == "" {
+			continue
+		}
+		routes = append(routes, SNIRoute{ServerName: strings.ToLower(name), TargetAddr: target})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].ServerName < routes[j].ServerName })
+	return routes, nil
 }
 
 // =============================================================================
 // Security Configuration - READ ONLY
 // =============================================================================
 
+// LoadAdminToken returns the admin API bearer token stored in Redis, or "" if none is set
+// Returns "" (and no error) if no token is stored
+func (r *RedisStore) LoadAdminToken() (string, error) {
+	if r == nil {
+		return "", ErrRedisNotEnabled
+	}
+	token, err := r.client.HGet(r.ctx, r.prefix+"admin:config", "token").Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return token, err
+}
+
+// GetBlockedIPs returns the WAF blocked IP set
+func (r *RedisStore) GetBlockedIPs() ([]string, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+	return r.client.SMembers(r.ctx, r.prefix+"waf:blocked_ips").Result()
+}
+
+// GetAllowedIPs returns the WAF allowlist set
+func (r *RedisStore) GetAllowedIPs() ([]string, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+	return r.client.SMembers(r.ctx, r.prefix+"waf:allowed_ips").Result()
+}
+
+// GetBlockedPatterns returns the WAF blocked pattern set
+func (r *RedisStore) GetBlockedPatterns() ([]string, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+	return r.client.SMembers(r.ctx, r.prefix+"waf:blocked_patterns").Result()
+}
+
+// AddBlockedIPs adds IPs or CIDRs to the WAF blocked set as a versioned write.
+// ifVersion is the version the caller last read, or AnyVersion.
+func (r *RedisStore) AddBlockedIPs(ifVersion int64, ips ...string) (int64, error) {
+	return r.addIPs("waf:blocked_ips", UpdateTypeBlockedIPs, ifVersion, ips)
+}
+
+// RemoveBlockedIPs removes entries from the WAF blocked set as a versioned write
+func (r *RedisStore) RemoveBlockedIPs(ifVersion int64, ips ...string) (int64, error) {
+	return r.updateSet("waf:blocked_ips", UpdateTypeBlockedIPs, false, ifVersion, ips)
+}
+
+// ClearBlockedIPs empties the WAF blocked set as a versioned write
+func (r *RedisStore) ClearBlockedIPs(ifVersion int64) (int64, error) {
+	return r.clearSet("waf:blocked_ips", UpdateTypeBlockedIPs, ifVersion)
+}
+
+// AddAllowedIPs adds IPs or CIDRs to the WAF allowlist as a versioned write
+func (r *RedisStore) AddAllowedIPs(ifVersion int64, ips ...string) (int64, error) {
+	return r.addIPs("waf:allowed_ips", UpdateTypeAllowedIPs, ifVersion, ips)
+}
+
+// RemoveAllowedIPs removes entries from the WAF allowlist as a versioned write
+func (r *RedisStore) RemoveAllowedIPs(ifVersion int64, ips ...string) (int64, error) {
+	return r.updateSet("waf:allowed_ips", UpdateTypeAllowedIPs, false, ifVersion, ips)
+}
+
+// ClearAllowedIPs empties the WAF allowlist as a versioned write
+func (r *RedisStore) ClearAllowedIPs(ifVersion int64) (int64, error) {
+	return r.clearSet("waf:allowed_ips", UpdateTypeAllowedIPs, ifVersion)
+}
+
+// AddBlockedPatterns adds regexes to the WAF pattern set as a versioned write
+func (r *RedisStore) AddBlockedPatterns(ifVersion int64, patterns ...string) (int64, error) {
+	return r.updateSet("waf:blocked_patterns", UpdateTypeBlockedPatterns, true, ifVersion, patterns)
+}
+
+// RemoveBlockedPatterns removes regexes from the WAF pattern set as a versioned write
+func (r *RedisStore) RemoveBlockedPatterns(ifVersion int64, patterns ...string) (int64, error) {
+	return r.updateSet("waf:blocked_patterns", UpdateTypeBlockedPatterns, false, ifVersion, patterns)
+}
+
+// ClearBlockedPatterns empties the WAF pattern set as a versioned write
+func (r *RedisStore) ClearBlockedPatterns(ifVersion int64) (int64, error) {
+	return r.clearSet("waf:blocked_patterns", UpdateTypeBlockedPatterns, ifVersion)
+}
+
+// AddAPIKey stores an API key digest (hex SHA-256) for client name as a versioned write.
+// Gateways reload their keys on the published api_keys update.
+func (r *RedisStore) AddAPIKey(ifVersion int64, digest, name string) (int64, error) {
+	if r == nil {
+		return 0, ErrRedisNotEnabled
+	}
+	version, err := r.writeVersioned(ifVersion, ConfigUpdate{Type: UpdateTypeAPIKeys}, func(pipe redis.Pipeliner) {
+		pipe.HSet(r.ctx, r.prefix+"auth:api_keys", digest, name)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to add API key: %w", err)
+	}
+	xlog.Infof("API key added: name=%s, version=%d", name, version)
+	return version, nil
+}
+
+// RemoveAPIKey revokes an API key by digest as a versioned write
+func (r *RedisStore) RemoveAPIKey(ifVersion int64, digest string) (int64, error) {
+	if r == nil {
+		return 0, ErrRedisNotEnabled
+	}
+	version, err := r.writeVersioned(ifVersion, ConfigUpdate{Type: UpdateTypeAPIKeys}, func(pipe redis.Pipeliner) {
+		pipe.HDel(r.ctx, r.prefix+"auth:api_keys", digest)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove API key: %w", err)
+	}
+	xlog.Infof("API key revoked: digest=%.12s, version=%d", digest, version)
+	return version, nil
+}
+
+// LoadAPIKeys returns the stored API key digests (hex SHA-256 -> client name)
+func (r *RedisStore) LoadAPIKeys() (map[string]string, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+	return r.client.HGetAll(r.ctx, r.prefix+"auth:api_keys").Result()
+}
+
+// addIPs trims and validates IP/CIDR entries before adding them to a WAF set,
+// so a typo never becomes an entry that silently matches nothing
+func (r *RedisStore) addIPs(key, updateType string, ifVersion int64, ips []string) (int64, error) {
+	trimmed := make([]string, len(ips))
+	for i, ip := range ips {
+		trimmed[i] = strings.TrimSpace(ip)
+	}
+	if invalid := ValidateIPs(trimmed); len(invalid) > 0 {
+		quoted := make([]string, len(invalid))
+		for i, ip := range invalid {
+			quoted[i] = strconv.Quote(ip)
+		}
+		return 0, fmt.Errorf("%w: not an IP or CIDR: %s", ErrInvalidEntries, strings.Join(quoted, ", "))
+	}
+	return r.updateSet(key, updateType, true, ifVersion, trimmed)
+}
+
+// ValidateIPs returns the entries that are neither an IP address nor a CIDR range.
+// Empty entries are ignored, matching the WAF loaders.
+func ValidateIPs(entries []string) []string {
+	var invalid []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err == nil {
+				continue
+			}
+		} else if net.ParseIP(entry) != nil {
+			continue
+		}
+		invalid = append(invalid, entry)
+	}
+	return invalid
+}
+
+// updateSet adds or removes members of a set through writeVersioned and
+// publishes the change incrementally (updateType with a SetChange)
+func (r *RedisStore) updateSet(key, updateType string, add bool, ifVersion int64, members []string) (int64, error) {
+	if r == nil {
+		return 0, ErrRedisNotEnabled
+	}
+	items := make([]string, 0, len(members))
+	for _, m := range members {
+		if m != "" {
+			items = append(items, m)
+		}
+	}
+	if len(items) == 0 {
+		return 0, fmt.Errorf("no entries to update in %s", key)
+	}
+	change := SetChange{Action: "remove", Items: items}
+	if add {
+		change.Action = "add"
+	}
+	data, err := json.Marshal(change)
+	if err != nil {
+		return 0, err
+	}
+	args := make([]interface{}, len(items))
+	for i, item := range items {
+		args[i] = item
+	}
+
+	version, err := r.writeVersioned(ifVersion, ConfigUpdate{Type: updateType, Data: data}, func(pipe redis.Pipeliner) {
+		if add {
+			pipe.SAdd(r.ctx, r.prefix+key, args...)
+		} else {
+			pipe.SRem(r.ctx, r.prefix+key, args...)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update %s: %w", key, err)
+	}
+	xlog.Infof("Updated %s: action=%s, count=%d, version=%d", key, change.Action, len(items), version)
+	return version, nil
+}
+
+// clearSet deletes a set through writeVersioned and publishes a clear change.
+// Clearing an empty set succeeds (and still creates a version).
+func (r *RedisStore) clearSet(key, updateType string, ifVersion int64) (int64, error) {
+	if r == nil {
+		return 0, ErrRedisNotEnabled
+	}
+	data, err := json.Marshal(SetChange{Action: "clear"})
+	if err != nil {
+		return 0, err
+	}
+	version, err := r.writeVersioned(ifVersion, ConfigUpdate{Type: updateType, Data: data}, func(pipe redis.Pipeliner) {
+		pipe.Del(r.ctx, r.prefix+key)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear %s: %w", key, err)
+	}
+	xlog.Infof("Cleared %s: version=%d", key, version)
+	return version, nil
+}
+
 // LoadSecurityConfig loads security configuration from Redis
 // Gateway ONLY reads this, never writes. External admin tools manage this.
 func (r *RedisStore) LoadSecurityConfig() (*SecurityConfig, error) {
@@ -212,9 +1267,37 @@ func (r *RedisStore) LoadSecurityConfig() (*SecurityConfig, error) {
 		if v, ok := authCfg["enabled"]; ok {
 			cfg.Auth.Enabled = v == "1" || v == "true"
 		}
+		if v, ok := authCfg["mode"]; ok {
+			cfg.Auth.Mode = v
+		}
 		if v, ok := authCfg["header_subject"]; ok && v != "" {
 			cfg.Auth.HeaderSubject = v
 		}
+		if v, ok := authCfg["api_key_header"]; ok && v != "" {
+			cfg.Auth.APIKeyHeader = v
+		}
+		if v, ok := authCfg["jwt.enabled"]; ok {
+			cfg.Auth.JWT.Enabled = v == "1" || v == "true"
+		}
+		if v, ok := authCfg["jwt.issuer"]; ok {
+			cfg.Auth.JWT.Issuer = v
+		}
+		if v, ok := authCfg["jwt.audience"]; ok {
+			cfg.Auth.JWT.Audience = v
+		}
+		if v, ok := authCfg["jwt.jwks_url"]; ok {
+			cfg.Auth.JWT.JWKSURL = v
+		}
+		if v, ok := authCfg["jwt.refresh_interval"]; ok && v != "" {
+			if d, err := time.ParseDuration(v); err == nil {
+				cfg.Auth.JWT.RefreshInterval = d
+			}
+		}
+	}
+
+	// Load API key digests (Hash: hex SHA-256 -> client name)
+	if keys, err := r.client.HGetAll(r.ctx, r.prefix+"auth:api_keys").Result(); err == nil {
+		cfg.Auth.APIKeys = keys
 	}
 
 	// Load allowed subjects
@@ -235,11 +1318,33 @@ func (r *RedisStore) LoadSecurityConfig() (*SecurityConfig, error) {
 		}
 	}
 
+	// Load rate limit policies (Hash: policy name -> JSON rule)
+	if policies, err := r.client.HGetAll(r.ctx, r.prefix+"rate_limit:policies").Result(); err == nil {
+		for name, raw := range policies {
+			p, err := ParseRateLimitPolicy(name, raw)
+			if err != nil {
+				xlog.Warnf("Skipping invalid %v", err)
+				continue
+			}
+			cfg.RateLimit.Policies = append(cfg.RateLimit.Policies, p)
+		}
+	}
+
 	// Load WAF config
 	if wafCfg, err := r.client.HGetAll(r.ctx, r.prefix+"waf:config").Result(); err == nil && len(wafCfg) > 0 {
 		if v, ok := wafCfg["enabled"]; ok {
 			cfg.WAF.Enabled = v == "1" || v == "true"
 		}
+		if v, ok := wafCfg["mode"]; ok && v != "" {
+			cfg.WAF.Mode = v
+		}
+		if v, ok := wafCfg["inspect_body"]; ok {
+			cfg.WAF.InspectBody = v == "1" || v == "true"
+		}
+		if v, ok := wafCfg["max_body_scan_bytes"]; ok && v != "" {
+			fmt.Sscanf(v, "%d", &cfg.WAF.MaxBodyScanBytes)
+		}
+		cfg.WAF.GeoIPDatabase = wafCfg["geoip_db"]
 	}
 
 	// Load blocked IPs (using Set for atomic add/remove without overwrite)
@@ -247,10 +1352,27 @@ func (r *RedisStore) LoadSecurityConfig() (*SecurityConfig, error) {
 		cfg.WAF.BlockedIPs = ips
 	}
 
+	// Load allowlisted IPs/CIDRs (only enforced in allowlist mode)
+	if ips, err := r.client.SMembers(r.ctx, r.prefix+"waf:allowed_ips").Result(); err == nil {
+		cfg.WAF.AllowedIPs = ips
+	}
+
 	// Load blocked patterns (using Set for atomic add/remove without overwrite)
 	if patterns, err := r.client.SMembers(r.ctx, r.prefix+"waf:blocked_patterns").Result(); err == nil {
 		cfg.WAF.BlockedPatterns = patterns
 	}
 
+	// Load blocked ISO country codes (Set)
+	if countries, err := r.client.SMembers(r.ctx, r.prefix+"waf:blocked_countries").Result(); err == nil {
+		sort.Strings(countries)
+		cfg.WAF.BlockedCountries = countries
+	}
+
+	// Load inspected header names (Set)
+	if headers, err := r.client.SMembers(r.ctx, r.prefix+"waf:inspect_headers").Result(); err == nil {
+		sort.Strings(headers)
+		cfg.WAF.InspectHeaders = headers
+	}
+
 	return &cfg, nil
 }
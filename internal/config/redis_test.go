@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestStore returns a RedisStore with key prefix "uag:" backed by miniredis
+func newTestStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	store, err := NewRedisStore(&RedisConfig{Enabled: true, Addr: mr.Addr(), KeyPrefix: "uag:"})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, mr
+}
+
+func TestLoadBusinessConfig(t *testing.T) {
+	store, mr := newTestStore(t)
+	mr.HSet("uag:business:config",
+		"server.listen_addr", ":8080",
+		"server.max_connections", "5000",
+		"server.sniff_timeout", "250ms",
+		"backends.http.target_url", "http://web:8080",
+		"backends.http.timeout", "30s",
+		"backends.tcp.target_addr", "game:9000",
+		"backends.tcp.idle_timeout", "2m",
+	)
+	mr.HSet("uag:business:http_routes",
+		"/api", "http://api:8080",
+		"/static", "http://cdn:8080",
+	)
+
+	cfg, err := store.LoadBusinessConfig()
+	if err != nil {
+		t.Fatalf("LoadBusinessConfig: %v", err)
+	}
+	if cfg.Server.ListenAddr != ":8080" {
+		t.Errorf("server.listen_addr = %q", cfg.Server.ListenAddr)
+	}
+	if cfg.Server.MaxConnections != 5000 {
+		t.Errorf("server.max_connections = %d", cfg.Server.MaxConnections)
+	}
+	if cfg.Server.SniffTimeout != 250*time.Millisecond {
+		t.Errorf("server.sniff_timeout = %v", cfg.Server.SniffTimeout)
+	}
+	if cfg.Backends.HTTP.TargetURL != "http://web:8080" || cfg.Backends.HTTP.Timeout != 30*time.Second {
+		t.Errorf("backends.http = %q, %v", cfg.Backends.HTTP.TargetURL, cfg.Backends.HTTP.Timeout)
+	}
+	if cfg.Backends.TCP.TargetAddr != "game:9000" || cfg.Backends.TCP.IdleTimeout != 2*time.Minute {
+		t.Errorf("backends.tcp = %q, %v", cfg.Backends.TCP.TargetAddr, cfg.Backends.TCP.IdleTimeout)
+	}
+	wantRoutes := []HTTPRoute{
+		{Prefix: "/api", TargetURL: "http://api:8080"},
+		{Prefix: "/static", TargetURL: "http://cdn:8080"},
+	}
+	if !reflect.DeepEqual(cfg.Backends.HTTP.Routes, wantRoutes) {
+		t.Errorf("routes = %+v, want %+v", cfg.Backends.HTTP.Routes, wantRoutes)
+	}
+}
+
+func TestLoadBusinessConfigMissingKeys(t *testing.T) {
+	store, mr := newTestStore(t)
+
+	if _, err := store.LoadBusinessConfig(); !errors.Is(err, ErrBusinessConfigNotFound) {
+		t.Fatalf("without business:config: err = %v, want ErrBusinessConfigNotFound", err)
+	}
+
+	mr.HSet("uag:business:config", "server.max_connections", "100")
+	if _, err := store.LoadBusinessConfig(); !errors.Is(err, ErrBusinessConfigIncomplete) {
+		t.Fatalf("without listen address and backends: err = %v, want ErrBusinessConfigIncomplete", err)
+	}
+
+	mr.HSet("uag:business:config", "server.listen_addr", ":8080")
+	if _, err := store.LoadBusinessConfig(); !errors.Is(err, ErrBusinessConfigIncomplete) {
+		t.Fatalf("without backends: err = %v, want ErrBusinessConfigIncomplete", err)
+	}
+
+	mr.HSet("uag:business:config", "backends.tcp.target_addr", "game:9000")
+	if _, err := store.LoadBusinessConfig(); err != nil {
+		t.Fatalf("complete config: %v", err)
+	}
+}
+
+func TestLoadSecurityConfig(t *testing.T) {
+	store, mr := newTestStore(t)
+	mr.HSet("uag:auth:config",
+		"enabled", "true",
+		"header_subject", "X-Subject",
+		"jwt.enabled", "1",
+		"jwt.issuer", "https://idp.example.com",
+		"jwt.jwks_url", "https://idp.example.com/jwks",
+		"jwt.refresh_interval", "10m",
+	)
+	mr.SAdd("uag:auth:allowed_subjects", "CN=billing", "O=Acme,OU=*")
+	mr.HSet("uag:rate_limit", "enabled", "false", "rps", "50.5", "burst", "75")
+	mr.HSet("uag:waf:config", "enabled", "true", "mode", "allowlist", "max_body_scan_bytes", "1024")
+	mr.SAdd("uag:waf:blocked_ips", "203.0.113.7", "198.51.100.0/24")
+	mr.SAdd("uag:waf:allowed_ips", "10.0.0.0/8")
+	mr.SAdd("uag:waf:blocked_patterns", "(?i)union\\s+select")
+
+	cfg, err := store.LoadSecurityConfig()
+	if err != nil {
+		t.Fatalf("LoadSecurityConfig: %v", err)
+	}
+
+	if !cfg.Auth.Enabled || cfg.Auth.HeaderSubject != "X-Subject" {
+		t.Errorf("auth = enabled %v, header_subject %q", cfg.Auth.Enabled, cfg.Auth.HeaderSubject)
+	}
+	if cfg.Auth.APIKeyHeader != "X-API-Key" {
+		t.Errorf("api_key_header = %q, want the default", cfg.Auth.APIKeyHeader)
+	}
+	wantJWT := JWTConfig{
+		Enabled:         true,
+		Issuer:          "https://idp.example.com",
+		JWKSURL:         "https://idp.example.com/jwks",
+		RefreshInterval: 10 * time.Minute,
+	}
+	if cfg.Auth.JWT != wantJWT {
+		t.Errorf("jwt = %+v, want %+v", cfg.Auth.JWT, wantJWT)
+	}
+	assertSet(t, "auth:allowed_subjects", cfg.Auth.AllowedSubjects, "CN=billing", "O=Acme,OU=*")
+
+	if cfg.RateLimit.Enabled || cfg.RateLimit.RequestsPerSecond != 50.5 || cfg.RateLimit.Burst != 75 {
+		t.Errorf("rate_limit = %+v", cfg.RateLimit)
+	}
+
+	if !cfg.WAF.Enabled || cfg.WAF.Mode != "allowlist" || cfg.WAF.MaxBodyScanBytes != 1024 {
+		t.Errorf("waf = enabled %v, mode %q, max_body_scan_bytes %d", cfg.WAF.Enabled, cfg.WAF.Mode, cfg.WAF.MaxBodyScanBytes)
+	}
+	assertSet(t, "waf:blocked_ips", cfg.WAF.BlockedIPs, "198.51.100.0/24", "203.0.113.7")
+	assertSet(t, "waf:allowed_ips", cfg.WAF.AllowedIPs, "10.0.0.0/8")
+	assertSet(t, "waf:blocked_patterns", cfg.WAF.BlockedPatterns, "(?i)union\\s+select")
+}
+
+func TestLoadSecurityConfigDefaults(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	cfg, err := store.LoadSecurityConfig()
+	if err != nil {
+		t.Fatalf("LoadSecurityConfig: %v", err)
+	}
+	want := DefaultSecurityState()
+	if cfg.Auth.Enabled != want.Auth.Enabled || cfg.Auth.HeaderSubject != want.Auth.HeaderSubject {
+		t.Errorf("auth = %+v, want defaults %+v", cfg.Auth, want.Auth)
+	}
+	if !reflect.DeepEqual(cfg.RateLimit, want.RateLimit) {
+		t.Errorf("rate_limit = %+v, want defaults %+v", cfg.RateLimit, want.RateLimit)
+	}
+	if cfg.WAF.Enabled || len(cfg.WAF.BlockedIPs) != 0 {
+		t.Errorf("waf = %+v, want disabled and empty", cfg.WAF)
+	}
+}
+
+// assertSet compares a Redis set read back in any order with want
+func assertSet(t *testing.T, name string, got []string, want ...string) {
+	t.Helper()
+	got = append([]string(nil), got...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %q, want %q", name, got, want)
+	}
+}
+
+func TestValidateIPs(t *testing.T) {
+	tests := []struct {
+		entry string
+		valid bool
+	}{
+		{"203.0.113.7", true},
+		{" 203.0.113.7 ", true},
+		{"2001:db8::1", true},
+		{"::ffff:192.0.2.1", true},
+		{"198.51.100.0/24", true},
+		{"0.0.0.0/0", true},
+		{"2001:db8::/32", true},
+		{"", true}, // ignored, like the WAF loaders do
+		{"192.168.0.256", false},
+		{"10.0.0", false},
+		{"2001:db8::g", false},
+		{"198.51.100.0/33", false},
+		{"2001:db8::/129", false},
+		{"198.51.100.0/", false},
+		{"198.51.100.0/-1", false},
+		{"/24", false},
+		{"example.com", false},
+		{"203.0.113.7:80", false},
+		{"not an ip", false},
+	}
+	for _, tt := range tests {
+		invalid := ValidateIPs([]string{tt.entry})
+		if got := len(invalid) == 0; got != tt.valid {
+			t.Errorf("ValidateIPs(%q) valid = %v, want %v", tt.entry, got, tt.valid)
+		}
+	}
+
+	invalid := ValidateIPs([]string{"203.0.113.7", "10.0.0.300", "198.51.100.0/24", "junk"})
+	if want := []string{"10.0.0.300", "junk"}; !reflect.DeepEqual(invalid, want) {
+		t.Errorf("ValidateIPs(mixed) = %q, want %q", invalid, want)
+	}
+}
+
+func TestAddBlockedIPsRejectsInvalidEntries(t *testing.T) {
+	store, mr := newTestStore(t)
+
+	if _, err := store.AddBlockedIPs(0, "203.0.113.7", "192.168.0.256"); !errors.Is(err, ErrInvalidEntries) {
+		t.Fatalf("err = %v, want ErrInvalidEntries", err)
+	}
+	if mr.Exists("uag:waf:blocked_ips") {
+		t.Fatal("a rejected batch must not store any entry")
+	}
+
+	if _, err := store.AddBlockedIPs(0, " 203.0.113.7 ", "2001:db8::/32"); err != nil {
+		t.Fatalf("AddBlockedIPs: %v", err)
+	}
+	ips, err := store.GetBlockedIPs()
+	if err != nil {
+		t.Fatalf("GetBlockedIPs: %v", err)
+	}
+	assertSet(t, "waf:blocked_ips", ips, "203.0.113.7", "2001:db8::/32")
+}
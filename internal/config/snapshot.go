@@ -0,0 +1,229 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"github.com/redis/go-redis/v9"
+)
+
+// SnapshotVersion is the format version written by ExportSnapshot
+const SnapshotVersion = 1
+
+// ErrInvalidSnapshot is returned by ImportSnapshot when validation fails
+var ErrInvalidSnapshot = errors.New("invalid config snapshot")
+
+// snapshotHashes and snapshotSets are the keys covered by export and import.
+// admin:config is left out on purpose: the admin token is a secret.
+var (
+	snapshotHashes = []string{
+		"business:config",
+		"business:http_routes",
+		"business:sni_routes",
+		"business:header_rules",
+		"business:method_rules",
+		"business:backend_weights",
+		"auth:config",
+		"auth:api_keys",
+		"rate_limit",
+		"rate_limit:policies",
+		"waf:config",
+	}
+	snapshotSets = []string{
+		"auth:allowed_subjects",
+		"waf:blocked_ips",
+		"waf:allowed_ips",
+		"waf:blocked_patterns",
+		"waf:blocked_countries",
+		"waf:inspect_headers",
+	}
+)
+
+// ConfigSnapshot is a portable copy of the dynamic configuration in Redis.
+// Keys are relative to the store prefix; a key missing from the snapshot is empty.
+type ConfigSnapshot struct {
+	Version    int                          `json:"version"`
+	ExportedAt time.Time                    `json:"exported_at"`
+	Hashes     map[string]map[string]string `json:"hashes"`
+	Sets       map[string][]string          `json:"sets"`
+}
+
+// ExportSnapshot reads every snapshot key in one MULTI/EXEC, so the result is
+// consistent even while admin tools are writing
+func (r *RedisStore) ExportSnapshot() (*ConfigSnapshot, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+	return r.readSnapshot(r.client.TxPipelined)
+}
+
+// readSnapshot reads the snapshot keys through pipelined (a client's TxPipelined,
+// or a WATCH transaction's Pipelined)
+func (r *RedisStore) readSnapshot(pipelined func(context.Context, func(redis.Pipeliner) error) ([]redis.Cmder, error)) (*ConfigSnapshot, error) {
+	hashCmds := make(map[string]*redis.MapStringStringCmd, len(snapshotHashes))
+	setCmds := make(map[string]*redis.StringSliceCmd, len(snapshotSets))
+	_, err := pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range snapshotHashes {
+			hashCmds[key] = pipe.HGetAll(r.ctx, r.prefix+key)
+		}
+		for _, key := range snapshotSets {
+			setCmds[key] = pipe.SMembers(r.ctx, r.prefix+key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export config: %w", err)
+	}
+
+	snap := &ConfigSnapshot{
+		Version:    SnapshotVersion,
+		ExportedAt: time.Now().UTC(),
+		Hashes:     make(map[string]map[string]string),
+		Sets:       make(map[string][]string),
+	}
+	for key, cmd := range hashCmds {
+		if fields := cmd.Val(); len(fields) > 0 {
+			snap.Hashes[key] = fields
+		}
+	}
+	for key, cmd := range setCmds {
+		if members := cmd.Val(); len(members) > 0 {
+			sort.Strings(members)
+			snap.Sets[key] = members
+		}
+	}
+	return snap, nil
+}
+
+// Validate checks the snapshot without touching Redis and returns every problem found.
+// Business config must be complete, since the gateway refuses to start without it.
+func (s *ConfigSnapshot) Validate() []string {
+	var problems []string
+	if s.Version != SnapshotVersion {
+		problems = append(problems, fmt.Sprintf("unsupported version %d (expected %d)", s.Version, SnapshotVersion))
+	}
+	for key := range s.Hashes {
+		if !containsKey(snapshotHashes, key) {
+			problems = append(problems, fmt.Sprintf("unknown hash key %q", key))
+		}
+	}
+	for key := range s.Sets {
+		if !containsKey(snapshotSets, key) {
+			problems = append(problems, fmt.Sprintf("unknown set key %q", key))
+		}
+	}
+
+	business := parseBusinessConfig(s.Hashes["business:config"])
+	for prefix, target := range s.Hashes["business:http_routes"] {
+		if prefix != "" && target != "" {
+			business.Backends.HTTP.Routes = append(business.Backends.HTTP.Routes, HTTPRoute{Prefix: prefix, TargetURL: target})
+		}
+	}
+	for name, target := range s.Hashes["business:sni_routes"] {
+		if name != "" && target != "" {
+			business.Backends.TLSPassthrough = append(business.Backends.TLSPassthrough, SNIRoute{ServerName: name, TargetAddr: target})
+		}
+	}
+	if missing := missingBusinessKeys(business); len(missing) > 0 {
+		problems = append(problems, "business:config is missing "+strings.Join(missing, ", "))
+	}
+
+	if rl := s.Hashes["rate_limit"]; rl != nil {
+		if v := rl["rps"]; v != "" {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				problems = append(problems, fmt.Sprintf("rate_limit.rps %q is not a number", v))
+			}
+		}
+		if v := rl["burst"]; v != "" {
+			if _, err := strconv.Atoi(v); err != nil {
+				problems = append(problems, fmt.Sprintf("rate_limit.burst %q is not an integer", v))
+			}
+		}
+	}
+	for name, raw := range s.Hashes["business:header_rules"] {
+		if _, err := ParseHeaderRule(name, raw); err != nil {
+			problems = append(problems, "business:header_rules: "+err.Error())
+		}
+	}
+	for prefix, raw := range s.Hashes["business:method_rules"] {
+		if _, err := ParseMethodRule(prefix, raw); err != nil {
+			problems = append(problems, "business:method_rules: "+err.Error())
+		}
+	}
+	for backend, raw := range s.Hashes["business:backend_weights"] {
+		if _, err := ParseBackendWeights(backend, raw); err != nil {
+			problems = append(problems, "business:backend_weights: "+err.Error())
+		}
+	}
+	for name, raw := range s.Hashes["rate_limit:policies"] {
+		if _, err := ParseRateLimitPolicy(name, raw); err != nil {
+			problems = append(problems, "rate_limit:policies: "+err.Error())
+		}
+	}
+	for digest := range s.Hashes["auth:api_keys"] {
+		if len(digest) != 64 || strings.Trim(strings.ToLower(digest), "0123456789abcdef") != "" {
+			problems = append(problems, fmt.Sprintf("auth:api_keys field %q is not a hex SHA-256 digest", digest))
+		}
+	}
+	if mode := s.Hashes["auth:config"]["mode"]; mode != "" && mode != AuthModeAPIKey {
+		problems = append(problems, fmt.Sprintf("auth:config.mode %q must be empty or %s", mode, AuthModeAPIKey))
+	}
+	if mode := s.Hashes["waf:config"]["mode"]; mode != "" && mode != "blocklist" && mode != "allowlist" {
+		problems = append(problems, fmt.Sprintf("waf:config.mode %q must be blocklist or allowlist", mode))
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// ImportSnapshot validates s and replaces every snapshot key in one MULTI/EXEC,
+// so Redis never holds a mix of old and new config. Keys absent from s are
+// deleted. The replaced config is kept in the version history and gateways
+// are notified through config:changed. ifVersion is the version the caller
+// last read, or AnyVersion. It returns the new config version.
+func (r *RedisStore) ImportSnapshot(s *ConfigSnapshot, ifVersion int64) (int64, error) {
+	if r == nil {
+		return 0, ErrRedisNotEnabled
+	}
+	if problems := s.Validate(); len(problems) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidSnapshot, strings.Join(problems, "; "))
+	}
+
+	version, err := r.writeVersioned(ifVersion, ConfigUpdate{Type: "business"}, func(pipe redis.Pipeliner) {
+		for _, key := range snapshotHashes {
+			pipe.Del(r.ctx, r.prefix+key)
+			if fields := s.Hashes[key]; len(fields) > 0 {
+				pipe.HSet(r.ctx, r.prefix+key, fields)
+			}
+		}
+		for _, key := range snapshotSets {
+			pipe.Del(r.ctx, r.prefix+key)
+			if members := s.Sets[key]; len(members) > 0 {
+				args := make([]interface{}, len(members))
+				for i, m := range members {
+					args[i] = m
+				}
+				pipe.SAdd(r.ctx, r.prefix+key, args...)
+			}
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to import config: %w", err)
+	}
+	xlog.Infof("Config snapshot imported: version=%d, hashes=%d, sets=%d", version, len(s.Hashes), len(s.Sets))
+	return version, nil
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// configHistoryLimit is the number of replaced configs kept for rollback
+	configHistoryLimit = 20
+	// AnyVersion skips the version check of a write
+	AnyVersion int64 = -1
+)
+
+var (
+	ErrVersionNotFound  = errors.New("config version not found in history")
+	ErrVersionConflict  = errors.New("config version does not match")
+	ErrConcurrentUpdate = errors.New("config version changed during update, retry")
+)
+
+// ConfigVersion is a history entry: the config that was live at Version,
+// captured when a later write replaced it
+type ConfigVersion struct {
+	Version    int64           `json:"version"`
+	ReplacedAt time.Time       `json:"replaced_at"`
+	Snapshot   *ConfigSnapshot `json:"snapshot,omitempty"`
+}
+
+// GetConfigVersion returns the current config version in Redis (0 before the first versioned write)
+func (r *RedisStore) GetConfigVersion() (int64, error) {
+	if r == nil {
+		return 0, ErrRedisNotEnabled
+	}
+	version, err := r.client.Get(r.ctx, r.prefix+"config:version").Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// AppliedVersion returns the latest config version this gateway has seen,
+// from startup or from config:changed notifications
+func (r *RedisStore) AppliedVersion() int64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.version)
+}
+
+// ListConfigVersions returns the rollback history, newest first, without snapshots
+func (r *RedisStore) ListConfigVersions() ([]ConfigVersion, error) {
+	history, err := r.loadHistory()
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		history[i].Snapshot = nil
+	}
+	return history, nil
+}
+
+// RollbackTo restores the config that was live at version. The rollback is a
+// write like any other: it gets a new version and the current config is kept.
+// ifVersion is the version the caller last read, or AnyVersion.
+func (r *RedisStore) RollbackTo(version, ifVersion int64) (int64, error) {
+	history, err := r.loadHistory()
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range history {
+		if entry.Version != version || entry.Snapshot == nil {
+			continue
+		}
+		newVersion, err := r.ImportSnapshot(entry.Snapshot, ifVersion)
+		if err != nil {
+			return 0, err
+		}
+		xlog.Infof("Config rolled back to version %d as version %d", version, newVersion)
+		return newVersion, nil
+	}
+	return 0, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+}
+
+func (r *RedisStore) loadHistory() ([]ConfigVersion, error) {
+	if r == nil {
+		return nil, ErrRedisNotEnabled
+	}
+	raw, err := r.client.LRange(r.ctx, r.prefix+"config:history", 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config history: %w", err)
+	}
+	history := make([]ConfigVersion, 0, len(raw))
+	for _, item := range raw {
+		var entry ConfigVersion
+		if err := json.Unmarshal([]byte(item), &entry); err != nil {
+			xlog.Warnf("Skipping unreadable config history entry: %v", err)
+			continue
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
+// writeVersioned runs write in a MULTI/EXEC that also bumps config:version,
+// pushes the replaced config onto config:history and publishes update with
+// the new version.
+// It fails with ErrVersionConflict unless the current version is ifVersion
+// (or ifVersion is AnyVersion), and WATCH on the version key makes concurrent
+// versioned writes fail instead of interleaving. It returns the new version.
+func (r *RedisStore) writeVersioned(ifVersion int64, update ConfigUpdate, write func(redis.Pipeliner)) (int64, error) {
+	versionKey := r.prefix + "config:version"
+	historyKey := r.prefix + "config:history"
+
+	var version int64
+	err := r.client.Watch(r.ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(r.ctx, versionKey).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if ifVersion != AnyVersion && current != ifVersion {
+			return fmt.Errorf("%w: expected %d, current %d", ErrVersionConflict, ifVersion, current)
+		}
+		replaced, err := r.readSnapshot(tx.Pipelined)
+		if err != nil {
+			return err
+		}
+		entry, err := json.Marshal(ConfigVersion{Version: current, ReplacedAt: time.Now().UTC(), Snapshot: replaced})
+		if err != nil {
+			return err
+		}
+		version = current + 1
+		update.Version = version
+		notice, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+			write(pipe)
+			pipe.Set(r.ctx, versionKey, version, 0)
+			pipe.LPush(r.ctx, historyKey, entry)
+			pipe.LTrim(r.ctx, historyKey, 0, configHistoryLimit-1)
+			pipe.Publish(r.ctx, r.prefix+"config:changed", notice)
+			return nil
+		})
+		return err
+	}, versionKey)
+	if errors.Is(err, redis.TxFailedErr) {
+		return 0, ErrConcurrentUpdate
+	}
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/api"
+)
+
+// connInfo is what /admin/connections reports about a tracked connection
+// beyond its byte counters. It is written once or twice per connection
+// (sniff, backend choice), so a per-connection mutex never contends with the
+// data path, which only touches the atomic counters.
+type connInfo struct {
+	mu       sync.Mutex
+	client   string
+	backend  string
+	protocol ProtocolType
+	tls      bool // Terminated by the listener
+}
+
+// Read counts bytes received from the client
+func (c *trackedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.bytesIn.Add(int64(n))
+	return n, err
+}
+
+// Write counts bytes sent to the client
+func (c *trackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.bytesOut.Add(int64(n))
+	return n, err
+}
+
+// AddBytes counts bytes moved without passing through Read and Write (the TCP
+// proxy splicing the underlying sockets)
+func (c *trackedConn) AddBytes(in, out int64) {
+	c.bytesIn.Add(in)
+	c.bytesOut.Add(out)
+}
+
+// setClient records the client address from a PROXY protocol header
+func (c *trackedConn) setClient(addr net.Addr) {
+	c.info.mu.Lock()
+	c.info.client = addr.String()
+	c.info.mu.Unlock()
+}
+
+// setProtocol records the protocol the connection was dispatched as
+func (c *trackedConn) setProtocol(proto ProtocolType, tls bool) {
+	c.info.mu.Lock()
+	c.info.protocol = proto
+	c.info.tls = tls
+	c.info.mu.Unlock()
+}
+
+// SetBackend records the backend the connection is proxied to (the last one
+// for HTTP keep-alive connections). See middleware.SetConnBackend.
+func (c *trackedConn) SetBackend(addr string) {
+	c.info.mu.Lock()
+	c.info.backend = addr
+	c.info.mu.Unlock()
+}
+
+func (c *trackedConn) snapshot() api.ConnectionInfo {
+	c.info.mu.Lock()
+	info := api.ConnectionInfo{
+		Listener: c.listener,
+		Client:   c.info.client,
+		Backend:  c.info.backend,
+		Protocol: c.info.protocol.String(),
+		TLS:      c.info.tls,
+	}
+	c.info.mu.Unlock()
+	if info.Client == "" {
+		info.Client = c.RemoteAddr().String()
+	}
+	info.Start = c.start
+	info.BytesIn = c.bytesIn.Load()
+	info.BytesOut = c.bytesOut.Load()
+	return info
+}
+
+// Connections returns up to limit open client connections, oldest first,
+// optionally only those dispatched as protocol, and the number that matched.
+// The registry lock is only held to copy the connection pointers.
+func (l *Listener) Connections(protocol string, limit int) ([]api.ConnectionInfo, int) {
+	l.connsMu.Lock()
+	conns := make([]*trackedConn, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.connsMu.Unlock()
+
+	infos := make([]api.ConnectionInfo, 0, len(conns))
+	for _, c := range conns {
+		info := c.snapshot()
+		if protocol != "" && info.Protocol != protocol {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Start.Before(infos[j].Start) })
+	matched := len(infos)
+	if limit > 0 && len(infos) > limit {
+		infos = infos[:limit]
+	}
+	return infos, matched
+}
@@ -1,43 +1,130 @@
 package core
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"reflect"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/healthcheck"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
 	httpproxy "github.com/SkynetNext/unified-access-gateway/internal/protocol/http"
 	tcpproxy "github.com/SkynetNext/unified-access-gateway/internal/protocol/tcp"
 	"github.com/SkynetNext/unified-access-gateway/internal/security"
+	"github.com/SkynetNext/unified-access-gateway/internal/sockopt"
+	"github.com/SkynetNext/unified-access-gateway/pkg/ebpf"
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 )
 
+// acceptPause is how long an accept loop waits for a free handler slot for an
+// accepted connection before rejecting it
+const acceptPause = 100 * time.Millisecond
+
 type Listener struct {
-	address  string
-	listener net.Listener
+	specs []config.ListenerSpec // server.listen_addr and server.listeners.*
 
 	cfg      *config.Config
 	security *security.Manager
 
 	httpHandler *httpproxy.Handler
 	tcpHandler  *tcpproxy.Handler
+	tls         *tlsTerminator // nil unless a listener terminates TLS
+	sni         *sniRouter     // TLS passthrough by server name
+
+	endpointsMu sync.Mutex
+	endpoints   []*endpoint // Open listen sockets, one accept loop each
+
+	activeConns    int64 // Atomic: currently open client connections (all listeners)
+	maxConnections int64 // Atomic: 0 = unlimited
+
+	handlerSlots     chan struct{} // Semaphore for handleConn goroutines (nil = unlimited)
+	inflightHandlers int64         // Atomic: running handleConn goroutines
+
+	connsMu sync.Mutex
+	conns   map[*trackedConn]struct{} // Open client connections, force-closed after drain
 }
 
-func NewListener(cfg *config.Config, sec *security.Manager) *Listener {
+// endpoint is one listen address. Handlers, the connection limit and drain
+// tracking are shared by all endpoints of a Listener.
+type endpoint struct {
+	spec        config.ListenerSpec
+	protocol    ProtocolType   // Fixed protocol, ProtocolUnknown = sniff
+	listeners   []net.Listener // SO_REUSEPORT shards of one address, one accept loop each
+	activeConns int64          // Atomic: open client connections accepted here
+	acceptLoops int32          // Atomic: running accept loops, len(listeners) when healthy
+}
+
+func NewListener(cfg *config.Config, sec *security.Manager, store *config.RedisStore, health *healthcheck.UpstreamHealthChecker) *Listener {
 	l := &Listener{
-		address:  cfg.Server.ListenAddr,
-		cfg:      cfg,
-		security: sec,
+		specs:          cfg.Server.ListenSpecs(),
+		cfg:            cfg,
+		security:       sec,
+		maxConnections: int64(cfg.Server.MaxConnections),
+		conns:          make(map[*trackedConn]struct{}),
+	}
+	if cfg.Server.MaxHandlers > 0 {
+		l.handlerSlots = make(chan struct{}, cfg.Server.MaxHandlers)
 	}
 
 	// Create handlers (may return nil if config is missing)
-	l.httpHandler = httpproxy.NewHandler(cfg, sec)
-	l.tcpHandler = tcpproxy.NewHandler(cfg, sec)
+	l.httpHandler = httpproxy.NewHandler(cfg, sec, store, health)
+	l.tcpHandler = tcpproxy.NewHandler(cfg, sec, store, health)
+
+	l.sni = newSNIRouter(cfg.Backends.TLSPassthrough)
+	if store != nil {
+		go l.sni.watch(store)
+		go l.watchListeners(store)
+	}
 
 	return l
 }
 
+// watchListeners applies server.max_connections changes from Redis and logs
+// that a restart is required when the listen addresses change: rebinding would
+// drop connections, so the running listeners are kept
+func (l *Listener) watchListeners(store *config.RedisStore) {
+	// Only a changed server.max_connections is applied, so unrelated reloads do
+	// not undo a limit set through the admin API
+	maxConns := l.cfg.Server.MaxConnections
+	for update := range store.Subscribe() {
+		if !update.Is("business") {
+			continue
+		}
+		businessCfg, err := store.LoadBusinessConfig()
+		if err != nil {
+			xlog.Warnf("Failed to reload listener config from Redis: %v", err)
+			continue
+		}
+		if businessCfg.Server.MaxConnections != maxConns {
+			maxConns = businessCfg.Server.MaxConnections
+			l.SetMaxConnections(int64(maxConns))
+		}
+		if specs := businessCfg.Server.ListenSpecs(); !reflect.DeepEqual(specs, l.specs) {
+			xlog.Warnf("Listeners changed in Redis (now %s), restart required to apply; still serving %s",
+				listenAddrs(specs), listenAddrs(l.specs))
+		}
+	}
+}
+
+// listenAddrs formats specs as name=addr pairs for logs
+func listenAddrs(specs []config.ListenerSpec) string {
+	addrs := make([]string, len(specs))
+	for i, spec := range specs {
+		addrs[i] = spec.Name + "=" + spec.Addr
+	}
+	return strings.Join(addrs, ",")
+}
+
+// Start opens every listen address and starts their accept loops. If any
+// address cannot be opened, the ones already opened are closed again.
 func (l *Listener) Start() error {
 	// Check if handlers are properly initialized
 	if l.httpHandler == nil && l.tcpHandler == nil {
@@ -45,95 +132,541 @@ func (l *Listener) Start() error {
 		return fmt.Errorf("no handlers available")
 	}
 
-	if l.address == "" {
+	if len(l.specs) == 0 {
 		xlog.Errorf("CRITICAL: server.listen_addr is not configured")
 		return fmt.Errorf("listen address not configured")
 	}
 
-	var err error
-	l.listener, err = net.Listen("tcp", l.address)
-	if err != nil {
-		return err
+	protocols := make([]ProtocolType, len(l.specs))
+	for i, spec := range l.specs {
+		proto, err := parseListenerProtocol(spec.Protocol)
+		if err != nil {
+			xlog.Errorf("CRITICAL: listener %s: %v", spec.Name, err)
+			return fmt.Errorf("listener %s: %w", spec.Name, err)
+		}
+		protocols[i] = proto
+	}
+
+	for _, spec := range l.specs {
+		if !spec.TLS {
+			continue
+		}
+		t, err := newTLSTerminator(l.cfg.Server.TLS)
+		if err != nil {
+			xlog.Errorf("CRITICAL: TLS termination misconfigured: %v", err)
+			return fmt.Errorf("tls: %w", err)
+		}
+		l.tls = t
+		break
+	}
+
+	shards := acceptShards(l.cfg.Server.AcceptShards)
+	buf := l.cfg.Server.Buffers
+	buffers := sockopt.Buffers(buf.SocketRecv, buf.SocketSend)
+	if (buf.SocketRecv > 0 || buf.SocketSend > 0) && !sockopt.BuffersSupported {
+		xlog.Warnf("server.buffers.socket_recv/socket_send are only applied on Linux, using default socket buffers")
+	}
+	if l.cfg.Server.KeepAlive.Interval > 0 && !sockopt.KeepAliveIntervalSupported {
+		xlog.Warnf("server.keepalive.interval is only applied on Linux, using the platform's probe interval")
+	}
+	endpoints := make([]*endpoint, 0, len(l.specs))
+	for i, spec := range l.specs {
+		listeners, err := listenShards(spec.Addr, shards, buffers)
+		if err != nil {
+			for _, ep := range endpoints {
+				ep.close()
+			}
+			if l.tls != nil {
+				l.tls.stop()
+			}
+			return fmt.Errorf("listener %s: %w", spec.Name, err)
+		}
+		endpoints = append(endpoints, &endpoint{spec: spec, protocol: protocols[i], listeners: listeners, acceptLoops: int32(len(listeners))})
 	}
 
-	xlog.Infof("Gateway listening on %s", l.address)
+	l.endpointsMu.Lock()
+	l.endpoints = endpoints
+	l.endpointsMu.Unlock()
 
-	go l.acceptLoop()
+	for _, ep := range endpoints {
+		protocol := ep.spec.Protocol
+		if ep.protocol == ProtocolUnknown {
+			protocol = "sniff"
+		}
+		xlog.Infof("Gateway listening on %s [%s] (max_connections=%d, proxy_protocol=%v, tls=%v, protocol=%s, accept_shards=%d)",
+			ep.spec.Addr, ep.spec.Name, l.MaxConnections(), ep.spec.ProxyProtocol, ep.spec.TLS, protocol, len(ep.listeners))
+		for _, ln := range ep.listeners {
+			go l.acceptLoop(ep, ln)
+		}
+	}
 	return nil
 }
 
+// acceptShards resolves server.accept_shards: 0 means GOMAXPROCS, and
+// platforms without SO_REUSEPORT always use a single listener
+func acceptShards(n int) int {
+	if !reusePortSupported {
+		return 1
+	}
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// listenShards opens n sockets on addr, each set up by control (may be nil).
+// With n > 1 every socket sets SO_REUSEPORT; shards after the first bind the
+// first one's resolved address, so a :0 port is shared too. Accepted
+// connections get server.keepalive in handleConn, not Go's default keep-alive.
+func listenShards(addr string, n int, control sockopt.Control) ([]net.Listener, error) {
+	if n <= 1 {
+		lc := net.ListenConfig{Control: control, KeepAlive: -1}
+		ln, err := lc.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{ln}, nil
+	}
+	lc := net.ListenConfig{Control: sockopt.Chain(reusePortControl, control), KeepAlive: -1}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		ln, err := lc.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			for _, open := range listeners {
+				open.Close()
+			}
+			return nil, err
+		}
+		if i == 0 {
+			addr = ln.Addr().String()
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// close closes every shard of the endpoint
+func (ep *endpoint) close() {
+	for _, ln := range ep.listeners {
+		ln.Close()
+	}
+}
+
+// Stop closes every listen socket; open connections are left to drain
 func (l *Listener) Stop() {
-	if l.listener != nil {
-		l.listener.Close()
+	l.endpointsMu.Lock()
+	for _, ep := range l.endpoints {
+		ep.close()
+	}
+	l.endpointsMu.Unlock()
+	if l.tcpHandler != nil {
+		l.tcpHandler.Close()
+	}
+	if l.tls != nil {
+		l.tls.stop()
 	}
 }
 
-func (l *Listener) acceptLoop() {
+// ShutdownHTTP gracefully shuts down open HTTP connections (see
+// httpproxy.Handler.Shutdown), returning when they have closed or ctx expires
+func (l *Listener) ShutdownHTTP(ctx context.Context) error {
+	if l.httpHandler == nil {
+		return nil
+	}
+	return l.httpHandler.Shutdown(ctx)
+}
+
+// CheckDataPlane returns an error unless every listen address is bound and all
+// of its accept loops are still running (an accept loop exits on a permanent
+// accept error, leaving its socket black-holing connections)
+func (l *Listener) CheckDataPlane() error {
+	l.endpointsMu.Lock()
+	defer l.endpointsMu.Unlock()
+	if len(l.endpoints) == 0 {
+		return fmt.Errorf("no listen address bound")
+	}
+	for _, ep := range l.endpoints {
+		if n := atomic.LoadInt32(&ep.acceptLoops); int(n) < len(ep.listeners) {
+			return fmt.Errorf("listener %s: %d of %d accept loops running", ep.spec.Name, n, len(ep.listeners))
+		}
+	}
+	return nil
+}
+
+// ActiveConnections returns the number of currently open client connections
+func (l *Listener) ActiveConnections() int64 {
+	return atomic.LoadInt64(&l.activeConns)
+}
+
+// ListenerConnections returns the open client connections per listener name
+func (l *Listener) ListenerConnections() map[string]int64 {
+	l.endpointsMu.Lock()
+	defer l.endpointsMu.Unlock()
+	counts := make(map[string]int64, len(l.endpoints))
+	for _, ep := range l.endpoints {
+		counts[ep.spec.Name] = atomic.LoadInt64(&ep.activeConns)
+	}
+	return counts
+}
+
+// SockMapStats returns eBPF sockmap counters for the TCP proxy
+func (l *Listener) SockMapStats() ebpf.SockMapStats {
+	return l.tcpHandler.SockMapStats()
+}
+
+// SetEBPFEnabled turns eBPF acceleration of the TCP proxy on or off at runtime
+func (l *Listener) SetEBPFEnabled(enabled bool, cgroupPath string) error {
+	return l.tcpHandler.SetEBPFEnabled(enabled, cgroupPath)
+}
+
+// SetDiscoveredTargets spreads the default HTTP or TCP backend (by proto)
+// across targets (host:port) found by service discovery
+func (l *Listener) SetDiscoveredTargets(proto ProtocolType, targets []string) {
+	switch {
+	case proto == ProtocolHTTP && l.httpHandler != nil:
+		l.httpHandler.SetDiscoveredTargets(targets)
+	case proto == ProtocolTCP && l.tcpHandler != nil:
+		l.tcpHandler.SetDiscoveredTargets(targets)
+	}
+}
+
+// MaxConnections returns the current connection limit (0 = unlimited)
+func (l *Listener) MaxConnections() int64 {
+	return atomic.LoadInt64(&l.maxConnections)
+}
+
+// SetMaxConnections updates the connection limit at runtime (0 = unlimited).
+// Existing connections above a lowered limit are not closed.
+func (l *Listener) SetMaxConnections(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&l.maxConnections, n)
+	xlog.Infof("Max connections updated: %d", n)
+}
+
+// acquireSlot reserves a connection slot, returns false if the limit is reached
+func (l *Listener) acquireSlot() bool {
 	for {
-		conn, err := l.listener.Accept()
+		current := atomic.LoadInt64(&l.activeConns)
+		max := atomic.LoadInt64(&l.maxConnections)
+		if max > 0 && current >= max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&l.activeConns, current, current+1) {
+			middleware.SetListenerConnections(current + 1)
+			return true
+		}
+	}
+}
+
+func (l *Listener) releaseSlot() {
+	middleware.SetListenerConnections(atomic.AddInt64(&l.activeConns, -1))
+}
+
+// acquireHandler reserves a handler goroutine slot, waiting up to acceptPause
+// when all are busy. It returns false if the handlers stayed saturated.
+func (l *Listener) acquireHandler() bool {
+	if l.handlerSlots == nil {
+		return true
+	}
+	select {
+	case l.handlerSlots <- struct{}{}:
+		return true
+	default:
+	}
+	timer := time.NewTimer(acceptPause)
+	defer timer.Stop()
+	select {
+	case l.handlerSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// releaseHandler frees a slot reserved by acquireHandler
+func (l *Listener) releaseHandler() {
+	if l.handlerSlots != nil {
+		<-l.handlerSlots
+	}
+}
+
+// runHandler serves c on the reserved handler slot, counted as in flight
+func (l *Listener) runHandler(c *trackedConn, ep *endpoint) {
+	middleware.SetInflightHandlers(atomic.AddInt64(&l.inflightHandlers, 1))
+	defer func() {
+		middleware.SetInflightHandlers(atomic.AddInt64(&l.inflightHandlers, -1))
+		l.releaseHandler()
+	}()
+	l.handleConn(c, ep)
+}
+
+// InflightHandlers returns the number of running connection handlers
+func (l *Listener) InflightHandlers() int64 {
+	return atomic.LoadInt64(&l.inflightHandlers)
+}
+
+// acceptLoop accepts connections on one shard of an endpoint. Each accepted
+// connection waits up to acceptPause for a handler slot and is rejected if none
+// frees up. The loop does not accept while it waits, so with saturated
+// handlers new connections queue in the kernel backlog instead of becoming
+// goroutines, and the backlog drains at one rejection per acceptPause.
+func (l *Listener) acceptLoop(ep *endpoint, ln net.Listener) {
+	defer atomic.AddInt32(&ep.acceptLoops, -1)
+	for {
+		conn, err := ln.Accept()
 		if err != nil {
 			// Check if listener was closed (normal shutdown during graceful shutdown)
 			errStr := err.Error()
 			if strings.Contains(errStr, "use of closed network connection") ||
 				strings.Contains(errStr, "operation on closed") {
 				// Listener was closed, exit gracefully (this is expected during shutdown)
-				xlog.Infof("Listener closed, exiting accept loop")
+				xlog.Infof("Listener %s closed, exiting accept loop", ep.spec.Name)
 				return
 			}
-			
+
 			// Check for temporary errors (network issues, can retry)
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Temporary() {
 				xlog.Warnf("Temporary accept error: %v", err)
 				continue
 			}
-			
+
 			// Other permanent errors
-			xlog.Errorf("Accept error: %v", err)
+			xlog.Errorf("Listener %s accept error: %v", ep.spec.Name, err)
 			return
 		}
 
-		go l.handleConn(conn)
+		// Decided after Accept so the wait reflects the handlers when the
+		// connection arrived, not when the previous one did
+		if !l.acquireHandler() {
+			l.rejectConn(conn, "handlers_saturated", fmt.Sprintf("connection handlers saturated (%d)", cap(l.handlerSlots)))
+			continue
+		}
+
+		if !l.acquireSlot() {
+			l.releaseHandler()
+			l.rejectConn(conn, "max_connections", fmt.Sprintf("max connections reached (%d)", l.MaxConnections()))
+			continue
+		}
+
+		tc := &trackedConn{Conn: conn, listener: ep.spec.Name, start: time.Now()}
+		atomic.AddInt64(&ep.activeConns, 1)
+		tc.release = func() {
+			l.untrack(tc)
+			l.releaseSlot()
+			atomic.AddInt64(&ep.activeConns, -1)
+		}
+		l.track(tc)
+		go l.runHandler(tc, ep)
+	}
+}
+
+func (l *Listener) track(c *trackedConn) {
+	l.connsMu.Lock()
+	l.conns[c] = struct{}{}
+	l.connsMu.Unlock()
+}
+
+func (l *Listener) untrack(c *trackedConn) {
+	l.connsMu.Lock()
+	delete(l.conns, c)
+	l.connsMu.Unlock()
+}
+
+// CloseActive force-closes every open client connection and returns how many were closed.
+// Used at the end of the drain phase for long-lived connections that did not finish.
+func (l *Listener) CloseActive() int {
+	l.connsMu.Lock()
+	conns := make([]*trackedConn, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
 	}
+	l.connsMu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+	return len(conns)
+}
+
+// rejectConn closes a connection that was refused before dispatch, auditing the reason
+func (l *Listener) rejectConn(c net.Conn, reason, detail string) {
+	xlog.Warnf("Connection %s rejected: %s", c.RemoteAddr(), detail)
+	middleware.RecordConnectionRejected(reason)
+	if l.security != nil {
+		l.security.AuditTCP("", c.RemoteAddr().String(), "", false, detail)
+	}
+	c.Close()
+}
+
+// trackedConn releases its listener slot exactly once when closed.
+// Handlers may outlive handleConn (HTTP serves on its own goroutine), so the
+// slot is tied to the connection's Close rather than to handleConn returning.
+// It also carries what /admin/connections reports (see connections.go).
+type trackedConn struct {
+	net.Conn
+	release   func()
+	closeOnce sync.Once
+
+	listener string
+	start    time.Time
+	bytesIn  atomic.Int64 // Read from the client
+	bytesOut atomic.Int64 // Written to the client
+	info     connInfo
 }
 
-func (l *Listener) handleConn(c net.Conn) {
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.release)
+	return err
+}
+
+// Unwrap returns the underlying net.Conn for eBPF socket cookie extraction
+func (c *trackedConn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+// handleConn serves one client connection with the options of the listener
+// that accepted it
+func (l *Listener) handleConn(c *trackedConn, ep *endpoint) {
+	spec := ep.spec
+	ka := l.cfg.Server.KeepAlive
+	if err := sockopt.SetKeepAlive(c.Conn, ka.Idle, ka.Interval); err != nil {
+		xlog.Debugf("Conn %s: TCP keep-alive not set: %v", c.RemoteAddr(), err)
+	}
+
+	// 1. Wrap connection (Support Peek)
+	sniffConn := NewSniffConn(c, l.cfg.Server.SniffTimeout, l.cfg.Server.Buffers.ReadSize)
+
+	// PROXY protocol header must be consumed before sniffing; it also
+	// provides the real client address used by WAF and audit below
+	if spec.ProxyProtocol {
+		if err := sniffConn.ReadProxyHeader(); err != nil {
+			l.rejectConn(c, "proxy_protocol", fmt.Sprintf("invalid PROXY protocol header: %v", err))
+			return
+		}
+		c.setClient(sniffConn.RemoteAddr())
+	}
+
 	if l.security != nil {
-		if err := l.security.CheckConnection(c.RemoteAddr()); err != nil {
-			xlog.Warnf("Connection %s rejected: %v", c.RemoteAddr(), err)
-			l.security.AuditTCP(c.RemoteAddr().String(), "", false, err.Error())
+		if err := l.security.CheckConnection(sniffConn.RemoteAddr()); err != nil {
+			xlog.Warnf("Connection %s rejected: %v", sniffConn.RemoteAddr(), err)
+			l.security.AuditTCP("", sniffConn.RemoteAddr().String(), "", false, err.Error())
 			c.Close()
 			return
 		}
 	}
-	// 1. Wrap connection (Support Peek)
-	sniffConn := NewSniffConn(c)
 
-	// 2. Sniff protocol (Magic Bytes)
-	proto := sniffConn.Sniff()
+	// 2. Sniff protocol (Magic Bytes), unless the listener has a fixed
+	// protocol. Fixed TLS still reads the ClientHello for SNI routing.
+	proto := ep.protocol
+	switch proto {
+	case ProtocolUnknown:
+		proto = sniffConn.Sniff()
+	case ProtocolTLS:
+		sniffConn.SniffTLS()
+	}
+
+	// Per-connection TLS decision: SNI routes are forwarded undecrypted,
+	// anything else is terminated here and the decrypted stream sniffed again
+	if proto == ProtocolTLS {
+		if backend := l.sni.match(sniffConn.SNI()); backend != "" {
+			c.setProtocol(ProtocolTLS, false)
+			l.passthroughTLS(sniffConn, backend)
+			return
+		}
+		if spec.TLS && l.tls != nil {
+			l.terminateTLS(sniffConn, c)
+			return
+		}
+	}
 
 	// 3. Dispatch
+	c.setProtocol(proto, false)
+	l.dispatch(sniffConn, proto)
+}
+
+// dispatch hands a sniffed connection to the handler for its protocol
+func (l *Listener) dispatch(conn net.Conn, proto ProtocolType) {
 	switch proto {
 	case ProtocolHTTP:
 		if l.httpHandler == nil {
-			xlog.Warnf("Conn %s -> HTTP but handler not configured, closing", c.RemoteAddr())
-			c.Close()
+			xlog.Warnf("Conn %s -> HTTP but handler not configured, closing", conn.RemoteAddr())
+			conn.Close()
+			return
+		}
+		xlog.Debugf("Conn %s -> HTTP", conn.RemoteAddr())
+		l.httpHandler.ServeConn(conn)
+
+	case ProtocolHTTP2:
+		if l.httpHandler == nil {
+			xlog.Warnf("Conn %s -> h2c but HTTP handler not configured, closing", conn.RemoteAddr())
+			conn.Close()
 			return
 		}
-		xlog.Debugf("Conn %s -> HTTP", c.RemoteAddr())
-		l.httpHandler.ServeConn(sniffConn)
+		xlog.Debugf("Conn %s -> h2c", conn.RemoteAddr())
+		l.httpHandler.ServeH2C(conn)
 
 	case ProtocolTCP:
 		if l.tcpHandler == nil {
-			xlog.Warnf("Conn %s -> TCP but handler not configured, closing", c.RemoteAddr())
-			c.Close()
+			xlog.Warnf("Conn %s -> TCP but handler not configured, closing", conn.RemoteAddr())
+			conn.Close()
 			return
 		}
-		xlog.Debugf("Conn %s -> TCP", c.RemoteAddr())
-		l.tcpHandler.Handle(sniffConn)
+		xlog.Debugf("Conn %s -> TCP", conn.RemoteAddr())
+		l.tcpHandler.Handle(conn)
 
 	default:
-		xlog.Warnf("Conn %s -> Unknown Protocol, closing", c.RemoteAddr())
-		c.Close()
+		xlog.Warnf("Conn %s -> Unknown Protocol, closing", conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
+// passthroughTLS forwards the raw TLS stream to backend. The peeked ClientHello
+// is still buffered in sniffConn, so the backend sees the handshake intact.
+func (l *Listener) passthroughTLS(sniffConn *SniffConn, backend string) {
+	if l.tcpHandler == nil {
+		xlog.Warnf("Conn %s -> TLS passthrough but TCP handler not configured, closing", sniffConn.RemoteAddr())
+		sniffConn.Close()
+		return
 	}
+	xlog.Debugf("Conn %s -> TLS passthrough (SNI=%q) to %s", sniffConn.RemoteAddr(), sniffConn.SNI(), backend)
+	l.tcpHandler.HandleTo(sniffConn, backend)
+}
+
+// terminateTLS completes the TLS handshake and dispatches the decrypted stream.
+// The eBPF sockmap cannot splice TLS, so TCP proxying of these connections
+// always stays in userspace.
+func (l *Listener) terminateTLS(sniffConn *SniffConn, tc *trackedConn) {
+	tlsConn := tls.Server(sniffConn, l.tls.serverConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+	err := tlsConn.HandshakeContext(ctx)
+	cancel()
+	if err != nil {
+		l.rejectConn(sniffConn, "tls_handshake", fmt.Sprintf("TLS handshake failed: %v", err))
+		return
+	}
+
+	inner := &tlsSniffConn{SniffConn: NewSniffConn(tlsConn, l.cfg.Server.SniffTimeout, l.cfg.Server.Buffers.ReadSize), tlsConn: tlsConn}
+	proto := inner.Sniff()
+	if proto == ProtocolTLS {
+		// TLS inside TLS is not supported
+		proto = ProtocolUnknown
+	}
+	tc.setProtocol(proto, true)
+	l.dispatch(inner, proto)
+}
+
+// tlsSniffConn is a SniffConn over a terminated TLS connection. It exposes the
+// handshake state so the HTTP handler can populate r.TLS (client certificates).
+type tlsSniffConn struct {
+	*SniffConn
+	tlsConn *tls.Conn
+}
+
+// ConnectionState returns the TLS state of the terminated connection
+func (c *tlsSniffConn) ConnectionState() tls.ConnectionState {
+	return c.tlsConn.ConnectionState()
 }
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProtocolMatcher detects a protocol from the first bytes of a connection.
+// peek holds up to the matcher's declared peek length; it is shorter only if
+// the client closed its side first.
+type ProtocolMatcher interface {
+	Match(peek []byte) (ProtocolType, bool)
+}
+
+// ProtocolMatcherFunc adapts a function to ProtocolMatcher
+type ProtocolMatcherFunc func(peek []byte) (ProtocolType, bool)
+
+// Match calls f(peek)
+func (f ProtocolMatcherFunc) Match(peek []byte) (ProtocolType, bool) {
+	return f(peek)
+}
+
+// registeredMatcher is a matcher with its ordering and peek requirements
+type registeredMatcher struct {
+	name     string
+	priority int
+	peekLen  int
+	matcher  ProtocolMatcher
+}
+
+var (
+	matchersMu sync.RWMutex
+	matchers   []registeredMatcher // Sorted by descending priority
+)
+
+// Built-in matcher priorities. TCP is the catch-all and runs last.
+const (
+	PriorityH2C  = 300
+	PriorityHTTP = 200
+	PriorityTLS  = 100
+	PriorityTCP  = 0
+)
+
+func init() {
+	RegisterMatcher("h2c", PriorityH2C, len(h2cPrefacePrefix), ProtocolMatcherFunc(matchH2C))
+	RegisterMatcher("http", PriorityHTTP, 5, ProtocolMatcherFunc(matchHTTP))
+	RegisterMatcher("tls", PriorityTLS, 1, ProtocolMatcherFunc(matchTLS))
+	RegisterMatcher("tcp", PriorityTCP, 2, ProtocolMatcherFunc(matchTCP))
+}
+
+// RegisterMatcher adds a protocol detector. Sniff runs matchers from highest
+// to lowest priority (ties in registration order) and peeks peekLen bytes for
+// each. Registering an existing name replaces that matcher.
+func RegisterMatcher(name string, priority, peekLen int, m ProtocolMatcher) error {
+	if m == nil {
+		return fmt.Errorf("protocol matcher %q is nil", name)
+	}
+	if peekLen <= 0 || peekLen > maxClientHelloPeek {
+		return fmt.Errorf("protocol matcher %q: peek length %d out of range (1-%d)", name, peekLen, maxClientHelloPeek)
+	}
+
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+	next := make([]registeredMatcher, 0, len(matchers)+1)
+	for _, rm := range matchers {
+		if rm.name != name {
+			next = append(next, rm)
+		}
+	}
+	next = append(next, registeredMatcher{name: name, priority: priority, peekLen: peekLen, matcher: m})
+	sort.SliceStable(next, func(i, j int) bool { return next[i].priority > next[j].priority })
+	matchers = next
+	return nil
+}
+
+// UnregisterMatcher removes a matcher by name, including built-ins
+func UnregisterMatcher(name string) {
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+	next := make([]registeredMatcher, 0, len(matchers))
+	for _, rm := range matchers {
+		if rm.name != name {
+			next = append(next, rm)
+		}
+	}
+	matchers = next
+}
+
+// registeredMatchers returns the current matchers in priority order.
+// The slice is replaced, never modified, so callers may iterate it unlocked.
+func registeredMatchers() []registeredMatcher {
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	return matchers
+}
+
+// h2cPrefacePrefix starts the HTTP/2 client preface "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n".
+// RFC 7540 reserves the PRI method for it, so five bytes are unambiguous and
+// short first packets are not held up; the HTTP/2 server checks the rest.
+const h2cPrefacePrefix = "PRI *"
+
+// matchH2C detects the HTTP/2 prior-knowledge preface
+func matchH2C(peek []byte) (ProtocolType, bool) {
+	if string(peek) == h2cPrefacePrefix {
+		return ProtocolHTTP2, true
+	}
+	return ProtocolUnknown, false
+}
+
+// matchHTTP detects HTTP/1.x request methods
+func matchHTTP(peek []byte) (ProtocolType, bool) {
+	head := string(peek)
+	if strings.HasPrefix(head, "GET") || strings.HasPrefix(head, "POST") ||
+		strings.HasPrefix(head, "PUT ") || strings.HasPrefix(head, "DELE") ||
+		strings.HasPrefix(head, "HEAD") || strings.HasPrefix(head, "HTTP") {
+		return ProtocolHTTP, true
+	}
+	return ProtocolUnknown, false
+}
+
+// matchTLS detects a TLS handshake record (0x16)
+func matchTLS(peek []byte) (ProtocolType, bool) {
+	if len(peek) > 0 && peek[0] == tlsRecordTypeHandshake {
+		return ProtocolTLS, true
+	}
+	return ProtocolUnknown, false
+}
+
+// matchTCP is the fallback for custom binary protocols (e.g. game traffic)
+func matchTCP(peek []byte) (ProtocolType, bool) {
+	if len(peek) < 2 {
+		return ProtocolUnknown, false
+	}
+	return ProtocolTCP, true
+}
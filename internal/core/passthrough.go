@@ -0,0 +1,75 @@
+package core
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// sniRouter maps TLS server names to passthrough backends.
+// Exact names win over wildcards; "*.example.com" matches one label only.
+type sniRouter struct {
+	mu     sync.RWMutex
+	routes map[string]string // lowercase server name or *.domain -> host:port
+}
+
+func newSNIRouter(routes []config.SNIRoute) *sniRouter {
+	r := &sniRouter{}
+	r.update(routes)
+	return r
+}
+
+// update replaces the routing table
+func (r *sniRouter) update(routes []config.SNIRoute) {
+	table := make(map[string]string, len(routes))
+	for _, rt := range routes {
+		if rt.ServerName == "" || rt.TargetAddr == "" {
+			continue
+		}
+		table[strings.ToLower(rt.ServerName)] = rt.TargetAddr
+	}
+	r.mu.Lock()
+	r.routes = table
+	r.mu.Unlock()
+}
+
+// match returns the passthrough backend for sni, or "" to handle the connection locally
+func (r *sniRouter) match(sni string) string {
+	if sni == "" {
+		return ""
+	}
+	sni = strings.ToLower(sni)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.routes) == 0 {
+		return ""
+	}
+	if target, ok := r.routes[sni]; ok {
+		return target
+	}
+	if i := strings.IndexByte(sni, '.'); i > 0 {
+		if target, ok := r.routes["*"+sni[i:]]; ok {
+			return target
+		}
+	}
+	return ""
+}
+
+// watch reloads the table when business config changes in Redis
+func (r *sniRouter) watch(store *config.RedisStore) {
+	for update := range store.Subscribe() {
+		if !update.Is("business", "sni_routes") {
+			continue
+		}
+		routes, err := store.LoadSNIRoutes()
+		if err != nil {
+			xlog.Warnf("Failed to reload SNI routes from Redis: %v", err)
+			continue
+		}
+		r.update(routes)
+		xlog.Infof("TLS passthrough routes updated: count=%d", len(routes))
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// proxyV1MaxLen is the maximum v1 header length including CRLF (spec section 2.1)
+	proxyV1MaxLen = 107
+	// proxyV2HeaderLen is signature (12) + ver/cmd (1) + family (1) + length (2)
+	proxyV2HeaderLen = 16
+
+	proxyV2CmdLocal = 0x0
+	proxyV2CmdProxy = 0x1
+
+	proxyV2FamInet  = 0x1
+	proxyV2FamInet6 = 0x2
+
+	// proxyHeaderTimeout bounds how long we wait for the LB to send the header
+	proxyHeaderTimeout = 2 * time.Second
+)
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errNoProxyHeader = errors.New("missing PROXY protocol header")
+)
+
+// ReadProxyHeader consumes a PROXY protocol v1 or v2 header and, if it carries
+// a client address, makes it the connection's effective RemoteAddr.
+// Must be called before Sniff. Any error means the peer is not a trusted LB
+// (or is broken) and the connection should be dropped.
+func (s *SniffConn) ReadProxyHeader() error {
+	s.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	defer s.Conn.SetReadDeadline(time.Time{})
+
+	prefix, err := s.r.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNoProxyHeader, err)
+	}
+	if bytes.Equal(prefix, proxyV1Prefix) {
+		return s.readProxyV1()
+	}
+
+	sig, err := s.r.Peek(len(proxyV2Signature))
+	if err != nil || !bytes.Equal(sig, proxyV2Signature) {
+		return errNoProxyHeader
+	}
+	return s.readProxyV2()
+}
+
+// RemoteAddr returns the client address from the PROXY header, if any
+func (s *SniffConn) RemoteAddr() net.Addr {
+	if s.remoteAddr != nil {
+		return s.remoteAddr
+	}
+	return s.Conn.RemoteAddr()
+}
+
+// readProxyV1 parses "PROXY TCP4|TCP6|UNKNOWN src dst sport dport\r\n"
+func (s *SniffConn) readProxyV1() error {
+	line, err := s.r.ReadSlice('\n')
+	if err != nil {
+		return fmt.Errorf("proxy v1: %w", err)
+	}
+	if len(line) > proxyV1MaxLen || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errors.New("proxy v1: malformed header line")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		// LB could not determine the source (e.g. health check), keep the socket address
+		return nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return fmt.Errorf("proxy v1: malformed header %q", line)
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return fmt.Errorf("proxy v1: invalid source address %q", fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return fmt.Errorf("proxy v1: invalid source port %q", fields[4])
+	}
+
+	s.remoteAddr = &net.TCPAddr{IP: ip, Port: int(port)}
+	return nil
+}
+
+// readProxyV2 parses the binary v2 header (signature already verified)
+func (s *SniffConn) readProxyV2() error {
+	header, err := s.r.Peek(proxyV2HeaderLen)
+	if err != nil {
+		return fmt.Errorf("proxy v2: %w", err)
+	}
+	verCmd, fam := header[12], header[13]
+	addrLen := int(binary.BigEndian.Uint16(header[14:16]))
+
+	if verCmd>>4 != 0x2 {
+		return fmt.Errorf("proxy v2: unsupported version %d", verCmd>>4)
+	}
+
+	// Addresses + TLVs; the length field is capped by the spec's uint16
+	payload, err := s.peek(proxyV2HeaderLen + addrLen)
+	if err != nil {
+		return fmt.Errorf("proxy v2: %w", err)
+	}
+	addrs := payload[proxyV2HeaderLen:]
+
+	var addr *net.TCPAddr
+	switch verCmd & 0x0F {
+	case proxyV2CmdLocal:
+		// Connection initiated by the LB itself (health check), keep the socket address
+	case proxyV2CmdProxy:
+		switch fam >> 4 {
+		case proxyV2FamInet:
+			if len(addrs) < 12 {
+				return errors.New("proxy v2: truncated IPv4 addresses")
+			}
+			addr = &net.TCPAddr{
+				IP:   net.IP(append([]byte(nil), addrs[0:4]...)),
+				Port: int(binary.BigEndian.Uint16(addrs[8:10])),
+			}
+		case proxyV2FamInet6:
+			if len(addrs) < 36 {
+				return errors.New("proxy v2: truncated IPv6 addresses")
+			}
+			addr = &net.TCPAddr{
+				IP:   net.IP(append([]byte(nil), addrs[0:16]...)),
+				Port: int(binary.BigEndian.Uint16(addrs[32:34])),
+			}
+		default:
+			// AF_UNSPEC / AF_UNIX: no routable source, keep the socket address
+		}
+	default:
+		return fmt.Errorf("proxy v2: unsupported command %d", verCmd&0x0F)
+	}
+
+	if _, err := s.r.Discard(proxyV2HeaderLen + addrLen); err != nil {
+		return fmt.Errorf("proxy v2: %w", err)
+	}
+	if addr != nil {
+		s.remoteAddr = addr
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package core
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortSupported reports whether listen sockets can be sharded with SO_REUSEPORT
+const reusePortSupported = true
+
+// reusePortControl sets SO_REUSEPORT so several sockets can bind one address;
+// the kernel then spreads incoming connections across them
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
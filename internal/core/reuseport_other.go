@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package core
+
+import "syscall"
+
+// reusePortSupported is false: accept sharding falls back to one listener
+const reusePortSupported = false
+
+var reusePortControl func(network, address string, c syscall.RawConn) error
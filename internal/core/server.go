@@ -2,64 +2,104 @@ package core
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/SkynetNext/unified-access-gateway/internal/api"
 	"github.com/SkynetNext/unified-access-gateway/internal/config"
 	"github.com/SkynetNext/unified-access-gateway/internal/healthcheck"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/internal/observability"
+	udpproxy "github.com/SkynetNext/unified-access-gateway/internal/protocol/udp"
 	"github.com/SkynetNext/unified-access-gateway/internal/security"
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Drain states of Server.draining. Both draining states fail /ready; only
+// shutdown closes the listeners.
+const (
+	drainRunning     int32 = iota
+	drainShutdown          // GracefulShutdown in progress
+	drainMaintenance       // Marked by POST /admin/drain, still serving
+)
+
+// errShuttingDown rejects maintenance drain changes once shutdown has started
+var errShuttingDown = errors.New("gateway is shutting down")
+
 type Server struct {
 	cfg           *config.Config
 	listener      *Listener
-	draining      int32 // Atomic: 0=Running, 1=Draining
+	draining      int32 // Atomic: drainRunning, drainShutdown or drainMaintenance
 	wg            sync.WaitGroup
 	security      *security.Manager
 	redisStore    *config.RedisStore
 	metricsServer *http.Server // For graceful shutdown
+	scrapeServer  *http.Server // /metrics when metrics.scrape_listen_addr is set
+	adminServer   *http.Server // /admin/* over mTLS when the admin TLS files are set
+	adminTLS      *tlsTerminator
 	healthChecker *healthcheck.UpstreamHealthChecker
+	udpHandler    *udpproxy.Handler // Optional UDP relay (nil if not configured)
 }
 
 func NewServer(cfg *config.Config, store *config.RedisStore) *Server {
 	sec := security.NewManager(cfg, store)
+	// Created before the listener so proxies can report passive failures
+	health := healthcheck.NewUpstreamHealthChecker(cfg, store)
 	return &Server{
-		cfg:        cfg,
-		listener:   NewListener(cfg, sec),
-		security:   sec,
-		redisStore: store,
+		cfg:           cfg,
+		listener:      NewListener(cfg, sec, store, health),
+		security:      sec,
+		redisStore:    store,
+		healthChecker: health,
+		udpHandler:    udpproxy.NewHandler(cfg, sec),
 	}
 }
 
 func (s *Server) Start() {
 	// 1. Start Metrics Server (if enabled)
 	if s.cfg.Metrics.Enabled {
+		// OpenMetrics exposes the request/connection ID exemplars
+		metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+		if s.cfg.Metrics.Token != "" {
+			metricsHandler = requireBearerToken(s.cfg.Metrics.Token, metricsHandler)
+		}
+
 		mux := http.NewServeMux()
-		mux.Handle("/metrics", promhttp.Handler())
+		if addr := s.cfg.Metrics.ScrapeListenAddr; addr != "" && addr != s.cfg.Metrics.ListenAddr {
+			// Scrapes on their own address, so the probe port can stay reachable
+			// by the kubelet only
+			scrapeMux := http.NewServeMux()
+			scrapeMux.Handle("/metrics", metricsHandler)
+			s.scrapeServer = &http.Server{Addr: addr, Handler: scrapeMux}
+			s.serveHTTP("Metrics scrape server", s.scrapeServer)
+		} else {
+			mux.Handle("/metrics", metricsHandler)
+		}
 		mux.HandleFunc("/health", s.healthHandler)
 		mux.HandleFunc("/ready", s.readyHandler) // K8s Readiness Probe
+		adminAPI := api.NewAdminAPI(s.cfg, s.security, s.redisStore, s.healthChecker, s.listener, s)
+		if s.cfg.Admin.MTLSEnabled() {
+			s.startAdminServer(adminAPI)
+		} else {
+			adminAPI.RegisterRoutes(mux)
+		}
 
 		s.metricsServer = &http.Server{
 			Addr:    s.cfg.Metrics.ListenAddr,
 			Handler: mux,
 		}
-
-		s.wg.Add(1)
-		go func() {
-			defer s.wg.Done()
-			xlog.Infof("Metrics server listening on %s", s.cfg.Metrics.ListenAddr)
-			if err := s.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				xlog.Errorf("Metrics server error: %v", err)
-			}
-		}()
+		s.serveHTTP("Metrics server", s.metricsServer)
 	}
 
 	// 2. Start Upstream Health Checker
-	s.healthChecker = healthcheck.NewUpstreamHealthChecker(s.cfg)
 	s.healthChecker.Start()
 
 	// 3. Start Business Listener
@@ -70,6 +110,14 @@ func (s *Server) Start() {
 			xlog.Errorf("Failed to start listener: %v", err)
 		}
 	}()
+
+	// 4. Start UDP Relay (optional, separate socket)
+	if s.udpHandler != nil {
+		if err := s.udpHandler.Start(); err != nil {
+			xlog.Errorf("Failed to start UDP relay: %v", err)
+			s.udpHandler = nil
+		}
+	}
 }
 
 // GracefulShutdown handles the shutdown process
@@ -78,7 +126,7 @@ func (s *Server) GracefulShutdown(timeout time.Duration) {
 
 	// 1. Mark as Draining
 	// This causes /ready to return 503, prompting K8s to remove this pod from endpoints
-	atomic.StoreInt32(&s.draining, 1)
+	atomic.StoreInt32(&s.draining, drainShutdown)
 
 	// 2. Wait for K8s endpoints propagation (usually 5-10s)
 	// Use shorter wait if timeout is small
@@ -100,22 +148,40 @@ func (s *Server) GracefulShutdown(timeout time.Duration) {
 	// Metrics server still running for monitoring and probes
 	s.listener.Stop()
 
+	// UDP sessions are connectionless and cannot be drained, close them now
+	if s.udpHandler != nil {
+		s.udpHandler.Stop()
+	}
+
 	// 5. Wait for active connections to drain
-	// Calculate remaining time for connection drain
+	// Calculate remaining time for connection drain, bounded by DrainWaitTime if set
 	// Metrics server remains available for monitoring and probes during this time
 	remainingTime := timeout - k8sWaitTime
 	if remainingTime < 0 {
 		remainingTime = 0
 	}
+	if d := s.cfg.Lifecycle.DrainWaitTime; d > 0 && (remainingTime == 0 || d < remainingTime) {
+		remainingTime = d
+	}
 
 	if remainingTime > 0 {
 		xlog.Infof("Waiting for active connections to drain (Timeout: %v)...", remainingTime)
 		xlog.Infof("Metrics server remains available for /health and /ready probes during drain")
-		time.Sleep(remainingTime)
+		// Idle HTTP keep-alive connections close now and busy ones after their
+		// request in flight, instead of being force-closed at the drain end
+		httpCtx, httpCancel := context.WithTimeout(context.Background(), remainingTime)
+		go s.listener.ShutdownHTTP(httpCtx)
+		s.waitForDrain(remainingTime)
+		httpCancel()
 	} else {
 		xlog.Infof("No time remaining for connection drain")
 	}
 
+	// Long-lived connections that outlived the drain window are closed forcibly
+	if n := s.listener.CloseActive(); n > 0 {
+		xlog.Warnf("Drain timeout reached, force-closed %d connections", n)
+	}
+
 	// 6. Stop Metrics Server (graceful shutdown) - LAST to close
 	// This allows monitoring and probes to work during entire shutdown process
 	// After this, metrics server goroutine will complete, and s.wg.Wait() can finish
@@ -126,6 +192,17 @@ func (s *Server) GracefulShutdown(timeout time.Duration) {
 		if err := s.metricsServer.Shutdown(ctx); err != nil {
 			xlog.Warnf("Metrics server shutdown error: %v", err)
 		}
+		if s.scrapeServer != nil {
+			if err := s.scrapeServer.Shutdown(ctx); err != nil {
+				xlog.Warnf("Metrics scrape server shutdown error: %v", err)
+			}
+		}
+		if s.adminServer != nil {
+			if err := s.adminServer.Shutdown(ctx); err != nil {
+				xlog.Warnf("Admin server shutdown error: %v", err)
+			}
+			s.adminTLS.stop()
+		}
 	}
 
 	// 7. Wait for all goroutines to finish
@@ -134,7 +211,22 @@ func (s *Server) GracefulShutdown(timeout time.Duration) {
 	xlog.Infof("Waiting for all goroutines to finish...")
 	s.wg.Wait()
 
-	// 8. Close Redis store (final cleanup)
+	// Flush queued access logs to Kafka. Requests and connections that were
+	// force-closed above may still be finishing; their entries are waited for.
+	logCtx, logCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer logCancel()
+	if err := middleware.Instance.Shutdown(logCtx); err != nil {
+		xlog.Warnf("Access log producer close error: %v", err)
+	}
+
+	// 8. Flush buffered spans so in-flight traces are not lost
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+	if err := observability.ShutdownTracing(tracingCtx); err != nil {
+		xlog.Warnf("Tracing shutdown error: %v", err)
+	}
+
+	// 9. Close Redis store (final cleanup)
 	// All services are stopped, now close external connections
 	if s.redisStore != nil {
 		if err := s.redisStore.Close(); err != nil {
@@ -144,21 +236,156 @@ func (s *Server) GracefulShutdown(timeout time.Duration) {
 	xlog.Infof("Shutdown complete.")
 }
 
+// SetMaintenanceDrain marks the gateway as draining for maintenance, so /ready
+// returns 503 and Kubernetes removes it from endpoints while it keeps serving,
+// or back to running. It fails once shutdown has started.
+func (s *Server) SetMaintenanceDrain(drain bool) error {
+	from, to := drainRunning, drainMaintenance
+	if !drain {
+		from, to = to, from
+	}
+	if atomic.CompareAndSwapInt32(&s.draining, from, to) || atomic.LoadInt32(&s.draining) == to {
+		return nil
+	}
+	return errShuttingDown
+}
+
+// DrainState reports "running", "maintenance" or "shutting_down"
+func (s *Server) DrainState() string {
+	switch atomic.LoadInt32(&s.draining) {
+	case drainShutdown:
+		return "shutting_down"
+	case drainMaintenance:
+		return "maintenance"
+	}
+	return "running"
+}
+
+// SetDiscoveredTargets proxies the default HTTP or TCP backend (by proto) to
+// targets, the host:port endpoints of its Kubernetes service. Weights set in
+// business:backend_weights for the backend take precedence.
+func (s *Server) SetDiscoveredTargets(proto ProtocolType, targets []string) {
+	s.listener.SetDiscoveredTargets(proto, targets)
+}
+
+// waitForDrain polls the listener until no client connections remain or timeout elapses
+func (s *Server) waitForDrain(timeout time.Duration) {
+	const (
+		pollInterval = 100 * time.Millisecond
+		logInterval  = 5 * time.Second
+	)
+	deadline := time.Now().Add(timeout)
+	nextLog := time.Now()
+	for {
+		active := s.listener.ActiveConnections()
+		if active == 0 {
+			xlog.Infof("All connections drained")
+			return
+		}
+		now := time.Now()
+		if !now.Before(deadline) {
+			return
+		}
+		if !now.Before(nextLog) {
+			xlog.Infof("Draining: %d active connections, %v remaining", active, deadline.Sub(now).Round(time.Second))
+			nextLog = now.Add(logInterval)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// startAdminServer serves the admin API on its own listener, which requires a
+// client certificate signed by admin.client_ca_file. On error the admin API is
+// not served at all rather than falling back to plain HTTP.
+func (s *Server) startAdminServer(adminAPI *api.AdminAPI) {
+	term, err := newTLSTerminator(config.TLSConfig{
+		CertFile:     s.cfg.Admin.CertFile,
+		KeyFile:      s.cfg.Admin.KeyFile,
+		ClientCAFile: s.cfg.Admin.ClientCAFile,
+		ClientAuth:   "require",
+	})
+	if err != nil {
+		xlog.Errorf("Failed to start admin server: %v", err)
+		return
+	}
+	mux := http.NewServeMux()
+	adminAPI.RegisterRoutes(mux)
+	s.adminTLS = term
+	s.adminServer = &http.Server{
+		Addr:      s.cfg.Admin.ListenAddr,
+		Handler:   mux,
+		TLSConfig: term.serverConfig(),
+	}
+	s.serveHTTP("Admin server", s.adminServer)
+}
+
+// serveHTTP runs srv in the background until it is shut down, over TLS if
+// srv.TLSConfig is set
+func (s *Server) serveHTTP(name string, srv *http.Server) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		xlog.Infof("%s listening on %s", name, srv.Addr)
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "") // Certificates come from TLSConfig
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			xlog.Errorf("%s error: %v", name, err)
+		}
+	}()
+}
+
+// requireBearerToken rejects requests without "Authorization: Bearer <token>"
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if len(auth) <= 7 || !strings.EqualFold(auth[:7], "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimSpace(auth[7:])), []byte(token)) != 1 {
+			middleware.RecordSecurityBlock("metrics_unauthorized")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="uag-metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// healthHandler for K8s Liveness Probe
+// Returns 503 if the data plane is down: a listen address is not bound or one
+// of its accept loops has exited. While draining, the listen sockets are closed
+// on purpose, so only the process is reported.
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.draining) != drainShutdown {
+		if err := s.listener.CheckDataPlane(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("Data Plane Down: " + err.Error()))
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
 // readyHandler for K8s Readiness Probe
 // Returns 503 if:
-// 1. Gateway is in drain mode (shutting down)
+// 1. Gateway is in drain mode (shutting down, or maintenance via /admin/drain)
 // 2. Redis is enabled but unavailable (business config cannot be loaded)
+// 3. eBPF is required (EBPF_REQUIRED) but the sockops program is not attached
 func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 	// Check 1: Drain mode
-	if atomic.LoadInt32(&s.draining) == 1 {
+	switch atomic.LoadInt32(&s.draining) {
+	case drainShutdown:
 		w.WriteHeader(http.StatusServiceUnavailable)
 		w.Write([]byte("Draining"))
 		return
+	case drainMaintenance:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Draining (maintenance)"))
+		return
 	}
 
 	// Check 2: Redis health (if enabled)
@@ -170,6 +397,16 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Check 3: eBPF acceleration (only when required). Attached means the
+	// program was loaded and linked to a verified cgroup v2 path.
+	if s.cfg.EBPF.Required {
+		if st := s.listener.SockMapStats(); !st.Enabled || st.CgroupPath == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("eBPF Not Attached"))
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Ready"))
 }
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	tlsRecordHeaderLen      = 5
+	tlsRecordTypeHandshake  = 0x16
+	tlsHandshakeClientHello = 0x01
+	tlsExtServerName        = 0x0000
+	sniHostNameType         = 0x00
+
+	// maxClientHelloPeek bounds how many bytes we are willing to buffer while
+	// reassembling a fragmented ClientHello (several max-size records)
+	maxClientHelloPeek = 64 * 1024
+)
+
+var errNotClientHello = errors.New("not a TLS ClientHello")
+
+// peekClientHello reassembles the ClientHello handshake message from one or more
+// TLS records without consuming any bytes from the connection.
+func (s *SniffConn) peekClientHello() ([]byte, error) {
+	var handshake []byte
+	offset := 0
+
+	for {
+		header, err := s.peek(offset + tlsRecordHeaderLen)
+		if err != nil {
+			return nil, err
+		}
+		header = header[offset:]
+		if header[0] != tlsRecordTypeHandshake {
+			return nil, errNotClientHello
+		}
+		recordLen := int(binary.BigEndian.Uint16(header[3:5]))
+
+		record, err := s.peek(offset + tlsRecordHeaderLen + recordLen)
+		if err != nil {
+			return nil, err
+		}
+		handshake = append(handshake, record[offset+tlsRecordHeaderLen:]...)
+		offset += tlsRecordHeaderLen + recordLen
+
+		// Handshake header: type (1) + length (3)
+		if len(handshake) >= 4 {
+			if handshake[0] != tlsHandshakeClientHello {
+				return nil, errNotClientHello
+			}
+			msgLen := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+			if len(handshake) >= 4+msgLen {
+				return handshake[4 : 4+msgLen], nil
+			}
+		}
+	}
+}
+
+// parseSNI extracts the server_name extension host from a ClientHello body
+func parseSNI(hello []byte) (string, error) {
+	// client_version (2) + random (32)
+	p := 34
+	if len(hello) < p+1 {
+		return "", errNotClientHello
+	}
+
+	// session_id
+	p += 1 + int(hello[p])
+	if len(hello) < p+2 {
+		return "", errNotClientHello
+	}
+
+	// cipher_suites
+	p += 2 + int(binary.BigEndian.Uint16(hello[p:]))
+	if len(hello) < p+1 {
+		return "", errNotClientHello
+	}
+
+	// compression_methods
+	p += 1 + int(hello[p])
+	if len(hello) < p+2 {
+		// No extensions: valid ClientHello without SNI
+		return "", nil
+	}
+
+	extEnd := p + 2 + int(binary.BigEndian.Uint16(hello[p:]))
+	p += 2
+	if extEnd > len(hello) {
+		return "", errNotClientHello
+	}
+
+	for p+4 <= extEnd {
+		extType := binary.BigEndian.Uint16(hello[p:])
+		extLen := int(binary.BigEndian.Uint16(hello[p+2:]))
+		p += 4
+		if p+extLen > extEnd {
+			return "", errNotClientHello
+		}
+		if extType == tlsExtServerName {
+			return parseServerNameExt(hello[p : p+extLen])
+		}
+		p += extLen
+	}
+	return "", nil
+}
+
+// parseServerNameExt returns the first host_name entry of a server_name extension
+func parseServerNameExt(ext []byte) (string, error) {
+	if len(ext) < 2 {
+		return "", errNotClientHello
+	}
+	listEnd := 2 + int(binary.BigEndian.Uint16(ext))
+	if listEnd > len(ext) {
+		return "", errNotClientHello
+	}
+	p := 2
+	for p+3 <= listEnd {
+		nameType := ext[p]
+		nameLen := int(binary.BigEndian.Uint16(ext[p+1:]))
+		p += 3
+		if p+nameLen > listEnd {
+			return "", errNotClientHello
+		}
+		if nameType == sniHostNameType {
+			return string(ext[p : p+nameLen]), nil
+		}
+		p += nameLen
+	}
+	return "", nil
+}
@@ -2,11 +2,13 @@ package core
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
 	"net"
-	"strings"
 	"time"
 
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 )
 
@@ -18,18 +20,39 @@ const (
 	ProtocolHTTP
 	ProtocolTCP // Custom Binary Protocol
 	ProtocolTLS
+	ProtocolHTTP2 // Cleartext HTTP/2 with prior knowledge (h2c)
 )
 
+// defaultSniffTimeout is used when server.sniff_timeout is unset
+const defaultSniffTimeout = 500 * time.Millisecond
+
+// defaultReadSize is used when server.buffers.read_size is unset (bufio's default)
+const defaultReadSize = 4096
+
 // SniffConn wraps net.Conn with Peek support
 type SniffConn struct {
 	net.Conn
-	r *bufio.Reader
+	r       *bufio.Reader
+	grown   []*bufio.Reader // Readers replaced by a larger one in peek, read through r (oldest first)
+	sni     string          // TLS server name from ClientHello (ProtocolTLS only)
+	timeout time.Duration   // Read deadline for Sniff
+
+	remoteAddr net.Addr // Client address from the PROXY protocol header (nil = socket peer)
 }
 
-func NewSniffConn(c net.Conn) *SniffConn {
+// NewSniffConn wraps c; timeout bounds how long Sniff waits for data and
+// readSize sizes the read buffer kept for the connection's lifetime (0 = defaults)
+func NewSniffConn(c net.Conn, timeout time.Duration, readSize int) *SniffConn {
+	if timeout <= 0 {
+		timeout = defaultSniffTimeout
+	}
+	if readSize <= 0 {
+		readSize = defaultReadSize
+	}
 	return &SniffConn{
-		Conn: c,
-		r:    bufio.NewReader(c),
+		Conn:    c,
+		r:       bufio.NewReaderSize(c, readSize),
+		timeout: timeout,
 	}
 }
 
@@ -38,43 +61,170 @@ func (s *SniffConn) Read(p []byte) (int, error) {
 	return s.r.Read(p)
 }
 
+// SNI returns the TLS server name extracted during Sniff (empty if not TLS or absent)
+func (s *SniffConn) SNI() string {
+	return s.sni
+}
+
+// peek returns the next n buffered bytes without consuming them, growing the
+// read buffer if n exceeds its current size. Bytes already buffered are kept,
+// since the larger reader drains the previous one first.
+func (s *SniffConn) peek(n int) ([]byte, error) {
+	if n > maxClientHelloPeek {
+		return nil, bufio.ErrBufferFull
+	}
+	if n > s.r.Size() {
+		size := s.r.Size()
+		for size < n {
+			size *= 2
+		}
+		s.grown = append(s.grown, s.r)
+		s.r = bufio.NewReaderSize(s.r, size)
+	}
+	return s.r.Peek(n)
+}
+
+// TakeBuffered removes and returns the bytes read from the connection but not
+// yet consumed, so the caller can go on reading from Unwrap() (e.g. to let
+// io.Copy splice the raw sockets). Reading s afterwards is still correct.
+func (s *SniffConn) TakeBuffered() []byte {
+	var buffered []byte
+	// Outer readers hold the earlier bytes
+	for i := len(s.grown); i >= 0; i-- {
+		r := s.r
+		if i < len(s.grown) {
+			r = s.grown[i]
+		}
+		if n := r.Buffered(); n > 0 {
+			b, _ := r.Peek(n)
+			buffered = append(buffered, b...)
+			r.Discard(n)
+		}
+	}
+	return buffered
+}
+
 // Unwrap returns the underlying net.Conn for eBPF socket cookie extraction
 // This implements the ebpf.UnwrappableConn interface (implicitly, no import needed)
 func (s *SniffConn) Unwrap() net.Conn {
 	return s.Conn
 }
 
-// Sniff detects protocol type
+// Sniff detects protocol type by running the registered matchers in priority
+// order, counting each outcome in gateway_sniff_total
 func (s *SniffConn) Sniff() ProtocolType {
+	proto := s.classify()
+	middleware.RecordSniff(proto.String())
+	return proto
+}
+
+func (s *SniffConn) classify() ProtocolType {
 	// Set read deadline to prevent hanging on malicious connections
-	s.Conn.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
+	s.Conn.SetReadDeadline(time.Now().Add(s.timeout))
 	defer s.Conn.SetReadDeadline(time.Time{}) // Clear deadline
 
-	// Peek first 5 bytes
-	bytes, err := s.r.Peek(5)
-	if err != nil && err != io.EOF {
-		return ProtocolUnknown
-	}
+	timedOut := false
+	for _, rm := range registeredMatchers() {
+		// Peek only as far as this matcher needs, so short first packets are
+		// not held up by matchers further down the list
+		peek, err := s.peek(rm.peekLen)
+		if err != nil && err != io.EOF {
+			if !isTimeout(err) {
+				xlog.Debugf("[SNIFF] %s -> unknown, read error after %d bytes: %v", s.RemoteAddr(), len(peek), err)
+				return ProtocolUnknown
+			}
+			if len(peek) == 0 {
+				// Nothing sent yet: a slow or server-speaks-first client,
+				// which only the TCP proxy can serve
+				middleware.RecordSniffTimeout("no_data")
+				xlog.Debugf("[SNIFF] %s -> TCP, no data within %v", s.RemoteAddr(), s.timeout)
+				return ProtocolTCP
+			}
+			// Timed out mid-peek; let the matchers decide on what arrived
+			if !timedOut {
+				timedOut = true
+				middleware.RecordSniffTimeout("partial")
+			}
+		}
 
-	if len(bytes) < 2 {
-		return ProtocolUnknown
+		proto, ok := rm.matcher.Match(peek)
+		if !ok {
+			continue
+		}
+		switch proto {
+		case ProtocolTLS:
+			s.extractSNI()
+		case ProtocolTCP:
+			xlog.Debugf("[SNIFF] %s -> TCP, peek: hex=%x ascii=%q", s.RemoteAddr(), peek, peek)
+		default:
+			xlog.Debugf("[SNIFF] %s -> %s matcher, protocol=%d", s.RemoteAddr(), rm.name, proto)
+		}
+		return proto
 	}
+	peek, _ := s.r.Peek(s.r.Buffered())
+	xlog.Debugf("[SNIFF] %s -> unknown, no matcher, peek: hex=%x", s.RemoteAddr(), peek)
+	return ProtocolUnknown
+}
+
+// SniffTLS reads the SNI of a connection already known to be TLS (a listener
+// with a fixed protocol), skipping the matchers
+func (s *SniffConn) SniffTLS() {
+	s.Conn.SetReadDeadline(time.Now().Add(s.timeout))
+	defer s.Conn.SetReadDeadline(time.Time{})
+	s.extractSNI()
+}
 
-	// HTTP detection: GET, POST, PUT, DELETE, HEAD...
-	// Check first 3-4 bytes for HTTP methods
-	head := string(bytes)
-	if strings.HasPrefix(head, "GET") || strings.HasPrefix(head, "POST") ||
-		strings.HasPrefix(head, "PUT ") || strings.HasPrefix(head, "DELE") ||
-		strings.HasPrefix(head, "HEAD") || strings.HasPrefix(head, "HTTP") {
-		return ProtocolHTTP
+// parseListenerProtocol maps a listener's protocol setting to the protocol
+// dispatched without sniffing; ProtocolUnknown means sniff every connection
+func parseListenerProtocol(name string) (ProtocolType, error) {
+	switch name {
+	case "", "auto":
+		return ProtocolUnknown, nil
+	case "http":
+		return ProtocolHTTP, nil
+	case "h2c":
+		return ProtocolHTTP2, nil
+	case "tcp":
+		return ProtocolTCP, nil
+	case "tls":
+		return ProtocolTLS, nil
 	}
+	return ProtocolUnknown, fmt.Errorf("unknown protocol %q (want http, h2c, tcp or tls)", name)
+}
 
-	// TLS detection: 0x16 (Handshake)
-	if bytes[0] == 0x16 {
-		return ProtocolTLS
+// String returns the protocol name used in listener config and /admin/connections
+func (p ProtocolType) String() string {
+	switch p {
+	case ProtocolHTTP:
+		return "http"
+	case ProtocolHTTP2:
+		return "h2c"
+	case ProtocolTCP:
+		return "tcp"
+	case ProtocolTLS:
+		return "tls"
 	}
+	return "unknown"
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
 
-	// Default fallback to TCP (Assuming custom game protocol)
-	xlog.Debugf("[SNIFF] %s -> TCP, peek: hex=%x ascii=%q string=%q", s.Conn.RemoteAddr(), bytes, bytes, head)
-	return ProtocolTCP
+// extractSNI parses the ClientHello (possibly spanning several records) and
+// stores the server name. Failures are non-fatal: the connection stays TLS.
+func (s *SniffConn) extractSNI() {
+	hello, err := s.peekClientHello()
+	if err != nil {
+		xlog.Debugf("[SNIFF] %s -> TLS, ClientHello not parsed: %v", s.RemoteAddr(), err)
+		return
+	}
+	sni, err := parseSNI(hello)
+	if err != nil {
+		xlog.Debugf("[SNIFF] %s -> TLS, malformed ClientHello: %v", s.RemoteAddr(), err)
+		return
+	}
+	s.sni = sni
+	xlog.Debugf("[SNIFF] %s -> TLS, SNI=%q", s.RemoteAddr(), sni)
 }
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	tcpproxy "github.com/SkynetNext/unified-access-gateway/internal/protocol/tcp"
+	"github.com/SkynetNext/unified-access-gateway/internal/sockopt"
+)
+
+// loopbackPair returns both ends of a loopback TCP connection whose sockets
+// use the given SO_RCVBUF/SO_SNDBUF sizes (0 = kernel default)
+func loopbackPair(tb testing.TB, socketBuf int) (client, server net.Conn) {
+	tb.Helper()
+	control := sockopt.Buffers(socketBuf, socketBuf)
+	ln, err := (&net.ListenConfig{Control: control}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	client, err = (&net.Dialer{Control: control}).Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatalf("dial: %v", err)
+	}
+	server, err = ln.Accept()
+	if err != nil {
+		client.Close()
+		tb.Fatalf("accept: %v", err)
+	}
+	tb.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// BenchmarkSniffConnRead reads a stream of small frames, as a game client
+// sends them, through SniffConn for each server.buffers setting. The writer
+// sends 64 KiB at a time so the reads, not the writes, are measured.
+func BenchmarkSniffConnRead(b *testing.B) {
+	for _, frame := range []int{64, 1024} {
+		for _, bm := range []struct {
+			readSize, socketBuf int
+		}{
+			{defaultReadSize, 0},
+			{16 << 10, 0},
+			{16 << 10, 1 << 20},
+		} {
+			name := fmt.Sprintf("frame=%d/read_size=%d/socket=%d", frame, bm.readSize, bm.socketBuf)
+			b.Run(name, func(b *testing.B) {
+				client, server := loopbackPair(b, bm.socketBuf)
+				sc := NewSniffConn(server, time.Second, bm.readSize)
+				go func() {
+					out := make([]byte, 64<<10)
+					for left := int64(b.N) * int64(frame); left > 0; {
+						n := int64(len(out))
+						if left < n {
+							n = left
+						}
+						if _, err := client.Write(out[:n]); err != nil {
+							return
+						}
+						left -= n
+					}
+				}()
+
+				in := make([]byte, frame)
+				b.SetBytes(int64(frame))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := io.ReadFull(sc, in); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestSniffedBytesReachSplicedBackend proxies a sniffed client through the
+// TCP handler, which splices the raw sockets and first sends the bytes the
+// SniffConn had buffered. The backend must receive the stream unchanged.
+func TestSniffedBytesReachSplicedBackend(t *testing.T) {
+	stream := make([]byte, 3<<20)
+	rand.New(rand.NewSource(1)).Read(stream)
+	copy(stream, "\x00\x01GAME") // Binary header: sniffed as TCP
+
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer backend.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		c, err := backend.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer c.Close()
+		b, _ := io.ReadAll(c)
+		received <- b
+	}()
+
+	cfg := &config.Config{}
+	cfg.Backends.TCP.TargetAddr = backend.Addr().String()
+	h := tcpproxy.NewHandler(cfg, nil, nil, nil)
+	if h == nil {
+		t.Fatal("NewHandler returned nil")
+	}
+	defer h.Close()
+	h.SetEBPFEnabled(false, "") // Userspace path, so io.Copy splices
+
+	client, server := loopbackPair(t, 0)
+	// Part of the stream is in flight before sniffing, so the sniffer buffers it
+	if _, err := client.Write(stream[:64<<10]); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sc := NewSniffConn(server, time.Second, 0)
+	if proto := sc.Sniff(); proto != ProtocolTCP {
+		t.Fatalf("Sniff() = %v, want tcp", proto)
+	}
+	if sc.r.Buffered() == 0 {
+		t.Fatal("nothing buffered by the sniffer")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Handle(sc)
+	}()
+	if _, err := client.Write(stream[64<<10:]); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	client.(*net.TCPConn).CloseWrite()
+
+	select {
+	case got := <-received:
+		if !bytes.Equal(got, stream) {
+			i := 0
+			for i < len(got) && i < len(stream) && got[i] == stream[i] {
+				i++
+			}
+			t.Fatalf("backend received %d bytes, want %d; first difference at byte %d", len(got), len(stream), i)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("backend did not receive the stream")
+	}
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not finish")
+	}
+}
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+const (
+	// tlsHandshakeTimeout bounds the handshake of a terminated connection
+	tlsHandshakeTimeout = 10 * time.Second
+	// tlsReloadInterval is how often certificate files are checked for changes
+	tlsReloadInterval = 10 * time.Second
+)
+
+// tlsTerminator holds the listener's TLS settings and reloads the certificate
+// and client CA bundle when their files change, so rotation needs no restart
+type tlsTerminator struct {
+	cfg  config.TLSConfig
+	base *tls.Config // Static settings, cloned per handshake with the current cert and CAs
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamps    []fileStamp // cert, key, CA state at last successful load
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// newTLSTerminator validates cfg and loads the certificate files
+func newTLSTerminator(cfg config.TLSConfig) (*tlsTerminator, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("server.tls.cert_file and server.tls.key_file are required")
+	}
+
+	minVersion, err := parseTLSVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := parseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := parseClientAuth(cfg.ClientAuth, cfg.ClientCAFile != "")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tlsTerminator{
+		cfg:      cfg,
+		stopChan: make(chan struct{}),
+	}
+	t.base = &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: suites,
+		ClientAuth:   clientAuth,
+	}
+	t.base.GetConfigForClient = t.configForClient
+
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	go t.watch()
+	return t, nil
+}
+
+// serverConfig returns the tls.Config used for tls.Server
+func (t *tlsTerminator) serverConfig() *tls.Config {
+	return t.base
+}
+
+// configForClient applies the currently loaded certificate and CA bundle
+func (t *tlsTerminator) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c := t.base.Clone()
+	c.GetConfigForClient = nil
+	c.Certificates = []tls.Certificate{*t.cert}
+	c.ClientCAs = t.clientCAs
+	return c, nil
+}
+
+// load reads the certificate, key and CA files, replacing the active ones on success
+func (t *tlsTerminator) load() error {
+	stamps, err := t.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.cfg.CertFile, t.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if t.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %s", t.cfg.ClientCAFile)
+		}
+	}
+
+	t.mu.Lock()
+	t.cert = &cert
+	t.clientCAs = pool
+	t.stamps = stamps
+	t.mu.Unlock()
+	return nil
+}
+
+// stat returns the current stamps of the configured files
+func (t *tlsTerminator) stat() ([]fileStamp, error) {
+	files := []string{t.cfg.CertFile, t.cfg.KeyFile}
+	if t.cfg.ClientCAFile != "" {
+		files = append(files, t.cfg.ClientCAFile)
+	}
+	stamps := make([]fileStamp, 0, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, fileStamp{modTime: info.ModTime(), size: info.Size()})
+	}
+	return stamps, nil
+}
+
+// changed reports whether any file differs from the last successful load
+func (t *tlsTerminator) changed() bool {
+	stamps, err := t.stat()
+	if err != nil {
+		// Mid-rotation (file replaced non-atomically); retry next tick
+		return false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for i := range stamps {
+		if stamps[i] != t.stamps[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// watch polls the certificate files and reloads them when they change.
+// A failed reload keeps the previous certificate.
+func (t *tlsTerminator) watch() {
+	ticker := time.NewTicker(tlsReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !t.changed() {
+				continue
+			}
+			if err := t.load(); err != nil {
+				xlog.Warnf("TLS certificate reload failed, keeping previous certificate: %v", err)
+				continue
+			}
+			xlog.Infof("TLS certificate reloaded from %s", t.cfg.CertFile)
+		case <-t.stopChan:
+			return
+		}
+	}
+}
+
+// stop ends the reload loop
+func (t *tlsTerminator) stop() {
+	t.stopOnce.Do(func() { close(t.stopChan) })
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	default:
+		return 0, fmt.Errorf("invalid server.tls.min_version %q (expected 1.0, 1.1, 1.2 or 1.3)", v)
+	}
+}
+
+// parseCipherSuites maps crypto/tls suite names to IDs; insecure suites are rejected
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseClientAuth(v string, hasCA bool) (tls.ClientAuthType, error) {
+	switch strings.ToLower(v) {
+	case "":
+		if hasCA {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		if !hasCA {
+			return 0, errors.New("server.tls.client_auth=request needs server.tls.client_ca_file")
+		}
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		if !hasCA {
+			return 0, errors.New("server.tls.client_auth=require needs server.tls.client_ca_file")
+		}
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("invalid server.tls.client_auth %q (expected none, request or require)", v)
+	}
+}
@@ -1,11 +1,14 @@
 package discovery
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
-	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 )
 
 // K8sServiceDiscovery provides Kubernetes-native service discovery
@@ -38,26 +41,28 @@ func (k *K8sServiceDiscovery) ResolveService(serviceName string) (string, error)
 	if strings.Contains(serviceName, ".") {
 		return serviceName, nil
 	}
+	ips, err := k.lookupIPs(serviceName)
+	if err != nil {
+		return "", err
+	}
+	// Return first IP (ResolveServiceSRV returns every target with its port)
+	return ips[0].String(), nil
+}
 
-	// Build FQDN: <service>.<namespace>.svc.cluster.local
-	fqdn := fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, k.namespace)
-
-	// Resolve DNS (K8s CoreDNS)
-	ips, err := net.LookupIP(fqdn)
+// lookupIPs resolves the service FQDN through K8s CoreDNS, falling back to the
+// short name (same namespace)
+func (k *K8sServiceDiscovery) lookupIPs(serviceName string) ([]net.IP, error) {
+	ips, err := net.LookupIP(k.ResolveServiceDNS(serviceName))
 	if err != nil {
-		// Fallback to short name (same namespace)
 		ips, err = net.LookupIP(serviceName)
 		if err != nil {
-			return "", fmt.Errorf("failed to resolve service %s: %w", serviceName, err)
+			return nil, fmt.Errorf("failed to resolve service %s: %w", serviceName, err)
 		}
 	}
-
 	if len(ips) == 0 {
-		return "", fmt.Errorf("no IPs found for service %s", serviceName)
+		return nil, fmt.Errorf("no IPs found for service %s", serviceName)
 	}
-
-	// Return first IP (or use SRV records for port)
-	return ips[0].String(), nil
+	return ips, nil
 }
 
 // ResolveServiceWithPort resolves service and returns address:port
@@ -69,6 +74,41 @@ func (k *K8sServiceDiscovery) ResolveServiceWithPort(serviceName string, port in
 	return fmt.Sprintf("%s:%d", ip, port), nil
 }
 
+// ResolveServiceSRV resolves a named service port through the SRV records K8s
+// publishes for it (_<portName>._tcp.<service>.<namespace>.svc.cluster.local)
+// and returns every target as host:port, in SRV priority/weight order, for
+// load balancing. Without SRV records (unnamed port, DNS outside the cluster)
+// it falls back to every A/AAAA record of the service with fallbackPort, or
+// fails if fallbackPort is 0.
+func (k *K8sServiceDiscovery) ResolveServiceSRV(serviceName, portName string, fallbackPort int) ([]string, error) {
+	_, srvs, err := net.LookupSRV(portName, "tcp", k.ResolveServiceDNS(serviceName))
+	if err == nil && len(srvs) > 0 {
+		addrs := make([]string, 0, len(srvs))
+		for _, srv := range srvs {
+			host := strings.TrimSuffix(srv.Target, ".")
+			addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+		}
+		return addrs, nil
+	}
+	if err == nil {
+		err = errors.New("no SRV records")
+	}
+	if fallbackPort <= 0 {
+		return nil, fmt.Errorf("failed to resolve port %q of service %s: %w", portName, serviceName, err)
+	}
+	xlog.Debugf("SRV lookup for port %q of service %s failed (%v), using A records with port %d", portName, serviceName, err, fallbackPort)
+
+	ips, err := k.lookupIPs(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), strconv.Itoa(fallbackPort)))
+	}
+	return addrs, nil
+}
+
 // ResolveServiceDNS returns the FQDN for a service
 func (k *K8sServiceDiscovery) ResolveServiceDNS(serviceName string) string {
 	if strings.Contains(serviceName, ".") {
@@ -93,23 +133,3 @@ func IsRunningInK8s() bool {
 	_, err := os.Stat("/var/run/secrets/kubernetes.io/serviceaccount/token")
 	return err == nil
 }
-
-// WatchServiceEndpoints watches for service endpoint changes (future enhancement)
-func (k *K8sServiceDiscovery) WatchServiceEndpoints(serviceName string, callback func([]string)) {
-	// This would use K8s API client to watch Endpoints
-	// For now, just periodic DNS lookup
-	ticker := time.NewTicker(10 * time.Second)
-	go func() {
-		for range ticker.C {
-			ips, err := net.LookupIP(k.ResolveServiceDNS(serviceName))
-			if err == nil {
-				var addrs []string
-				for _, ip := range ips {
-					addrs = append(addrs, ip.String())
-				}
-				callback(addrs)
-			}
-		}
-	}()
-}
-
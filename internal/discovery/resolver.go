@@ -0,0 +1,162 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// defaultResolverTTL is how long resolved backend addresses are used before
+// a background refresh
+const defaultResolverTTL = 30 * time.Second
+
+// DefaultResolver is the backend address cache shared by the proxy handlers
+var DefaultResolver = NewResolver(defaultResolverTTL)
+
+// Resolver caches every address of backend host names and hands them out in
+// round-robin order, so connections to a headless service spread across all
+// of its pods instead of pinning to the first DNS answer. An expired entry
+// keeps being served while it is refreshed in the background; a failed
+// refresh keeps the previous addresses until the next attempt.
+// Hosts are the configured backends, so the cache is not evicted.
+type Resolver struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	mu      sync.Mutex
+	entries map[string]*resolverEntry
+}
+
+type resolverEntry struct {
+	addrs      []string
+	expires    time.Time
+	refreshing bool
+	next       atomic.Uint64 // Round-robin position
+}
+
+// NewResolver returns a resolver refreshing addresses after ttl
+func NewResolver(ttl time.Duration) *Resolver {
+	return &Resolver{
+		ttl:     ttl,
+		lookup:  net.DefaultResolver.LookupHost,
+		entries: make(map[string]*resolverEntry),
+	}
+}
+
+// Resolve returns every cached address of host, looking it up on first use
+func (r *Resolver) Resolve(ctx context.Context, host string) ([]string, error) {
+	e, err := r.entry(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return e.addrs, nil
+}
+
+// Next returns the next address of host in round-robin order
+func (r *Resolver) Next(ctx context.Context, host string) (string, error) {
+	addrs, start, err := r.pick(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	return addrs[start], nil
+}
+
+// pick returns the addresses of host and the round-robin index to start at
+func (r *Resolver) pick(ctx context.Context, host string) ([]string, int, error) {
+	e, err := r.entry(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	r.mu.Lock()
+	addrs := e.addrs
+	r.mu.Unlock()
+	return addrs, int((e.next.Add(1) - 1) % uint64(len(addrs))), nil
+}
+
+// entry returns the cache entry of host, resolving it synchronously the first
+// time and starting a background refresh once it has expired
+func (r *Resolver) entry(ctx context.Context, host string) (*resolverEntry, error) {
+	r.mu.Lock()
+	e, ok := r.entries[host]
+	if ok {
+		if !e.refreshing && time.Now().After(e.expires) {
+			e.refreshing = true
+			go r.refresh(host, e)
+		}
+		r.mu.Unlock()
+		return e, nil
+	}
+	r.mu.Unlock()
+
+	addrs, err := r.lookup(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no addresses for " + host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[host]; ok {
+		return e, nil // Resolved concurrently
+	}
+	e = &resolverEntry{addrs: addrs, expires: time.Now().Add(r.ttl)}
+	r.entries[host] = e
+	return e, nil
+}
+
+func (r *Resolver) refresh(host string, e *resolverEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	addrs, err := r.lookup(ctx, host)
+	cancel()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.refreshing = false
+	e.expires = time.Now().Add(r.ttl)
+	if err != nil || len(addrs) == 0 {
+		xlog.Debugf("Backend resolver: refreshing %s failed (%v), keeping %d addresses", host, err, len(e.addrs))
+		return
+	}
+	if !equalStrings(sortedCopy(addrs), sortedCopy(e.addrs)) {
+		xlog.Infof("Backend resolver: %s -> %v", host, addrs)
+	}
+	e.addrs = addrs
+}
+
+func sortedCopy(addrs []string) []string {
+	return dedupSorted(append([]string(nil), addrs...))
+}
+
+// DialContext dials addr ("host:port") with dialer. A host name is resolved
+// through the cache and its addresses are tried in round-robin order, moving
+// on to the next one if a dial fails. IP addresses are dialed directly.
+func (r *Resolver) DialContext(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	addrs, start, err := r.pick(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for i := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addrs[(start+i)%len(addrs)], port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
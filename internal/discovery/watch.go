@@ -0,0 +1,222 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// dnsPollInterval is used when the K8s API is not reachable (not in-cluster)
+const dnsPollInterval = 10 * time.Second
+
+// WatchServiceEndpoints watches the ready endpoints of a service port and
+// invokes callback with their host:port whenever the set changes. The port is
+// the one named portName, else fallbackPort. In-cluster it uses a client-go
+// informer on the service's EndpointSlices (requires list/watch RBAC on
+// endpointslices); otherwise, or if the API client cannot be set up, it polls
+// ResolveServiceSRV. The returned function stops the watcher and waits for it to exit.
+func (k *K8sServiceDiscovery) WatchServiceEndpoints(serviceName, portName string, fallbackPort int, callback func([]string)) (stop func()) {
+	if IsRunningInK8s() {
+		client, err := inClusterClient()
+		if err == nil {
+			w := newEndpointWatcher(client, k.namespace, serviceName, portName, fallbackPort, callback)
+			return w.start()
+		}
+		xlog.Warnf("EndpointSlice watch unavailable for %s: %v, falling back to DNS polling", serviceName, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		k.pollDNS(ctx, serviceName, portName, fallbackPort, callback)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func inClusterClient() (kubernetes.Interface, error) {
+	restCfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restCfg)
+}
+
+// endpointWatcher tracks the ready endpoints of one service port through an
+// EndpointSlice informer scoped to the service
+type endpointWatcher struct {
+	factory  informers.SharedInformerFactory
+	informer cache.SharedIndexInformer
+	lister   discoverylisters.EndpointSliceNamespaceLister
+	selector labels.Selector
+
+	service      string
+	portName     string
+	fallbackPort int
+	callback     func([]string)
+
+	synced atomic.Bool // Events before the initial list has synced are ignored
+	mu     sync.Mutex
+	last   []string // Last addresses passed to callback (nil = none yet)
+}
+
+func newEndpointWatcher(client kubernetes.Interface, namespace, service, portName string, fallbackPort int, callback func([]string)) *endpointWatcher {
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service})
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector.String()
+		}))
+	slices := factory.Discovery().V1().EndpointSlices()
+	w := &endpointWatcher{
+		factory:      factory,
+		informer:     slices.Informer(),
+		lister:       slices.Lister().EndpointSlices(namespace),
+		selector:     selector,
+		service:      service,
+		portName:     portName,
+		fallbackPort: fallbackPort,
+		callback:     callback,
+	}
+	w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.notify() },
+		UpdateFunc: func(interface{}, interface{}) { w.notify() },
+		DeleteFunc: func(interface{}) { w.notify() },
+	})
+	return w
+}
+
+// start runs the informer until the returned function is called, which waits
+// for its goroutines to exit
+func (w *endpointWatcher) start() (stop func()) {
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	w.factory.Start(stopCh)
+	go func() {
+		defer close(done)
+		if !cache.WaitForCacheSync(stopCh, w.informer.HasSynced) {
+			return // Stopped first
+		}
+		w.synced.Store(true)
+		w.notify()
+	}()
+	return func() {
+		close(stopCh)
+		<-done
+		w.factory.Shutdown()
+	}
+}
+
+// notify invokes the callback if the ready endpoints of the service changed
+func (w *endpointWatcher) notify() {
+	if !w.synced.Load() {
+		return
+	}
+	slices, err := w.lister.List(w.selector)
+	if err != nil {
+		xlog.Warnf("Listing EndpointSlices of %s: %v", w.service, err)
+		return
+	}
+	addrs := []string{}
+	for _, slice := range slices {
+		addrs = append(addrs, w.readyAddresses(slice)...)
+	}
+	addrs = dedupSorted(addrs)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last != nil && equalStrings(addrs, w.last) {
+		return
+	}
+	w.last = addrs
+	w.callback(addrs)
+}
+
+// readyAddresses returns host:port of the ready endpoints of slice. A nil
+// ready condition means "unknown" and is treated as ready (per the API
+// contract). Slices without the named port and no fallback are skipped.
+func (w *endpointWatcher) readyAddresses(slice *discoveryv1.EndpointSlice) []string {
+	port := w.fallbackPort
+	for _, p := range slice.Ports {
+		if p.Name != nil && *p.Name == w.portName && p.Port != nil {
+			port = int(*p.Port)
+			break
+		}
+	}
+	if port <= 0 {
+		return nil
+	}
+	var addrs []string
+	for _, ep := range slice.Endpoints {
+		if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+			continue
+		}
+		for _, addr := range ep.Addresses {
+			addrs = append(addrs, net.JoinHostPort(addr, strconv.Itoa(port)))
+		}
+	}
+	return addrs
+}
+
+// pollDNS resolves the service port periodically, invoking callback on changes
+func (k *K8sServiceDiscovery) pollDNS(ctx context.Context, serviceName, portName string, fallbackPort int, callback func([]string)) {
+	ticker := time.NewTicker(dnsPollInterval)
+	defer ticker.Stop()
+
+	var last []string
+	for {
+		if addrs, err := k.ResolveServiceSRV(serviceName, portName, fallbackPort); err == nil {
+			addrs = dedupSorted(addrs)
+			if !equalStrings(addrs, last) {
+				last = addrs
+				callback(addrs)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func dedupSorted(in []string) []string {
+	sort.Strings(in)
+	out := in[:0]
+	for i, s := range in {
+		if i == 0 || s != in[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
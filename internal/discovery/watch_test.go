@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// endpointSlice returns a slice of service with the port named "tcp" and one
+// endpoint per address, ready unless listed in notReady
+func endpointSlice(name, service string, addrs []string, notReady ...string) *discoveryv1.EndpointSlice {
+	portName, port := "tcp", int32(7000)
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "games",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+	}
+	for _, addr := range addrs {
+		ep := discoveryv1.Endpoint{Addresses: []string{addr}}
+		for _, nr := range notReady {
+			if nr == addr {
+				ready := false
+				ep.Conditions.Ready = &ready
+			}
+		}
+		slice.Endpoints = append(slice.Endpoints, ep)
+	}
+	return slice
+}
+
+// expectAddrs waits for the next callback and compares its addresses
+func expectAddrs(t *testing.T, updates <-chan []string, want []string) {
+	t.Helper()
+	select {
+	case got := <-updates:
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("callback got %v, want %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no callback, want %v", want)
+	}
+}
+
+func TestEndpointWatcherReadyAddresses(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	slices := client.DiscoveryV1().EndpointSlices("games")
+	slices.Create(ctx, endpointSlice("game-a", "game", []string{"10.0.0.1", "10.0.0.2"}, "10.0.0.2"), metav1.CreateOptions{})
+	slices.Create(ctx, endpointSlice("lobby-a", "lobby", []string{"10.0.1.1"}), metav1.CreateOptions{})
+	goroutines := runtime.NumGoroutine()
+
+	updates := make(chan []string, 10)
+	w := newEndpointWatcher(client, "games", "game", "tcp", 0, func(addrs []string) { updates <- addrs })
+	stop := w.start()
+
+	// Initial list: only ready endpoints of the service, with the named port
+	expectAddrs(t, updates, []string{"10.0.0.1:7000"})
+
+	// A second slice and an endpoint becoming ready
+	slices.Create(ctx, endpointSlice("game-b", "game", []string{"10.0.0.3"}), metav1.CreateOptions{})
+	expectAddrs(t, updates, []string{"10.0.0.1:7000", "10.0.0.3:7000"})
+	slices.Update(ctx, endpointSlice("game-a", "game", []string{"10.0.0.1", "10.0.0.2"}), metav1.UpdateOptions{})
+	expectAddrs(t, updates, []string{"10.0.0.1:7000", "10.0.0.2:7000", "10.0.0.3:7000"})
+
+	// Changes that leave the ready set alone do not call back
+	slices.Update(ctx, endpointSlice("lobby-a", "lobby", []string{"10.0.1.1", "10.0.1.2"}), metav1.UpdateOptions{})
+	unchanged := endpointSlice("game-b", "game", []string{"10.0.0.3"})
+	unchanged.Annotations = map[string]string{"touched": "true"}
+	slices.Update(ctx, unchanged, metav1.UpdateOptions{})
+
+	// Deleting every slice leaves no endpoints
+	slices.Delete(ctx, "game-a", metav1.DeleteOptions{})
+	expectAddrs(t, updates, []string{"10.0.0.3:7000"})
+	slices.Delete(ctx, "game-b", metav1.DeleteOptions{})
+	expectAddrs(t, updates, []string{})
+
+	stop()
+	select {
+	case addrs := <-updates:
+		t.Errorf("unexpected callback %v", addrs)
+	default:
+	}
+
+	// The informer goroutines have exited
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after stop, %d before start", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
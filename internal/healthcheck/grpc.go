@@ -0,0 +1,95 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// grpcProber issues grpc.health.v1.Health/Check RPCs, keeping one client
+// connection per upstream so probes do not reconnect every interval
+type grpcProber struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn // dial target -> connection
+}
+
+func newGRPCProber() *grpcProber {
+	return &grpcProber{conns: make(map[string]*grpc.ClientConn)}
+}
+
+// check reports whether the upstream answers SERVING for service
+func (p *grpcProber) check(ctx context.Context, target string, useTLS bool, service string) bool {
+	conn, err := p.conn(target, useTLS)
+	if err != nil {
+		xlog.Debugf("Health check: gRPC backend %s dial failed: %v", target, err)
+		return false
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		xlog.Debugf("Health check: gRPC backend %s is unhealthy: %v", target, err)
+		return false
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		xlog.Debugf("Health check: gRPC backend %s service %q reported %s", target, service, resp.GetStatus())
+		return false
+	}
+	return true
+}
+
+// conn returns the cached connection for target, dialing it on first use.
+// Dialing is non-blocking; grpc reconnects in the background after failures.
+func (p *grpcProber) conn(target string, useTLS bool) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn, ok := p.conns[target]; ok {
+		return conn, nil
+	}
+
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	p.conns[target] = conn
+	return conn, nil
+}
+
+// close releases every cached connection
+func (p *grpcProber) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for target, conn := range p.conns {
+		conn.Close()
+		delete(p.conns, target)
+	}
+}
+
+// grpcTargetFromURL derives a host:port dial target from an HTTP backend URL.
+// https targets use TLS.
+func grpcTargetFromURL(targetURL string) (target string, useTLS bool, err error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return "", false, err
+	}
+	useTLS = u.Scheme == "https"
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if useTLS {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(host, port), useTLS, nil
+}
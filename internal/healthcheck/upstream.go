@@ -2,8 +2,10 @@ package healthcheck
 
 import (
 	"context"
+	"io"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,49 +14,320 @@ import (
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 )
 
+// Defaults used when backends.health_check.* is not configured
+const (
+	defaultInterval  = 30 * time.Second
+	defaultTimeout   = 5 * time.Second
+	defaultStatusMin = 200
+	defaultStatusMax = 399 // 2xx and 3xx are healthy
+
+	defaultUnhealthyThreshold = 3
+	defaultHealthyThreshold   = 1
+
+	defaultPassiveFailures = 5
+	defaultEjectionWindow  = 30 * time.Second
+
+	defaultHTTPType = "http"
+	defaultTCPType  = "tcp"
+
+	// DefaultUserAgent identifies HTTP probes, so backends can tell them from
+	// client traffic. Set backends.health_check.user_agent to "kube-probe/1.0"
+	// for backends that already special-case Kubernetes probes.
+	DefaultUserAgent = "uag-healthcheck/1.0"
+
+	// Probe transport pool: one idle connection per upstream is enough for
+	// one probe per interval, and bounds the pool with many backends
+	probeMaxIdleConns        = 64
+	probeMaxIdleConnsPerHost = 1
+	probeIdleConnTimeout     = 90 * time.Second
+)
+
 // UpstreamHealthChecker periodically checks the health of upstream backends
 type UpstreamHealthChecker struct {
-	cfg        *config.Config
+	transport  *http.Transport // Dedicated to probes, never shared with proxied traffic
 	httpClient *http.Client
-	tcpTimeout time.Duration
-	interval   time.Duration
+	grpc       *grpcProber
 	stopChan   chan struct{}
+	resetChan  chan time.Duration // New probe interval after a config reload
 	wg         sync.WaitGroup
 	mu         sync.RWMutex
-	healthMap  map[string]bool // upstream -> healthy
+	settings   config.HealthCheckConfig  // Normalized, guarded by mu
+	weights    []config.BackendWeights   // Weighted targets are probed too, guarded by mu
+	httpTarget string                    // backends.http.target_url, guarded by mu
+	tcpTarget  string                    // backends.tcp.target_addr, guarded by mu
+	healthMap  map[string]*upstreamState // upstream -> health state
 }
 
-// NewUpstreamHealthChecker creates a new health checker
-func NewUpstreamHealthChecker(cfg *config.Config) *UpstreamHealthChecker {
-	return &UpstreamHealthChecker{
-		cfg:        cfg,
-		httpClient: &http.Client{Timeout: 5 * time.Second},
-		tcpTimeout: 5 * time.Second,
-		interval:   30 * time.Second, // Check every 30 seconds
+// upstreamState tracks the current health and the running count of
+// consecutive probe results that disagree with it
+type upstreamState struct {
+	healthy              bool
+	consecutiveFailures  int
+	consecutiveSuccesses int
+
+	passiveFailures []time.Time // Proxy errors within the ejection window
+	ejectedUntil    time.Time   // Zero if not ejected
+}
+
+func (s *upstreamState) ejected(now time.Time) bool {
+	return now.Before(s.ejectedUntil)
+}
+
+// NewUpstreamHealthChecker creates a new health checker.
+// If store is non-nil, health check settings are reloaded on business config updates.
+func NewUpstreamHealthChecker(cfg *config.Config, store *config.RedisStore) *UpstreamHealthChecker {
+	transport := newProbeTransport()
+	c := &UpstreamHealthChecker{
+		transport: transport,
+		// Per-probe timeout is applied via request context
+		httpClient: &http.Client{Transport: transport},
+		grpc:       newGRPCProber(),
 		stopChan:   make(chan struct{}),
-		healthMap:  make(map[string]bool),
+		resetChan:  make(chan time.Duration, 1),
+		settings:   normalizeSettings(cfg.Backends.HealthCheck),
+		weights:    cfg.Backends.Weights,
+		httpTarget: cfg.Backends.HTTP.TargetURL,
+		tcpTarget:  cfg.Backends.TCP.TargetAddr,
+		healthMap:  make(map[string]*upstreamState),
+	}
+	middleware.SetHealthCheckThresholds(c.settings.UnhealthyThreshold, c.settings.HealthyThreshold)
+	if store != nil {
+		go c.watchConfig(store)
+	}
+	return c
+}
+
+// newProbeTransport returns the HTTP transport of probes: a small keep-alive
+// pool so each probe does not pay a new connection, bounded across backends
+func newProbeTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConns = probeMaxIdleConns
+	tr.MaxIdleConnsPerHost = probeMaxIdleConnsPerHost
+	tr.IdleConnTimeout = probeIdleConnTimeout
+	return tr
+}
+
+// normalizeSettings fills in defaults for unset fields
+func normalizeSettings(s config.HealthCheckConfig) config.HealthCheckConfig {
+	if s.Interval <= 0 {
+		s.Interval = defaultInterval
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = defaultTimeout
+	}
+	if s.StatusMin <= 0 || s.StatusMax <= 0 || s.StatusMin > s.StatusMax {
+		s.StatusMin, s.StatusMax = defaultStatusMin, defaultStatusMax
+	}
+	if s.UnhealthyThreshold <= 0 {
+		s.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	if s.HealthyThreshold <= 0 {
+		s.HealthyThreshold = defaultHealthyThreshold
+	}
+	if s.PassiveFailures <= 0 {
+		s.PassiveFailures = defaultPassiveFailures
 	}
+	if s.EjectionWindow <= 0 {
+		s.EjectionWindow = defaultEjectionWindow
+	}
+	if s.HTTPType == "" {
+		s.HTTPType = defaultHTTPType
+	}
+	if s.TCPType == "" {
+		s.TCPType = defaultTCPType
+	}
+	if s.UserAgent == "" {
+		s.UserAgent = DefaultUserAgent
+	}
+	return s
 }
 
 // Start begins periodic health checking
 func (c *UpstreamHealthChecker) Start() {
 	c.wg.Add(1)
 	go c.run()
-	xlog.Infof("Upstream health checker started (interval: %v)", c.interval)
+	xlog.Infof("Upstream health checker started (interval: %v)", c.getSettings().Interval)
 }
 
 // Stop stops the health checker
 func (c *UpstreamHealthChecker) Stop() {
 	close(c.stopChan)
 	c.wg.Wait()
+	c.grpc.close()
+	c.transport.CloseIdleConnections()
 	xlog.Infof("Upstream health checker stopped")
 }
 
-// IsHealthy returns the health status of an upstream
+// IsHealthy returns the health status of an upstream (false while ejected)
 func (c *UpstreamHealthChecker) IsHealthy(upstream string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.healthMap[upstream]
+	state, ok := c.healthMap[upstream]
+	return ok && state.healthy && !state.ejected(time.Now())
+}
+
+// UpstreamStatus is the health of one upstream as reported by Snapshot
+type UpstreamStatus struct {
+	Healthy bool `json:"healthy"`
+	Ejected bool `json:"ejected"`
+}
+
+// Snapshot returns the state of every known upstream. Safe to call on a nil checker.
+func (c *UpstreamHealthChecker) Snapshot() map[string]UpstreamStatus {
+	statuses := make(map[string]UpstreamStatus)
+	if c == nil {
+		return statuses
+	}
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for upstream, state := range c.healthMap {
+		statuses[upstream] = UpstreamStatus{Healthy: state.healthy, Ejected: state.ejected(now)}
+	}
+	return statuses
+}
+
+// Available reports whether an upstream may be selected by a weighted
+// backend: it is not ejected and not known to be unhealthy (upstreams that are
+// not probed count as available). Safe to call on a nil checker.
+func (c *UpstreamHealthChecker) Available(upstream string) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state, ok := c.healthMap[upstream]
+	return !ok || (state.healthy && !state.ejected(time.Now()))
+}
+
+// IsEjected reports whether passive health checking has taken the upstream
+// out of rotation. Safe to call on a nil checker.
+func (c *UpstreamHealthChecker) IsEjected(upstream string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state, ok := c.healthMap[upstream]
+	return ok && state.ejected(time.Now())
+}
+
+// ReportFailure records a proxy-side error (dial failure, upstream reset).
+// Once PassiveFailures errors occur within EjectionWindow, the upstream is
+// ejected for EjectionWindow without waiting for the next active probe.
+// Safe to call on a nil checker.
+func (c *UpstreamHealthChecker) ReportFailure(upstream string) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	settings := c.settings
+	state, ok := c.healthMap[upstream]
+	if !ok {
+		// Not actively probed (e.g. an HTTP route target): assume healthy until ejected
+		state = &upstreamState{healthy: true}
+		c.healthMap[upstream] = state
+	}
+	if state.ejected(now) {
+		c.mu.Unlock()
+		return
+	}
+
+	// Keep only failures inside the window
+	cutoff := now.Add(-settings.EjectionWindow)
+	recent := state.passiveFailures[:0]
+	for _, t := range state.passiveFailures {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	state.passiveFailures = append(recent, now)
+
+	eject := len(state.passiveFailures) >= settings.PassiveFailures
+	if eject {
+		state.ejectedUntil = now.Add(settings.EjectionWindow)
+		state.passiveFailures = nil
+	}
+	c.mu.Unlock()
+
+	if eject {
+		middleware.SetUpstreamHealth(upstream, false)
+		middleware.RecordUpstreamEjection(upstream)
+		xlog.Warnf("Upstream %s ejected for %v after %d proxy errors", upstream, settings.EjectionWindow, settings.PassiveFailures)
+	}
+}
+
+// UpdateSettings applies new probe settings; a changed interval takes effect immediately
+func (c *UpstreamHealthChecker) UpdateSettings(s config.HealthCheckConfig) {
+	s = normalizeSettings(s)
+
+	c.mu.Lock()
+	old := c.settings
+	c.settings = s
+	c.mu.Unlock()
+
+	if s == old {
+		return
+	}
+	if s.Interval != old.Interval {
+		// Drop a pending (stale) interval, then queue the new one
+		select {
+		case <-c.resetChan:
+		default:
+		}
+		c.resetChan <- s.Interval
+	}
+	if s.UnhealthyThreshold != old.UnhealthyThreshold || s.HealthyThreshold != old.HealthyThreshold {
+		middleware.SetHealthCheckThresholds(s.UnhealthyThreshold, s.HealthyThreshold)
+	}
+	xlog.Infof("Health check settings updated: interval=%v, timeout=%v, path=%q, status=%d-%d, thresholds=%d/%d, types=%s/%s, user_agent=%q",
+		s.Interval, s.Timeout, s.Path, s.StatusMin, s.StatusMax, s.UnhealthyThreshold, s.HealthyThreshold, s.HTTPType, s.TCPType, s.UserAgent)
+}
+
+func (c *UpstreamHealthChecker) getSettings() config.HealthCheckConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings
+}
+
+// UpdateWeights sets the weighted backends whose targets are probed
+func (c *UpstreamHealthChecker) UpdateWeights(weights []config.BackendWeights) {
+	c.mu.Lock()
+	c.weights = weights
+	c.mu.Unlock()
+}
+
+// UpdateTargets sets the configured HTTP and TCP backends to probe. Empty
+// values keep the current backend, as the handlers do.
+func (c *UpstreamHealthChecker) UpdateTargets(httpTarget, tcpTarget string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if httpTarget != "" {
+		c.httpTarget = httpTarget
+	}
+	if tcpTarget != "" {
+		c.tcpTarget = tcpTarget
+	}
+}
+
+// watchConfig reloads health check settings, the backends and the weighted
+// targets from Redis on business config updates
+func (c *UpstreamHealthChecker) watchConfig(store *config.RedisStore) {
+	for update := range store.Subscribe() {
+		if !update.Is("business", "health_check", "backend_weights") {
+			continue
+		}
+		businessCfg, err := store.LoadBusinessConfig()
+		if err != nil {
+			xlog.Warnf("Failed to reload health check config from Redis: %v", err)
+			continue
+		}
+		c.UpdateSettings(businessCfg.Backends.HealthCheck)
+		c.UpdateTargets(businessCfg.Backends.HTTP.TargetURL, businessCfg.Backends.TCP.TargetAddr)
+		c.UpdateWeights(businessCfg.Backends.Weights)
+	}
 }
 
 // run performs periodic health checks
@@ -64,65 +337,132 @@ func (c *UpstreamHealthChecker) run() {
 	// Initial check
 	c.checkAll()
 
-	ticker := time.NewTicker(c.interval)
+	ticker := time.NewTicker(c.getSettings().Interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			c.checkAll()
+		case interval := <-c.resetChan:
+			ticker.Reset(interval)
 		case <-c.stopChan:
 			return
 		}
 	}
 }
 
-// checkAll checks all configured upstreams
+// checkAll checks all configured upstreams and the targets of weighted backends
 func (c *UpstreamHealthChecker) checkAll() {
+	settings := c.getSettings()
+	c.mu.RLock()
+	weights := c.weights
+	httpTarget, tcpTarget := c.httpTarget, c.tcpTarget
+	c.mu.RUnlock()
+
+	probed := make(map[string]bool)
+
 	// Check HTTP backend
-	if c.cfg.Backends.HTTP.TargetURL != "" {
-		healthy := c.checkHTTP(c.cfg.Backends.HTTP.TargetURL)
-		c.updateHealth(c.cfg.Backends.HTTP.TargetURL, healthy)
+	if targetURL := httpTarget; targetURL != "" {
+		c.updateHealth(targetURL, c.checkHTTPBackend(targetURL, settings))
+		probed[targetURL] = true
 	}
 
 	// Check TCP backend
-	if c.cfg.Backends.TCP.TargetAddr != "" {
-		healthy := c.checkTCP(c.cfg.Backends.TCP.TargetAddr)
-		c.updateHealth(c.cfg.Backends.TCP.TargetAddr, healthy)
+	if addr := tcpTarget; addr != "" {
+		c.updateHealth(addr, c.checkTCPBackend(addr, settings))
+		probed[addr] = true
+	}
+
+	// Check weighted targets, so unhealthy ones are not selected
+	for _, w := range weights {
+		for target := range w.Targets {
+			if probed[target] {
+				continue
+			}
+			probed[target] = true
+			if w.IsHTTP() {
+				c.updateHealth(target, c.checkHTTPBackend(target, settings))
+			} else {
+				c.updateHealth(target, c.checkTCPBackend(target, settings))
+			}
+		}
+	}
+}
+
+// checkHTTPBackend probes an HTTP backend URL as backends.health_check.http_type says
+func (c *UpstreamHealthChecker) checkHTTPBackend(targetURL string, settings config.HealthCheckConfig) bool {
+	switch settings.HTTPType {
+	case "grpc", "tcp":
+		target, useTLS, err := grpcTargetFromURL(targetURL)
+		if err != nil {
+			xlog.Debugf("Health check: invalid HTTP backend URL %s: %v", targetURL, err)
+			return false
+		}
+		if settings.HTTPType == "grpc" {
+			return c.checkGRPC(target, useTLS, settings)
+		}
+		return c.checkTCP(target, settings)
+	default:
+		return c.checkHTTP(targetURL, settings)
+	}
+}
+
+// checkTCPBackend probes a TCP backend address as backends.health_check.tcp_type says
+func (c *UpstreamHealthChecker) checkTCPBackend(addr string, settings config.HealthCheckConfig) bool {
+	if settings.TCPType == "grpc" {
+		return c.checkGRPC(addr, false, settings)
 	}
+	return c.checkTCP(addr, settings)
 }
 
-// checkHTTP checks HTTP backend health
-func (c *UpstreamHealthChecker) checkHTTP(url string) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// checkGRPC checks backend health with the standard gRPC health checking protocol
+func (c *UpstreamHealthChecker) checkGRPC(target string, useTLS bool, settings config.HealthCheckConfig) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), settings.Timeout)
+	defer cancel()
+	return c.grpc.check(ctx, target, useTLS, settings.GRPCService)
+}
+
+// checkHTTP checks HTTP backend health by requesting TargetURL + health path
+func (c *UpstreamHealthChecker) checkHTTP(targetURL string, settings config.HealthCheckConfig) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), settings.Timeout)
 	defer cancel()
 
+	url := targetURL
+	if settings.Path != "" {
+		url = strings.TrimSuffix(targetURL, "/") + "/" + strings.TrimPrefix(settings.Path, "/")
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		xlog.Debugf("Health check: failed to create HTTP request for %s: %v", url, err)
 		return false
 	}
+	req.Header.Set("User-Agent", settings.UserAgent)
 
-	// Try to hit a health endpoint, or just check if the connection works
-	// If the URL doesn't have a path, try /health or / as fallback
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		xlog.Debugf("Health check: HTTP backend %s is unhealthy: %v", url, err)
+		// Reconnect on the next probe rather than reuse a pooled connection
+		// to a backend that may have restarted
+		c.transport.CloseIdleConnections()
 		return false
 	}
+	// Drain so the connection goes back to the pool
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
 	resp.Body.Close()
 
-	// Consider 2xx and 3xx as healthy
-	healthy := resp.StatusCode >= 200 && resp.StatusCode < 400
+	healthy := resp.StatusCode >= settings.StatusMin && resp.StatusCode <= settings.StatusMax
 	if !healthy {
-		xlog.Debugf("Health check: HTTP backend %s returned status %d", url, resp.StatusCode)
+		xlog.Debugf("Health check: HTTP backend %s returned status %d (expected %d-%d)",
+			url, resp.StatusCode, settings.StatusMin, settings.StatusMax)
 	}
 	return healthy
 }
 
 // checkTCP checks TCP backend health
-func (c *UpstreamHealthChecker) checkTCP(addr string) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), c.tcpTimeout)
+func (c *UpstreamHealthChecker) checkTCP(addr string, settings config.HealthCheckConfig) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), settings.Timeout)
 	defer cancel()
 
 	var d net.Dialer
@@ -135,22 +475,60 @@ func (c *UpstreamHealthChecker) checkTCP(addr string) bool {
 	return true
 }
 
-// updateHealth updates the health status and metrics
-func (c *UpstreamHealthChecker) updateHealth(upstream string, healthy bool) {
+// updateHealth records a probe result and flips the upstream's state only after
+// the configured number of consecutive results. The first probe of an upstream
+// sets its initial state directly.
+func (c *UpstreamHealthChecker) updateHealth(upstream string, probeHealthy bool) {
 	c.mu.Lock()
-	oldHealthy := c.healthMap[upstream]
-	c.healthMap[upstream] = healthy
+	state, known := c.healthMap[upstream]
+	if !known {
+		state = &upstreamState{healthy: probeHealthy}
+		c.healthMap[upstream] = state
+	}
+	changed := false
+	if known {
+		if probeHealthy {
+			state.consecutiveFailures = 0
+			state.consecutiveSuccesses++
+			if !state.healthy && state.consecutiveSuccesses >= c.settings.HealthyThreshold {
+				state.healthy = true
+				changed = true
+			}
+		} else {
+			state.consecutiveSuccesses = 0
+			state.consecutiveFailures++
+			if state.healthy && state.consecutiveFailures >= c.settings.UnhealthyThreshold {
+				state.healthy = false
+				changed = true
+			}
+		}
+	}
+	healthy := state.healthy
+	// An expired ejection hands the upstream back to the active probe state
+	now := time.Now()
+	ejected := state.ejected(now)
+	ejectionEnded := !state.ejectedUntil.IsZero() && !ejected
+	if ejectionEnded {
+		state.ejectedUntil = time.Time{}
+	}
 	c.mu.Unlock()
 
-	// Update Prometheus metric
-	middleware.SetUpstreamHealth(upstream, healthy)
+	if ejectionEnded {
+		xlog.Infof("Upstream %s ejection ended (healthy=%v)", upstream, healthy)
+		middleware.SetUpstreamHealth(upstream, healthy)
+	}
+	if known && !changed {
+		return
+	}
 
-	// Log status changes
-	if oldHealthy != healthy {
-		if healthy {
-			xlog.Infof("Upstream %s is now healthy", upstream)
-		} else {
-			xlog.Warnf("Upstream %s is now unhealthy", upstream)
-		}
+	// Update Prometheus metric and log on initial state or transition only.
+	// The gauge stays at 0 while the upstream is ejected.
+	middleware.SetUpstreamHealth(upstream, healthy && !ejected)
+	if !known {
+		xlog.Infof("Upstream %s initial health: healthy=%v", upstream, healthy)
+	} else if healthy {
+		xlog.Infof("Upstream %s is now healthy", upstream)
+	} else {
+		xlog.Warnf("Upstream %s is now unhealthy", upstream)
 	}
 }
@@ -1,19 +1,26 @@
 package middleware
 
 import (
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/SkynetNext/unified-access-gateway/internal/observability"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/trace"
 )
 
 // CloudNativeMiddleware adds cloud-native headers and tracing
 func CloudNativeMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Shutdown flushes the access log only after this request has logged
+		Instance.Hold()
+		defer Instance.Release()
+
 		// 1. Extract trace context (for distributed tracing)
 		ctx := observability.ExtractTraceContext(r.Context(), r)
 
@@ -40,14 +47,34 @@ func CloudNativeMiddleware(next http.Handler) http.Handler {
 		// 5. Inject trace context into response headers (for downstream services)
 		observability.InjectTraceContext(ctx, r)
 
-		// 6. Add cloud-native headers
+		// 6. Add cloud-native headers. The request ID is kept from the client
+		// (or an upstream proxy) when valid; the proxy forwards it to backends.
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = NewRequestID(ctx)
+		}
+		// One sampling decision per request, shared with the audit entry
+		sampled := sampleHTTPLog()
+		r = r.WithContext(withLogSampled(WithRequestID(r.Context(), requestID), sampled))
+		span.SetAttributes(attribute.String("http.request_id", requestID))
 		w.Header().Set("X-Gateway-Pod", os.Getenv("POD_NAME"))
 		w.Header().Set("X-Gateway-Version", "1.0.0")
-		w.Header().Set("X-Request-ID", trace.SpanContextFromContext(ctx).TraceID().String())
+		w.Header().Set(RequestIDHeader, requestID)
 
 		// 7. Wrap response writer to capture status and bytes
 		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
+		// Streamed (chunked/HTTP2) bodies have no declared length; count what is read
+		var body *countingBody
+		if r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody {
+			body = &countingBody{ReadCloser: r.Body}
+			r.Body = body
+		}
+
+		// Captured before the handler runs, since auth may strip credential headers
+		query := RedactQuery(r.URL.RawQuery)
+		headers := LoggedHeaders(r.Header)
+
 		// 8. Record metrics
 		start := time.Now()
 		next.ServeHTTP(rw, r)
@@ -64,13 +91,35 @@ func CloudNativeMiddleware(next http.Handler) http.Handler {
 		if bytesIn < 0 {
 			bytesIn = 0 // ContentLength can be -1 if unknown
 		}
+		if body != nil {
+			bytesIn = body.n.Load()
+		}
 
 		upstream := r.Header.Get("X-Upstream") // Set by proxy if available
 		if upstream == "" {
 			upstream = "unknown"
 		}
 
-		RecordHTTPMetrics(r.Method, strconv.Itoa(rw.statusCode), upstream, duration.Seconds(), bytesIn, rw.bytesWritten)
+		RecordHTTPMetrics(ctx, r.Method, strconv.Itoa(rw.statusCode), upstream, requestID, duration.Seconds(), bytesIn, rw.bytesWritten)
+
+		// Sampled out unless it failed: errors and denies are always logged
+		if !sampled && rw.statusCode < http.StatusBadRequest {
+			return
+		}
+		Instance.Log(&AccessLog{
+			Timestamp:  start,
+			RequestID:  requestID,
+			ClientIP:   clientIP(r.RemoteAddr),
+			Protocol:   "HTTP",
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      query,
+			Headers:    headers,
+			DurationMs: duration.Milliseconds(),
+			Status:     rw.statusCode,
+			BytesIn:    bytesIn,
+			BytesOut:   rw.bytesWritten,
+		})
 	})
 }
 
@@ -92,11 +141,38 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController
+// (required for Hijack on websocket upgrades and for Flush)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// clientIP strips the port from a remote address
+func clientIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// countingBody counts request body bytes read by the handler. The proxy
+// transport may still be reading from another goroutine, hence the atomic.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
 // K8sProbeMiddleware handles K8s liveness/readiness probes
 func K8sProbeMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// K8s probes use specific User-Agent
-		if r.Header.Get("User-Agent") == "kube-probe/1.0" {
+		// K8s probes and upstream health checks of another gateway use specific User-Agents
+		if ua := r.Header.Get("User-Agent"); strings.HasPrefix(ua, "kube-probe/") || strings.HasPrefix(ua, "uag-healthcheck/") {
 			// Short-circuit for probes (no tracing, no metrics)
 			next.ServeHTTP(w, r)
 			return
@@ -0,0 +1,23 @@
+package middleware
+
+import "net"
+
+// SetConnBackend records the backend a client connection is proxied to on the
+// first wrapper that tracks it (the listener's connection registry, shown by
+// /admin/connections). Wrappers are followed through Unwrap() or NetConn().
+func SetConnBackend(c net.Conn, backend string) {
+	for c != nil {
+		if t, ok := c.(interface{ SetBackend(addr string) }); ok {
+			t.SetBackend(backend)
+			return
+		}
+		switch u := c.(type) {
+		case interface{ Unwrap() net.Conn }:
+			c = u.Unwrap()
+		case interface{ NetConn() net.Conn }:
+			c = u.NetConn()
+		default:
+			return
+		}
+	}
+}
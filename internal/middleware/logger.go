@@ -1,78 +1,207 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
-	
+
+	"github.com/IBM/sarama"
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 )
 
 // AccessLog defines the structure of access logs
 type AccessLog struct {
-	Timestamp   time.Time `json:"ts"`
-	ClientIP    string    `json:"client_ip"`
-	Protocol    string    `json:"protocol"` // HTTP, TCP
-	Method      string    `json:"method,omitempty"` // HTTP only
-	Path        string    `json:"path,omitempty"`   // HTTP only
-	DurationMs  int64     `json:"duration_ms"`
-	Status      int       `json:"status"`
-	BytesIn     int64     `json:"bytes_in"`
-	BytesOut    int64     `json:"bytes_out"`
+	Timestamp  time.Time         `json:"ts"`
+	RequestID  string            `json:"request_id,omitempty"` // HTTP request ID or TCP connection ID
+	ClientIP   string            `json:"client_ip"`
+	Protocol   string            `json:"protocol"`          // HTTP, TCP
+	Method     string            `json:"method,omitempty"`  // HTTP only
+	Path       string            `json:"path,omitempty"`    // HTTP only
+	Query      string            `json:"query,omitempty"`   // HTTP only, redacted
+	Headers    map[string]string `json:"headers,omitempty"` // HTTP only, server.access_log.headers (redacted)
+	DurationMs int64             `json:"duration_ms"`
+	Status     int               `json:"status"`
+	BytesIn    int64             `json:"bytes_in"`
+	BytesOut   int64             `json:"bytes_out"`
 }
 
+// Logger ships access logs to Kafka. Log never blocks: entries are queued and
+// dropped (and counted) when the queue is full or the producer fails.
 type Logger struct {
-	logChan chan *AccessLog
+	logChan  chan *AccessLog
+	producer sarama.AsyncProducer
+	topic    string
+
+	pending atomic.Int64 // Held requests/connections that will still log (see Hold)
+	closed  atomic.Bool  // Set once Shutdown starts draining; later entries are dropped
+
+	stopChan  chan struct{}
+	done      chan struct{} // Closed when the consumer has exited
+	closeOnce sync.Once
 }
 
+// pendingPoll is how often Shutdown checks for held entries
+const pendingPoll = 10 * time.Millisecond
+
+// Instance is the process-wide access logger (nil if disabled)
 var Instance *Logger
 
-func InitLogger(bufferSize int) {
+// InitLogger connects the Kafka producer and starts the consumer.
+// It is a no-op if no brokers are configured.
+func InitLogger(cfg config.AccessLogConfig) error {
+	if len(cfg.KafkaBrokers) == 0 {
+		return nil
+	}
+	if cfg.KafkaTopic == "" {
+		return fmt.Errorf("access log topic is empty")
+	}
+
+	producerCfg := sarama.NewConfig()
+	producerCfg.Producer.RequiredAcks = sarama.WaitForLocal
+	producerCfg.Producer.Return.Successes = false
+	producerCfg.Producer.Return.Errors = true
+	producerCfg.Producer.Flush.Messages = 100
+	producerCfg.Producer.Flush.Frequency = time.Second
+	if cfg.KafkaCompression != "" {
+		if err := producerCfg.Producer.Compression.UnmarshalText([]byte(cfg.KafkaCompression)); err != nil {
+			return fmt.Errorf("invalid access log compression %q: %w", cfg.KafkaCompression, err)
+		}
+	}
+
+	producer, err := sarama.NewAsyncProducer(cfg.KafkaBrokers, producerCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
 	Instance = &Logger{
-		logChan: make(chan *AccessLog, bufferSize),
+		logChan:  make(chan *AccessLog, bufferSize),
+		producer: producer,
+		topic:    cfg.KafkaTopic,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 	go Instance.startConsumer()
+	go Instance.handleErrors()
+	xlog.Infof("Access logs shipped to Kafka: brokers=%v, topic=%s, compression=%s",
+		cfg.KafkaBrokers, cfg.KafkaTopic, producerCfg.Producer.Compression)
+	return nil
 }
 
+// Hold marks an entry that will be logged later (a request or connection in
+// progress), so Shutdown waits for it. Every Hold must be paired with Release.
+// Safe to call on a nil logger.
+func (l *Logger) Hold() {
+	if l != nil {
+		l.pending.Add(1)
+	}
+}
+
+// Release ends a Hold, after the entry was logged (or skipped)
+func (l *Logger) Release() {
+	if l != nil {
+		l.pending.Add(-1)
+	}
+}
+
+// Log queues an entry. Safe to call on a nil logger.
 func (l *Logger) Log(entry *AccessLog) {
+	if l == nil {
+		return
+	}
+	if l.closed.Load() {
+		RecordAccessLogDropped("closed")
+		return
+	}
 	select {
 	case l.logChan <- entry:
 	default:
 		// Buffer full, drop log to prevent blocking main flow
-		xlog.Warnf("Access log buffer full, dropping log")
+		RecordAccessLogDropped("buffer_full")
 	}
 }
 
+// startConsumer hands queued entries to the producer, which batches them
+// (100 messages or 1s, whichever comes first)
 func (l *Logger) startConsumer() {
-	// Simulate batch sending to Kafka
-	// In production, use sarama.AsyncProducer
-	batch := make([]*AccessLog, 0, 100)
-	ticker := time.NewTicker(1 * time.Second)
-	
+	defer close(l.done)
 	for {
 		select {
 		case entry := <-l.logChan:
-			batch = append(batch, entry)
-			if len(batch) >= 100 {
-				l.flushToKafka(batch)
-				batch = batch[:0]
-			}
-		case <-ticker.C:
-			if len(batch) > 0 {
-				l.flushToKafka(batch)
-				batch = batch[:0]
+			l.produce(entry)
+		case <-l.stopChan:
+			// Drain what is already queued before the producer is closed
+			for {
+				select {
+				case entry := <-l.logChan:
+					l.produce(entry)
+				default:
+					return
+				}
 			}
 		}
 	}
 }
 
-func (l *Logger) flushToKafka(logs []*AccessLog) {
-	// Mock: Print to console, actually produce to Kafka Topic
-	xlog.Infof("Flushing %d access logs to Kafka...", len(logs))
-	for _, log := range logs {
-		data, _ := json.Marshal(log)
-		// In real scenario: producer.Input() <- &sarama.ProducerMessage{...}
-		// Print only the first log for demo
-		xlog.Debugf("Kafka Log Payload: %s", string(data))
-		break 
+func (l *Logger) produce(entry *AccessLog) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		RecordAccessLogDropped("encode_error")
+		return
+	}
+	l.producer.Input() <- &sarama.ProducerMessage{
+		Topic: l.topic,
+		Value: sarama.ByteEncoder(data),
+	}
+}
+
+// handleErrors counts failed deliveries; the producer has already retried them
+func (l *Logger) handleErrors() {
+	for perr := range l.producer.Errors() {
+		RecordAccessLogDropped("producer_error")
+		xlog.Debugf("Access log delivery to Kafka failed: %v", perr.Err)
+	}
+}
+
+// Close flushes queued entries and closes the producer without waiting for
+// held entries. Safe to call on a nil logger.
+func (l *Logger) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return l.Shutdown(ctx)
+}
+
+// Shutdown waits until every held entry is logged or ctx is done, then drains
+// the queue, flushes the final batch to Kafka and closes the producer. Entries
+// logged afterwards are dropped (reason "closed"). Safe to call on a nil logger.
+func (l *Logger) Shutdown(ctx context.Context) error {
+	if l == nil {
+		return nil
 	}
+	var err error
+	l.closeOnce.Do(func() {
+		ticker := time.NewTicker(pendingPoll)
+		defer ticker.Stop()
+	wait:
+		for l.pending.Load() > 0 {
+			select {
+			case <-ctx.Done():
+				xlog.Warnf("Access log shutdown: %d requests/connections still in progress, not waiting", l.pending.Load())
+				break wait
+			case <-ticker.C:
+			}
+		}
+		l.closed.Store(true)
+		close(l.stopChan)
+		<-l.done
+		err = l.producer.Close()
+	})
+	return err
 }
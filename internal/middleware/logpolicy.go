@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// redactedValue replaces masked query parameter and header values
+const redactedValue = "REDACTED"
+
+// defaultRedact is masked when server.access_log.redact is unset
+var defaultRedact = []string{
+	"authorization", "proxy-authorization", "cookie", "x-api-key",
+	"access_token", "id_token", "token", "api_key", "apikey", "password", "secret",
+}
+
+// httpLogPolicy is the compiled server.access_log config
+type httpLogPolicy struct {
+	sampleRate uint64
+	redact     map[string]bool // Lowercased query parameter and header names
+	headers    []string        // Canonical names of the headers recorded
+}
+
+var (
+	httpLogPolicyPtr atomic.Pointer[httpLogPolicy]
+	httpLogCounter   atomic.Uint64
+)
+
+func init() {
+	SetHTTPLogPolicy(config.HTTPLogConfig{})
+}
+
+// SetHTTPLogPolicy replaces the sampling rate, redaction list and recorded
+// headers of HTTP audit entries and access logs
+func SetHTTPLogPolicy(cfg config.HTTPLogConfig) {
+	p := &httpLogPolicy{sampleRate: 1, redact: make(map[string]bool)}
+	if cfg.SampleRate > 1 {
+		p.sampleRate = uint64(cfg.SampleRate)
+	}
+	redact := cfg.Redact
+	if len(redact) == 0 {
+		redact = defaultRedact
+	}
+	for _, name := range redact {
+		p.redact[strings.ToLower(name)] = true
+	}
+	for _, name := range cfg.Headers {
+		p.headers = append(p.headers, http.CanonicalHeaderKey(name))
+	}
+
+	if old := httpLogPolicyPtr.Swap(p); old != nil && old.sampleRate != p.sampleRate {
+		xlog.Infof("HTTP log sample rate updated: 1 in %d", p.sampleRate)
+	}
+}
+
+// sampleHTTPLog decides whether an allowed request is logged (1 in sample_rate)
+func sampleHTTPLog() bool {
+	rate := httpLogPolicyPtr.Load().sampleRate
+	return rate <= 1 || httpLogCounter.Add(1)%rate == 0
+}
+
+type logSampledKey struct{}
+
+// withLogSampled records the sampling decision for a request, so the audit
+// entry and the access log of one request are kept or dropped together
+func withLogSampled(ctx context.Context, sampled bool) context.Context {
+	return context.WithValue(ctx, logSampledKey{}, sampled)
+}
+
+// LogSampled reports whether the request's allow entries should be written.
+// Requests that did not pass through CloudNativeMiddleware are always logged.
+func LogSampled(ctx context.Context) bool {
+	sampled, ok := ctx.Value(logSampledKey{}).(bool)
+	return !ok || sampled
+}
+
+// RedactQuery masks the values of redacted parameters in a raw query string,
+// keeping the order and encoding of the others
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	redact := httpLogPolicyPtr.Load().redact
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, hasValue := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if hasValue && redact[strings.ToLower(name)] {
+			params[i] = key + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// LoggedHeaders returns the configured request headers present in h, with
+// redacted ones masked (nil if none are configured or present)
+func LoggedHeaders(h http.Header) map[string]string {
+	p := httpLogPolicyPtr.Load()
+	var logged map[string]string
+	for _, name := range p.headers {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if logged == nil {
+			logged = make(map[string]string, len(p.headers))
+		}
+		if p.redact[strings.ToLower(name)] {
+			logged[name] = redactedValue
+		} else {
+			logged[name] = strings.Join(values, ", ")
+		}
+	}
+	return logged
+}
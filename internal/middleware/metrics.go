@@ -1,8 +1,28 @@
 package middleware
 
 import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sizeBuckets are payload size buckets: 64B, 256B, 1KB ... 16MB, 64MB
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 11)
+
+// Default latency buckets (1ms to 10s), replaced by metrics.request_duration_buckets
+// and metrics.upstream_duration_buckets
+var (
+	DefaultRequestDurationBuckets  = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	DefaultUpstreamDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
 )
 
 var (
@@ -22,15 +42,8 @@ var (
 
 	// RequestDuration: Request latency histogram (Histogram)
 	// Labels: protocol, method, upstream
-	// Buckets optimized for gateway latency (1ms to 10s)
-	RequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "gateway_request_duration_seconds",
-			Help:    "Request latency in seconds",
-			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
-		},
-		[]string{"protocol", "method", "upstream"},
-	)
+	// Buckets are configurable, so it is registered by InitMetrics, not at init
+	RequestDuration = newRequestDuration(DefaultRequestDurationBuckets)
 
 	// RequestBytes: Request/Response bytes (Counter)
 	// Labels: protocol, direction (in/out)
@@ -42,6 +55,47 @@ var (
 		[]string{"protocol", "direction"},
 	)
 
+	// HTTPResponsesTotal: HTTP responses by normalized status class (Counter)
+	// Labels: method, status_class (1xx-5xx), upstream
+	// Bounded alternative to the raw status label of gateway_requests_total
+	HTTPResponsesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_http_responses_total",
+			Help: "Total HTTP responses by status class",
+		},
+		[]string{"method", "status_class", "upstream"},
+	)
+
+	// HTTPRequestSize / HTTPResponseSize: HTTP body sizes (Histogram)
+	// Labels: upstream
+	// Buckets from 64B to 64MB in powers of 4, covering small API calls up to uploads
+	HTTPRequestSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gateway_http_request_size_bytes",
+			Help:    "HTTP request body size in bytes",
+			Buckets: sizeBuckets,
+		},
+		[]string{"upstream"},
+	)
+	HTTPResponseSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gateway_http_response_size_bytes",
+			Help:    "HTTP response body size in bytes",
+			Buckets: sizeBuckets,
+		},
+		[]string{"upstream"},
+	)
+
+	// AccessLogsDropped: Access log entries not delivered (Counter)
+	// Labels: reason (buffer_full, encode_error, producer_error, closed)
+	AccessLogsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_access_logs_dropped_total",
+			Help: "Total access log entries dropped before reaching Kafka",
+		},
+		[]string{"reason"},
+	)
+
 	// ============================================================================
 	// Connection Metrics
 	// ============================================================================
@@ -77,6 +131,81 @@ var (
 		[]string{"protocol"},
 	)
 
+	// ListenerConnections: Client connections currently holding a listener slot (Gauge)
+	// Compared against server.max_connections
+	ListenerConnections = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_listener_connections",
+			Help: "Current number of client connections counted against max_connections",
+		},
+	)
+
+	// InflightHandlers: Connection handler goroutines currently running (Gauge)
+	// Compared against server.max_handlers
+	InflightHandlers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_listener_inflight_handlers",
+			Help: "Current number of connection handler goroutines, bounded by max_handlers",
+		},
+	)
+
+	// ConnectionsRejectedTotal: Connections refused at accept time (Counter)
+	// Labels: reason (max_connections, handlers_saturated, etc.)
+	ConnectionsRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_connections_rejected_total",
+			Help: "Total connections rejected by the listener before dispatch",
+		},
+		[]string{"reason"},
+	)
+
+	// SniffTotal: Protocol classification of sniffed connections (Counter)
+	// Labels: protocol (http, h2c, tcp, tls, unknown)
+	SniffTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_sniff_total",
+			Help: "Total connections classified by the protocol sniffer",
+		},
+		[]string{"protocol"},
+	)
+
+	// SniffTimeoutsTotal: Sniff deadlines reached before classification (Counter)
+	// Labels: outcome (no_data: treated as TCP, partial: classified on the bytes received)
+	SniffTimeoutsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_sniff_timeouts_total",
+			Help: "Total connections whose first bytes did not arrive within server.sniff_timeout",
+		},
+		[]string{"outcome"},
+	)
+
+	// UDPActiveSessions: Current active UDP relay sessions (Gauge)
+	// UDP has no connection close, sessions expire after an idle timeout
+	UDPActiveSessions = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_udp_active_sessions",
+			Help: "Current number of active UDP relay sessions",
+		},
+	)
+
+	// EBPFSockMapPairs: Socket pairs currently in the eBPF sock_pair_map (Gauge)
+	EBPFSockMapPairs = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_ebpf_sockmap_pairs",
+			Help: "Current number of socket pairs registered for eBPF redirection",
+		},
+	)
+
+	// EBPFRegistrationTotal: eBPF socket pair registrations per proxied TCP connection (Counter)
+	// Labels: result (registered, failed, disabled), reason (failure cause, "" otherwise)
+	EBPFRegistrationTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_ebpf_registration_total",
+			Help: "Total TCP connections by eBPF sockmap registration outcome",
+		},
+		[]string{"result", "reason"},
+	)
+
 	// ============================================================================
 	// Upstream/Backend Metrics
 	// ============================================================================
@@ -93,14 +222,8 @@ var (
 
 	// UpstreamDuration: Upstream response time (Histogram)
 	// Labels: upstream
-	UpstreamDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "gateway_upstream_duration_seconds",
-			Help:    "Upstream response time in seconds",
-			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
-		},
-		[]string{"upstream"},
-	)
+	// Buckets are configurable, so it is registered by InitMetrics, not at init
+	UpstreamDuration = newUpstreamDuration(DefaultUpstreamDurationBuckets)
 
 	// UpstreamHealth: Upstream health status (Gauge, 1=healthy, 0=unhealthy)
 	// Labels: upstream
@@ -112,6 +235,66 @@ var (
 		[]string{"upstream"},
 	)
 
+	// UpstreamRetriesTotal: Retried upstream requests (Counter)
+	// Labels: upstream, reason (error or status code)
+	UpstreamRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_upstream_retries_total",
+			Help: "Total upstream request retries",
+		},
+		[]string{"upstream", "reason"},
+	)
+
+	// TCPPoolRequestsTotal: TCP backend pool lookups (Counter)
+	// Labels: upstream, result (hit/miss)
+	TCPPoolRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_tcp_pool_requests_total",
+			Help: "Total TCP backend connection pool lookups by result",
+		},
+		[]string{"upstream", "result"},
+	)
+
+	// BackendSelectionsTotal: Weighted backend selections (Counter)
+	// Labels: backend (the configured backend), target (the one selected)
+	BackendSelectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_backend_selections_total",
+			Help: "Total connections and requests sent to each target of a weighted backend",
+		},
+		[]string{"backend", "target"},
+	)
+
+	// UpstreamEjectionsTotal: Upstreams ejected by passive health checking (Counter)
+	// Labels: upstream
+	UpstreamEjectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_upstream_ejections_total",
+			Help: "Total upstream ejections caused by repeated proxy errors",
+		},
+		[]string{"upstream"},
+	)
+
+	// CircuitBreakerState: Upstream circuit breaker state (Gauge)
+	// Labels: upstream. Values: 0=closed, 1=open, 2=half-open
+	CircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_circuit_breaker_state",
+			Help: "Upstream circuit breaker state (0=closed, 1=open, 2=half-open)",
+		},
+		[]string{"upstream"},
+	)
+
+	// UpstreamHealthCheckInfo: Active health check thresholds (Gauge, always 1)
+	// Labels: unhealthy_threshold, healthy_threshold
+	UpstreamHealthCheckInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_upstream_health_check_info",
+			Help: "Health check flap suppression thresholds (value is always 1)",
+		},
+		[]string{"unhealthy_threshold", "healthy_threshold"},
+	)
+
 	// ============================================================================
 	// Security & Policy Metrics
 	// ============================================================================
@@ -135,37 +318,295 @@ var (
 		},
 		[]string{"limit_name"},
 	)
+
+	// RateLimitDecisions: Rate limiter decisions (Counter)
+	// Labels: limit_name, outcome (allowed, limited)
+	RateLimitDecisions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_ratelimit_decisions_total",
+			Help: "Total rate limiter decisions by outcome",
+		},
+		[]string{"limit_name", "outcome"},
+	)
+
+	// RateLimitConfig: Configured rate limit (Gauge, 0 when disabled)
+	// Labels: limit_name, setting (rps, burst)
+	RateLimitConfig = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_ratelimit_config",
+			Help: "Configured rate limit settings (0 when disabled)",
+		},
+		[]string{"limit_name", "setting"},
+	)
+
+	// ============================================================================
+	// Config Store Metrics
+	// ============================================================================
+
+	// RedisPubSubReconnects: Config pub/sub resubscriptions after a lost connection (Counter)
+	RedisPubSubReconnects = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "gateway_redis_pubsub_reconnects_total",
+			Help: "Total config pub/sub reconnections (each triggers a full config reload)",
+		},
+	)
+
+	// ConfigReloads: Config updates applied from Redis pub/sub (Counter)
+	// Labels: type (update type, "other" for unknown types), result (success, error)
+	ConfigReloads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_config_reloads_total",
+			Help: "Total config updates received from Redis and applied, by type and result",
+		},
+		[]string{"type", "result"},
+	)
+
+	// ConfigLastReload: Unix time of the last successfully applied config update (Gauge)
+	// Alert on time() - gateway_config_last_reload_timestamp_seconds together with config pushes
+	ConfigLastReload = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_config_last_reload_timestamp_seconds",
+			Help: "Unix time of the last successfully applied config update",
+		},
+	)
+
+	// ConfigApplyDuration: Time to apply a config update once received (Histogram)
+	// Labels: type
+	ConfigApplyDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gateway_config_apply_duration_seconds",
+			Help:    "Time to apply a config update received from Redis",
+			Buckets: []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+		},
+		[]string{"type"},
+	)
+
+	// ConfigVersion: Config version last applied by this gateway (Gauge)
+	// Replicas reporting different versions for long show stalled propagation
+	ConfigVersion = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "gateway_config_version",
+			Help: "Config version last applied from Redis (0 before the first versioned write)",
+		},
+	)
 )
 
-// RecordHTTPMetrics records comprehensive HTTP request metrics
-func RecordHTTPMetrics(method, status, upstream string, durationSeconds float64, bytesIn, bytesOut int64) {
+func newRequestDuration(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gateway_request_duration_seconds",
+			Help:    "Request latency in seconds",
+			Buckets: buckets,
+		},
+		[]string{"protocol", "method", "upstream"},
+	)
+}
+
+func newUpstreamDuration(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gateway_upstream_duration_seconds",
+			Help:    "Upstream response time in seconds",
+			Buckets: buckets,
+		},
+		[]string{"upstream"},
+	)
+}
+
+var initMetricsOnce sync.Once
+
+// InitMetrics creates the latency histograms with the configured buckets and
+// registers them. Call it once the config is loaded and before serving
+// traffic; later calls are no-ops.
+func InitMetrics(cfg config.MetricsConfig) {
+	initMetricsOnce.Do(func() {
+		RequestDuration = newRequestDuration(durationBuckets("request_duration_buckets", cfg.RequestDurationBuckets, DefaultRequestDurationBuckets))
+		UpstreamDuration = newUpstreamDuration(durationBuckets("upstream_duration_buckets", cfg.UpstreamDurationBuckets, DefaultUpstreamDurationBuckets))
+		prometheus.MustRegister(RequestDuration, UpstreamDuration)
+	})
+}
+
+// durationBuckets returns the configured buckets if they are positive and
+// strictly increasing, else the defaults
+func durationBuckets(name string, configured, defaults []float64) []float64 {
+	if len(configured) == 0 {
+		return defaults
+	}
+	for i, b := range configured {
+		if b <= 0 || (i > 0 && b <= configured[i-1]) {
+			xlog.Warnf("metrics.%s %v must be positive and strictly increasing, using the defaults", name, configured)
+			return defaults
+		}
+	}
+	xlog.Infof("metrics.%s: %v", name, configured)
+	return configured
+}
+
+// RecordHTTPMetrics records comprehensive HTTP request metrics. The request
+// ID, and the trace ID of ctx's span when sampled, are attached to the duration
+// sample as an exemplar.
+func RecordHTTPMetrics(ctx context.Context, method, status, upstream, requestID string, durationSeconds float64, bytesIn, bytesOut int64) {
 	RequestsTotal.WithLabelValues("http", method, status, upstream).Inc()
-	RequestDuration.WithLabelValues("http", method, upstream).Observe(durationSeconds)
+	observeWithExemplar(RequestDuration.WithLabelValues("http", method, upstream), durationSeconds, durationExemplar(ctx, "request_id", requestID))
 	RequestBytes.WithLabelValues("http", "in").Add(float64(bytesIn))
 	RequestBytes.WithLabelValues("http", "out").Add(float64(bytesOut))
+	HTTPResponsesTotal.WithLabelValues(method, StatusClass(status), upstream).Inc()
+	HTTPRequestSize.WithLabelValues(upstream).Observe(float64(bytesIn))
+	HTTPResponseSize.WithLabelValues(upstream).Observe(float64(bytesOut))
+}
+
+// StatusClass normalizes a status code or status line ("404", "404 Not Found")
+// to its class ("4xx"). Anything else is "unknown".
+func StatusClass(status string) string {
+	status = strings.TrimSpace(status)
+	if len(status) < 3 || status[0] < '1' || status[0] > '5' {
+		return "unknown"
+	}
+	for i := 1; i < 3; i++ {
+		if status[i] < '0' || status[i] > '9' {
+			return "unknown"
+		}
+	}
+	if len(status) > 3 && status[3] != ' ' {
+		return "unknown"
+	}
+	return status[:1] + "xx"
 }
 
-// RecordTCPMetrics records TCP connection metrics
-func RecordTCPMetrics(upstream string, durationSeconds float64, bytesIn, bytesOut int64) {
+// RecordTCPMetrics records TCP connection metrics, with the connection ID (and
+// the sampled trace ID of ctx) as exemplar of the duration samples
+func RecordTCPMetrics(ctx context.Context, upstream, connID string, durationSeconds float64, bytesIn, bytesOut int64) {
 	RequestsTotal.WithLabelValues("tcp", "tcp", "success", upstream).Inc()
-	RequestDuration.WithLabelValues("tcp", "tcp", upstream).Observe(durationSeconds)
+	observeWithExemplar(RequestDuration.WithLabelValues("tcp", "tcp", upstream), durationSeconds, durationExemplar(ctx, "conn_id", connID))
 	RequestBytes.WithLabelValues("tcp", "in").Add(float64(bytesIn))
 	RequestBytes.WithLabelValues("tcp", "out").Add(float64(bytesOut))
 }
 
+// durationExemplar returns the exemplar labels of a duration sample: {name: id}
+// and, only for sampled spans (unsampled trace IDs lead nowhere), trace_id.
+// It returns nil when there is neither.
+func durationExemplar(ctx context.Context, name, id string) prometheus.Labels {
+	var labels prometheus.Labels
+	if id != "" {
+		labels = prometheus.Labels{name: id}
+	}
+	// Always keyed trace_id, even when the request ID is the trace ID, so
+	// dashboards can link on one label (at most 114 of the 128 runes allowed)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		if labels == nil {
+			labels = prometheus.Labels{}
+		}
+		labels["trace_id"] = sc.TraceID().String()
+	}
+	return labels
+}
+
+// observeWithExemplar observes v with the exemplar labels (exposed in the
+// OpenMetrics format), or plainly when there are none
+func observeWithExemplar(o prometheus.Observer, v float64, labels prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && len(labels) > 0 {
+		eo.ObserveWithExemplar(v, labels)
+		return
+	}
+	o.Observe(v)
+}
+
+// RecordUDPMetrics records UDP session metrics when a session expires
+func RecordUDPMetrics(upstream string, durationSeconds float64, bytesIn, bytesOut int64) {
+	RequestsTotal.WithLabelValues("udp", "udp", "success", upstream).Inc()
+	RequestDuration.WithLabelValues("udp", "udp", upstream).Observe(durationSeconds)
+	RequestBytes.WithLabelValues("udp", "in").Add(float64(bytesIn))
+	RequestBytes.WithLabelValues("udp", "out").Add(float64(bytesOut))
+	ConnectionDuration.WithLabelValues("udp").Observe(durationSeconds)
+}
+
 // RecordMetrics is kept for backward compatibility
 func RecordMetrics(protocol string, status string, durationSeconds float64) {
 	RequestsTotal.WithLabelValues(protocol, "unknown", status, "unknown").Inc()
 	RequestDuration.WithLabelValues(protocol, "unknown", "unknown").Observe(durationSeconds)
 }
 
+// activeByProtocol mirrors the ActiveConnections gauge for cheap reads (protocol -> *int64)
+var activeByProtocol sync.Map
+
+func activeCounter(protocol string) *int64 {
+	if v, ok := activeByProtocol.Load(protocol); ok {
+		return v.(*int64)
+	}
+	v, _ := activeByProtocol.LoadOrStore(protocol, new(int64))
+	return v.(*int64)
+}
+
 func IncActiveConnections(protocol string) {
 	ActiveConnections.WithLabelValues(protocol).Inc()
 	ConnectionsTotal.WithLabelValues(protocol).Inc()
+	atomic.AddInt64(activeCounter(protocol), 1)
 }
 
 func DecActiveConnections(protocol string) {
 	ActiveConnections.WithLabelValues(protocol).Dec()
+	atomic.AddInt64(activeCounter(protocol), -1)
+}
+
+// ActiveConnectionCounts returns the current active connections per protocol
+func ActiveConnectionCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	activeByProtocol.Range(func(k, v interface{}) bool {
+		counts[k.(string)] = atomic.LoadInt64(v.(*int64))
+		return true
+	})
+	return counts
+}
+
+// RecordAccessLogDropped counts an access log entry that was not delivered
+func RecordAccessLogDropped(reason string) {
+	AccessLogsDropped.WithLabelValues(reason).Inc()
+}
+
+// IncUDPSessions increments the active UDP session gauge
+func IncUDPSessions() {
+	UDPActiveSessions.Inc()
+	ConnectionsTotal.WithLabelValues("udp").Inc()
+}
+
+// DecUDPSessions decrements the active UDP session gauge
+func DecUDPSessions() {
+	UDPActiveSessions.Dec()
+}
+
+// SetEBPFSockMapPairs sets the registered eBPF socket pair gauge
+func SetEBPFSockMapPairs(n int) {
+	EBPFSockMapPairs.Set(float64(n))
+}
+
+// RecordEBPFRegistration counts the eBPF registration outcome of a TCP connection
+func RecordEBPFRegistration(result, reason string) {
+	EBPFRegistrationTotal.WithLabelValues(result, reason).Inc()
+}
+
+// SetListenerConnections sets the listener slot usage gauge
+func SetListenerConnections(n int64) {
+	ListenerConnections.Set(float64(n))
+}
+
+// SetInflightHandlers sets the running connection handler gauge
+func SetInflightHandlers(n int64) {
+	InflightHandlers.Set(float64(n))
+}
+
+// RecordConnectionRejected records a connection refused by the listener
+func RecordConnectionRejected(reason string) {
+	ConnectionsRejectedTotal.WithLabelValues(reason).Inc()
+}
+
+// RecordSniff records the protocol a connection was classified as
+func RecordSniff(protocol string) {
+	SniffTotal.WithLabelValues(protocol).Inc()
+}
+
+// RecordSniffTimeout records a sniff deadline reached before classification
+func RecordSniffTimeout(outcome string) {
+	SniffTimeoutsTotal.WithLabelValues(outcome).Inc()
 }
 
 // RecordConnectionDuration records connection lifetime
@@ -188,6 +629,37 @@ func SetUpstreamHealth(upstream string, healthy bool) {
 	UpstreamHealth.WithLabelValues(upstream).Set(health)
 }
 
+// RecordUpstreamRetry records a retried upstream request
+func RecordUpstreamRetry(upstream, reason string) {
+	UpstreamRetriesTotal.WithLabelValues(upstream, reason).Inc()
+}
+
+// RecordTCPPoolRequest records a TCP backend pool hit or miss
+func RecordTCPPoolRequest(upstream, result string) {
+	TCPPoolRequestsTotal.WithLabelValues(upstream, result).Inc()
+}
+
+// RecordBackendSelection records the target chosen for a weighted backend
+func RecordBackendSelection(backend, target string) {
+	BackendSelectionsTotal.WithLabelValues(backend, target).Inc()
+}
+
+// RecordUpstreamEjection records a passive health check ejection
+func RecordUpstreamEjection(upstream string) {
+	UpstreamEjectionsTotal.WithLabelValues(upstream).Inc()
+}
+
+// SetCircuitBreakerState sets an upstream's circuit breaker state
+func SetCircuitBreakerState(upstream string, state int) {
+	CircuitBreakerState.WithLabelValues(upstream).Set(float64(state))
+}
+
+// SetHealthCheckThresholds publishes the current health check thresholds
+func SetHealthCheckThresholds(unhealthy, healthy int) {
+	UpstreamHealthCheckInfo.Reset()
+	UpstreamHealthCheckInfo.WithLabelValues(strconv.Itoa(unhealthy), strconv.Itoa(healthy)).Set(1)
+}
+
 // RecordSecurityBlock records a security block event
 func RecordSecurityBlock(reason string) {
 	SecurityBlocksTotal.WithLabelValues(reason).Inc()
@@ -197,3 +669,38 @@ func RecordSecurityBlock(reason string) {
 func RecordRateLimitHit(limitName string) {
 	RateLimitHits.WithLabelValues(limitName).Inc()
 }
+
+// RecordRedisReconnect records a config pub/sub reconnection
+func RecordRedisReconnect() {
+	RedisPubSubReconnects.Inc()
+}
+
+// RecordConfigReload records an applied (or failed) config update and its apply time
+func RecordConfigReload(updateType string, err error, duration time.Duration) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	ConfigReloads.WithLabelValues(updateType, result).Inc()
+	ConfigApplyDuration.WithLabelValues(updateType).Observe(duration.Seconds())
+	if err == nil {
+		ConfigLastReload.SetToCurrentTime()
+	}
+}
+
+// SetConfigVersion publishes the config version applied by this gateway
+func SetConfigVersion(version int64) {
+	ConfigVersion.Set(float64(version))
+}
+
+// SetRateLimitConfig publishes the configured rate and burst for a limit
+func SetRateLimitConfig(limitName string, rps float64, burst int) {
+	RateLimitConfig.WithLabelValues(limitName, "rps").Set(rps)
+	RateLimitConfig.WithLabelValues(limitName, "burst").Set(float64(burst))
+}
+
+// ClearRateLimitConfig removes the settings of a limit that no longer exists
+func ClearRateLimitConfig(limitName string) {
+	RateLimitConfig.DeleteLabelValues(limitName, "rps")
+	RateLimitConfig.DeleteLabelValues(limitName, "burst")
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID to backends and back to clients
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds accepted incoming IDs (and keeps exemplars under the
+// 128 character label limit)
+const maxRequestIDLen = 64
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request (or connection) ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID stored by WithRequestID, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns the trace ID of ctx's span when it is sampled or
+// propagated (so logs and traces share one ID), or else a random 128-bit hex ID
+func NewRequestID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.TraceID().IsValid() {
+		return sc.TraceID().String()
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID accepts IDs from clients and upstream proxies: at most
+// maxRequestIDLen characters of [A-Za-z0-9._:/+=-], so they are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
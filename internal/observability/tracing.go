@@ -2,12 +2,16 @@ package observability
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
@@ -16,18 +20,18 @@ import (
 )
 
 var (
-	tracer trace.Tracer
+	tracer   trace.Tracer
+	provider *tracesdk.TracerProvider
 )
 
-// InitTracing initializes OpenTelemetry tracing
-func InitTracing(serviceName, jaegerEndpoint string) error {
-	if jaegerEndpoint == "" {
+// InitTracing initializes OpenTelemetry tracing with the configured exporter and sampler
+func InitTracing(serviceName string, cfg config.TracingConfig) error {
+	if cfg.Endpoint == "" {
 		// Tracing disabled
 		return nil
 	}
 
-	// Create Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(jaegerEndpoint)))
+	exp, err := newExporter(cfg)
 	if err != nil {
 		return err
 	}
@@ -38,7 +42,7 @@ func InitTracing(serviceName, jaegerEndpoint string) error {
 		semconv.ServiceNameKey.String(serviceName),
 		semconv.ServiceVersionKey.String("1.0.0"),
 	}
-	
+
 	// Add Pod information if running in Kubernetes
 	if podName := os.Getenv("POD_NAME"); podName != "" {
 		attrs = append(attrs, semconv.ServiceInstanceIDKey.String(podName))
@@ -46,7 +50,7 @@ func InitTracing(serviceName, jaegerEndpoint string) error {
 	if podNamespace := os.Getenv("POD_NAMESPACE"); podNamespace != "" {
 		attrs = append(attrs, semconv.ServiceNamespaceKey.String(podNamespace))
 	}
-	
+
 	res := resource.NewWithAttributes(
 		semconv.SchemaURL,
 		attrs...,
@@ -55,9 +59,11 @@ func InitTracing(serviceName, jaegerEndpoint string) error {
 	// Create tracer provider
 	tp := tracesdk.NewTracerProvider(
 		tracesdk.WithBatcher(exp),
+		tracesdk.WithSampler(newSampler(cfg)),
 		tracesdk.WithResource(res),
 	)
 
+	provider = tp
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
@@ -68,6 +74,54 @@ func InitTracing(serviceName, jaegerEndpoint string) error {
 	return nil
 }
 
+// newExporter creates the span exporter selected by cfg.Exporter
+func newExporter(cfg config.TracingConfig) (tracesdk.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "otlp-grpc", "otlp", "":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		// Connection is established lazily, so a missing collector does not block startup
+		return otlptracegrpc.New(context.Background(), opts...)
+	case "otlp-http":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	case "jaeger":
+		// Legacy: the OTel Jaeger exporter is deprecated, prefer OTLP
+		return jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.Endpoint)))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (expected otlp-grpc, otlp-http or jaeger)", cfg.Exporter)
+	}
+}
+
+// newSampler builds a ratio sampler, optionally respecting the parent's decision
+func newSampler(cfg config.TracingConfig) tracesdk.Sampler {
+	ratio := cfg.SampleRatio
+	if ratio < 0 {
+		ratio = 0
+	} else if ratio > 1 {
+		ratio = 1
+	}
+	sampler := tracesdk.TraceIDRatioBased(ratio)
+	if cfg.ParentBased {
+		return tracesdk.ParentBased(sampler)
+	}
+	return sampler
+}
+
+// ShutdownTracing flushes buffered spans and stops the exporter.
+// Safe to call if tracing was never initialized.
+func ShutdownTracing(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
 // GetTracer returns the global tracer
 func GetTracer() trace.Tracer {
 	if tracer == nil {
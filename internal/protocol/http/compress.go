@@ -0,0 +1,274 @@
+package http
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// defaultCompressMinBytes applies when backends.http.compression.min_bytes is unset
+const defaultCompressMinBytes = 1024
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	zlibWriters = sync.Pool{New: func() interface{} { return zlib.NewWriter(nil) }}
+)
+
+// compressor is implemented by *gzip.Writer and *zlib.Writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// UpdateCompression sets the response compression settings
+func (h *Handler) UpdateCompression(cfg config.CompressionConfig) {
+	if cfg.MinBytes <= 0 {
+		cfg.MinBytes = defaultCompressMinBytes
+	}
+	if old := h.compression.Swap(&cfg); old == nil || *old != cfg {
+		if cfg.Enabled || old != nil {
+			xlog.Infof("HTTP response compression updated: enabled=%v, min_bytes=%d", cfg.Enabled, cfg.MinBytes)
+		}
+	}
+}
+
+// newCompressWriter wraps w when compression is enabled and the client
+// accepts gzip or deflate. It returns nil when the response is sent as is.
+func (h *Handler) newCompressWriter(w http.ResponseWriter, r *http.Request) *compressWriter {
+	cfg := h.compression.Load()
+	if cfg == nil || !cfg.Enabled || r.Method == http.MethodHead || isWebSocketUpgrade(r) {
+		return nil
+	}
+	encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+	return &compressWriter{ResponseWriter: w, encoding: encoding, minBytes: cfg.MinBytes}
+}
+
+// negotiateEncoding picks gzip, else deflate, from the Accept-Encoding header
+// values ("" if neither is acceptable)
+func negotiateEncoding(values []string) string {
+	gzipQ, deflateQ, anyQ := -1.0, -1.0, -1.0
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			q := 1.0
+			if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					f = 0
+				}
+				q = f
+			}
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "gzip", "x-gzip":
+				gzipQ = q
+			case "deflate":
+				deflateQ = q
+			case "*":
+				anyQ = q
+			}
+		}
+	}
+	if gzipQ < 0 {
+		gzipQ = anyQ
+	}
+	if deflateQ < 0 {
+		deflateQ = anyQ
+	}
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressibleType reports whether a Content-Type is worth compressing:
+// text (except event streams, which must not be buffered) and JSON, XML and
+// JavaScript documents. Images, archives and media are already compressed.
+func compressibleType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/x-javascript",
+		"application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter compresses the response once it is known to be compressible
+// and at least minBytes long. Until then the status and the first bytes are
+// held back, so a short response can still go out unchanged. The wrapped
+// writer sees (and the byte metrics count) the compressed body.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minBytes int64
+
+	status  int    // Held status (0 = none yet)
+	decided bool   // Status sent, compressing or passing through
+	buf     []byte // Held body while undecided
+	enc     compressor
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	if code < http.StatusOK {
+		// 1xx informational responses (e.g. 103 Early Hints) go out directly
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+
+	header := cw.Header()
+	if code == http.StatusNoContent || code == http.StatusPartialContent || code == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || !compressibleType(header.Get("Content-Type")) {
+		cw.passThrough()
+		return
+	}
+	// A declared length decides right away
+	if v := header.Get("Content-Length"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			if n < cw.minBytes {
+				cw.passThrough()
+			} else {
+				cw.startCompression()
+			}
+		}
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 && !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if int64(len(cw.buf)) >= cw.minBytes {
+		cw.startCompression()
+		if err := cw.writeHeld(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush decides on what is held so far (streamed responses cannot wait for
+// minBytes), then flushes the compressor and the connection. A flush before
+// any body is held is ignored: the proxy flushes right after the headers of
+// responses without a Content-Length.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if len(cw.buf) == 0 {
+			return
+		}
+		if int64(len(cw.buf)) >= cw.minBytes {
+			cw.startCompression()
+		} else {
+			cw.passThrough()
+		}
+		if err := cw.writeHeld(); err != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close sends a held response as is and finishes the compressed stream. It
+// must be called once the proxy has written the response.
+func (cw *compressWriter) Close() error {
+	if !cw.decided && cw.status != 0 {
+		cw.passThrough()
+		if err := cw.writeHeld(); err != nil {
+			return err
+		}
+	}
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	cw.enc.Reset(nil)
+	if cw.encoding == "gzip" {
+		gzipWriters.Put(cw.enc)
+	} else {
+		zlibWriters.Put(cw.enc)
+	}
+	cw.enc = nil
+	return err
+}
+
+func (cw *compressWriter) passThrough() {
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) startCompression() {
+	cw.decided = true
+	header := cw.Header()
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	header.Add("Vary", "Accept-Encoding")
+	// The compressed body is a different representation of the resource
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	if cw.encoding == "gzip" {
+		cw.enc = gzipWriters.Get().(*gzip.Writer)
+	} else {
+		cw.enc = zlibWriters.Get().(*zlib.Writer)
+	}
+	cw.enc.Reset(cw.ResponseWriter)
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// writeHeld writes the body held while undecided
+func (cw *compressWriter) writeHeld() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"golang.org/x/net/http2"
+)
+
+// h2cKey marks request contexts of connections served as h2c
+type h2cKey struct{}
+
+// newUpstreamH2CTransport speaks cleartext HTTP/2 (prior knowledge) to
+// upstreams with the dialer and idle timeout of the HTTP/1.x transport.
+// http2.Transport has no response header timeout.
+func newUpstreamH2CTransport(t upstreamTimeouts) *http2.Transport {
+	dial := upstreamDialer(t)
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+		IdleConnTimeout: t.idleConn,
+	}
+}
+
+// protocolTransport forwards h2c requests to http:// upstreams over h2c, so
+// gRPC keeps HTTP/2 end to end; everything else uses next. https upstreams
+// negotiate HTTP/2 through ALPN in next.
+type protocolTransport struct {
+	next *upstreamTransport
+}
+
+func (t protocolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" && req.Context().Value(h2cKey{}) != nil {
+		return t.next.roundTripH2C(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// ServeH2C serves a connection that opened with the HTTP/2 client preface
+// (prior knowledge, e.g. gRPC over cleartext). The preface is still buffered
+// in c and is consumed by the HTTP/2 server.
+func (h *Handler) ServeH2C(c net.Conn) {
+	middleware.IncActiveConnections("http")
+	defer middleware.DecActiveConnections("http")
+
+	s := h.server.Load()
+	s.conns.Add(1)
+	defer h.servers.release(s)
+	s.h2s.ServeConn(c, &http2.ServeConnOpts{
+		Context:    withConnInfo(context.WithValue(context.Background(), h2cKey{}, true), c),
+		BaseConfig: s.srv,
+		Handler:    s.srv.Handler,
+	})
+}
@@ -1,81 +1,331 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/SkynetNext/unified-access-gateway/internal/circuitbreaker"
 	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/healthcheck"
 	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
 	"github.com/SkynetNext/unified-access-gateway/internal/security"
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
 )
 
+var (
+	errNoRoute         = errors.New("no route matched")
+	errUpstreamEjected = errors.New("upstream ejected by passive health check")
+	errCircuitOpen     = errors.New("upstream circuit breaker open")
+)
+
 type Handler struct {
-	proxy    *httputil.ReverseProxy
-	backend  string
 	security *security.Manager
+	health   *healthcheck.UpstreamHealthChecker // Passive failure reporting (may be nil)
+	retry    config.RetryConfig
+	breakers *circuitbreaker.Group // nil if circuit breaking is disabled
+
+	maxRequestBytes  int64 // Atomic: 0 = unlimited
+	maxResponseBytes int64 // Atomic: 0 = unlimited
+
+	transport      *upstreamTransport                          // Shared by every route's proxy, rebuilt on timeout changes
+	serverTimeouts atomic.Pointer[config.HTTPServerTimeouts]   // Client-facing timeouts for new connections
+	headerRules    atomic.Pointer[headerRules]                 // business:header_rules
+	methodRules    atomic.Pointer[[]config.MethodRule]         // business:method_rules
+	weights        atomic.Pointer[map[string]*weightedBackend] // business:backend_weights and discovered targets
+	compression    atomic.Pointer[config.CompressionConfig]    // MinBytes resolved
+
+	handler http.Handler                 // Security controls, routing and metrics, shared by every server
+	server  atomic.Pointer[sharedServer] // Serves new connections
+	servers serverSet                    // Current and retired servers, for Shutdown
+
+	routesMu     sync.RWMutex
+	routes       []*route // Sorted by prefix length, longest first
+	defaultRoute *route   // backends.http.target_url (nil if only routes are configured), swapped on cutover
+
+	// Sources of weights, merged under weightsMu: business:backend_weights and
+	// the endpoints discovered for the backend service
+	weightsMu  sync.Mutex
+	configured []config.BackendWeights
+	discovered []string
+}
+
+// route is a path prefix bound to its own reverse proxy
+type route struct {
+	prefix   string
+	target   *url.URL
+	upstream string // Health checker key (the configured target URL)
+	proxy    *httputil.ReverseProxy
 }
 
-func NewHandler(cfg *config.Config, sec *security.Manager) *Handler {
+func NewHandler(cfg *config.Config, sec *security.Manager, store *config.RedisStore, health *healthcheck.UpstreamHealthChecker) *Handler {
 	backend := cfg.Backends.HTTP.TargetURL
-	if backend == "" {
+	if backend == "" && len(cfg.Backends.HTTP.Routes) == 0 {
 		// Business config MUST be loaded from Redis, no fallback
 		xlog.Errorf("CRITICAL: backends.http.target_url is not configured (must be set in Redis)")
 		return nil
 	}
 
-	target, err := url.Parse(backend)
-	if err != nil {
-		xlog.Errorf("CRITICAL: Invalid backend URL: %s, error: %v", backend, err)
-		return nil
+	h := &Handler{
+		security: sec,
+		health:   health,
+		retry:    cfg.Backends.HTTP.Retry,
+		breakers: circuitbreaker.NewGroup(cfg.Backends.CircuitBreaker),
+
+		maxRequestBytes:  cfg.Backends.HTTP.MaxRequestBytes,
+		maxResponseBytes: cfg.Backends.HTTP.MaxResponseBytes,
+
+		transport: newUpstreamTransport(cfg.Backends.HTTP),
+	}
+	serverTimeouts := resolveServerTimeouts(cfg.Server.HTTP)
+	h.serverTimeouts.Store(&serverTimeouts)
+	middleware.SetHTTPLogPolicy(cfg.Server.AccessLog)
+
+	if backend != "" {
+		target, err := url.Parse(backend)
+		if err != nil {
+			xlog.Errorf("CRITICAL: Invalid backend URL: %s, error: %v", backend, err)
+			return nil
+		}
+		h.defaultRoute = &route{prefix: "/", target: target, upstream: backend, proxy: h.newProxy(target, backend)}
 	}
+	h.UpdateRoutes(cfg.Backends.HTTP.Routes)
+	h.UpdateHeaderRules(cfg.Backends.HTTP.HeaderRules)
+	h.UpdateMethodRules(cfg.Backends.HTTP.MethodRules)
+	h.UpdateCompression(cfg.Backends.HTTP.Compression)
+	h.UpdateWeights(cfg.Backends.Weights)
+	h.handler = h.newRequestHandler()
+	h.replaceServer()
 
+	// Hot-reload routing table via Redis pub/sub
+	if store != nil {
+		go h.watchRoutes(store)
+	}
+
+	return h
+}
+
+// newProxy builds a reverse proxy for one upstream target
+func (h *Handler) newProxy(target *url.URL, upstream string) *httputil.ReverseProxy {
 	// Custom Director to support Metrics and Header modification
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = newRetryTransport(protocolTransport{next: h.transport}, upstream, h.retry)
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		originalDirector(req)
 		// Add X-Forwarded-For or other headers here
 		req.Header.Set("X-Gateway-ID", "uag-v1")
+		// Request ID chosen by CloudNativeMiddleware (the client's when valid)
+		if id := middleware.RequestID(req.Context()); id != "" {
+			req.Header.Set(middleware.RequestIDHeader, id)
+		}
 		// Set upstream identifier for metrics
 		req.Header.Set("X-Upstream", target.Host)
+		// Header rules last, so they can also override the headers above
+		if rules := headerRulesFrom(req.Context()); rules != nil {
+			applyHeaderRules(req.Header, rules.request)
+		}
 	}
 
-	// Custom ModifyResponse to record Status Code (Optional)
 	proxy.ModifyResponse = func(resp *http.Response) error {
-		// Log status code here for Access Log
-		return nil
+		if rules := headerRulesFrom(resp.Request.Context()); rules != nil {
+			applyHeaderRules(resp.Header, rules.response)
+		}
+		return h.limitResponseBody(resp, upstream)
 	}
 
-	return &Handler{
-		proxy:    proxy,
-		backend:  backend,
-		security: sec,
+	// Upstream connection errors feed passive health checking
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, context.Canceled):
+			// Client went away, not an upstream failure
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		case errors.As(err, &maxErr):
+			// Streamed request body hit max_request_bytes
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, errResponseTooLarge):
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		xlog.Warnf("HTTP upstream %s error: %v", upstream, err)
+		h.health.ReportFailure(upstream)
+		// Dial, TLS handshake and response header timeouts
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return proxy
+}
+
+// UpdateRoutes atomically replaces the routing table. Invalid entries are skipped.
+func (h *Handler) UpdateRoutes(routes []config.HTTPRoute) {
+	built := make([]*route, 0, len(routes))
+	for _, rt := range routes {
+		if rt.Prefix == "" || rt.TargetURL == "" {
+			continue
+		}
+		target, err := url.Parse(rt.TargetURL)
+		if err != nil || target.Host == "" {
+			xlog.Warnf("Invalid HTTP route %s -> %s: %v", rt.Prefix, rt.TargetURL, err)
+			continue
+		}
+		prefix := rt.Prefix
+		if !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+		built = append(built, &route{prefix: prefix, target: target, upstream: rt.TargetURL, proxy: h.newProxy(target, rt.TargetURL)})
 	}
+	sort.SliceStable(built, func(i, j int) bool { return len(built[i].prefix) > len(built[j].prefix) })
+
+	h.routesMu.Lock()
+	h.routes = built
+	h.routesMu.Unlock()
+	xlog.Infof("HTTP routes updated: count=%d", len(built))
 }
 
-func (h *Handler) ServeConn(c net.Conn) {
-	// Metrics: Inc active connection
-	middleware.IncActiveConnections("http")
-	defer middleware.DecActiveConnections("http")
+// UpdateDefaultBackend points the default route at targetURL (backends.http.target_url
+// changed in Redis). Requests in flight finish on the old backend. An empty or
+// invalid targetURL keeps the current backend.
+func (h *Handler) UpdateDefaultBackend(targetURL string) {
+	h.routesMu.RLock()
+	old := h.defaultRoute
+	h.routesMu.RUnlock()
+	oldURL := ""
+	if old != nil {
+		oldURL = old.upstream
+	}
+	if targetURL == oldURL {
+		return
+	}
+	if targetURL == "" {
+		xlog.Warnf("backends.http.target_url removed from Redis, keeping %s", oldURL)
+		return
+	}
+	target, err := url.Parse(targetURL)
+	if err != nil || target.Host == "" {
+		xlog.Warnf("Invalid backends.http.target_url %s, keeping %s: %v", targetURL, oldURL, err)
+		return
+	}
+	rt := &route{prefix: "/", target: target, upstream: targetURL, proxy: h.newProxy(target, targetURL)}
+
+	h.routesMu.Lock()
+	h.defaultRoute = rt
+	h.routesMu.Unlock()
+	xlog.Infof("HTTP backend updated: %s -> %s", oldURL, targetURL)
+	h.weightsMu.Lock()
+	if len(h.discovered) > 0 {
+		// The discovered endpoints follow the backend they were found for
+		h.applyWeights()
+	}
+	h.weightsMu.Unlock()
+}
 
-	start := time.Now()
+// match selects the route with the longest prefix matching path, falling back to the default route
+func (h *Handler) match(path string) *route {
+	h.routesMu.RLock()
+	defer h.routesMu.RUnlock()
+	for _, rt := range h.routes {
+		if prefixMatches(rt.prefix, path) {
+			return rt
+		}
+	}
+	return h.defaultRoute
+}
 
-	// Create a OneShotListener for this connection
-	l := &oneShotListener{c: c}
+// prefixMatches matches on path segment boundaries: /api/users matches
+// /api/users and /api/users/1 but not /api/usersx
+func prefixMatches(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
 
+// watchRoutes reloads the routing table, header and method rules and backend
+// weights (and the backend, body limits, timeouts, compression and the log
+// policy on business updates) when config changes in Redis
+func (h *Handler) watchRoutes(store *config.RedisStore) {
+	for update := range store.Subscribe() {
+		if update.Is("business", "header_rules") {
+			if rules, err := store.LoadHeaderRules(); err != nil {
+				xlog.Warnf("Failed to reload HTTP header rules from Redis: %v", err)
+			} else {
+				h.UpdateHeaderRules(rules)
+			}
+		}
+		if update.Is("business", "method_rules") {
+			if rules, err := store.LoadMethodRules(); err != nil {
+				xlog.Warnf("Failed to reload HTTP method rules from Redis: %v", err)
+			} else {
+				h.UpdateMethodRules(rules)
+			}
+		}
+		if update.Is("business", "backend_weights") {
+			if weights, err := store.LoadBackendWeights(); err != nil {
+				xlog.Warnf("Failed to reload HTTP backend weights from Redis: %v", err)
+			} else {
+				h.UpdateWeights(weights)
+			}
+		}
+		if update.Is("business", "http_routes") {
+			// A bad http_routes key keeps the current routes but must not
+			// hold back the default backend and limits below
+			if routes, err := store.LoadHTTPRoutes(); err != nil {
+				xlog.Warnf("Failed to reload HTTP routes from Redis: %v", err)
+			} else {
+				h.UpdateRoutes(routes)
+			}
+		}
+		if update.Is("business") {
+			businessCfg, err := store.LoadBusinessConfig()
+			if err != nil {
+				xlog.Warnf("Failed to reload HTTP body limits and timeouts from Redis: %v", err)
+				continue
+			}
+			h.UpdateDefaultBackend(businessCfg.Backends.HTTP.TargetURL)
+			h.UpdateBodyLimits(businessCfg.Backends.HTTP.MaxRequestBytes, businessCfg.Backends.HTTP.MaxResponseBytes)
+			h.UpdateTimeouts(businessCfg.Backends.HTTP, businessCfg.Server.HTTP)
+			h.UpdateCompression(businessCfg.Backends.HTTP.Compression)
+			middleware.SetHTTPLogPolicy(businessCfg.Server.AccessLog)
+		}
+	}
+}
+
+// newRequestHandler wraps the proxy with security controls and metrics. It is
+// shared by HTTP/1.x and h2c connections.
+func (h *Handler) newRequestHandler() http.Handler {
 	// Wrap handler to record metrics and security controls
 	wrappedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		conn := connInfoFrom(r.Context())
+		if r.TLS == nil {
+			r.TLS = conn.tlsState
+		}
 		var denyErr error
 		denyStatus := http.StatusForbidden
 		if h.security != nil {
-			if err := h.security.AuthorizeHTTP(r); err != nil {
+			if subject, err := h.security.AuthorizeHTTP(r); err != nil {
 				http.Error(w, err.Error(), http.StatusUnauthorized)
 				denyStatus = http.StatusUnauthorized
 				denyErr = err
+			} else if err := h.security.LimitHTTP(subject, r.URL.Path); err != nil {
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				denyStatus = http.StatusTooManyRequests
+				denyErr = err
 			} else if err := h.security.ApplyWAF(r); err != nil {
 				http.Error(w, "blocked by WAF", http.StatusForbidden)
 				denyErr = err
@@ -86,24 +336,89 @@ func (h *Handler) ServeConn(c net.Conn) {
 			}
 		}
 
-		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
-		h.proxy.ServeHTTP(recorder, r)
+		if allow, ok := h.checkMethod(r.Method, r.URL.Path); !ok {
+			middleware.RecordSecurityBlock("method_not_allowed")
+			w.Header().Set("Allow", allow)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			if h.security != nil {
+				h.security.AuditHTTP(r, http.StatusMethodNotAllowed, time.Since(start), errMethodNotAllowed)
+			}
+			return
+		}
+
+		rt := h.match(r.URL.Path)
+		if rt == nil {
+			http.NotFound(w, r)
+			if h.security != nil {
+				h.security.AuditHTTP(r, http.StatusNotFound, time.Since(start), errNoRoute)
+			}
+			return
+		}
+		rt = h.pickTarget(rt, r)
+
+		if h.health.IsEjected(rt.upstream) {
+			http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+			if h.security != nil {
+				h.security.AuditHTTP(r, http.StatusServiceUnavailable, time.Since(start), errUpstreamEjected)
+			}
+			return
+		}
+
+		if !h.breakers.Allow(rt.upstream) {
+			http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+			if h.security != nil {
+				h.security.AuditHTTP(r, http.StatusServiceUnavailable, time.Since(start), errCircuitOpen)
+			}
+			return
+		}
+
+		// The reverse proxy hijacks upgraded connections and relays both
+		// directions until either side closes, so ServeHTTP blocks for the
+		// websocket's lifetime. Hijacking clears the server's read/write
+		// deadlines, so ReadTimeout/WriteTimeout do not cut long-lived sockets.
+		if isWebSocketUpgrade(r) {
+			middleware.IncActiveConnections("websocket")
+			defer middleware.DecActiveConnections("websocket")
+		}
+
+		body, err := h.limitRequestBody(w, r)
+		if err != nil {
+			middleware.RecordSecurityBlock("request_too_large")
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			if h.security != nil {
+				h.security.AuditHTTP(r, http.StatusRequestEntityTooLarge, time.Since(start), err)
+			}
+			return
+		}
+
+		middleware.SetConnBackend(conn.conn, rt.upstream)
+		// Compression sits below the recorder, so the status is the
+		// backend's and the access log counts the bytes on the wire
+		var out http.ResponseWriter = w
+		cw := h.newCompressWriter(w, r)
+		if cw != nil {
+			out = cw
+		}
+		recorder := &statusRecorder{ResponseWriter: out, statusCode: http.StatusOK}
+		rt.proxy.ServeHTTP(recorder, h.withHeaderRules(r))
+		if cw != nil {
+			cw.Close()
+		}
+		h.breakers.Record(rt.upstream, recorder.statusCode < http.StatusInternalServerError)
+
+		var auditErr error
+		if body != nil && body.exceeded.Load() {
+			middleware.RecordSecurityBlock("request_too_large")
+			auditErr = errRequestTooLarge
+		}
 
 		duration := time.Since(start)
 		if h.security != nil {
-			h.security.AuditHTTP(r, recorder.statusCode, duration, nil)
+			h.security.AuditHTTP(r, recorder.statusCode, duration, auditErr)
 		}
 	})
 
-	server := &http.Server{
-		Handler:      middleware.K8sProbeMiddleware(middleware.CloudNativeMiddleware(wrappedHandler)),
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-	}
-
-	if err := server.Serve(l); err != nil && err != ErrListenerClosed {
-		xlog.Debugf("HTTP Serve finished: %v", err)
-	}
+	return middleware.K8sProbeMiddleware(middleware.CloudNativeMiddleware(wrappedHandler))
 }
 
 type statusRecorder struct {
@@ -116,26 +431,23 @@ func (sr *statusRecorder) WriteHeader(code int) {
 	sr.ResponseWriter.WriteHeader(code)
 }
 
-// oneShotListener is a helper struct
-type oneShotListener struct {
-	c    net.Conn
-	done bool
+// Unwrap exposes the underlying writer so the reverse proxy can hijack the
+// connection on protocol upgrades (via http.ResponseController)
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
 }
 
-var ErrListenerClosed = net.ErrClosed
-
-func (l *oneShotListener) Accept() (net.Conn, error) {
-	if l.done {
-		return nil, ErrListenerClosed
+// isWebSocketUpgrade reports whether r asks to switch to the websocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
 	}
-	l.done = true
-	return l.c, nil
-}
-
-func (l *oneShotListener) Close() error {
-	return nil
-}
-
-func (l *oneShotListener) Addr() net.Addr {
-	return l.c.LocalAddr()
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
 }
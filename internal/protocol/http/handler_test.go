@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// defaultUpstream returns the target of the default route
+func (h *Handler) defaultUpstream() string {
+	h.routesMu.RLock()
+	defer h.routesMu.RUnlock()
+	if h.defaultRoute == nil {
+		return ""
+	}
+	return h.defaultRoute.upstream
+}
+
+func TestBusinessReloadSurvivesBadRoutesKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store, err := config.NewRedisStore(&config.RedisConfig{Enabled: true, Addr: mr.Addr(), KeyPrefix: "uag:"})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	defer store.Close()
+
+	cfg := &config.Config{}
+	cfg.Backends.HTTP.TargetURL = "http://web-old:8080"
+	h := NewHandler(cfg, nil, store, nil)
+	if h == nil {
+		t.Fatal("NewHandler returned nil")
+	}
+	defer h.Shutdown(context.Background())
+
+	// http_routes holds a string instead of a hash: loading it fails
+	mr.Set("uag:business:http_routes", "not a hash")
+	mr.HSet("uag:business:config",
+		"server.listen_addr", ":8080",
+		"backends.http.target_url", "http://web-new:8080",
+	)
+
+	// Publish until the handler's subscription has seen the update
+	waitFor(t, "the default backend cutover", func() bool {
+		mr.Publish("uag:config:changed", `{"type":"business"}`)
+		return h.defaultUpstream() == "http://web-new:8080"
+	})
+}
+
+func TestPickTargetDiscoveredTargets(t *testing.T) {
+	h, _ := newTestHandler(t, "http://web:8080/app")
+	defer h.Shutdown(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	h.SetDiscoveredTargets([]string{"10.0.0.1:8080", "10.0.0.2:8080"})
+	picked := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		picked[h.pickTarget(h.match("/"), r).upstream] = true
+	}
+	if len(picked) != 2 || !picked["http://10.0.0.1:8080/app"] || !picked["http://10.0.0.2:8080/app"] {
+		t.Errorf("picked %v, want both discovered endpoints with the backend's scheme and path", picked)
+	}
+
+	h.SetDiscoveredTargets(nil)
+	if got := h.pickTarget(h.match("/"), r).upstream; got != "http://web:8080/app" {
+		t.Errorf("without endpoints picked %s, want the backend itself", got)
+	}
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// headerRules is the business:header_rules table split by direction, each in
+// name order. It is replaced as a whole on reload.
+type headerRules struct {
+	all      []config.HeaderRule
+	request  []config.HeaderRule
+	response []config.HeaderRule
+}
+
+type headerRulesKey struct{}
+
+// UpdateHeaderRules replaces the request and response header rules
+func (h *Handler) UpdateHeaderRules(rules []config.HeaderRule) {
+	hr := &headerRules{all: rules}
+	for _, rule := range rules {
+		if rule.Direction == config.HeaderRuleRequest {
+			hr.request = append(hr.request, rule)
+		} else {
+			hr.response = append(hr.response, rule)
+		}
+	}
+	old := h.headerRules.Swap(hr)
+	if old != nil && reflect.DeepEqual(old.all, rules) {
+		return
+	}
+	if len(rules) > 0 || old != nil {
+		xlog.Infof("HTTP header rules updated: request=%d, response=%d", len(hr.request), len(hr.response))
+	}
+}
+
+// matchHeaderRules returns the rules whose prefix matches path, or nil
+func (h *Handler) matchHeaderRules(path string) *headerRules {
+	hr := h.headerRules.Load()
+	if hr == nil || len(hr.all) == 0 {
+		return nil
+	}
+	match := func(rules []config.HeaderRule) []config.HeaderRule {
+		var out []config.HeaderRule
+		for _, rule := range rules {
+			if rule.Prefix == "" || prefixMatches(rule.Prefix, path) {
+				out = append(out, rule)
+			}
+		}
+		return out
+	}
+	m := &headerRules{request: match(hr.request), response: match(hr.response)}
+	if len(m.request) == 0 && len(m.response) == 0 {
+		return nil
+	}
+	return m
+}
+
+// withHeaderRules stores the rules matching the client's path in the request
+// context: the Director and ModifyResponse only see the rewritten upstream path
+func (h *Handler) withHeaderRules(r *http.Request) *http.Request {
+	m := h.matchHeaderRules(r.URL.Path)
+	if m == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), headerRulesKey{}, m))
+}
+
+func headerRulesFrom(ctx context.Context) *headerRules {
+	m, _ := ctx.Value(headerRulesKey{}).(*headerRules)
+	return m
+}
+
+// applyHeaderRules applies rules in order. Set and remove replace or delete
+// every value of a multi-valued header; add appends one more value. Rule
+// header names are canonical, like the keys of received headers.
+func applyHeaderRules(header http.Header, rules []config.HeaderRule) {
+	for _, rule := range rules {
+		switch rule.Action {
+		case config.HeaderActionSet:
+			header[rule.Header] = []string{rule.Value}
+		case config.HeaderActionAdd:
+			header[rule.Header] = append(header[rule.Header], rule.Value)
+		case config.HeaderActionRemove:
+			delete(header, rule.Header)
+		}
+	}
+}
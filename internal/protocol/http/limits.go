@@ -0,0 +1,101 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+var (
+	errRequestTooLarge  = errors.New("request body exceeds max_request_bytes")
+	errResponseTooLarge = errors.New("upstream response body exceeds max_response_bytes")
+)
+
+// UpdateBodyLimits sets the request and response body limits (0 = unlimited)
+func (h *Handler) UpdateBodyLimits(maxRequest, maxResponse int64) {
+	if maxRequest < 0 {
+		maxRequest = 0
+	}
+	if maxResponse < 0 {
+		maxResponse = 0
+	}
+	oldReq := atomic.SwapInt64(&h.maxRequestBytes, maxRequest)
+	oldResp := atomic.SwapInt64(&h.maxResponseBytes, maxResponse)
+	if oldReq != maxRequest || oldResp != maxResponse {
+		xlog.Infof("HTTP body limits updated: max_request_bytes=%d, max_response_bytes=%d", maxRequest, maxResponse)
+	}
+}
+
+// requestBody enforces max_request_bytes on a streamed body and remembers
+// whether the limit was hit, so the 413 can be told apart from upstream errors
+type requestBody struct {
+	io.ReadCloser
+	exceeded atomic.Bool
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.exceeded.Store(true)
+	}
+	return n, err
+}
+
+// limitRequestBody rejects requests whose declared length exceeds the limit and
+// bounds the rest with http.MaxBytesReader. It returns errRequestTooLarge if the
+// request was rejected, and the wrapped body (nil when unlimited) otherwise.
+func (h *Handler) limitRequestBody(w http.ResponseWriter, r *http.Request) (*requestBody, error) {
+	max := atomic.LoadInt64(&h.maxRequestBytes)
+	if max <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if r.ContentLength > max {
+		return nil, errRequestTooLarge
+	}
+	body := &requestBody{ReadCloser: http.MaxBytesReader(w, r.Body, max)}
+	r.Body = body
+	return body, nil
+}
+
+// responseBody aborts an upstream response once it exceeds the limit
+type responseBody struct {
+	io.ReadCloser
+	remaining int64
+	upstream  string
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// Read one byte past the limit to detect overflow
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		xlog.Errorf("HTTP upstream %s response exceeded max_response_bytes, aborting", b.upstream)
+		return n + int(b.remaining), errResponseTooLarge
+	}
+	return n, err
+}
+
+// limitResponseBody enforces max_response_bytes from ModifyResponse. A declared
+// oversized length fails before headers are sent; streamed bodies are cut off.
+func (h *Handler) limitResponseBody(resp *http.Response, upstream string) error {
+	max := atomic.LoadInt64(&h.maxResponseBytes)
+	if max <= 0 || resp.StatusCode == http.StatusSwitchingProtocols {
+		return nil
+	}
+	if resp.ContentLength > max {
+		xlog.Errorf("HTTP upstream %s response of %d bytes exceeds max_response_bytes=%d", upstream, resp.ContentLength, max)
+		return errResponseTooLarge
+	}
+	resp.Body = &responseBody{ReadCloser: resp.Body, remaining: max, upstream: upstream}
+	return nil
+}
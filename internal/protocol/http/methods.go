@@ -0,0 +1,63 @@
+package http
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+var errMethodNotAllowed = errors.New("method denied by method rules")
+
+// allowCandidates are the methods listed in the Allow header of a 405, when
+// the matching rules permit them
+var allowCandidates = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// UpdateMethodRules replaces the method allow/deny rules
+func (h *Handler) UpdateMethodRules(rules []config.MethodRule) {
+	old := h.methodRules.Swap(&rules)
+	if old != nil && reflect.DeepEqual(*old, rules) {
+		return
+	}
+	if len(rules) > 0 || old != nil {
+		xlog.Infof("HTTP method rules updated: count=%d", len(rules))
+	}
+}
+
+// checkMethod returns "", true when every rule matching path permits method.
+// Otherwise it returns the Allow header value: the common methods the
+// matching rules permit.
+func (h *Handler) checkMethod(method, path string) (string, bool) {
+	rules := h.methodRules.Load()
+	if rules == nil || len(*rules) == 0 {
+		return "", true
+	}
+	var matched []config.MethodRule
+	denied := false
+	for _, rule := range *rules {
+		if !prefixMatches(rule.Prefix, path) {
+			continue
+		}
+		matched = append(matched, rule)
+		if !rule.Allows(method) {
+			denied = true
+		}
+	}
+	if !denied {
+		return "", true
+	}
+
+	allow := make([]string, 0, len(allowCandidates))
+candidates:
+	for _, m := range allowCandidates {
+		for _, rule := range matched {
+			if !rule.Allows(m) {
+				continue candidates
+			}
+		}
+		allow = append(allow, m)
+	}
+	return strings.Join(allow, ", "), false
+}
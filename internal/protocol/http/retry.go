@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// Retry defaults used when backends.http.retry.* is partially configured
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff     = 2 * time.Second
+)
+
+var (
+	defaultRetryStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+	defaultRetryMethods  = []string{http.MethodGet, http.MethodHead}
+)
+
+// retryTransport retries failed upstream round trips with exponential backoff
+type retryTransport struct {
+	next     http.RoundTripper
+	upstream string
+	policy   config.RetryConfig
+}
+
+// newRetryTransport wraps next, returning next unchanged if retries are disabled
+func newRetryTransport(next http.RoundTripper, upstream string, policy config.RetryConfig) http.RoundTripper {
+	if policy.MaxAttempts <= 1 {
+		return next
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = defaultRetryBackoff
+	}
+	if len(policy.Statuses) == 0 {
+		policy.Statuses = defaultRetryStatuses
+	}
+	if len(policy.Methods) == 0 {
+		policy.Methods = defaultRetryMethods
+	}
+	return &retryTransport{next: next, upstream: upstream, policy: policy}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.retryable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	backoff := t.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+
+		reason := ""
+		switch {
+		case err != nil:
+			if errors.Is(err, context.Canceled) {
+				return nil, err // Client went away
+			}
+			reason = "error"
+		case t.retryableStatus(resp.StatusCode):
+			reason = strconv.Itoa(resp.StatusCode)
+		}
+		if reason == "" || attempt >= t.policy.MaxAttempts {
+			return resp, err
+		}
+
+		// Discard the failed response before retrying
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		middleware.RecordUpstreamRetry(t.upstream, reason)
+		xlog.Debugf("Retrying %s %s to %s (attempt %d/%d, reason=%s, backoff=%v)",
+			req.Method, req.URL.Path, t.upstream, attempt+1, t.policy.MaxAttempts, reason, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// retryable reports whether req may be sent more than once: the method must be
+// allowed and there must be no body, since a consumed body cannot be replayed
+func (t *retryTransport) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	if req.Header.Get("Upgrade") != "" {
+		return false
+	}
+	for _, m := range t.policy.Methods {
+		if strings.EqualFold(m, req.Method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *retryTransport) retryableStatus(code int) bool {
+	for _, s := range t.policy.Statuses {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"golang.org/x/net/http2"
+)
+
+// shutdownPollInterval is how often Shutdown checks for connections still serving
+const shutdownPollInterval = 50 * time.Millisecond
+
+var ErrListenerClosed = net.ErrClosed
+
+// connListener is a net.Listener fed with connections already accepted and
+// sniffed by the gateway listener
+type connListener struct {
+	addr      net.Addr
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// push hands c to the server, false if the listener is closed
+func (l *connListener) push(c net.Conn) bool {
+	select {
+	case l.conns <- c:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
+
+// connInfo is the client connection of a request, attached to its context
+type connInfo struct {
+	conn     net.Conn
+	tlsState *tls.ConnectionState // TLS terminated by the gateway listener (nil = plaintext)
+}
+
+type connInfoKey struct{}
+
+func withConnInfo(ctx context.Context, c net.Conn) context.Context {
+	info := &connInfo{conn: c}
+	// TLS terminated by the listener: http.Server only sees a wrapped conn,
+	// so the handshake state is attached to each request here
+	if tc, ok := c.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		state := tc.ConnectionState()
+		info.tlsState = &state
+	}
+	return context.WithValue(ctx, connInfoKey{}, info)
+}
+
+func connInfoFrom(ctx context.Context) *connInfo {
+	info, _ := ctx.Value(connInfoKey{}).(*connInfo)
+	if info == nil {
+		return &connInfo{}
+	}
+	return info
+}
+
+// sharedServer is a long-lived server fed every HTTP/1.x connection through
+// its listener; h2c connections are served by h2s with srv as base config
+type sharedServer struct {
+	srv     *http.Server
+	h2s     *http2.Server
+	ln      *connListener
+	conns   atomic.Int64 // Open connections, HTTP/1.x and h2c
+	retired atomic.Bool  // Replaced after a timeout change, serving only its open connections
+}
+
+// newSharedServer starts a server with the current client-facing timeouts
+func (h *Handler) newSharedServer() *sharedServer {
+	timeouts := h.serverTimeouts.Load()
+	s := &sharedServer{
+		h2s: &http2.Server{},
+		ln:  newConnListener(&net.TCPAddr{}),
+	}
+	s.srv = &http.Server{
+		Handler:           h.handler,
+		ReadHeaderTimeout: timeouts.ReadHeaderTimeout,
+		ReadTimeout:       timeouts.ReadTimeout,
+		WriteTimeout:      timeouts.WriteTimeout,
+		IdleTimeout:       timeouts.IdleTimeout,
+		ConnContext:       withConnInfo,
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			// Hijacked (upgraded) connections are no longer served by srv
+			if state == http.StateClosed || state == http.StateHijacked {
+				middleware.DecActiveConnections("http")
+				h.servers.release(s)
+			}
+		},
+	}
+	// Registers GOAWAY to h2c connections on srv.Shutdown
+	if err := http2.ConfigureServer(s.srv, s.h2s); err != nil {
+		xlog.Warnf("h2c graceful shutdown not available: %v", err)
+	}
+	h.servers.add(s)
+	go func() {
+		if err := s.srv.Serve(s.ln); err != nil && err != ErrListenerClosed && err != http.ErrServerClosed {
+			xlog.Errorf("HTTP server stopped: %v", err)
+		}
+	}()
+	return s
+}
+
+// replaceServer serves new connections with a server built from the current
+// timeouts; open connections stay on the old one until they close
+func (h *Handler) replaceServer() {
+	old := h.server.Swap(h.newSharedServer())
+	if old != nil {
+		old.ln.Close()
+		old.retired.Store(true)
+		if old.conns.Load() == 0 {
+			h.servers.remove(old)
+		}
+	}
+}
+
+// serverSet tracks the current server and the retired ones still serving
+// connections, so they can be shut down gracefully
+type serverSet struct {
+	mu      sync.Mutex
+	servers map[*sharedServer]struct{}
+}
+
+func (s *serverSet) add(srv *sharedServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.servers == nil {
+		s.servers = make(map[*sharedServer]struct{})
+	}
+	s.servers[srv] = struct{}{}
+}
+
+func (s *serverSet) remove(srv *sharedServer) {
+	s.mu.Lock()
+	delete(s.servers, srv)
+	s.mu.Unlock()
+}
+
+// release records a closed connection of srv, dropping srv once it is
+// retired and has none left
+func (s *serverSet) release(srv *sharedServer) {
+	if srv.conns.Add(-1) == 0 && srv.retired.Load() {
+		s.remove(srv)
+	}
+}
+
+func (s *serverSet) list() []*sharedServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*sharedServer, 0, len(s.servers))
+	for srv := range s.servers {
+		list = append(list, srv)
+	}
+	return list
+}
+
+// open returns the connections still served
+func (s *serverSet) open() int64 {
+	var n int64
+	for _, srv := range s.list() {
+		n += srv.conns.Load()
+	}
+	return n
+}
+
+func (h *Handler) ServeConn(c net.Conn) {
+	// Counted before the hand-off: the server's ConnState hook decrements it
+	// when the connection ends, which may be before push returns
+	middleware.IncActiveConnections("http")
+	for {
+		s := h.server.Load()
+		s.conns.Add(1)
+		if s.ln.push(c) {
+			return
+		}
+		h.servers.release(s)
+		if h.server.Load() != s {
+			// Replaced after a timeout change (swapped before its listener
+			// closes): hand the connection to the new server
+			continue
+		}
+		// Shut down while the connection was being sniffed
+		middleware.DecActiveConnections("http")
+		xlog.Debugf("HTTP server shut down, closing %s", c.RemoteAddr())
+		c.Close()
+		return
+	}
+}
+
+// Shutdown gracefully shuts down every open HTTP and h2c connection: idle
+// keep-alive connections are closed, HTTP/1.x connections close after their
+// request in flight and h2c connections receive GOAWAY and close once their
+// streams finish. It returns when all have closed, or ctx.Err() when ctx
+// expires first; the connections left are then up to the caller to close.
+// Upgraded (websocket) connections are not waited for.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	servers := h.servers.list()
+	xlog.Infof("Shutting down %d HTTP connections", h.servers.open())
+	for _, s := range servers {
+		go s.srv.Shutdown(ctx)
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if h.servers.open() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
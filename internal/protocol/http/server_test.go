@@ -0,0 +1,230 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+)
+
+// newTestHandler returns a Handler proxying every path to upstream
+func newTestHandler(t testing.TB, upstream string) (*Handler, *config.Config) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Backends.HTTP.TargetURL = upstream
+	h := NewHandler(cfg, nil, nil, nil)
+	if h == nil {
+		t.Fatal("NewHandler returned nil")
+	}
+	return h, cfg
+}
+
+// dialHandler connects a client to h over loopback, as the gateway listener
+// would after sniffing HTTP
+func dialHandler(t testing.TB, h *Handler) net.Conn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			h.ServeConn(c)
+		}
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// blockingUpstream answers /slow only once release is closed, and signals
+// started when such a request arrives. Other paths answer immediately.
+func blockingUpstream(t *testing.T) (url string, started <-chan struct{}, release chan struct{}) {
+	t.Helper()
+	startedCh := make(chan struct{}, 1)
+	release = make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			startedCh <- struct{}{}
+			<-release
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, startedCh, release
+}
+
+// roundTrip writes req on c and reads its response
+func roundTrip(c net.Conn, br *bufio.Reader, path string) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodGet, "http://gateway"+path, nil)
+	if err := req.Write(c); err != nil {
+		return nil, err
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp, nil
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTimeoutReloadKeepsOpenConnections(t *testing.T) {
+	upstream, started, release := blockingUpstream(t)
+	h, cfg := newTestHandler(t, upstream)
+	defer h.Shutdown(context.Background())
+
+	c := dialHandler(t, h)
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	br := bufio.NewReader(c)
+	if resp, err := roundTrip(c, br, "/"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("first request: %v, %v", resp, err)
+	}
+
+	// A request in flight on the current server when the timeouts change
+	done := make(chan error, 1)
+	go func() {
+		resp, err := roundTrip(c, br, "/slow")
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = io.ErrUnexpectedEOF
+		}
+		done <- err
+	}()
+	<-started
+	old := h.server.Load()
+	h.UpdateTimeouts(cfg.Backends.HTTP, config.HTTPServerTimeouts{ReadTimeout: 45 * time.Second})
+	if h.server.Load() == old {
+		t.Fatal("timeout change did not start a new server")
+	}
+	if n := len(h.servers.list()); n != 2 {
+		t.Fatalf("%d servers tracked, want the retired and the new one", n)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("request in flight during the reload: %v", err)
+	}
+	// The connection stays on the retired server and keeps its keep-alive
+	if resp, err := roundTrip(c, br, "/"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request after the reload: %v, %v", resp, err)
+	}
+
+	// New connections go to the new server
+	c2 := dialHandler(t, h)
+	c2.SetDeadline(time.Now().Add(10 * time.Second))
+	if resp, err := roundTrip(c2, bufio.NewReader(c2), "/"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request on a new connection: %v, %v", resp, err)
+	}
+	if old.conns.Load() != 1 {
+		t.Errorf("retired server has %d connections, want 1", old.conns.Load())
+	}
+
+	// The retired server is dropped once its last connection closes
+	c.Close()
+	waitFor(t, "the retired server to be dropped", func() bool { return len(h.servers.list()) == 1 })
+}
+
+func TestShutdownDrainsRetiredServers(t *testing.T) {
+	upstream, started, release := blockingUpstream(t)
+	h, cfg := newTestHandler(t, upstream)
+
+	c := dialHandler(t, h)
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	br := bufio.NewReader(c)
+	done := make(chan *http.Response, 1)
+	go func() {
+		resp, err := roundTrip(c, br, "/slow")
+		if err != nil {
+			t.Errorf("request in flight during shutdown: %v", err)
+		}
+		done <- resp
+	}()
+	<-started
+	h.UpdateTimeouts(cfg.Backends.HTTP, config.HTTPServerTimeouts{ReadTimeout: 45 * time.Second})
+
+	// An idle connection on the new server is closed right away
+	idle := dialHandler(t, h)
+	idle.SetDeadline(time.Now().Add(10 * time.Second))
+	idleReader := bufio.NewReader(idle)
+	if _, err := roundTrip(idle, idleReader, "/"); err != nil {
+		t.Fatalf("request on the new server: %v", err)
+	}
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- h.Shutdown(context.Background()) }()
+
+	if _, err := idleReader.ReadByte(); err != io.EOF {
+		t.Errorf("idle connection: read err = %v, want EOF", err)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v with a request in flight on the retired server", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if resp := <-done; resp != nil && !resp.Close {
+		t.Error("response during shutdown did not close the connection")
+	}
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the last request finished")
+	}
+	if n := h.servers.open(); n != 0 {
+		t.Errorf("%d connections still open after Shutdown", n)
+	}
+}
+
+// BenchmarkServeConn proxies one request per new client connection, the
+// pattern the shared server replaced a per-connection http.Server for
+func BenchmarkServeConn(b *testing.B) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+	h, _ := newTestHandler(b, upstream.URL)
+	defer h.Shutdown(context.Background())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client, server := net.Pipe()
+		go h.ServeConn(server)
+		req, _ := http.NewRequest(http.MethodGet, "http://gateway/", nil)
+		req.Close = true
+		go req.Write(client)
+		resp, err := http.ReadResponse(bufio.NewReader(client), req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		client.Close()
+	}
+}
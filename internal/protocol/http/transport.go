@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/discovery"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"golang.org/x/net/http2"
+)
+
+// Defaults used when neither the specific setting nor backends.http.timeout is set
+const (
+	defaultDialTimeout         = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConnsPerHost = 32
+
+	// Client-facing defaults (the previously hardcoded server timeouts)
+	defaultServerReadTimeout  = 30 * time.Second
+	defaultServerWriteTimeout = 30 * time.Second
+)
+
+// upstreamTimeouts is the resolved transport configuration (no zero values)
+type upstreamTimeouts struct {
+	dial                time.Duration
+	tlsHandshake        time.Duration
+	responseHeader      time.Duration // 0 = no limit
+	idleConn            time.Duration
+	maxIdleConnsPerHost int
+}
+
+// resolveUpstreamTimeouts applies backends.http.timeout and the defaults to the
+// unset transport settings
+func resolveUpstreamTimeouts(cfg config.HTTPBackend) upstreamTimeouts {
+	or := func(d, fallback time.Duration) time.Duration {
+		if d > 0 {
+			return d
+		}
+		if cfg.Timeout > 0 {
+			return cfg.Timeout
+		}
+		return fallback
+	}
+	t := upstreamTimeouts{
+		dial:                or(cfg.DialTimeout, defaultDialTimeout),
+		tlsHandshake:        or(cfg.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		responseHeader:      or(cfg.ResponseHeaderTimeout, 0),
+		idleConn:            cfg.IdleConnTimeout,
+		maxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+	}
+	if t.idleConn <= 0 {
+		t.idleConn = defaultIdleConnTimeout
+	}
+	if t.maxIdleConnsPerHost <= 0 {
+		t.maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	return t
+}
+
+// upstreamDialer returns the dial function of the upstream transports, with
+// the dial timeout and keep-alive
+func upstreamDialer(t upstreamTimeouts) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: t.dial, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return discovery.DefaultResolver.DialContext(ctx, dialer, network, addr)
+	}
+}
+
+// newUpstreamHTTPTransport clones http.DefaultTransport (keeping its proxy and
+// HTTP/2 settings) with the given timeouts
+func newUpstreamHTTPTransport(t upstreamTimeouts) *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = upstreamDialer(t)
+	tr.TLSHandshakeTimeout = t.tlsHandshake
+	tr.ResponseHeaderTimeout = t.responseHeader
+	tr.IdleConnTimeout = t.idleConn
+	tr.MaxIdleConnsPerHost = t.maxIdleConnsPerHost
+	return tr
+}
+
+// upstreamTransport is the RoundTripper shared by every route's proxy. It
+// forwards to the current *http.Transport, which is replaced when the timeouts
+// change; requests in flight finish on the transport they started on.
+type upstreamTransport struct {
+	current atomic.Pointer[upstreamTransportState]
+}
+
+type upstreamTransportState struct {
+	timeouts  upstreamTimeouts
+	transport *http.Transport
+	h2c       *http2.Transport // Same dialer and idle timeout (see protocolTransport)
+}
+
+func newUpstreamTransportState(timeouts upstreamTimeouts) *upstreamTransportState {
+	return &upstreamTransportState{
+		timeouts:  timeouts,
+		transport: newUpstreamHTTPTransport(timeouts),
+		h2c:       newUpstreamH2CTransport(timeouts),
+	}
+}
+
+func newUpstreamTransport(cfg config.HTTPBackend) *upstreamTransport {
+	t := &upstreamTransport{}
+	t.current.Store(newUpstreamTransportState(resolveUpstreamTimeouts(cfg)))
+	return t
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.current.Load().transport.RoundTrip(req)
+}
+
+// roundTripH2C sends req over cleartext HTTP/2 (prior knowledge)
+func (t *upstreamTransport) roundTripH2C(req *http.Request) (*http.Response, error) {
+	return t.current.Load().h2c.RoundTrip(req)
+}
+
+// update swaps in a new transport when the resolved timeouts changed. The old
+// transport's idle connections are closed; its active ones drain normally.
+func (t *upstreamTransport) update(cfg config.HTTPBackend) {
+	timeouts := resolveUpstreamTimeouts(cfg)
+	old := t.current.Load()
+	if old.timeouts == timeouts {
+		return
+	}
+	t.current.Store(newUpstreamTransportState(timeouts))
+	old.transport.CloseIdleConnections()
+	old.h2c.CloseIdleConnections()
+	xlog.Infof("HTTP upstream timeouts updated: dial=%v, tls_handshake=%v, response_header=%v, idle_conn=%v, max_idle_conns_per_host=%d",
+		timeouts.dial, timeouts.tlsHandshake, timeouts.responseHeader, timeouts.idleConn, timeouts.maxIdleConnsPerHost)
+}
+
+// resolveServerTimeouts fills in the client-facing defaults
+func resolveServerTimeouts(cfg config.HTTPServerTimeouts) config.HTTPServerTimeouts {
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = defaultServerReadTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultServerWriteTimeout
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = cfg.ReadTimeout
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = cfg.ReadTimeout
+	}
+	return cfg
+}
+
+// UpdateTimeouts applies new upstream transport and client-facing server
+// timeouts. Server timeouts take effect on new client connections.
+func (h *Handler) UpdateTimeouts(upstream config.HTTPBackend, server config.HTTPServerTimeouts) {
+	h.transport.update(upstream)
+
+	server = resolveServerTimeouts(server)
+	if old := h.serverTimeouts.Swap(&server); old == nil || *old != server {
+		h.replaceServer()
+		xlog.Infof("HTTP server timeouts updated: read_header=%v, read=%v, write=%v, idle=%v",
+			server.ReadHeaderTimeout, server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/balancer"
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// weightedBackend is the split of one configured backend URL, with a proxy
+// per target (index-aligned with group.Targets)
+type weightedBackend struct {
+	group   *balancer.Weighted
+	targets []*route
+}
+
+// UpdateWeights replaces the weighted splits of HTTP backends (entries for
+// TCP backends are ignored). Applies to requests from now on.
+func (h *Handler) UpdateWeights(weights []config.BackendWeights) {
+	h.weightsMu.Lock()
+	defer h.weightsMu.Unlock()
+	h.configured = weights
+	h.applyWeights()
+}
+
+// SetDiscoveredTargets spreads requests to the default backend evenly across
+// targets (host:port), the endpoints found by service discovery, keeping the
+// backend URL's scheme and path. Weights configured for the backend take
+// precedence; empty targets send requests to the backend itself again.
+func (h *Handler) SetDiscoveredTargets(targets []string) {
+	h.weightsMu.Lock()
+	defer h.weightsMu.Unlock()
+	h.discovered = targets
+	h.applyWeights()
+}
+
+// applyWeights rebuilds the weighted splits from the configured weights and
+// the discovered targets. Callers hold weightsMu.
+func (h *Handler) applyWeights() {
+	weights := h.configured
+	h.routesMu.RLock()
+	def := h.defaultRoute
+	h.routesMu.RUnlock()
+	if def != nil && len(h.discovered) > 0 {
+		urls := make([]string, 0, len(h.discovered))
+		for _, addr := range h.discovered {
+			u := *def.target
+			u.Host = addr
+			urls = append(urls, u.String())
+		}
+		weights = config.WithDiscoveredTargets(weights, def.upstream, urls)
+	}
+	backends := make(map[string]*weightedBackend)
+	for _, w := range weights {
+		if !w.IsHTTP() {
+			continue
+		}
+		wb := &weightedBackend{group: balancer.NewWeighted(w)}
+		for _, t := range wb.group.Targets {
+			target, err := url.Parse(t.Addr)
+			if err != nil {
+				break // Rejected by ParseBackendWeights
+			}
+			wb.targets = append(wb.targets, &route{target: target, upstream: t.Addr, proxy: h.newProxy(target, t.Addr)})
+		}
+		if len(wb.targets) == len(wb.group.Targets) {
+			backends[w.Backend] = wb
+		}
+	}
+	old := h.weights.Swap(&backends)
+	if len(backends) > 0 || (old != nil && len(*old) > 0) {
+		xlog.Infof("HTTP backend weights updated: count=%d", len(backends))
+	}
+}
+
+// pickTarget returns the target selected for r when rt's upstream has a
+// weighted split, else rt itself. The hash strategy keys on the hash_header
+// value, or the client IP when it is not configured or absent.
+func (h *Handler) pickTarget(rt *route, r *http.Request) *route {
+	backends := h.weights.Load()
+	if backends == nil {
+		return rt
+	}
+	wb := (*backends)[rt.upstream]
+	if wb == nil {
+		return rt
+	}
+	key := ""
+	if wb.group.Hash {
+		if wb.group.HashHeader != "" {
+			key = r.Header.Get(wb.group.HashHeader)
+		}
+		if key == "" {
+			key = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(key); err == nil {
+				key = host
+			}
+		}
+	}
+	i := wb.group.Select(key, h.health.Available)
+	if i < 0 {
+		return rt
+	}
+	return wb.targets[i]
+}
@@ -1,46 +1,108 @@
 package tcp
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"net"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/SkynetNext/unified-access-gateway/internal/balancer"
+	"github.com/SkynetNext/unified-access-gateway/internal/circuitbreaker"
 	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/discovery"
+	"github.com/SkynetNext/unified-access-gateway/internal/healthcheck"
 	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/internal/observability"
 	"github.com/SkynetNext/unified-access-gateway/internal/security"
+	"github.com/SkynetNext/unified-access-gateway/internal/sockopt"
 	"github.com/SkynetNext/unified-access-gateway/pkg/ebpf"
 	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// backendDialTimeout bounds connection establishment to the TCP backend
+const backendDialTimeout = 5 * time.Second
+
 type Handler struct {
-	backendAddr string
-	sockMapMgr  *ebpf.SockMapManager
-	ebpfEnabled bool
+	backend     atomic.Pointer[tcpBackend] // backends.tcp.target_addr, swapped on cutover
+	dialer      *backendDialer
+	poolCfg     config.TCPPoolConfig
+	tlsCfg      config.BackendTLSConfig
+	sockMapMgr  *ebpf.SockMapManager // Toggled at runtime via SetEBPFEnabled
 	security    *security.Manager
+	health      *healthcheck.UpstreamHealthChecker            // Passive failure reporting (may be nil)
+	breakers    *circuitbreaker.Group                         // nil if circuit breaking is disabled
+	idleTimeout atomic.Int64                                  // Nanoseconds, 0 disables (reloadable)
+	weights     atomic.Pointer[map[string]*balancer.Weighted] // Weighted splits by configured backend
+	// Sources of weights, merged under weightsMu: business:backend_weights and
+	// the endpoints discovered for the backend service
+	weightsMu  sync.Mutex
+	configured []config.BackendWeights
+	discovered []string
+	// Backends sent a PROXY v2 header (reloadable)
+	proxyProtocolTargets atomic.Pointer[map[string]bool]
+}
+
+// tcpBackend is the configured backend with its connection settings, replaced
+// as a whole when backends.tcp.target_addr changes
+type tcpBackend struct {
+	addr string      // "" if only TLS passthrough routes are configured
+	tls  *tls.Config // TLS originated to addr (nil = plain TCP)
+	pool *connPool   // nil if pooling is disabled
 }
 
-func NewHandler(cfg *config.Config, sec *security.Manager) *Handler {
+func NewHandler(cfg *config.Config, sec *security.Manager, store *config.RedisStore, health *healthcheck.UpstreamHealthChecker) *Handler {
 	addr := cfg.Backends.TCP.TargetAddr
-	if addr == "" {
+	if addr == "" && len(cfg.Backends.TLSPassthrough) == 0 {
 		// Business config MUST be loaded from Redis, no fallback
 		xlog.Errorf("CRITICAL: backends.tcp.target_addr is not configured (must be set in Redis)")
 		return nil
 	}
 
 	h := &Handler{
-		backendAddr: addr,
-		security:    sec,
+		dialer: &backendDialer{
+			Dialer: net.Dialer{
+				Timeout:   backendDialTimeout,
+				KeepAlive: -1,
+				Control:   sockopt.Buffers(cfg.Server.Buffers.SocketRecv, cfg.Server.Buffers.SocketSend),
+			},
+			keepAlive: cfg.Server.KeepAlive,
+		},
+		poolCfg:  cfg.Backends.TCP.Pool,
+		tlsCfg:   cfg.Backends.TCP.TLS,
+		security: sec,
+		health:   health,
+		breakers: circuitbreaker.NewGroup(cfg.Backends.CircuitBreaker),
+	}
+	backend, err := h.newBackend(addr)
+	if err != nil {
+		xlog.Errorf("CRITICAL: backends.tcp.tls: %v", err)
+		return nil
+	}
+	h.backend.Store(backend)
+	h.SetIdleTimeout(cfg.Backends.TCP.IdleTimeout)
+	h.SetProxyProtocolTargets(cfg.Backends.TCP.SendProxyProtocol)
+	h.UpdateWeights(cfg.Backends.Weights)
+	if store != nil {
+		go h.watchConfig(store)
 	}
 
 	// Try to initialize eBPF SockMap (optional, graceful fallback)
 	mgr, err := ebpf.NewSockMapManager()
 	if err != nil {
 		xlog.Infof("eBPF SockMap initialization failed (falling back to userspace): %v", err)
-		h.ebpfEnabled = false
 	} else {
 		h.sockMapMgr = mgr
-		h.ebpfEnabled = mgr.IsEnabled()
-		if h.ebpfEnabled {
+		mgr.ObservePairs(middleware.SetEBPFSockMapPairs)
+		if mgr.IsEnabled() {
 			xlog.Infof("eBPF SockMap acceleration enabled")
 			// Try to attach to cgroup (optional, improves performance)
 			// Empty string triggers auto-detection
@@ -53,88 +115,425 @@ func NewHandler(cfg *config.Config, sec *security.Manager) *Handler {
 	return h
 }
 
+// Close releases idle pooled backend connections. Active proxied
+// connections are not affected.
+func (h *Handler) Close() {
+	if pool := h.backend.Load().pool; pool != nil {
+		pool.Close()
+	}
+}
+
+// newBackend prepares the TLS settings and connection pool of addr
+func (h *Handler) newBackend(addr string) (*tcpBackend, error) {
+	b := &tcpBackend{addr: addr}
+	if addr == "" {
+		return b, nil
+	}
+	if h.tlsCfg.Enabled {
+		tc, err := newBackendTLSConfig(h.tlsCfg, addr)
+		if err != nil {
+			return nil, err
+		}
+		b.tls = tc
+		xlog.Infof("TCP backend TLS enabled: server_name=%s (eBPF acceleration not used for these connections)", tc.ServerName)
+	}
+	b.pool = newConnPool(addr, h.dialer, h.poolCfg)
+	return b, nil
+}
+
+// SetBackend switches new connections to addr (backends.tcp.target_addr
+// changed in Redis). Connections already proxied stay on the old backend; its
+// idle pooled connections are closed. An empty addr keeps the current backend.
+func (h *Handler) SetBackend(addr string) {
+	old := h.backend.Load()
+	if addr == old.addr {
+		return
+	}
+	if addr == "" {
+		xlog.Warnf("backends.tcp.target_addr removed from Redis, keeping %s", old.addr)
+		return
+	}
+	backend, err := h.newBackend(addr)
+	if err != nil {
+		xlog.Warnf("TCP backend %s not applied, keeping %s: backends.tcp.tls: %v", addr, old.addr, err)
+		return
+	}
+	h.backend.Store(backend)
+	if old.pool != nil {
+		old.pool.Close()
+	}
+	xlog.Infof("TCP backend updated: %s -> %s", old.addr, addr)
+	h.weightsMu.Lock()
+	if len(h.discovered) > 0 {
+		// The discovered endpoints follow the backend they were found for
+		h.applyWeights()
+	}
+	h.weightsMu.Unlock()
+}
+
+// SockMapStats returns eBPF redirection counters. Safe to call on a nil handler.
+func (h *Handler) SockMapStats() ebpf.SockMapStats {
+	if h == nil {
+		return ebpf.SockMapStats{}
+	}
+	return h.sockMapMgr.Stats()
+}
+
+// SetEBPFEnabled attaches or detaches eBPF sockmap acceleration at runtime.
+// Detaching returns the connections already accelerated to the userspace copy
+// (see ebpf.SockMapManager Enable and Disable). cgroupPath only applies when
+// enabling ("" = the cgroup used before).
+func (h *Handler) SetEBPFEnabled(enabled bool, cgroupPath string) error {
+	if h == nil || h.sockMapMgr == nil {
+		return errors.New("TCP proxy not configured")
+	}
+	if !enabled {
+		h.sockMapMgr.Disable()
+		return nil
+	}
+	return h.sockMapMgr.Enable(cgroupPath)
+}
+
+// dialBackend connects to addr for src, sending the PROXY header if addr
+// expects one and then originating TLS when tc is set. Pooled connections are
+// plain TCP; both happen once one is taken.
+func (h *Handler) dialBackend(src net.Conn, addr string, tc *tls.Config) (net.Conn, error) {
+	var c net.Conn
+	var err error
+	if b := h.backend.Load(); b.pool != nil && addr == b.addr {
+		c, err = b.pool.Get()
+	} else {
+		c, err = h.dialer.dial(addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if h.sendsProxyProtocol(addr) {
+		if err := writeProxyHeader(c, src); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("PROXY header: %w", err)
+		}
+	}
+	if tc == nil {
+		return c, nil
+	}
+	return originateTLS(c, tc)
+}
+
+// backendDialer dials backends with the server.buffers socket sizes and the
+// server.keepalive settings (in place of Go's default keep-alive)
+type backendDialer struct {
+	net.Dialer
+	keepAlive config.KeepAliveConfig
+}
+
+// dial connects to a backend, spreading connections across every address
+// of a host name (e.g. the pods of a headless service)
+func (d *backendDialer) dial(addr string) (net.Conn, error) {
+	c, err := discovery.DefaultResolver.DialContext(context.Background(), &d.Dialer, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := sockopt.SetKeepAlive(c, d.keepAlive.Idle, d.keepAlive.Interval); err != nil {
+		xlog.Debugf("TCP backend %s: keep-alive not set: %v", addr, err)
+	}
+	return c, nil
+}
+
+// IdleTimeout returns the idle timeout applied to new connections (0: none)
+func (h *Handler) IdleTimeout() time.Duration {
+	return time.Duration(h.idleTimeout.Load())
+}
+
+// SetIdleTimeout changes the idle timeout of connections proxied from now on
+func (h *Handler) SetIdleTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if old := time.Duration(h.idleTimeout.Swap(int64(d))); old != d {
+		xlog.Infof("TCP idle timeout updated: %v", d)
+	}
+}
+
+// watchConfig reloads the backend address, idle timeout, PROXY protocol
+// targets and backend weights when business config changes in Redis
+func (h *Handler) watchConfig(store *config.RedisStore) {
+	for update := range store.Subscribe() {
+		if !update.Is("business", "backend_weights") {
+			continue
+		}
+		businessCfg, err := store.LoadBusinessConfig()
+		if err != nil {
+			xlog.Warnf("Failed to reload TCP backend config from Redis: %v", err)
+			continue
+		}
+		h.SetBackend(businessCfg.Backends.TCP.TargetAddr)
+		h.SetIdleTimeout(businessCfg.Backends.TCP.IdleTimeout)
+		h.SetProxyProtocolTargets(businessCfg.Backends.TCP.SendProxyProtocol)
+		h.UpdateWeights(businessCfg.Backends.Weights)
+	}
+}
+
+// closeWrite shuts down the write side of c (sends FIN), looking through
+// wrappers that expose Unwrap() net.Conn. It reports whether c supports it.
+func closeWrite(c net.Conn) bool {
+	for {
+		if cw, ok := c.(interface{ CloseWrite() error }); ok {
+			return cw.CloseWrite() == nil
+		}
+		u, ok := c.(interface{ Unwrap() net.Conn })
+		if !ok {
+			return false
+		}
+		c = u.Unwrap()
+	}
+}
+
+// Handle proxies src to the configured TCP backend
 func (h *Handler) Handle(src net.Conn) {
+	backend := h.backend.Load()
+	if backend.addr == "" {
+		// Only TLS passthrough routes are configured
+		xlog.Warnf("Conn %s -> TCP but backends.tcp.target_addr not configured, closing", src.RemoteAddr())
+		src.Close()
+		return
+	}
+	h.proxy(src, backend.addr, backend.tls)
+}
+
+// HandleTo proxies src to backendAddr, forwarding any bytes already buffered
+// in src (e.g. a peeked TLS ClientHello) unchanged. backends.tcp.tls does not
+// apply: these connections already carry the client's TLS.
+func (h *Handler) HandleTo(src net.Conn, backendAddr string) {
+	h.proxy(src, backendAddr, nil)
+}
+
+// proxy relays src to backendAddr (or the target its weighted split selects),
+// over TLS when backendTLS is set
+func (h *Handler) proxy(src net.Conn, backendAddr string, backendTLS *tls.Config) {
+	if target := h.pickBackend(backendAddr, src); target != backendAddr {
+		backendAddr = target
+		backendTLS = h.backendTLSFor(target, backendTLS)
+	}
+
 	// Metrics: Track active connections
 	middleware.IncActiveConnections("tcp")
 	defer middleware.DecActiveConnections("tcp")
 	defer src.Close()
+	// Shutdown flushes the access log only after this connection has logged
+	middleware.Instance.Hold()
+	defer middleware.Instance.Release()
+
+	// Span covers dial + proxy lifetime. Raw TCP carries no propagation
+	// headers, so this is a root span unless the conn provides a context.
+	ctx := context.Background()
+	if tc, ok := src.(interface{ TraceContext() context.Context }); ok {
+		ctx = tc.TraceContext()
+	}
+	ctx, span := observability.GetTracer().Start(ctx, "gateway.tcp",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("net.peer.addr", src.RemoteAddr().String()),
+			attribute.String("gateway.backend", backendAddr),
+		))
+	defer span.End()
+
+	// Connection ID for correlating audit entries, access logs and metric exemplars
+	connID := middleware.NewRequestID(ctx)
+	span.SetAttributes(attribute.String("gateway.conn_id", connID))
 
 	// Track connection start time and bytes for metrics
 	startTime := time.Now()
 	var bytesIn, bytesOut int64
 
-	// Connect to backend with timeout
-	connTimeout := 5 * time.Second
+	// Fail fast while passive health checking has the backend out of rotation
+	if h.health.IsEjected(backendAddr) {
+		xlog.Warnf("TCP backend %s is ejected, closing %s", backendAddr, src.RemoteAddr())
+		if h.security != nil {
+			h.security.AuditTCP(connID, src.RemoteAddr().String(), backendAddr, false, "upstream ejected")
+		}
+		middleware.RecordUpstreamRequest(backendAddr, "ejected", 0)
+		span.SetStatus(codes.Error, "backend ejected")
+		return
+	}
+
+	// Fail fast while the backend's circuit breaker is open
+	if !h.breakers.Allow(backendAddr) {
+		xlog.Warnf("TCP backend %s circuit open, closing %s", backendAddr, src.RemoteAddr())
+		if h.security != nil {
+			h.security.AuditTCP(connID, src.RemoteAddr().String(), backendAddr, false, "circuit breaker open")
+		}
+		middleware.RecordUpstreamRequest(backendAddr, "circuit_open", 0)
+		span.SetStatus(codes.Error, "circuit breaker open")
+		return
+	}
+
+	// Connect to backend with timeout (warm pooled connection if available)
+	middleware.SetConnBackend(src, backendAddr)
 	dialStartTime := time.Now()
-	dst, err := net.DialTimeout("tcp", h.backendAddr, connTimeout)
+	dst, err := h.dialBackend(src, backendAddr, backendTLS)
 	dialDuration := time.Since(dialStartTime)
+	h.breakers.Record(backendAddr, err == nil)
 	if err != nil {
-		xlog.Errorf("Failed to dial backend %s: %v", h.backendAddr, err)
+		xlog.Errorf("Failed to dial backend %s: %v", backendAddr, err)
 		if h.security != nil {
-			h.security.AuditTCP(src.RemoteAddr().String(), h.backendAddr, false, err.Error())
+			h.security.AuditTCP(connID, src.RemoteAddr().String(), backendAddr, false, err.Error())
 		}
 		// Record failed connection metrics (dial time even for failures)
-		middleware.RecordUpstreamRequest(h.backendAddr, "connection_failed", dialDuration.Seconds())
+		middleware.RecordUpstreamRequest(backendAddr, "connection_failed", dialDuration.Seconds())
+		h.health.ReportFailure(backendAddr)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "backend dial failed")
 		return
 	}
 	defer dst.Close()
 
 	// Record connection establishment time (dial time) for TCP
 	// This is the meaningful latency metric for TCP transparent proxy
-	middleware.RecordUpstreamRequest(h.backendAddr, "success", dialDuration.Seconds())
+	middleware.RecordUpstreamRequest(backendAddr, "success", dialDuration.Seconds())
+	span.AddEvent("backend.connected", trace.WithAttributes(attribute.Int64("dial_ms", dialDuration.Milliseconds())))
 
-	xlog.Infof("TCP Proxy: %s <-> %s", src.RemoteAddr(), dst.RemoteAddr())
+	xlog.Infof("TCP Proxy: %s <-> %s (conn %s)", src.RemoteAddr(), dst.RemoteAddr(), connID)
 	if h.security != nil {
-		h.security.AuditTCP(src.RemoteAddr().String(), h.backendAddr, true, "")
+		h.security.AuditTCP(connID, src.RemoteAddr().String(), backendAddr, true, "")
 	}
 
-	// Register socket pair for eBPF redirection (if enabled)
-	if h.ebpfEnabled {
-		if err := h.sockMapMgr.RegisterSocketPair(src, dst); err != nil {
+	// Register socket pair for eBPF redirection (if enabled). Never with
+	// backend TLS: sockmap would splice plaintext into the encrypted stream.
+	// A PROXY header was already written by dialBackend, so redirected
+	// client bytes cannot overtake it.
+	accelerated := false
+	if backendTLS != nil {
+		middleware.RecordEBPFRegistration("skipped", "backend_tls")
+	} else {
+		switch err := h.sockMapMgr.RegisterSocketPair(src, dst); {
+		case errors.Is(err, ebpf.ErrDisabled):
+			middleware.RecordEBPFRegistration("disabled", "")
+		case err != nil:
 			xlog.Debugf("Failed to register socket pair in eBPF: %v", err)
-		} else {
+			middleware.RecordEBPFRegistration("failed", ebpf.FailureReason(err))
+		default:
 			xlog.Debugf("Socket pair registered in eBPF SockMap")
+			middleware.RecordEBPFRegistration("registered", "")
+			accelerated = true
 			defer h.sockMapMgr.UnregisterSocketPair(src, dst)
 		}
 	}
+	span.SetAttributes(attribute.Bool("gateway.ebpf_accelerated", accelerated))
 
 	// Bidirectional Copy (userspace fallback + eBPF acceleration)
 	// Even with eBPF, we need this for initial packets and fallback
 	// eBPF will handle most packets at kernel level after registration
-	errChan := make(chan error, 2)
-	bytesChan := make(chan struct{ in, out int64 }, 2)
+	type copyResult struct {
+		upstream   bool // true: src -> dst
+		n          int64
+		err        error
+		halfClosed bool // EOF was forwarded as a FIN and the other direction stays open
+	}
+	results := make(chan copyResult, 2)
+
+	// Idle timeout via read deadlines. Not with eBPF redirection, which moves
+	// bytes in the kernel without the reads that keep the pair alive.
+	var idle *idleTimer
+	var upstreamSrc, downstreamSrc net.Conn = src, dst
+	var upstreamDst, downstreamDst net.Conn = dst, src
+	var pending []byte // Client bytes buffered by the sniffer, sent before splicing
+	spliced := false   // Copies bypass src's wrappers, including its byte counters
+	if timeout := h.IdleTimeout(); timeout > 0 && !accelerated {
+		idle = newIdleTimer(timeout, src, dst)
+		upstreamSrc = &idleReader{Conn: src, timer: idle}
+		downstreamSrc = &idleReader{Conn: dst, timer: idle}
+	} else if rawSrc, rawDst, buffered, ok := spliceConns(src, dst); ok {
+		// Between two *net.TCPConn io.Copy uses splice(2) on Linux. Not with
+		// the idle timeout, whose reader wrappers need to see every read.
+		upstreamSrc, upstreamDst = rawSrc, rawDst
+		downstreamSrc, downstreamDst = rawDst, rawSrc
+		pending = buffered
+		spliced = true
+	}
 
 	go func() {
 		// src -> dst (Upstream)
-		n, err := io.Copy(dst, src)
-		bytesChan <- struct{ in, out int64 }{in: n, out: 0}
-		errChan <- err
+		var n int64
+		var err error
+		if len(pending) > 0 {
+			var w int
+			w, err = upstreamDst.Write(pending)
+			n = int64(w)
+		}
+		if err == nil {
+			var copied int64
+			copied, err = io.Copy(upstreamDst, upstreamSrc)
+			n += copied
+			if spliced {
+				addSplicedBytes(src, copied, 0)
+			}
+		}
+		results <- copyResult{upstream: true, n: n, err: err, halfClosed: err == nil && closeWrite(dst)}
 	}()
 
 	go func() {
 		// dst -> src (Downstream)
-		n, err := io.Copy(src, dst)
-		bytesChan <- struct{ in, out int64 }{in: 0, out: n}
-		errChan <- err
+		n, err := io.Copy(downstreamDst, downstreamSrc)
+		if spliced {
+			addSplicedBytes(src, 0, n)
+		}
+		results <- copyResult{upstream: false, n: n, err: err, halfClosed: err == nil && closeWrite(src)}
 	}()
 
-	// Wait for any side to close
-	<-errChan
-
-	// Collect bytes transferred from both directions
+	// Wait for both directions so neither byte count is lost. A clean EOF is
+	// passed on as a half-close, so a peer that stops sending can still
+	// receive (request/response protocols over raw TCP). After an error, or
+	// if the peer cannot be half-closed, an immediate read deadline unblocks
+	// the other copy without closing the sockets (still needed for eBPF
+	// unregistration).
+	tornDown, idleExpired := false, false
 	for i := 0; i < 2; i++ {
-		select {
-		case b := <-bytesChan:
-			bytesIn += b.in
-			bytesOut += b.out
-		default:
+		r := <-results
+		if r.upstream {
+			bytesIn = r.n
+		} else {
+			bytesOut = r.n
+		}
+		if idle != nil && !tornDown && errors.Is(r.err, os.ErrDeadlineExceeded) {
+			idleExpired = true
+		}
+		if !tornDown && (r.err != nil || !r.halfClosed) {
+			tornDown = true
+			if idle != nil {
+				idle.stop()
+			}
+			now := time.Now()
+			src.SetReadDeadline(now)
+			dst.SetReadDeadline(now)
+		}
+	}
+	if idleExpired {
+		xlog.Infof("TCP Proxy: %s <-> %s idle for %v, closing", src.RemoteAddr(), dst.RemoteAddr(), idle.timeout)
+		if h.security != nil {
+			h.security.AuditTCP(connID, src.RemoteAddr().String(), backendAddr, false, "idle timeout")
 		}
+		span.AddEvent("idle.timeout")
 	}
 
 	// Record TCP metrics
 	duration := time.Since(startTime)
-	middleware.RecordTCPMetrics(h.backendAddr, duration.Seconds(), bytesIn, bytesOut)
+	middleware.RecordTCPMetrics(ctx, backendAddr, connID, duration.Seconds(), bytesIn, bytesOut)
 	middleware.RecordConnectionDuration("tcp", duration.Seconds())
+	clientIP, _, _ := net.SplitHostPort(src.RemoteAddr().String())
+	middleware.Instance.Log(&middleware.AccessLog{
+		Timestamp:  startTime,
+		RequestID:  connID,
+		ClientIP:   clientIP,
+		Protocol:   "TCP",
+		DurationMs: duration.Milliseconds(),
+		BytesIn:    bytesIn,
+		BytesOut:   bytesOut,
+	})
+	span.SetAttributes(
+		attribute.Int64("gateway.bytes_in", bytesIn),
+		attribute.Int64("gateway.bytes_out", bytesOut),
+	)
 
 	// Note: Upstream request latency (dial time) is already recorded after connection establishment
 }
@@ -0,0 +1,169 @@
+package tcp
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestHandler returns a Handler proxying to backendAddr without pooling,
+// TLS, eBPF or security checks
+func newTestHandler(backendAddr string, idleTimeout time.Duration) *Handler {
+	h := &Handler{dialer: &backendDialer{Dialer: net.Dialer{Timeout: backendDialTimeout}}}
+	h.backend.Store(&tcpBackend{addr: backendAddr})
+	h.SetIdleTimeout(idleTimeout)
+	return h
+}
+
+// listen returns a loopback listener closed at the end of the test
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+// proxyOnce accepts one client connection on a gateway listener and proxies it
+// through h. It returns the client connection and a channel closed when Handle
+// has returned.
+func proxyOnce(t *testing.T, h *Handler) (*net.TCPConn, <-chan struct{}) {
+	t.Helper()
+	ln := listen(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		h.Handle(c)
+	}()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial gateway: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client.(*net.TCPConn), done
+}
+
+// waitDone fails the test if Handle does not return in time
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy did not finish")
+	}
+}
+
+func TestHandleCountsBothDirections(t *testing.T) {
+	request := bytes.Repeat([]byte("q"), 100<<10)
+	response := bytes.Repeat([]byte("r"), 300<<10)
+
+	for _, tc := range []struct {
+		name        string
+		idleTimeout time.Duration
+	}{
+		{"splice", 0},
+		{"idle timeout", time.Minute},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := listen(t)
+			received := make(chan int, 1)
+			go func() {
+				c, err := backend.Accept()
+				if err != nil {
+					return
+				}
+				defer c.Close()
+				n, _ := io.Copy(io.Discard, c)
+				received <- int(n)
+				c.Write(response)
+			}()
+
+			inBefore := testutil.ToFloat64(middleware.RequestBytes.WithLabelValues("tcp", "in"))
+			outBefore := testutil.ToFloat64(middleware.RequestBytes.WithLabelValues("tcp", "out"))
+
+			client, done := proxyOnce(t, newTestHandler(backend.Addr().String(), tc.idleTimeout))
+			if _, err := client.Write(request); err != nil {
+				t.Fatalf("write request: %v", err)
+			}
+			client.CloseWrite()
+			got, err := io.ReadAll(client)
+			if err != nil {
+				t.Fatalf("read response: %v", err)
+			}
+			waitDone(t, done)
+
+			if n := <-received; n != len(request) {
+				t.Errorf("backend received %d bytes, want %d", n, len(request))
+			}
+			if len(got) != len(response) {
+				t.Errorf("client received %d bytes, want %d", len(got), len(response))
+			}
+			in := testutil.ToFloat64(middleware.RequestBytes.WithLabelValues("tcp", "in")) - inBefore
+			out := testutil.ToFloat64(middleware.RequestBytes.WithLabelValues("tcp", "out")) - outBefore
+			if in != float64(len(request)) {
+				t.Errorf("bytes in = %v, want %d", in, len(request))
+			}
+			if out != float64(len(response)) {
+				t.Errorf("bytes out = %v, want %d", out, len(response))
+			}
+		})
+	}
+}
+
+func TestHandleForwardsBackendHalfClose(t *testing.T) {
+	greeting := []byte("ready\n")
+	request := bytes.Repeat([]byte("q"), 64<<10)
+
+	backend := listen(t)
+	received := make(chan []byte, 1)
+	go func() {
+		c, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		// Send everything, then half-close and keep reading the client
+		c.Write(greeting)
+		c.(*net.TCPConn).CloseWrite()
+		data, _ := io.ReadAll(c)
+		received <- data
+	}()
+
+	client, done := proxyOnce(t, newTestHandler(backend.Addr().String(), 0))
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// The backend's FIN reaches the client as EOF after its data
+	got, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("read from backend: %v", err)
+	}
+	if !bytes.Equal(got, greeting) {
+		t.Fatalf("client received %q, want %q", got, greeting)
+	}
+
+	// The client -> backend direction is still open
+	if _, err := client.Write(request); err != nil {
+		t.Fatalf("write after backend half-close: %v", err)
+	}
+	client.CloseWrite()
+	select {
+	case data := <-received:
+		if !bytes.Equal(data, request) {
+			t.Errorf("backend received %d bytes after its half-close, want %d", len(data), len(request))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend did not see the client's EOF")
+	}
+	waitDone(t, done)
+}
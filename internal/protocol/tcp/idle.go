@@ -0,0 +1,78 @@
+package tcp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idleTimer enforces an idle timeout on a proxied connection pair through read
+// deadlines, without a timer goroutine: every read that returns data pushes
+// both read deadlines forward, so a pair only times out when neither side has
+// sent anything for the timeout.
+type idleTimer struct {
+	timeout time.Duration
+	conns   [2]net.Conn
+	// lastRefresh (unix nanos) limits deadline updates to a few per timeout
+	lastRefresh atomic.Int64
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+func newIdleTimer(timeout time.Duration, a, b net.Conn) *idleTimer {
+	t := &idleTimer{timeout: timeout, conns: [2]net.Conn{a, b}}
+	t.refresh(time.Now())
+	return t
+}
+
+// touch records activity, refreshing the deadlines once timeout/8 has passed
+// since the last refresh (so the effective timeout is within 1/8 of the setting)
+func (t *idleTimer) touch() {
+	now := time.Now()
+	if now.UnixNano()-t.lastRefresh.Load() < int64(t.timeout/8) {
+		return
+	}
+	t.refresh(now)
+}
+
+func (t *idleTimer) refresh(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	t.lastRefresh.Store(now.UnixNano())
+	deadline := now.Add(t.timeout)
+	for _, c := range t.conns {
+		c.SetReadDeadline(deadline)
+	}
+}
+
+// stop prevents further refreshes, so a teardown deadline set afterwards sticks
+func (t *idleTimer) stop() {
+	t.mu.Lock()
+	t.stopped = true
+	t.mu.Unlock()
+}
+
+// idleReader wraps one side of the pair, reporting reads to the timer. Write
+// and the other net.Conn methods go straight to the connection.
+type idleReader struct {
+	net.Conn
+	timer *idleTimer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if n > 0 {
+		r.timer.touch()
+	}
+	return n, err
+}
+
+// Unwrap returns the wrapped connection (see closeWrite)
+func (r *idleReader) Unwrap() net.Conn {
+	return r.Conn
+}
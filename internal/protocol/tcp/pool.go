@@ -0,0 +1,232 @@
+package tcp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// Defaults used when backends.tcp.pool.* is partially configured
+const (
+	defaultPoolMaxLifetime = 5 * time.Minute
+	defaultPoolIdleTimeout = 60 * time.Second
+	// poolProbeTimeout is how long Get waits for data/EOF when verifying a conn is idle
+	poolProbeTimeout = time.Millisecond
+)
+
+// connPool keeps pre-dialed backend connections ready to hide handshake latency.
+// Arbitrary TCP streams carry application state, so a connection is only
+// reusable if it was returned via Put without ever carrying client traffic;
+// the proxy never returns connections it has relayed. Every connection is
+// verified idle (no pending data, not closed by the peer) before being handed out.
+// Not suitable for server-speaks-first protocols (the greeting fails the idle check).
+type connPool struct {
+	addr        string
+	dialer      *backendDialer
+	maxIdle     int
+	maxLifetime time.Duration
+	idleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle []*pooledConn
+
+	wakeCh chan struct{}
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+type pooledConn struct {
+	net.Conn
+	createdAt  time.Time
+	returnedAt time.Time
+}
+
+// newConnPool returns nil if pooling is disabled (max_idle <= 0)
+func newConnPool(addr string, dialer *backendDialer, cfg config.TCPPoolConfig) *connPool {
+	if cfg.MaxIdle <= 0 {
+		return nil
+	}
+	p := &connPool{
+		addr:        addr,
+		dialer:      dialer,
+		maxIdle:     cfg.MaxIdle,
+		maxLifetime: cfg.MaxLifetime,
+		idleTimeout: cfg.IdleTimeout,
+		wakeCh:      make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+	}
+	if p.maxLifetime <= 0 {
+		p.maxLifetime = defaultPoolMaxLifetime
+	}
+	if p.idleTimeout <= 0 {
+		p.idleTimeout = defaultPoolIdleTimeout
+	}
+
+	xlog.Infof("TCP backend pool enabled: addr=%s, max_idle=%d, max_lifetime=%v, idle_timeout=%v",
+		addr, p.maxIdle, p.maxLifetime, p.idleTimeout)
+
+	p.wg.Add(1)
+	go p.maintain()
+	return p
+}
+
+// Get returns a verified idle pooled connection, or dials a new one on a miss
+func (p *connPool) Get() (net.Conn, error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		// LIFO: most recently returned conns are least likely to be stale
+		pc := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if p.expired(pc, time.Now()) || !verifyIdle(pc.Conn) {
+			pc.Close()
+			continue
+		}
+		middleware.RecordTCPPoolRequest(p.addr, "hit")
+		p.wake()
+		return pc.Conn, nil
+	}
+
+	middleware.RecordTCPPoolRequest(p.addr, "miss")
+	p.wake()
+	return p.dialer.dial(p.addr)
+}
+
+// Put returns a connection that has never carried client traffic
+func (p *connPool) Put(c net.Conn) {
+	p.put(&pooledConn{Conn: c, createdAt: time.Now(), returnedAt: time.Now()})
+}
+
+func (p *connPool) put(pc *pooledConn) {
+	select {
+	case <-p.stopCh:
+		pc.Close()
+		return
+	default:
+	}
+
+	p.mu.Lock()
+	if len(p.idle) >= p.maxIdle {
+		p.mu.Unlock()
+		pc.Close()
+		return
+	}
+	p.idle = append(p.idle, pc)
+	p.mu.Unlock()
+}
+
+// Close stops the maintainer and closes all idle connections
+func (p *connPool) Close() {
+	close(p.stopCh)
+	p.wg.Wait()
+
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, pc := range idle {
+		pc.Close()
+	}
+	xlog.Infof("TCP backend pool closed: addr=%s, closed_idle=%d", p.addr, len(idle))
+}
+
+func (p *connPool) wake() {
+	select {
+	case p.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (p *connPool) expired(pc *pooledConn, now time.Time) bool {
+	return now.Sub(pc.createdAt) >= p.maxLifetime || now.Sub(pc.returnedAt) >= p.idleTimeout
+}
+
+// maintain evicts expired connections and keeps maxIdle warm connections ready
+func (p *connPool) maintain() {
+	defer p.wg.Done()
+
+	interval := p.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.evictExpired()
+		p.fill()
+
+		select {
+		case <-ticker.C:
+		case <-p.wakeCh:
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+func (p *connPool) evictExpired() {
+	now := time.Now()
+	p.mu.Lock()
+	kept := p.idle[:0]
+	var expired []*pooledConn
+	for _, pc := range p.idle {
+		if p.expired(pc, now) {
+			expired = append(expired, pc)
+		} else {
+			kept = append(kept, pc)
+		}
+	}
+	p.idle = kept
+	p.mu.Unlock()
+
+	for _, pc := range expired {
+		pc.Close()
+	}
+}
+
+// fill dials until maxIdle connections are pooled, stopping at the first error
+func (p *connPool) fill() {
+	for {
+		p.mu.Lock()
+		missing := p.maxIdle - len(p.idle)
+		p.mu.Unlock()
+		if missing <= 0 {
+			return
+		}
+
+		select {
+		case <-p.stopCh:
+			return
+		default:
+		}
+
+		c, err := p.dialer.dial(p.addr)
+		if err != nil {
+			xlog.Debugf("TCP backend pool: pre-dial to %s failed: %v", p.addr, err)
+			return
+		}
+		p.Put(c)
+	}
+}
+
+// verifyIdle checks that the peer has neither sent data nor closed the connection
+func verifyIdle(c net.Conn) bool {
+	c.SetReadDeadline(time.Now().Add(poolProbeTimeout))
+	var b [1]byte
+	_, err := c.Read(b[:])
+	c.SetReadDeadline(time.Time{})
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,82 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// PROXY protocol v2 header fields (spec section 2.2)
+const (
+	proxyV2VerCmdLocal = 0x20 // Version 2, LOCAL
+	proxyV2VerCmdProxy = 0x21 // Version 2, PROXY
+
+	proxyV2FamUnspec   = 0x00
+	proxyV2FamTCP4     = 0x11 // AF_INET, STREAM
+	proxyV2FamTCP6     = 0x21 // AF_INET6, STREAM
+	proxyV2AddrLenTCP4 = 12
+	proxyV2AddrLenTCP6 = 36
+)
+
+// proxyHeaderWriteTimeout bounds writing the header to a new backend connection
+const proxyHeaderWriteTimeout = 2 * time.Second
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// SetProxyProtocolTargets sets the backend addresses that receive a PROXY v2
+// header, applied to connections dialed from now on
+func (h *Handler) SetProxyProtocolTargets(addrs []string) {
+	targets := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		targets[addr] = true
+	}
+	old := h.proxyProtocolTargets.Swap(&targets)
+	if (old == nil && len(targets) == 0) || (old != nil && reflect.DeepEqual(*old, targets)) {
+		return
+	}
+	xlog.Infof("TCP PROXY protocol targets updated: %v", addrs)
+}
+
+// sendsProxyProtocol reports whether backendAddr is sent a PROXY v2 header
+func (h *Handler) sendsProxyProtocol(backendAddr string) bool {
+	targets := h.proxyProtocolTargets.Load()
+	return targets != nil && (*targets)[backendAddr]
+}
+
+// writeProxyHeader sends a PROXY v2 header carrying src's client and
+// destination addresses on a freshly dialed backend connection
+func writeProxyHeader(dst, src net.Conn) error {
+	dst.SetWriteDeadline(time.Now().Add(proxyHeaderWriteTimeout))
+	defer dst.SetWriteDeadline(time.Time{})
+	_, err := dst.Write(proxyV2Header(src.RemoteAddr(), src.LocalAddr()))
+	return err
+}
+
+// proxyV2Header encodes a PROXY command for TCP addresses. Mixed families are
+// sent as IPv6 (IPv4-mapped); anything else is sent as LOCAL, which tells the
+// backend to use the connection's own addresses.
+func proxyV2Header(clientAddr, localAddr net.Addr) []byte {
+	header := append([]byte(nil), proxyV2Signature...)
+	client, ok1 := clientAddr.(*net.TCPAddr)
+	local, ok2 := localAddr.(*net.TCPAddr)
+	if !ok1 || !ok2 || client.IP == nil || local.IP == nil {
+		return append(header, proxyV2VerCmdLocal, proxyV2FamUnspec, 0, 0)
+	}
+
+	if client4, local4 := client.IP.To4(), local.IP.To4(); client4 != nil && local4 != nil {
+		header = append(header, proxyV2VerCmdProxy, proxyV2FamTCP4)
+		header = binary.BigEndian.AppendUint16(header, proxyV2AddrLenTCP4)
+		header = append(header, client4...)
+		header = append(header, local4...)
+	} else {
+		header = append(header, proxyV2VerCmdProxy, proxyV2FamTCP6)
+		header = binary.BigEndian.AppendUint16(header, proxyV2AddrLenTCP6)
+		header = append(header, client.IP.To16()...)
+		header = append(header, local.IP.To16()...)
+	}
+	header = binary.BigEndian.AppendUint16(header, uint16(client.Port))
+	return binary.BigEndian.AppendUint16(header, uint16(local.Port))
+}
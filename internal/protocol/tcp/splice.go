@@ -0,0 +1,68 @@
+package tcp
+
+import "net"
+
+// bufferedConn is a wrapper that may hold bytes already read from the
+// connection it wraps (core.SniffConn after sniffing)
+type bufferedConn interface {
+	Unwrap() net.Conn
+	TakeBuffered() []byte
+}
+
+// byteCounter is a wrapper that counts the bytes read and written through it
+// (the listener's connection tracking)
+type byteCounter interface {
+	AddBytes(in, out int64)
+}
+
+// rawTCP returns the *net.TCPConn under the wrappers of c, or nil if c is not
+// a plain TCP connection (e.g. TLS terminated by the gateway). Wrappers are
+// looked through by Unwrap() net.Conn and must not change the bytes read or
+// written (SniffConn, the listener's connection tracking).
+func rawTCP(c net.Conn) *net.TCPConn {
+	for {
+		switch v := c.(type) {
+		case *net.TCPConn:
+			return v
+		case interface{ Unwrap() net.Conn }:
+			c = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// spliceConns returns the raw TCP connections of src and dst, so io.Copy
+// between them uses splice(2) on Linux instead of copying through userspace
+// buffers, with the bytes the wrappers of src had already read. ok is false
+// (and nothing is taken) unless both sides are plain TCP.
+func spliceConns(src, dst net.Conn) (rawSrc, rawDst *net.TCPConn, pending []byte, ok bool) {
+	rawSrc, rawDst = rawTCP(src), rawTCP(dst)
+	if rawSrc == nil || rawDst == nil {
+		return nil, nil, nil, false
+	}
+	for c := src; c != net.Conn(rawSrc); {
+		if b, ok := c.(bufferedConn); ok {
+			pending = append(pending, b.TakeBuffered()...)
+		}
+		c = c.(interface{ Unwrap() net.Conn }).Unwrap()
+	}
+	return rawSrc, rawDst, pending, true
+}
+
+// addSplicedBytes reports bytes copied past the wrappers of c to the first one
+// that counts its traffic. Bytes the wrappers had buffered were counted when
+// they were read, so in only covers what was spliced.
+func addSplicedBytes(c net.Conn, in, out int64) {
+	for {
+		if bc, ok := c.(byteCounter); ok {
+			bc.AddBytes(in, out)
+			return
+		}
+		u, ok := c.(interface{ Unwrap() net.Conn })
+		if !ok {
+			return
+		}
+		c = u.Unwrap()
+	}
+}
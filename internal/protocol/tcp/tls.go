@@ -0,0 +1,66 @@
+package tcp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+)
+
+// newBackendTLSConfig builds the client TLS settings for backendAddr. The
+// server name defaults to the host of the address.
+func newBackendTLSConfig(cfg config.BackendTLSConfig, backendAddr string) (*tls.Config, error) {
+	tc := &tls.Config{
+		ServerName:         cfg.ServerName,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if tc.ServerName == "" {
+		host, _, err := net.SplitHostPort(backendAddr)
+		if err != nil {
+			return nil, fmt.Errorf("server name from %q: %w", backendAddr, err)
+		}
+		tc.ServerName = host
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("cert_file and key_file must be set together")
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// originateTLS performs the client handshake on a dialed backend connection,
+// closing it on failure
+func originateTLS(c net.Conn, tc *tls.Config) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), backendDialTimeout)
+	defer cancel()
+	tlsConn := tls.Client(c, tc)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("backend TLS handshake: %w", err)
+	}
+	return tlsConn, nil
+}
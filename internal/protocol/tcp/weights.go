@@ -0,0 +1,91 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/balancer"
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// UpdateWeights replaces the weighted splits of TCP backends (entries for
+// HTTP backends are ignored). Applies to connections accepted from now on.
+func (h *Handler) UpdateWeights(weights []config.BackendWeights) {
+	h.weightsMu.Lock()
+	defer h.weightsMu.Unlock()
+	h.configured = weights
+	h.applyWeights()
+}
+
+// SetDiscoveredTargets spreads connections to the configured backend evenly
+// across targets (host:port), the endpoints found by service discovery.
+// Weights configured for the backend take precedence; empty targets send
+// connections to the backend itself again.
+func (h *Handler) SetDiscoveredTargets(targets []string) {
+	h.weightsMu.Lock()
+	defer h.weightsMu.Unlock()
+	h.discovered = targets
+	h.applyWeights()
+}
+
+// applyWeights rebuilds the weighted splits from the configured weights and
+// the discovered targets. Callers hold weightsMu.
+func (h *Handler) applyWeights() {
+	backend := ""
+	if b := h.backend.Load(); b != nil {
+		backend = b.addr
+	}
+	weights := config.WithDiscoveredTargets(h.configured, backend, h.discovered)
+	groups := make(map[string]*balancer.Weighted)
+	for _, w := range weights {
+		if !w.IsHTTP() {
+			groups[w.Backend] = balancer.NewWeighted(w)
+		}
+	}
+	old := h.weights.Swap(&groups)
+	if len(groups) > 0 || (old != nil && len(*old) > 0) {
+		xlog.Infof("TCP backend weights updated: count=%d", len(groups))
+	}
+}
+
+// pickBackend returns the target selected for backendAddr and the client of
+// src when backendAddr has a weighted split, else backendAddr itself. The
+// hash strategy keys on the client IP (from the PROXY header if one was read).
+func (h *Handler) pickBackend(backendAddr string, src net.Conn) string {
+	groups := h.weights.Load()
+	if groups == nil {
+		return backendAddr
+	}
+	g := (*groups)[backendAddr]
+	if g == nil {
+		return backendAddr
+	}
+	key := ""
+	if g.Hash {
+		key = src.RemoteAddr().String()
+		if host, _, err := net.SplitHostPort(key); err == nil {
+			key = host
+		}
+	}
+	i := g.Select(key, h.health.Available)
+	if i < 0 {
+		return backendAddr
+	}
+	return g.Targets[i].Addr
+}
+
+// backendTLSFor returns the TLS settings for a target selected instead of the
+// configured backend: the server name follows the target unless set explicitly
+func (h *Handler) backendTLSFor(target string, tc *tls.Config) *tls.Config {
+	if tc == nil || h.tlsCfg.ServerName != "" {
+		return tc
+	}
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return tc
+	}
+	tc = tc.Clone()
+	tc.ServerName = host
+	return tc
+}
@@ -0,0 +1,128 @@
+package tcp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+)
+
+// clientConn is a net.Conn that only reports its client address
+type clientConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c clientConn) RemoteAddr() net.Addr { return c.remote }
+
+// hashTargets maps 1000 client IPs to the target picked for them with the
+// hash strategy over targets (equal weights)
+func hashTargets(targets ...string) map[string]string {
+	h := &Handler{}
+	w := config.BackendWeights{Backend: "game:9000", Strategy: config.SelectHash, Targets: map[string]int{}}
+	for _, t := range targets {
+		w.Targets[t] = 1
+	}
+	h.UpdateWeights([]config.BackendWeights{w})
+
+	picks := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		src := clientConn{remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000 + i}}
+		picks[ip] = h.pickBackend("game:9000", src)
+	}
+	return picks
+}
+
+func TestPickBackendHashIsSticky(t *testing.T) {
+	h := &Handler{}
+	h.UpdateWeights([]config.BackendWeights{{
+		Backend:  "game:9000",
+		Strategy: config.SelectHash,
+		Targets:  map[string]int{"game-a:9000": 1, "game-b:9000": 1, "game-c:9000": 1},
+	}})
+
+	// Reconnects come from new source ports; only the IP is hashed
+	first := h.pickBackend("game:9000", clientConn{remote: &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 50000}})
+	for port := 50001; port < 50100; port++ {
+		got := h.pickBackend("game:9000", clientConn{remote: &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: port}})
+		if got != first {
+			t.Fatalf("port %d picked %s, earlier connections picked %s", port, got, first)
+		}
+	}
+
+	// Every target gets a share of the clients
+	counts := make(map[string]int)
+	for _, target := range hashTargets("game-a:9000", "game-b:9000", "game-c:9000") {
+		counts[target]++
+	}
+	for _, target := range []string{"game-a:9000", "game-b:9000", "game-c:9000"} {
+		if counts[target] < 200 {
+			t.Errorf("%s got %d of 1000 clients, want about a third", target, counts[target])
+		}
+	}
+}
+
+func TestPickBackendHashMinimalRemapping(t *testing.T) {
+	four := hashTargets("a:9000", "b:9000", "c:9000", "d:9000")
+
+	// Adding a target only moves clients to it
+	five := hashTargets("a:9000", "b:9000", "c:9000", "d:9000", "e:9000")
+	moved := 0
+	for ip, before := range four {
+		if after := five[ip]; after != before {
+			moved++
+			if after != "e:9000" {
+				t.Errorf("adding e: %s moved %s -> %s", ip, before, after)
+			}
+		}
+	}
+	if moved == 0 || moved > 300 {
+		t.Errorf("adding e moved %d of 1000 clients, want about 200", moved)
+	}
+
+	// Removing a target only moves the clients it had
+	three := hashTargets("a:9000", "b:9000", "c:9000")
+	for ip, before := range four {
+		after := three[ip]
+		if before != "d:9000" && after != before {
+			t.Errorf("removing d: %s moved %s -> %s", ip, before, after)
+		}
+		if after == "d:9000" {
+			t.Errorf("removing d: %s still picks d", ip)
+		}
+	}
+}
+
+func TestPickBackendDiscoveredTargets(t *testing.T) {
+	h := newTestHandler("game:9000", 0)
+	src := clientConn{remote: &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 50000}}
+
+	h.SetDiscoveredTargets([]string{"10.0.0.1:9000", "10.0.0.2:9000"})
+	picked := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		picked[h.pickBackend("game:9000", src)] = true
+	}
+	if len(picked) != 2 || !picked["10.0.0.1:9000"] || !picked["10.0.0.2:9000"] {
+		t.Errorf("picked %v, want both discovered endpoints", picked)
+	}
+
+	// Configured weights for the backend take precedence
+	h.UpdateWeights([]config.BackendWeights{{Backend: "game:9000", Targets: map[string]int{"game-canary:9000": 1}}})
+	if got := h.pickBackend("game:9000", src); got != "game-canary:9000" {
+		t.Errorf("with configured weights picked %s, want game-canary:9000", got)
+	}
+	h.UpdateWeights(nil)
+
+	// The endpoints follow a backend cutover
+	h.SetBackend("game-v2:9000")
+	if got := h.pickBackend("game-v2:9000", src); got != "10.0.0.1:9000" && got != "10.0.0.2:9000" {
+		t.Errorf("after cutover picked %s, want a discovered endpoint", got)
+	}
+
+	h.SetDiscoveredTargets(nil)
+	if got := h.pickBackend("game-v2:9000", src); got != "game-v2:9000" {
+		t.Errorf("without endpoints picked %s, want the backend itself", got)
+	}
+}
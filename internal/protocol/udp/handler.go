@@ -0,0 +1,298 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/internal/security"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+const (
+	// maxDatagramSize is the largest UDP payload we relay (64KB - headers)
+	maxDatagramSize = 65507
+	// defaultIdleTimeout is used when backends.udp.timeout is not configured
+	defaultIdleTimeout = 60 * time.Second
+)
+
+// Handler relays UDP datagrams between clients and a single backend.
+// UDP has no connection close, so each client source address gets a session
+// that is reaped by a timer once it has been idle for idleTimeout.
+type Handler struct {
+	listenAddr  string
+	backendAddr string
+	idleTimeout time.Duration
+	security    *security.Manager
+
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	sessions map[string]*session // client addr -> session
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// session maps one client source address to a dedicated backend socket
+type session struct {
+	clientAddr *net.UDPAddr
+	backend    *net.UDPConn
+	lastActive int64 // Atomic: UnixNano of last datagram in either direction
+	startTime  time.Time
+	bytesIn    int64 // Atomic: client -> backend
+	bytesOut   int64 // Atomic: backend -> client
+}
+
+func (s *session) touch() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+}
+
+func (s *session) idleSince() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastActive)))
+}
+
+// NewHandler creates a UDP handler. Returns nil if UDP is not configured
+// (UDP is optional, unlike the HTTP/TCP backends).
+func NewHandler(cfg *config.Config, sec *security.Manager) *Handler {
+	udpCfg := cfg.Backends.UDP
+	if udpCfg.ListenAddr == "" || udpCfg.TargetAddr == "" {
+		xlog.Infof("UDP relay disabled (backends.udp.listen_addr / target_addr not configured)")
+		return nil
+	}
+
+	idleTimeout := udpCfg.Timeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+
+	return &Handler{
+		listenAddr:  udpCfg.ListenAddr,
+		backendAddr: udpCfg.TargetAddr,
+		idleTimeout: idleTimeout,
+		security:    sec,
+		sessions:    make(map[string]*session),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start binds the UDP socket and starts the relay and session reaper
+func (h *Handler) Start() error {
+	addr, err := net.ResolveUDPAddr("udp", h.listenAddr)
+	if err != nil {
+		return err
+	}
+	h.conn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	xlog.Infof("UDP relay listening on %s -> %s (idle timeout: %v)", h.listenAddr, h.backendAddr, h.idleTimeout)
+
+	h.wg.Add(2)
+	go h.readLoop()
+	go h.reapLoop()
+	return nil
+}
+
+// Stop closes the listening socket and all active sessions. It is safe to
+// call more than once.
+func (h *Handler) Stop() {
+	h.stopOnce.Do(h.stop)
+}
+
+func (h *Handler) stop() {
+	close(h.stopCh)
+	if h.conn != nil {
+		h.conn.Close()
+	}
+
+	// Closing backend sockets unblocks the relayBackend goroutines
+	h.mu.Lock()
+	for key, s := range h.sessions {
+		h.closeSessionLocked(key, s)
+	}
+	h.mu.Unlock()
+
+	h.wg.Wait()
+	xlog.Infof("UDP relay stopped")
+}
+
+// ActiveSessions returns the current number of UDP sessions
+func (h *Handler) ActiveSessions() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.sessions)
+}
+
+// readLoop receives client datagrams and forwards them to the backend
+func (h *Handler) readLoop() {
+	defer h.wg.Done()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, clientAddr, err := h.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				xlog.Infof("UDP socket closed, exiting read loop")
+				return
+			}
+			xlog.Warnf("UDP read error: %v", err)
+			continue
+		}
+
+		s, err := h.getOrCreateSession(clientAddr)
+		if err != nil {
+			xlog.Debugf("UDP datagram from %s dropped: %v", clientAddr, err)
+			continue
+		}
+
+		if _, err := s.backend.Write(buf[:n]); err != nil {
+			xlog.Debugf("UDP write to backend %s failed: %v", h.backendAddr, err)
+			continue
+		}
+		s.touch()
+		atomic.AddInt64(&s.bytesIn, int64(n))
+	}
+}
+
+// getOrCreateSession returns the session for a client, dialing the backend on
+// first datagram. The dial (which may resolve DNS) runs without h.mu held, so
+// a slow backend does not stall the reaper or session teardown.
+func (h *Handler) getOrCreateSession(clientAddr *net.UDPAddr) (*session, error) {
+	key := clientAddr.String()
+
+	h.mu.Lock()
+	s, ok := h.sessions[key]
+	h.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	select {
+	case <-h.stopCh:
+		return nil, errors.New("udp relay stopped")
+	default:
+	}
+
+	if h.security != nil {
+		if err := h.security.CheckConnection(clientAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	dialStartTime := time.Now()
+	backend, err := net.DialTimeout("udp", h.backendAddr, 5*time.Second)
+	dialDuration := time.Since(dialStartTime)
+	if err != nil {
+		xlog.Errorf("Failed to dial UDP backend %s: %v", h.backendAddr, err)
+		middleware.RecordUpstreamRequest(h.backendAddr, "connection_failed", dialDuration.Seconds())
+		return nil, err
+	}
+	middleware.RecordUpstreamRequest(h.backendAddr, "success", dialDuration.Seconds())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Stop may have run, or another caller created the session, during the dial
+	select {
+	case <-h.stopCh:
+		backend.Close()
+		return nil, errors.New("udp relay stopped")
+	default:
+	}
+	if existing, ok := h.sessions[key]; ok {
+		backend.Close()
+		return existing, nil
+	}
+
+	s = &session{
+		clientAddr: clientAddr,
+		backend:    backend.(*net.UDPConn),
+		startTime:  time.Now(),
+	}
+	s.touch()
+	h.sessions[key] = s
+
+	middleware.IncUDPSessions()
+	xlog.Debugf("UDP session created: %s <-> %s", clientAddr, h.backendAddr)
+
+	h.wg.Add(1)
+	go h.relayBackend(s)
+	return s, nil
+}
+
+// relayBackend copies backend replies to the client until the session is
+// closed. A read error on an open session (e.g. ICMP port unreachable) closes
+// it, so the client's next datagram creates a new session that is read again.
+func (h *Handler) relayBackend(s *session) {
+	defer h.wg.Done()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, err := s.backend.Read(buf)
+		if err != nil {
+			key := s.clientAddr.String()
+			h.mu.Lock()
+			// Not when closed by the reaper or Stop (already removed)
+			if h.sessions[key] == s {
+				xlog.Debugf("UDP session closed: %s (backend read failed: %v)", key, err)
+				h.closeSessionLocked(key, s)
+			}
+			h.mu.Unlock()
+			return
+		}
+		if _, err := h.conn.WriteToUDP(buf[:n], s.clientAddr); err != nil {
+			xlog.Debugf("UDP write to client %s failed: %v", s.clientAddr, err)
+			continue
+		}
+		s.touch()
+		atomic.AddInt64(&s.bytesOut, int64(n))
+	}
+}
+
+// reapLoop expires sessions that have been idle longer than idleTimeout
+func (h *Handler) reapLoop() {
+	defer h.wg.Done()
+
+	interval := h.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.reapIdle()
+		case <-h.stopCh:
+			return
+		}
+	}
+}
+
+func (h *Handler) reapIdle() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, s := range h.sessions {
+		if s.idleSince() >= h.idleTimeout {
+			xlog.Debugf("UDP session expired: %s (idle > %v)", key, h.idleTimeout)
+			h.closeSessionLocked(key, s)
+		}
+	}
+}
+
+// closeSessionLocked closes a session and records its metrics. Caller must hold h.mu.
+func (h *Handler) closeSessionLocked(key string, s *session) {
+	delete(h.sessions, key)
+	s.backend.Close()
+	middleware.DecUDPSessions()
+
+	duration := time.Since(s.startTime)
+	middleware.RecordUDPMetrics(h.backendAddr, duration.Seconds(), atomic.LoadInt64(&s.bytesIn), atomic.LoadInt64(&s.bytesOut))
+}
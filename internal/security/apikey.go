@@ -0,0 +1,114 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// apiKey is a stored key digest and the client name it identifies
+type apiKey struct {
+	digest [sha256.Size]byte
+	name   string
+}
+
+// HashAPIKey returns the hex SHA-256 digest under which key is stored in Redis
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random key (256 bits, base64url)
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// apiKeySet is the apikey auth mode state: the header carrying the key and the accepted keys
+type apiKeySet struct {
+	header string
+	keys   []apiKey
+}
+
+// UpdateAPIKeys sets the auth mode, key header and accepted API keys
+// (hex SHA-256 digest -> client name). Outside apikey mode the keys are ignored.
+// Keys without a name are identified by a digest prefix. Invalid digests are logged and skipped.
+func (m *Manager) UpdateAPIKeys(mode, header string, keys map[string]string) {
+	var set *apiKeySet
+	if mode == config.AuthModeAPIKey {
+		if header == "" {
+			header = config.DefaultSecurityState().Auth.APIKeyHeader
+		}
+		set = &apiKeySet{header: header, keys: make([]apiKey, 0, len(keys))}
+		for digest, name := range keys {
+			raw, err := hex.DecodeString(digest)
+			if err != nil || len(raw) != sha256.Size {
+				xlog.Warnf("Invalid API key digest %q (expected hex SHA-256), skipped", digest)
+				continue
+			}
+			if name == "" {
+				name = "apikey:" + digest[:12]
+			}
+			k := apiKey{name: name}
+			copy(k.digest[:], raw)
+			set.keys = append(set.keys, k)
+		}
+	} else if mode != "" {
+		xlog.Warnf("Unknown auth mode %q, using certificate/JWT/header subjects", mode)
+	}
+
+	m.stateMu.Lock()
+	m.apiKeys = set
+	m.stateMu.Unlock()
+	if set != nil {
+		xlog.Infof("API key auth updated: header=%s, keys=%d", set.header, len(set.keys))
+	}
+}
+
+func (m *Manager) getAPIKeys() *apiKeySet {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return m.apiKeys
+}
+
+// authenticateAPIKey checks the configured header and returns the key's client
+// name. The header is removed so the key is not forwarded upstream.
+func authenticateAPIKey(set *apiKeySet, r *http.Request) (string, error) {
+	key := r.Header.Get(set.header)
+	r.Header.Del(set.header)
+	if key == "" {
+		middleware.RecordSecurityBlock("auth_missing_key")
+		return "", fmt.Errorf("API key header %s missing", set.header)
+	}
+	name, ok := set.lookup(key)
+	if !ok {
+		middleware.RecordSecurityBlock("auth_invalid_key")
+		return "", errors.New("invalid API key")
+	}
+	return name, nil
+}
+
+// lookup compares the key's digest with every stored digest in constant
+// time, so timing does not reveal whether or where a key matched
+func (s *apiKeySet) lookup(key string) (string, bool) {
+	digest := sha256.Sum256([]byte(key))
+	name, found := "", 0
+	for i := range s.keys {
+		if subtle.ConstantTimeCompare(digest[:], s.keys[i].digest[:]) == 1 {
+			name, found = s.keys[i].name, 1
+		}
+	}
+	return name, found == 1
+}
@@ -0,0 +1,171 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	// geoCacheTTL bounds how long a per-IP country lookup is reused
+	geoCacheTTL = time.Minute
+	// geoCacheMax caps cached IPs; the cache is reset when full
+	geoCacheMax = 100000
+	// geoReloadInterval is how often the database file is checked for changes
+	geoReloadInterval = 30 * time.Second
+)
+
+// geoRecord is the subset of a MaxMind Country/City record we need
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+type geoCacheEntry struct {
+	country string
+	expires time.Time
+}
+
+// geoIP resolves client IPs to ISO country codes from a MaxMind mmdb file,
+// reopening the file when it changes on disk
+type geoIP struct {
+	path string
+
+	mu      sync.RWMutex
+	db      *maxminddb.Reader
+	modTime time.Time
+	size    int64
+
+	cacheMu sync.Mutex
+	cache   map[string]geoCacheEntry
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func newGeoIP(path string) (*geoIP, error) {
+	g := &geoIP{
+		path:     path,
+		cache:    make(map[string]geoCacheEntry),
+		stopChan: make(chan struct{}),
+	}
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+	go g.watch()
+	return g, nil
+}
+
+// load opens the database, replacing the current one on success
+func (g *geoIP) load() error {
+	info, err := os.Stat(g.path)
+	if err != nil {
+		return err
+	}
+	db, err := maxminddb.Open(g.path)
+	if err != nil {
+		return fmt.Errorf("failed to open GeoIP database %s: %w", g.path, err)
+	}
+
+	g.mu.Lock()
+	old := g.db
+	g.db, g.modTime, g.size = db, info.ModTime(), info.Size()
+	g.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+
+	g.cacheMu.Lock()
+	g.cache = make(map[string]geoCacheEntry)
+	g.cacheMu.Unlock()
+	return nil
+}
+
+// country returns the ISO code for ip, or "" if unknown
+func (g *geoIP) country(ip string) string {
+	now := time.Now()
+	g.cacheMu.Lock()
+	if e, ok := g.cache[ip]; ok && now.Before(e.expires) {
+		g.cacheMu.Unlock()
+		return e.country
+	}
+	g.cacheMu.Unlock()
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	var rec geoRecord
+	g.mu.RLock()
+	if g.db == nil {
+		g.mu.RUnlock()
+		return "" // Closed
+	}
+	err := g.db.Lookup(parsed, &rec)
+	g.mu.RUnlock()
+	if err != nil {
+		xlog.Debugf("GeoIP lookup for %s failed: %v", ip, err)
+		return ""
+	}
+	country := rec.Country.ISOCode
+	if country == "" {
+		country = rec.RegisteredCountry.ISOCode
+	}
+
+	g.cacheMu.Lock()
+	if len(g.cache) >= geoCacheMax {
+		g.cache = make(map[string]geoCacheEntry)
+	}
+	g.cache[ip] = geoCacheEntry{country: country, expires: now.Add(geoCacheTTL)}
+	g.cacheMu.Unlock()
+	return country
+}
+
+// watch reloads the database when the file changes. A failed reload keeps
+// the previous database.
+func (g *geoIP) watch() {
+	ticker := time.NewTicker(geoReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(g.path)
+			if err != nil {
+				continue
+			}
+			g.mu.RLock()
+			changed := !info.ModTime().Equal(g.modTime) || info.Size() != g.size
+			g.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := g.load(); err != nil {
+				xlog.Warnf("GeoIP reload failed, keeping previous database: %v", err)
+				continue
+			}
+			xlog.Infof("GeoIP database reloaded from %s", g.path)
+		case <-g.stopChan:
+			return
+		}
+	}
+}
+
+// close stops the reload loop and releases the database
+func (g *geoIP) close() {
+	g.stopOnce.Do(func() {
+		close(g.stopChan)
+		g.mu.Lock()
+		g.db.Close()
+		g.db = nil
+		g.mu.Unlock()
+	})
+}
@@ -0,0 +1,144 @@
+package security
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// applyIncremental applies an add/remove/clear update to the matching in-memory
+// set without reloading from Redis. It returns false if the update needs a full
+// reload (other update types, or a malformed change).
+func (m *Manager) applyIncremental(update config.ConfigUpdate) bool {
+	var apply func(add, remove []string)
+	var clear func([]string)
+	switch update.Type {
+	case config.UpdateTypeBlockedIPs:
+		apply, clear = m.changeBlockedIPs, m.UpdateBlockedIPs
+	case config.UpdateTypeAllowedIPs:
+		apply, clear = m.changeAllowedIPs, m.UpdateAllowedIPs
+	case config.UpdateTypeBlockedPatterns:
+		apply, clear = m.changeBlockedPatterns, m.UpdateBlockedPatterns
+	case config.UpdateTypeAllowedSubjects:
+		apply, clear = m.changeAllowedSubjects, m.UpdateAllowedSubjects
+	default:
+		return false
+	}
+
+	var change config.SetChange
+	if err := json.Unmarshal(update.Data, &change); err != nil {
+		xlog.Warnf("Invalid %s update data, reloading all security config: %v", update.Type, err)
+		return false
+	}
+	switch change.Action {
+	case "add":
+		apply(change.Items, nil)
+	case "remove":
+		apply(nil, change.Items)
+	case "clear":
+		clear(nil)
+	default:
+		xlog.Warnf("Unknown %s update action %q, reloading all security config", update.Type, change.Action)
+		return false
+	}
+	return true
+}
+
+func (m *Manager) changeBlockedIPs(add, remove []string) {
+	m.stateMu.Lock()
+	set, invalid := m.blockedIPs.with(add, remove)
+	m.blockedIPs = set
+	m.cfg.Security.WAF.BlockedIPs = changeList(m.cfg.Security.WAF.BlockedIPs, add, remove)
+	count := len(m.cfg.Security.WAF.BlockedIPs)
+	m.stateMu.Unlock()
+
+	for _, entry := range invalid {
+		xlog.Warnf("Invalid WAF blocked CIDR %q, skipped", entry)
+	}
+	xlog.Infof("Blocked IPs updated: added=%d, removed=%d, count=%d", len(add), len(remove), count)
+}
+
+func (m *Manager) changeAllowedIPs(add, remove []string) {
+	m.stateMu.Lock()
+	set, invalid := m.allowedIPs.with(add, remove)
+	m.allowedIPs = set
+	m.cfg.Security.WAF.AllowedIPs = changeList(m.cfg.Security.WAF.AllowedIPs, add, remove)
+	count := len(m.cfg.Security.WAF.AllowedIPs)
+	allowlistEmpty := m.allowlistMode && set.empty()
+	m.stateMu.Unlock()
+
+	for _, entry := range invalid {
+		xlog.Warnf("Invalid WAF allowlist CIDR %q, skipped", entry)
+	}
+	xlog.Infof("Allowed IPs updated: added=%d, removed=%d, count=%d", len(add), len(remove), count)
+	if allowlistEmpty {
+		xlog.Warnf("WAF allowlist mode is active with an empty allowlist, all clients will be denied")
+	}
+}
+
+// changeBlockedPatterns only compiles added patterns; the rest are reused
+func (m *Manager) changeBlockedPatterns(add, remove []string) {
+	var invalid []string
+	m.stateMu.Lock()
+	compiled := make(map[string]*regexp.Regexp, len(m.blockedPatterns))
+	for _, re := range m.blockedPatterns {
+		compiled[re.String()] = re
+	}
+	list := changeList(m.cfg.Security.WAF.BlockedPatterns, add, remove)
+	patterns := make([]*regexp.Regexp, 0, len(list))
+	for _, pattern := range list {
+		if pattern == "" {
+			continue
+		}
+		re, ok := compiled[pattern]
+		if !ok {
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				invalid = append(invalid, pattern)
+				continue
+			}
+		}
+		patterns = append(patterns, re)
+	}
+	m.blockedPatterns = patterns
+	m.cfg.Security.WAF.BlockedPatterns = list
+	m.stateMu.Unlock()
+
+	for _, pattern := range invalid {
+		xlog.Warnf("Invalid WAF pattern %q, skipped", pattern)
+	}
+	xlog.Infof("Blocked patterns updated: added=%d, removed=%d, count=%d", len(add), len(remove), len(patterns))
+}
+
+func (m *Manager) changeAllowedSubjects(add, remove []string) {
+	m.stateMu.Lock()
+	matcher := m.allowedSubjects.with(add, remove)
+	m.allowedSubjects = matcher
+	m.cfg.Security.Auth.AllowedSubjects = changeList(m.cfg.Security.Auth.AllowedSubjects, add, remove)
+	m.stateMu.Unlock()
+	xlog.Infof("Allowed subjects updated: added=%d, removed=%d, count=%d", len(add), len(remove), matcher.size())
+}
+
+// changeList returns a copy of list with remove dropped and new add entries
+// appended, keeping the set semantics of the Redis key it mirrors
+func changeList(list, add, remove []string) []string {
+	drop := make(map[string]struct{}, len(remove))
+	for _, entry := range remove {
+		drop[entry] = struct{}{}
+	}
+	out := make([]string, 0, len(list)+len(add))
+	seen := make(map[string]struct{}, len(list)+len(add))
+	for _, entry := range append(append([]string(nil), list...), add...) {
+		if _, ok := drop[entry]; ok {
+			continue
+		}
+		if _, ok := seen[entry]; ok {
+			continue
+		}
+		seen[entry] = struct{}{}
+		out = append(out, entry)
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package security
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+)
+
+// ipSet matches client IPs against single addresses and CIDR ranges.
+// CIDRs are grouped by prefix length and stored as masked network keys, so a
+// lookup costs one map probe per distinct prefix length rather than one
+// comparison per CIDR (hundreds of /24s and /16s stay two probes).
+type ipSet struct {
+	exact      map[string]struct{}
+	nets       map[int]map[string]struct{} // prefix length (IPv6 form) -> masked network
+	prefixLens []int                       // Keys of nets, longest first
+}
+
+// newIPSet builds a set from IPs and CIDR strings, returning entries that could not be parsed
+func newIPSet(entries []string) (*ipSet, []string) {
+	s := &ipSet{
+		exact: make(map[string]struct{}, len(entries)),
+		nets:  make(map[int]map[string]struct{}),
+	}
+	invalid := s.insert(entries)
+	return s, invalid
+}
+
+// with returns a copy of s with entries added and removed. s itself is left
+// untouched, since readers use it without holding stateMu.
+func (s *ipSet) with(add, remove []string) (*ipSet, []string) {
+	c := &ipSet{
+		exact: make(map[string]struct{}),
+		nets:  make(map[int]map[string]struct{}),
+	}
+	if s != nil {
+		for k := range s.exact {
+			c.exact[k] = struct{}{}
+		}
+		for ones, nets := range s.nets {
+			c.nets[ones] = make(map[string]struct{}, len(nets))
+			for k := range nets {
+				c.nets[ones][k] = struct{}{}
+			}
+		}
+		c.prefixLens = append([]int(nil), s.prefixLens...)
+	}
+	c.delete(remove)
+	invalid := c.insert(add)
+	return c, invalid
+}
+
+// insert adds entries, returning CIDRs that could not be parsed
+func (s *ipSet) insert(entries []string) []string {
+	var invalid []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			s.exact[exactKey(entry)] = struct{}{}
+			continue
+		}
+
+		ones, network, err := cidrKey(entry)
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		if s.nets[ones] == nil {
+			s.nets[ones] = make(map[string]struct{})
+			s.prefixLens = append(s.prefixLens, ones)
+		}
+		s.nets[ones][network] = struct{}{}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(s.prefixLens)))
+	return invalid
+}
+
+// delete removes entries; unknown entries are ignored
+func (s *ipSet) delete(entries []string) {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			delete(s.exact, exactKey(entry))
+			continue
+		}
+		ones, network, err := cidrKey(entry)
+		if err != nil || s.nets[ones] == nil {
+			continue
+		}
+		delete(s.nets[ones], network)
+		if len(s.nets[ones]) == 0 {
+			delete(s.nets, ones)
+			for i, l := range s.prefixLens {
+				if l == ones {
+					s.prefixLens = append(s.prefixLens[:i], s.prefixLens[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+}
+
+// exactKey normalizes an IP; unparseable entries are kept as exact strings (previous behavior)
+func exactKey(entry string) string {
+	if ip := net.ParseIP(entry); ip != nil {
+		return ip.String()
+	}
+	return entry
+}
+
+// cidrKey returns the prefix length (IPv6 form) and masked network of a CIDR
+func cidrKey(entry string) (int, string, error) {
+	_, ipNet, err := net.ParseCIDR(entry)
+	if err != nil {
+		return 0, "", err
+	}
+	ones, bits := ipNet.Mask.Size()
+	if bits == 32 {
+		ones += 96 // Match in IPv4-in-IPv6 form
+	}
+	return ones, string(ipNet.IP.To16().Mask(net.CIDRMask(ones, 128))), nil
+}
+
+// contains reports whether ip (textual form) is in the set
+func (s *ipSet) contains(ip string) bool {
+	if s == nil || ip == "" {
+		return false
+	}
+	if _, ok := s.exact[ip]; ok {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	if _, ok := s.exact[parsed.String()]; ok {
+		return true
+	}
+	ip16 := parsed.To16()
+	for _, ones := range s.prefixLens {
+		if _, ok := s.nets[ones][string(ip16.Mask(net.CIDRMask(ones, 128)))]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// empty reports whether the set has no entries
+func (s *ipSet) empty() bool {
+	return s == nil || (len(s.exact) == 0 && len(s.prefixLens) == 0)
+}
+
+// ValidateIPs returns the entries that are neither an IP address nor a CIDR range.
+// Empty entries are ignored, matching the WAF loaders.
+func ValidateIPs(entries []string) []string {
+	return config.ValidateIPs(entries)
+}
@@ -0,0 +1,67 @@
+package security
+
+import (
+	"fmt"
+	"testing"
+)
+
+// blocklist returns 300 /24s plus a few wider and IPv6 ranges and single IPs,
+// the size of blocklist the CIDR matching is meant to handle
+func blocklist() []string {
+	entries := []string{"198.18.0.0/15", "100.64.0.0/10", "2001:db8::/32", "203.0.113.7", "2001:db8:ffff::1"}
+	for i := 0; i < 300; i++ {
+		entries = append(entries, fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+	}
+	return entries
+}
+
+func TestIPSetContains(t *testing.T) {
+	s, invalid := newIPSet(blocklist())
+	if len(invalid) > 0 {
+		t.Fatalf("invalid entries: %q", invalid)
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"203.0.113.7", true},
+		{"10.0.0.1", true},
+		{"10.1.43.255", true},
+		{"10.1.44.1", false}, // 300th /24 is 10.1.43.0
+		{"198.19.255.255", true},
+		{"100.127.0.1", true},
+		{"100.128.0.1", false},
+		{"::ffff:10.0.5.9", true},
+		{"2001:db8:1::1", true},
+		{"2001:db9::1", false},
+		{"192.0.2.1", false},
+		{"not an ip", false},
+	}
+	for _, tt := range tests {
+		if got := s.contains(tt.ip); got != tt.want {
+			t.Errorf("contains(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkIPSetContains measures the connection-path lookup against a
+// blocklist of 300 /24s and a few other ranges
+func BenchmarkIPSetContains(b *testing.B) {
+	s, _ := newIPSet(blocklist())
+	for _, bm := range []struct {
+		name string
+		ip   string
+	}{
+		{"exact", "203.0.113.7"},
+		{"cidr", "10.1.20.30"},
+		{"miss", "192.0.2.1"},
+		{"miss_ipv6", "2001:db9::1"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.contains(bm.ip)
+			}
+		})
+	}
+}
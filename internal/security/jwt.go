@@ -0,0 +1,395 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // Registers SHA-384/512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+const (
+	defaultJWKSRefreshInterval = 10 * time.Minute
+	// minJWKSRefetchInterval bounds on-demand refetches triggered by unknown key IDs
+	minJWKSRefetchInterval = 30 * time.Second
+	// jwtClockSkew tolerates small clock drift between the IdP and the gateway
+	jwtClockSkew = 30 * time.Second
+)
+
+var (
+	errTokenMalformed = errors.New("malformed bearer token")
+	errTokenExpired   = errors.New("token expired")
+	errUnknownKey     = errors.New("token signed with unknown key")
+)
+
+// jwtClaims holds the registered claims the gateway validates
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"` // string or []string
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+}
+
+// audiences normalizes the aud claim into a list
+func (c *jwtClaims) audiences() []string {
+	if len(c.Audience) == 0 {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(c.Audience, &single); err == nil {
+		return []string{single}
+	}
+	var multi []string
+	if err := json.Unmarshal(c.Audience, &multi); err == nil {
+		return multi
+	}
+	return nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwk is a single JSON Web Key (RSA or EC public key)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwtVerifier validates bearer tokens against a cached JWKS
+type jwtVerifier struct {
+	cfg        config.JWTConfig
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey // kid -> public key
+	refreshLock sync.Mutex                  // Serializes JWKS fetches
+	lastAttempt time.Time                   // Last fetch attempt, successful or not; guarded by refreshLock
+
+	stopCh chan struct{}
+}
+
+func newJWTVerifier(cfg config.JWTConfig) *jwtVerifier {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultJWKSRefreshInterval
+	}
+	v := &jwtVerifier{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		keys:       make(map[string]crypto.PublicKey),
+		stopCh:     make(chan struct{}),
+	}
+
+	if err := v.refresh(); err != nil {
+		// Not fatal: keys will be fetched on the next refresh or on demand
+		xlog.Warnf("Initial JWKS fetch from %s failed: %v", cfg.JWKSURL, err)
+	}
+	go v.refreshLoop()
+	return v
+}
+
+// Stop terminates the background JWKS refresh
+func (v *jwtVerifier) Stop() {
+	close(v.stopCh)
+}
+
+func (v *jwtVerifier) refreshLoop() {
+	ticker := time.NewTicker(v.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := v.refresh(); err != nil {
+				// Keep serving with the previously cached keys
+				xlog.Warnf("JWKS refresh from %s failed: %v", v.cfg.JWKSURL, err)
+			}
+		case <-v.stopCh:
+			return
+		}
+	}
+}
+
+// refresh fetches the JWKS and atomically replaces the key set
+func (v *jwtVerifier) refresh() error {
+	v.refreshLock.Lock()
+	defer v.refreshLock.Unlock()
+	return v.fetchLocked()
+}
+
+// fetchLocked does the JWKS fetch; the caller holds refreshLock
+func (v *jwtVerifier) fetchLocked() error {
+	v.lastAttempt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			xlog.Warnf("Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	xlog.Infof("JWKS refreshed: keys=%d", len(keys))
+	return nil
+}
+
+// lookupKey returns the key for kid, refetching the JWKS once if the key is
+// unknown (handles IdP key rotation between scheduled refreshes)
+func (v *jwtVerifier) lookupKey(kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	v.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	// A burst of tokens with an unknown kid queues here and results in at most
+	// one fetch per minJWKSRefetchInterval; the waiters re-check the new keys
+	v.refreshLock.Lock()
+	v.mu.RLock()
+	key, ok = v.keys[kid]
+	v.mu.RUnlock()
+	if ok {
+		v.refreshLock.Unlock()
+		return key, nil
+	}
+	if time.Since(v.lastAttempt) < minJWKSRefetchInterval {
+		v.refreshLock.Unlock()
+		return nil, errUnknownKey
+	}
+	err := v.fetchLocked()
+	v.refreshLock.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("%w (JWKS refetch failed: %v)", errUnknownKey, err)
+	}
+
+	v.mu.RLock()
+	key, ok = v.keys[kid]
+	v.mu.RUnlock()
+	if !ok {
+		return nil, errUnknownKey
+	}
+	return key, nil
+}
+
+// Verify validates signature, expiry, issuer and audience and returns the subject
+func (v *jwtVerifier) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errTokenMalformed
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", errTokenMalformed
+	}
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", errTokenMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errTokenMalformed
+	}
+
+	key, err := v.lookupKey(header.Kid)
+	if err != nil {
+		return "", err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtClockSkew)) {
+		return "", errTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(jwtClockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return "", errors.New("token not yet valid")
+	}
+	if v.cfg.Issuer != "" && claims.Issuer != v.cfg.Issuer {
+		return "", fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if v.cfg.Audience != "" && !containsString(claims.audiences(), v.cfg.Audience) {
+		return "", errors.New("token audience mismatch")
+	}
+	if claims.Subject == "" {
+		return "", errors.New("token subject missing")
+	}
+	return claims.Subject, nil
+}
+
+func decodeSegment(seg string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// verifySignature checks sig over signingInput for the RS*/ES* algorithm families
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match alg")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, sig); err != nil {
+			return errors.New("invalid token signature")
+		}
+	case strings.HasPrefix(alg, "ES"):
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match alg")
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		// Rejects "none" and symmetric algs (HS*), which must never be accepted with a JWKS
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	return nil
+}
+
+// publicKey converts the JWK into an RSA or EC public key
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package security
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -19,15 +21,32 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// limitGlobal names the gateway-wide connection rate limiter in metrics
+const limitGlobal = "global"
+
+// Counters are bound once so the per-connection path avoids the label lookup
+var (
+	globalLimitAllowed = middleware.RateLimitDecisions.WithLabelValues(limitGlobal, "allowed")
+	globalLimitLimited = middleware.RateLimitDecisions.WithLabelValues(limitGlobal, "limited")
+)
+
 // Manager coordinates auth, rate limiting, WAF, and audit logging.
 type Manager struct {
 	cfg *config.Config
 
 	stateMu         sync.RWMutex
-	allowedSubjects map[string]struct{}
-	blockedIPs      map[string]struct{}
+	allowedSubjects *subjectMatcher
+	apiKeys         *apiKeySet // nil unless in apikey auth mode
+	blockedIPs      *ipSet     // Single IPs and CIDR ranges
+	allowedIPs      *ipSet     // Enforced only in allowlist mode
+	allowlistMode   bool
 	blockedPatterns []*regexp.Regexp
+	inspectHeaders  []string // Canonical header names checked against blockedPatterns
+	geo             *geoIP   // nil unless a GeoIP database is configured
+	blockedCountry  map[string]struct{}
 	limiter         *rate.Limiter
+	policyLimiters  *policyLimiters // Per-subject/per-route HTTP limits
+	jwtVerifier     *jwtVerifier
 
 	auditEnabled bool
 	auditSink    io.Writer
@@ -70,7 +89,7 @@ func NewManager(cfg *config.Config, store *config.RedisStore) *Manager {
 				xlog.Warnf("Failed to create audit log dir %s: %v", path, err)
 				m.auditSink = os.Stdout
 			} else {
-				f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+				f, err := newRotatingFile(path, cfg.Security.Audit)
 				if err != nil {
 					xlog.Warnf("Failed to open audit log file %s: %v", path, err)
 					m.auditSink = os.Stdout
@@ -78,6 +97,14 @@ func NewManager(cfg *config.Config, store *config.RedisStore) *Manager {
 					m.auditSink = f
 				}
 			}
+		case strings.HasPrefix(cfg.Security.Audit.Sink, "syslog"):
+			w, err := newSyslogSink(cfg.Security.Audit.Sink, cfg.Security.Audit)
+			if err != nil {
+				xlog.Warnf("Failed to connect audit syslog sink %s, using stdout: %v", cfg.Security.Audit.Sink, err)
+				m.auditSink = os.Stdout
+			} else {
+				m.auditSink = w
+			}
 		default:
 			m.auditSink = os.Stdout
 		}
@@ -89,13 +116,22 @@ func NewManager(cfg *config.Config, store *config.RedisStore) *Manager {
 func (m *Manager) loadStaticConfig() {
 	if m.cfg.Security.Auth.Enabled {
 		m.UpdateAllowedSubjects(m.cfg.Security.Auth.AllowedSubjects)
+		m.UpdateJWTConfig(m.cfg.Security.Auth.JWT)
+		m.UpdateAPIKeys(m.cfg.Security.Auth.Mode, m.cfg.Security.Auth.APIKeyHeader, m.cfg.Security.Auth.APIKeys)
 	}
 	if m.cfg.Security.RateLimit.Enabled && m.cfg.Security.RateLimit.RequestsPerSecond > 0 {
 		m.UpdateRateLimit(m.cfg.Security.RateLimit.RequestsPerSecond, m.cfg.Security.RateLimit.Burst)
 	}
+	if len(m.cfg.Security.RateLimit.Policies) > 0 {
+		m.UpdateRateLimitPolicies(m.cfg.Security.RateLimit.Policies)
+	}
 	if m.cfg.Security.WAF.Enabled {
+		m.UpdateAllowedIPs(m.cfg.Security.WAF.AllowedIPs)
+		m.UpdateWAFMode(m.cfg.Security.WAF.Mode)
 		m.UpdateBlockedIPs(m.cfg.Security.WAF.BlockedIPs)
 		m.UpdateBlockedPatterns(m.cfg.Security.WAF.BlockedPatterns)
+		m.UpdateInspectHeaders(m.cfg.Security.WAF.InspectHeaders)
+		m.UpdateGeoBlocking(m.cfg.Security.WAF.GeoIPDatabase, m.cfg.Security.WAF.BlockedCountries)
 	}
 }
 
@@ -110,15 +146,40 @@ func (m *Manager) applySnapshot(sec *config.SecurityConfig) {
 			m.DisableRateLimit()
 		}
 	}
-	if len(sec.WAF.BlockedIPs) > 0 {
+	m.UpdateRateLimitPolicies(sec.RateLimit.Policies)
+	m.UpdateAllowedIPs(sec.WAF.AllowedIPs)
+	m.UpdateWAFMode(sec.WAF.Mode)
+	// nil means the set could not be read; an empty set was cleared and applies
+	if sec.WAF.BlockedIPs != nil {
 		m.UpdateBlockedIPs(sec.WAF.BlockedIPs)
 	}
-	if len(sec.WAF.BlockedPatterns) > 0 {
+	if sec.WAF.BlockedPatterns != nil {
 		m.UpdateBlockedPatterns(sec.WAF.BlockedPatterns)
 	}
+	m.UpdateInspectHeaders(sec.WAF.InspectHeaders)
+	m.UpdateGeoBlocking(sec.WAF.GeoIPDatabase, sec.WAF.BlockedCountries)
+	m.UpdateBodyInspection(sec.WAF.InspectBody, sec.WAF.MaxBodyScanBytes)
 	if len(sec.Auth.AllowedSubjects) > 0 {
 		m.UpdateAllowedSubjects(sec.Auth.AllowedSubjects)
 	}
+	m.UpdateAPIKeys(sec.Auth.Mode, sec.Auth.APIKeyHeader, sec.Auth.APIKeys)
+	if sec.Auth.JWT != m.getJWTConfig() {
+		m.UpdateJWTConfig(sec.Auth.JWT)
+	}
+}
+
+// reloadMetricTypes bounds the type label of the config reload metrics;
+// other published types are counted as "other"
+var reloadMetricTypes = map[string]bool{
+	"business": true, "http_routes": true, "sni_routes": true, "header_rules": true, "method_rules": true,
+	"health_check": true, "backend_weights": true,
+	"security": true, "auth": true, "rate_limit": true, "waf": true, "admin": true,
+	config.UpdateTypeReload:          true,
+	config.UpdateTypeBlockedIPs:      true,
+	config.UpdateTypeAllowedIPs:      true,
+	config.UpdateTypeBlockedPatterns: true,
+	config.UpdateTypeAllowedSubjects: true,
+	config.UpdateTypeAPIKeys:         true,
 }
 
 func (m *Manager) consumeRedisUpdates() {
@@ -126,19 +187,38 @@ func (m *Manager) consumeRedisUpdates() {
 	if ch == nil {
 		return
 	}
+	middleware.SetConfigVersion(m.redisStore.AppliedVersion())
 	for update := range ch {
 		xlog.Infof("Received config update from Redis: type=%s", update.Type)
-		// Reload all security config from Redis on any change
-		// This is simpler and ensures consistency
-		if snapshot, err := m.redisStore.LoadSecurityConfig(); err == nil && snapshot != nil {
-			m.applySnapshot(snapshot)
-			xlog.Infof("Reloaded security configuration from Redis")
-		} else if err != nil {
-			xlog.Warnf("Failed to reload security config from Redis: %v", err)
+		start := time.Now()
+		err := m.applyUpdate(update)
+		updateType := update.Type
+		if !reloadMetricTypes[updateType] {
+			updateType = "other"
+		}
+		middleware.RecordConfigReload(updateType, err, time.Since(start))
+		if err == nil {
+			middleware.SetConfigVersion(m.redisStore.AppliedVersion())
 		}
 	}
 }
 
+// applyUpdate applies set add/remove updates in place; anything else reloads
+// all security config from Redis, which is simpler and ensures consistency
+func (m *Manager) applyUpdate(update config.ConfigUpdate) error {
+	if m.applyIncremental(update) {
+		return nil
+	}
+	snapshot, err := m.redisStore.LoadSecurityConfig()
+	if err != nil {
+		xlog.Warnf("Failed to reload security config from Redis: %v", err)
+		return err
+	}
+	m.applySnapshot(snapshot)
+	xlog.Infof("Reloaded security configuration from Redis")
+	return nil
+}
+
 // CheckConnection performs per-connection checks before accepting traffic.
 func (m *Manager) CheckConnection(addr net.Addr) error {
 	if addr == nil {
@@ -146,49 +226,81 @@ func (m *Manager) CheckConnection(addr net.Addr) error {
 	}
 	ip := extractIP(addr.String())
 
-	if m.cfg.Security.WAF.Enabled && m.isBlockedIP(ip) {
-		middleware.RecordSecurityBlock("waf_blocked_ip")
-		return fmt.Errorf("blocked IP: %s", ip)
+	if m.cfg.Security.WAF.Enabled {
+		if err := m.checkIP(ip); err != nil {
+			return err
+		}
 	}
 
-	limiter := m.getLimiter()
-	if limiter != nil && !limiter.Allow() {
-		middleware.RecordSecurityBlock("rate_limit")
-		return errors.New("rate limit exceeded")
+	if limiter := m.getLimiter(); limiter != nil {
+		if !limiter.Allow() {
+			globalLimitLimited.Inc()
+			middleware.RecordRateLimitHit(limitGlobal)
+			middleware.RecordSecurityBlock("rate_limit")
+			return errors.New("rate limit exceeded")
+		}
+		globalLimitAllowed.Inc()
 	}
 
 	return nil
 }
 
-// AuthorizeHTTP validates client identity using TLS certificate subject or headers.
-func (m *Manager) AuthorizeHTTP(r *http.Request) error {
+// AuthorizeHTTP validates client identity using TLS certificate subject, JWT bearer token, or headers.
+// In apikey mode the client is identified by its API key instead.
+// It returns the authenticated subject ("" when auth is disabled).
+func (m *Manager) AuthorizeHTTP(r *http.Request) (string, error) {
 	if !m.cfg.Security.Auth.Enabled {
-		return nil
+		return "", nil
 	}
 
 	subject := ""
-	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+	if keys := m.getAPIKeys(); keys != nil {
+		name, err := authenticateAPIKey(keys, r)
+		if err != nil {
+			return "", err
+		}
+		subject = name
+	}
+	if subject == "" && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
 		subject = r.TLS.PeerCertificates[0].Subject.String()
 	}
-	if subject == "" && m.cfg.Security.Auth.HeaderSubject != "" {
-		subject = r.Header.Get(m.cfg.Security.Auth.HeaderSubject)
+	if subject == "" {
+		if verifier := m.getJWTVerifier(); verifier != nil {
+			if token := bearerToken(r); token != "" {
+				sub, err := verifier.Verify(token)
+				if err != nil {
+					middleware.RecordSecurityBlock("auth_invalid_token")
+					return "", fmt.Errorf("invalid bearer token: %w", err)
+				}
+				subject = sub
+			}
+		}
+	}
+	if name := m.cfg.Security.Auth.HeaderSubject; name != "" {
+		// The header is client-controlled unless a trusted proxy sets it, so with
+		// JWT enabled it only counts when trust_header_subject is on. It is never
+		// forwarded upstream.
+		if subject == "" && (m.getJWTVerifier() == nil || m.cfg.Security.Auth.TrustHeaderSubject) {
+			subject = r.Header.Get(name)
+		}
+		r.Header.Del(name)
 	}
 	if subject == "" {
 		middleware.RecordSecurityBlock("auth_missing_subject")
-		return errors.New("client certificate subject missing")
+		return "", errors.New("client certificate subject missing")
 	}
 
 	m.stateMu.RLock()
 	allowed := m.allowedSubjects
 	m.stateMu.RUnlock()
-	if len(allowed) == 0 {
-		return nil
+	if allowed.size() == 0 {
+		return subject, nil
 	}
-	if _, ok := allowed[subject]; !ok {
+	if !allowed.match(subject) {
 		middleware.RecordSecurityBlock("auth_unauthorized")
-		return fmt.Errorf("subject %s not allowed", subject)
+		return "", fmt.Errorf("subject %s not allowed", subject)
 	}
-	return nil
+	return subject, nil
 }
 
 // ApplyWAF enforces HTTP-level WAF rules.
@@ -196,10 +308,8 @@ func (m *Manager) ApplyWAF(r *http.Request) error {
 	if !m.cfg.Security.WAF.Enabled {
 		return nil
 	}
-	ip := extractIP(r.RemoteAddr)
-	if m.isBlockedIP(ip) {
-		middleware.RecordSecurityBlock("waf_blocked_ip")
-		return fmt.Errorf("blocked IP: %s", ip)
+	if err := m.checkIP(extractIP(r.RemoteAddr)); err != nil {
+		return err
 	}
 	patterns := m.getBlockedPatterns()
 	if len(patterns) == 0 {
@@ -215,6 +325,58 @@ func (m *Manager) ApplyWAF(r *http.Request) error {
 			return fmt.Errorf("blocked by pattern %s", re.String())
 		}
 	}
+	if err := m.inspectHeaderValues(r, patterns); err != nil {
+		return err
+	}
+	return m.inspectBody(r, patterns)
+}
+
+// inspectHeaderValues matches patterns against the configured request headers
+func (m *Manager) inspectHeaderValues(r *http.Request, patterns []*regexp.Regexp) error {
+	m.stateMu.RLock()
+	headers := m.inspectHeaders
+	m.stateMu.RUnlock()
+
+	for _, name := range headers {
+		for _, value := range r.Header.Values(name) {
+			for _, re := range patterns {
+				if re.MatchString(value) {
+					middleware.RecordSecurityBlock("waf_header_match")
+					return fmt.Errorf("header %s blocked by pattern %s", name, re.String())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// inspectBody matches patterns against up to MaxBodyScanBytes of the request body,
+// then restores the body so the reverse proxy can still forward it unchanged.
+func (m *Manager) inspectBody(r *http.Request, patterns []*regexp.Regexp) error {
+	m.stateMu.RLock()
+	enabled := m.cfg.Security.WAF.InspectBody
+	maxBytes := m.cfg.Security.WAF.MaxBodyScanBytes
+	m.stateMu.RUnlock()
+	if !enabled || maxBytes <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxBytes))
+	// Restore: scanned prefix followed by the unread remainder of the original body
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil {
+		return fmt.Errorf("reading request body: %w", err)
+	}
+
+	for _, re := range patterns {
+		if re.Match(buf) {
+			middleware.RecordSecurityBlock("waf_body_match")
+			return fmt.Errorf("request body blocked by pattern %s", re.String())
+		}
+	}
 	return nil
 }
 
@@ -224,12 +386,27 @@ func (m *Manager) getLimiter() *rate.Limiter {
 	return m.limiter
 }
 
+func (m *Manager) getJWTVerifier() *jwtVerifier {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return m.jwtVerifier
+}
+
+func (m *Manager) getJWTConfig() config.JWTConfig {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return m.cfg.Security.Auth.JWT
+}
+
 func (m *Manager) getBlockedPatterns() []*regexp.Regexp {
 	m.stateMu.RLock()
 	defer m.stateMu.RUnlock()
 	return append([]*regexp.Regexp(nil), m.blockedPatterns...)
 }
 
+// AuditHTTP records an HTTP request decision. Allowed requests are subject to
+// server.access_log sampling; denies are always written. Query parameters and
+// headers on the redaction list are masked.
 func (m *Manager) AuditHTTP(r *http.Request, status int, duration time.Duration, err error) {
 	if !m.auditEnabled || m.auditSink == nil {
 		return
@@ -239,13 +416,20 @@ func (m *Manager) AuditHTTP(r *http.Request, status int, duration time.Duration,
 	if err != nil {
 		action = "deny"
 		detail = err.Error()
+	} else if !middleware.LogSampled(r.Context()) {
+		return
 	}
+	query, _ := json.Marshal(middleware.RedactQuery(r.URL.RawQuery))
+	headers, _ := json.Marshal(middleware.LoggedHeaders(r.Header))
 	entry := fmt.Sprintf(
-		`{"ts":"%s","protocol":"http","remote_addr":"%s","method":"%s","path":"%s","status":%d,"action":"%s","duration_ms":%d,"detail":"%s"}`+"\n",
+		`{"ts":"%s","protocol":"http","request_id":"%s","remote_addr":"%s","method":"%s","path":"%s","query":%s,"headers":%s,"status":%d,"action":"%s","duration_ms":%d,"detail":"%s"}`+"\n",
 		time.Now().Format(time.RFC3339Nano),
+		middleware.RequestID(r.Context()),
 		r.RemoteAddr,
 		r.Method,
 		r.URL.Path,
+		query,
+		headers,
 		status,
 		action,
 		duration.Milliseconds(),
@@ -254,7 +438,9 @@ func (m *Manager) AuditHTTP(r *http.Request, status int, duration time.Duration,
 	m.writeAudit(entry)
 }
 
-func (m *Manager) AuditTCP(remoteAddr, backend string, allowed bool, detail string) {
+// AuditTCP records a TCP connection decision. connID is empty for connections
+// rejected before proxying.
+func (m *Manager) AuditTCP(connID, remoteAddr, backend string, allowed bool, detail string) {
 	if !m.auditEnabled || m.auditSink == nil {
 		return
 	}
@@ -263,8 +449,9 @@ func (m *Manager) AuditTCP(remoteAddr, backend string, allowed bool, detail stri
 		action = "deny"
 	}
 	entry := fmt.Sprintf(
-		`{"ts":"%s","protocol":"tcp","remote_addr":"%s","backend":"%s","action":"%s","detail":"%s"}`+"\n",
+		`{"ts":"%s","protocol":"tcp","conn_id":"%s","remote_addr":"%s","backend":"%s","action":"%s","detail":"%s"}`+"\n",
 		time.Now().Format(time.RFC3339Nano),
+		connID,
 		remoteAddr,
 		backend,
 		action,
@@ -281,14 +468,54 @@ func (m *Manager) writeAudit(payload string) {
 	}
 }
 
+// checkIP applies the IP rules: the blocklist always denies, and in allowlist
+// mode any client outside the allowlist is denied as well
+func (m *Manager) checkIP(ip string) error {
+	if m.isBlockedIP(ip) {
+		middleware.RecordSecurityBlock("waf_blocked_ip")
+		return fmt.Errorf("blocked IP: %s", ip)
+	}
+	if country := m.blockedCountryOf(ip); country != "" {
+		middleware.RecordSecurityBlock("waf_geo_blocked")
+		return fmt.Errorf("blocked country %s: %s", country, ip)
+	}
+
+	m.stateMu.RLock()
+	allowlistMode, allowed := m.allowlistMode, m.allowedIPs
+	m.stateMu.RUnlock()
+	if allowlistMode && !allowed.contains(ip) {
+		middleware.RecordSecurityBlock("waf_not_allowlisted")
+		return fmt.Errorf("IP not allowlisted: %s", ip)
+	}
+	return nil
+}
+
+// blockedCountryOf returns ip's country if it is blocklisted, else ""
+func (m *Manager) blockedCountryOf(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	m.stateMu.RLock()
+	geo, blocked := m.geo, m.blockedCountry
+	m.stateMu.RUnlock()
+	if geo == nil || len(blocked) == 0 {
+		return ""
+	}
+	country := geo.country(ip)
+	if _, ok := blocked[country]; ok && country != "" {
+		return country
+	}
+	return ""
+}
+
 func (m *Manager) isBlockedIP(ip string) bool {
 	if ip == "" {
 		return false
 	}
 	m.stateMu.RLock()
-	_, blocked := m.blockedIPs[ip]
+	blocked := m.blockedIPs
 	m.stateMu.RUnlock()
-	return blocked
+	return blocked.contains(ip)
 }
 
 func extractIP(addr string) string {
@@ -306,6 +533,27 @@ func escapeQuotes(s string) string {
 	return strings.ReplaceAll(s, `"`, `'`)
 }
 
+// BlockedIPs returns a copy of the active blocked IP list
+func (m *Manager) BlockedIPs() []string {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return append([]string(nil), m.cfg.Security.WAF.BlockedIPs...)
+}
+
+// AllowedIPs returns a copy of the active allowlist
+func (m *Manager) AllowedIPs() []string {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return append([]string(nil), m.cfg.Security.WAF.AllowedIPs...)
+}
+
+// BlockedPatterns returns a copy of the active blocked pattern list
+func (m *Manager) BlockedPatterns() []string {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return append([]string(nil), m.cfg.Security.WAF.BlockedPatterns...)
+}
+
 // UpdateRateLimit updates rate limiter configuration at runtime
 func (m *Manager) UpdateRateLimit(rps float64, burst int) {
 	if rps <= 0 || burst <= 0 {
@@ -318,6 +566,7 @@ func (m *Manager) UpdateRateLimit(rps float64, burst int) {
 	m.cfg.Security.RateLimit.Burst = burst
 	m.limiter = rate.NewLimiter(rate.Limit(rps), burst)
 	m.stateMu.Unlock()
+	middleware.SetRateLimitConfig(limitGlobal, rps, burst)
 	xlog.Infof("Rate limiter updated: rps=%.2f, burst=%d", rps, burst)
 }
 
@@ -329,24 +578,62 @@ func (m *Manager) DisableRateLimit() {
 	m.cfg.Security.RateLimit.Burst = 0
 	m.limiter = nil
 	m.stateMu.Unlock()
+	middleware.SetRateLimitConfig(limitGlobal, 0, 0)
 	xlog.Infof("Rate limiting disabled")
 }
 
-// UpdateBlockedIPs updates the blocked IP list at runtime
+// UpdateBlockedIPs updates the blocked IP list at runtime.
+// Entries containing "/" are treated as CIDR ranges.
 func (m *Manager) UpdateBlockedIPs(ips []string) {
-	m.stateMu.Lock()
-	m.blockedIPs = make(map[string]struct{}, len(ips))
-	for _, ip := range ips {
-		if ip == "" {
-			continue
-		}
-		m.blockedIPs[ip] = struct{}{}
+	set, invalid := newIPSet(ips)
+	for _, entry := range invalid {
+		xlog.Warnf("Invalid WAF blocked CIDR %q, skipped", entry)
 	}
+
+	m.stateMu.Lock()
+	m.blockedIPs = set
 	m.cfg.Security.WAF.BlockedIPs = append([]string(nil), ips...)
 	m.stateMu.Unlock()
 	xlog.Infof("Blocked IPs updated: count=%d", len(ips))
 }
 
+// UpdateWAFMode switches between "blocklist" (default) and "allowlist" at runtime.
+// Unknown modes fall back to blocklist.
+func (m *Manager) UpdateWAFMode(mode string) {
+	allowlist := strings.EqualFold(mode, "allowlist")
+	if mode != "" && !allowlist && !strings.EqualFold(mode, "blocklist") {
+		xlog.Warnf("Unknown WAF mode %q, using blocklist", mode)
+	}
+
+	m.stateMu.Lock()
+	changed := m.allowlistMode != allowlist
+	m.allowlistMode = allowlist
+	m.cfg.Security.WAF.Mode = mode
+	empty := m.allowedIPs.empty()
+	m.stateMu.Unlock()
+
+	if changed {
+		xlog.Infof("WAF mode updated: allowlist=%v", allowlist)
+	}
+	if allowlist && empty {
+		xlog.Warnf("WAF allowlist mode is active with an empty allowlist, all clients will be denied")
+	}
+}
+
+// UpdateAllowedIPs updates the WAF allowlist (IPs and CIDRs) at runtime
+func (m *Manager) UpdateAllowedIPs(ips []string) {
+	set, invalid := newIPSet(ips)
+	for _, entry := range invalid {
+		xlog.Warnf("Invalid WAF allowlist CIDR %q, skipped", entry)
+	}
+
+	m.stateMu.Lock()
+	m.allowedIPs = set
+	m.cfg.Security.WAF.AllowedIPs = append([]string(nil), ips...)
+	m.stateMu.Unlock()
+	xlog.Infof("Allowed IPs updated: count=%d", len(ips))
+}
+
 // UpdateBlockedPatterns updates the blocked pattern list at runtime
 func (m *Manager) UpdateBlockedPatterns(patterns []string) {
 	m.stateMu.Lock()
@@ -367,17 +654,127 @@ func (m *Manager) UpdateBlockedPatterns(patterns []string) {
 	xlog.Infof("Blocked patterns updated: count=%d", len(m.blockedPatterns))
 }
 
+// PatternError describes a WAF pattern that failed to compile
+type PatternError struct {
+	Pattern string `json:"pattern"`
+	Error   string `json:"error"`
+}
+
+// ValidatePatterns compiles each WAF pattern and returns the ones that are invalid.
+// Empty patterns are ignored, matching UpdateBlockedPatterns.
+func ValidatePatterns(patterns []string) []PatternError {
+	var invalid []PatternError
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			invalid = append(invalid, PatternError{Pattern: pattern, Error: err.Error()})
+		}
+	}
+	return invalid
+}
+
 // UpdateAllowedSubjects updates the allowed subject list at runtime
 func (m *Manager) UpdateAllowedSubjects(subjects []string) {
+	matcher := newSubjectMatcher(subjects)
 	m.stateMu.Lock()
-	m.allowedSubjects = make(map[string]struct{}, len(subjects))
-	for _, subj := range subjects {
-		if subj == "" {
-			continue
+	m.allowedSubjects = matcher
+	m.cfg.Security.Auth.AllowedSubjects = append([]string(nil), subjects...)
+	m.stateMu.Unlock()
+	xlog.Infof("Allowed subjects updated: count=%d, patterns=%d", matcher.size(), len(matcher.patterns))
+}
+
+// UpdateJWTConfig replaces the JWT verifier at runtime (nil verifier when disabled)
+func (m *Manager) UpdateJWTConfig(jwtCfg config.JWTConfig) {
+	var verifier *jwtVerifier
+	if jwtCfg.Enabled {
+		if jwtCfg.JWKSURL == "" {
+			xlog.Warnf("JWT auth enabled but jwks_url is empty, JWT validation disabled")
+		} else {
+			verifier = newJWTVerifier(jwtCfg)
 		}
-		m.allowedSubjects[subj] = struct{}{}
 	}
-	m.cfg.Security.Auth.AllowedSubjects = append([]string(nil), subjects...)
+
+	m.stateMu.Lock()
+	old := m.jwtVerifier
+	m.jwtVerifier = verifier
+	m.cfg.Security.Auth.JWT = jwtCfg
+	m.stateMu.Unlock()
+
+	if old != nil {
+		old.Stop()
+	}
+	xlog.Infof("JWT auth updated: enabled=%v, issuer=%s, jwks=%s", verifier != nil, jwtCfg.Issuer, jwtCfg.JWKSURL)
+}
+
+// UpdateBodyInspection updates WAF request body scanning at runtime
+func (m *Manager) UpdateBodyInspection(enabled bool, maxBytes int64) {
+	m.stateMu.Lock()
+	changed := m.cfg.Security.WAF.InspectBody != enabled || m.cfg.Security.WAF.MaxBodyScanBytes != maxBytes
+	m.cfg.Security.WAF.InspectBody = enabled
+	m.cfg.Security.WAF.MaxBodyScanBytes = maxBytes
+	m.stateMu.Unlock()
+	if changed {
+		xlog.Infof("WAF body inspection updated: enabled=%v, max_scan_bytes=%d", enabled, maxBytes)
+	}
+}
+
+// UpdateGeoBlocking sets the GeoIP database and blocked ISO country codes.
+// The database is reopened only when the path changes; an empty path disables
+// geo blocking. A database that fails to open leaves geo blocking off.
+func (m *Manager) UpdateGeoBlocking(dbPath string, countries []string) {
+	blocked := make(map[string]struct{}, len(countries))
+	for _, c := range countries {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			blocked[c] = struct{}{}
+		}
+	}
+
+	m.stateMu.RLock()
+	current := m.geo
+	m.stateMu.RUnlock()
+
+	geo := current
+	if current == nil || current.path != dbPath {
+		geo = nil
+		if dbPath != "" {
+			g, err := newGeoIP(dbPath)
+			if err != nil {
+				xlog.Warnf("GeoIP blocking disabled: %v", err)
+			} else {
+				geo = g
+			}
+		}
+	}
+
+	m.stateMu.Lock()
+	old := m.geo
+	m.geo = geo
+	m.blockedCountry = blocked
+	m.cfg.Security.WAF.GeoIPDatabase = dbPath
+	m.cfg.Security.WAF.BlockedCountries = append([]string(nil), countries...)
+	m.stateMu.Unlock()
+	if old != nil && old != geo {
+		old.close()
+	}
+	if geo != nil && geo != current {
+		xlog.Infof("GeoIP blocking enabled: database=%s, blocked_countries=%d", dbPath, len(blocked))
+	}
+}
+
+// UpdateInspectHeaders updates the request headers inspected by the WAF at runtime
+func (m *Manager) UpdateInspectHeaders(headers []string) {
+	canonical := make([]string, 0, len(headers))
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			canonical = append(canonical, http.CanonicalHeaderKey(h))
+		}
+	}
+
+	m.stateMu.Lock()
+	m.inspectHeaders = canonical
+	m.cfg.Security.WAF.InspectHeaders = append([]string(nil), headers...)
 	m.stateMu.Unlock()
-	xlog.Infof("Allowed subjects updated: count=%d", len(subjects))
+	xlog.Infof("WAF inspected headers updated: count=%d", len(canonical))
 }
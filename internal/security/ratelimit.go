@@ -0,0 +1,226 @@
+package security
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/internal/middleware"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// policyLimiterIdle is how long an unused per-client bucket is kept
+	policyLimiterIdle = 10 * time.Minute
+	// policyLimiterMax caps the buckets kept across all policies; the least
+	// recently used bucket is evicted beyond it
+	policyLimiterMax = 100000
+)
+
+// ErrRateLimited is returned by LimitHTTP when a policy rejects the request
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ratePolicy is a compiled config.RateLimitPolicy
+type ratePolicy struct {
+	config.RateLimitPolicy
+	subject *subjectMatcher // nil matches any client
+	rank    int             // Subject specificity: 2 exact, 1 pattern, 0 any
+}
+
+func (p *ratePolicy) matches(subject, path string) bool {
+	if p.subject != nil && !p.subject.match(subject) {
+		return false
+	}
+	return p.Route == "" || routeMatches(p.Route, path)
+}
+
+// routeMatches matches on path segment boundaries, like the HTTP router
+func routeMatches(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// policyLimiters holds the policies, most specific first, and their per-client
+// buckets. It is replaced as a whole when the policies change, which also
+// resets every bucket.
+type policyLimiters struct {
+	policies []*ratePolicy
+
+	mu        sync.Mutex
+	buckets   map[policyKey]*policyBucket
+	lastSweep time.Time
+}
+
+type policyKey struct {
+	policy  string
+	subject string
+}
+
+type policyBucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func newPolicyLimiters(policies []config.RateLimitPolicy) *policyLimiters {
+	pl := &policyLimiters{
+		buckets:   make(map[policyKey]*policyBucket),
+		lastSweep: time.Now(),
+	}
+	for _, p := range policies {
+		rp := &ratePolicy{RateLimitPolicy: p}
+		if p.Subject != "" && p.Subject != "*" {
+			rp.subject = newSubjectMatcher([]string{p.Subject})
+			if rp.subject.size() == 0 {
+				xlog.Warnf("Rate limit policy %s: invalid subject %q, skipped", p.Name, p.Subject)
+				continue
+			}
+			rp.rank = 1
+			if len(rp.subject.exact) == 1 {
+				rp.rank = 2
+			}
+		}
+		pl.policies = append(pl.policies, rp)
+	}
+	// Most specific first: subject kind, then the longest route, then the
+	// longest subject pattern, then name for a stable order
+	sort.Slice(pl.policies, func(i, j int) bool {
+		a, b := pl.policies[i], pl.policies[j]
+		if a.rank != b.rank {
+			return a.rank > b.rank
+		}
+		if len(a.Route) != len(b.Route) {
+			return len(a.Route) > len(b.Route)
+		}
+		if len(a.Subject) != len(b.Subject) {
+			return len(a.Subject) > len(b.Subject)
+		}
+		return a.Name < b.Name
+	})
+	return pl
+}
+
+// match returns the most specific policy for the request, or nil
+func (pl *policyLimiters) match(subject, path string) *ratePolicy {
+	for _, p := range pl.policies {
+		if p.matches(subject, path) {
+			return p
+		}
+	}
+	return nil
+}
+
+// allow takes a token from the client's bucket for policy p
+func (pl *policyLimiters) allow(p *ratePolicy, subject string) bool {
+	now := time.Now()
+	key := policyKey{policy: p.Name, subject: subject}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if now.Sub(pl.lastSweep) >= policyLimiterIdle {
+		pl.sweep(now)
+	}
+	b, ok := pl.buckets[key]
+	if !ok {
+		if len(pl.buckets) >= policyLimiterMax {
+			pl.evictOldest()
+		}
+		b = &policyBucket{limiter: rate.NewLimiter(rate.Limit(p.RPS), p.Burst)}
+		pl.buckets[key] = b
+	}
+	b.lastUsed = now
+	return b.limiter.AllowN(now, 1)
+}
+
+// sweep drops buckets idle for policyLimiterIdle. An idle bucket is full
+// again, so dropping it does not change any decision.
+func (pl *policyLimiters) sweep(now time.Time) {
+	for key, b := range pl.buckets {
+		if now.Sub(b.lastUsed) >= policyLimiterIdle {
+			delete(pl.buckets, key)
+		}
+	}
+	pl.lastSweep = now
+}
+
+func (pl *policyLimiters) evictOldest() {
+	var oldestKey policyKey
+	var oldest time.Time
+	for key, b := range pl.buckets {
+		if oldest.IsZero() || b.lastUsed.Before(oldest) {
+			oldestKey, oldest = key, b.lastUsed
+		}
+	}
+	delete(pl.buckets, oldestKey)
+}
+
+// samePolicies reports whether a and b hold the same rules in the same order
+func samePolicies(a, b []*ratePolicy) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].RateLimitPolicy != b[i].RateLimitPolicy {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateRateLimitPolicies replaces the per-subject/per-route HTTP rate limit
+// policies. Unchanged policies keep their buckets, so unrelated reloads do not
+// refill every client's bucket.
+func (m *Manager) UpdateRateLimitPolicies(policies []config.RateLimitPolicy) {
+	pl := newPolicyLimiters(policies)
+
+	m.stateMu.Lock()
+	old := m.policyLimiters
+	if old != nil && samePolicies(old.policies, pl.policies) {
+		m.stateMu.Unlock()
+		return
+	}
+	m.policyLimiters = pl
+	m.cfg.Security.RateLimit.Policies = policies
+	m.stateMu.Unlock()
+
+	if old != nil {
+		for _, p := range old.policies {
+			middleware.ClearRateLimitConfig(p.Name)
+		}
+	}
+	for _, p := range pl.policies {
+		middleware.SetRateLimitConfig(p.Name, p.RPS, p.Burst)
+	}
+	if len(pl.policies) > 0 || old != nil {
+		xlog.Infof("Rate limit policies updated: count=%d", len(pl.policies))
+	}
+}
+
+// LimitHTTP applies the most specific rate limit policy matching the
+// authenticated subject and the request path. Requests matching no policy are
+// not limited. It returns ErrRateLimited when the client's bucket is empty.
+func (m *Manager) LimitHTTP(subject, path string) error {
+	m.stateMu.RLock()
+	pl := m.policyLimiters
+	m.stateMu.RUnlock()
+	if pl == nil {
+		return nil
+	}
+	p := pl.match(subject, path)
+	if p == nil {
+		return nil
+	}
+	if !pl.allow(p, subject) {
+		middleware.RateLimitDecisions.WithLabelValues(p.Name, "limited").Inc()
+		middleware.RecordRateLimitHit(p.Name)
+		middleware.RecordSecurityBlock("rate_limit")
+		return ErrRateLimited
+	}
+	middleware.RateLimitDecisions.WithLabelValues(p.Name, "allowed").Inc()
+	return nil
+}
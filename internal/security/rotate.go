@@ -0,0 +1,133 @@
+package security
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// rotatedTimeFormat suffixes rotated files; it sorts chronologically
+const rotatedTimeFormat = "20060102-150405.000"
+
+// rotatingFile is an append-only file that is rotated by size and age.
+// Rotation happens inside Write under the same lock, so no entry is lost or
+// split across files; if rotation fails, writing continues to the old file.
+type rotatingFile struct {
+	path       string
+	maxSize    int64         // 0 = no size limit
+	maxAge     time.Duration // 0 = no age limit
+	maxBackups int           // 0 = keep all
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func newRotatingFile(path string, cfg config.AuditConfig) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxAge:     cfg.MaxAge,
+		maxBackups: cfg.MaxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens (or creates) the active file. An existing file keeps its age,
+// approximated by its modification time, so restarts do not postpone rotation.
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	r.opened = time.Now()
+	if r.size > 0 {
+		r.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.shouldRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			xlog.Warnf("Audit log rotation failed, continuing with %s: %v", r.path, err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) shouldRotate(next int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+int64(next) > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && time.Since(r.opened) >= r.maxAge
+}
+
+// rotate renames the active file to path.<timestamp> and opens a fresh one
+func (r *rotatingFile) rotate() error {
+	backup := r.path + "." + time.Now().Format(rotatedTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	old := r.f
+	if err := r.open(); err != nil {
+		// Keep writing to the renamed file rather than dropping entries
+		return fmt.Errorf("reopen after rename: %w", err)
+	}
+	old.Close()
+	r.prune()
+	return nil
+}
+
+// prune deletes the oldest rotated files beyond maxBackups
+func (r *rotatingFile) prune() {
+	if r.maxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+	prefix := r.path + "."
+	rotated := backups[:0]
+	for _, b := range backups {
+		if _, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(b, prefix)); err == nil {
+			rotated = append(rotated, b)
+		}
+	}
+	if len(rotated) <= r.maxBackups {
+		return
+	}
+	sort.Strings(rotated)
+	for _, b := range rotated[:len(rotated)-r.maxBackups] {
+		if err := os.Remove(b); err != nil {
+			xlog.Warnf("Failed to remove old audit log %s: %v", b, err)
+		}
+	}
+}
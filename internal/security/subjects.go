@@ -0,0 +1,209 @@
+package security
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+)
+
+// regexSubjectPrefix marks an allowed subject entry as a regular expression
+const regexSubjectPrefix = "regex:"
+
+// subjectMatcher checks client subjects against the allowed entries.
+// Exact entries are a map lookup; patterns are only tried on a miss.
+type subjectMatcher struct {
+	exact    map[string]struct{}
+	patterns []subjectPattern
+}
+
+// subjectPattern is a compiled pattern entry. DN globs (containing "=") match
+// RDN components in any order; other entries match the whole subject.
+type subjectPattern struct {
+	raw   string           // Entry as configured
+	whole *regexp.Regexp   // regex: entries and plain globs
+	rdns  []*regexp.Regexp // DN glob components, each must match some subject RDN
+}
+
+// newSubjectMatcher compiles entries: "regex:<expr>" is an anchored regular
+// expression, entries containing * or ? are globs (e.g. "O=Acme,OU=*"), and
+// anything else must match exactly. Invalid patterns are logged and skipped.
+func newSubjectMatcher(entries []string) *subjectMatcher {
+	s := &subjectMatcher{exact: make(map[string]struct{}, len(entries))}
+	s.insert(entries)
+	return s
+}
+
+// with returns a copy of s with entries added and removed; s is left untouched
+// for concurrent readers and unchanged patterns are not recompiled
+func (s *subjectMatcher) with(add, remove []string) *subjectMatcher {
+	c := &subjectMatcher{exact: make(map[string]struct{})}
+	removed := make(map[string]struct{}, len(remove))
+	for _, entry := range remove {
+		removed[entry] = struct{}{}
+	}
+	if s != nil {
+		for k := range s.exact {
+			if _, ok := removed[k]; !ok {
+				c.exact[k] = struct{}{}
+			}
+		}
+		for _, p := range s.patterns {
+			if _, ok := removed[p.raw]; !ok {
+				c.patterns = append(c.patterns, p)
+			}
+		}
+	}
+	c.insert(add)
+	return c
+}
+
+func (s *subjectMatcher) insert(entries []string) {
+	for _, entry := range entries {
+		if entry == "" || s.hasPattern(entry) {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(entry, regexSubjectPrefix):
+			re, err := regexp.Compile("^(?:" + strings.TrimPrefix(entry, regexSubjectPrefix) + ")$")
+			if err != nil {
+				xlog.Warnf("Invalid allowed subject pattern %q: %v", entry, err)
+				continue
+			}
+			s.patterns = append(s.patterns, subjectPattern{raw: entry, whole: re})
+		case strings.ContainsAny(entry, "*?"):
+			s.patterns = append(s.patterns, compileSubjectGlob(entry))
+		default:
+			s.exact[entry] = struct{}{}
+		}
+	}
+}
+
+func (s *subjectMatcher) hasPattern(entry string) bool {
+	for _, p := range s.patterns {
+		if p.raw == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// size returns the number of usable entries
+func (s *subjectMatcher) size() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.exact) + len(s.patterns)
+}
+
+// match reports whether subject is allowed
+func (s *subjectMatcher) match(subject string) bool {
+	if _, ok := s.exact[subject]; ok {
+		return true
+	}
+	if len(s.patterns) == 0 {
+		return false
+	}
+	var rdns []string
+	for _, p := range s.patterns {
+		if p.whole != nil {
+			if p.whole.MatchString(subject) {
+				return true
+			}
+			continue
+		}
+		if rdns == nil {
+			rdns = splitDN(subject)
+		}
+		if p.matchRDNs(rdns) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p subjectPattern) matchRDNs(rdns []string) bool {
+	for _, want := range p.rdns {
+		found := false
+		for _, rdn := range rdns {
+			if want.MatchString(rdn) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// compileSubjectGlob turns a glob into a pattern. Globs containing "=" are
+// split into DN components so attribute order and spacing do not matter.
+func compileSubjectGlob(glob string) subjectPattern {
+	if !strings.Contains(glob, "=") {
+		return subjectPattern{raw: glob, whole: globRegexp(glob, false)}
+	}
+	p := subjectPattern{raw: glob}
+	for _, rdn := range splitDN(glob) {
+		p.rdns = append(p.rdns, globRegexp(rdn, true))
+	}
+	return p
+}
+
+// globRegexp compiles * and ? wildcards; attribute names in RDNs are case-insensitive
+func globRegexp(glob string, rdn bool) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	rest := glob
+	if rdn {
+		if i := strings.IndexByte(glob, '='); i > 0 {
+			b.WriteString("(?i:" + regexp.QuoteMeta(glob[:i]) + ")=")
+			rest = glob[i+1:]
+		}
+	}
+	for _, r := range rest {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// splitDN splits a DN on unescaped commas, normalizing spaces around "=" and ","
+func splitDN(dn string) []string {
+	var parts []string
+	var cur strings.Builder
+	escaped := false
+	for _, r := range dn {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			cur.WriteRune(r)
+			escaped = true
+		case r == ',' || r == '+':
+			parts = append(parts, normalizeRDN(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	parts = append(parts, normalizeRDN(cur.String()))
+	return parts
+}
+
+func normalizeRDN(rdn string) string {
+	rdn = strings.TrimSpace(rdn)
+	if i := strings.IndexByte(rdn, '='); i > 0 {
+		return strings.TrimSpace(rdn[:i]) + "=" + strings.TrimSpace(rdn[i+1:])
+	}
+	return rdn
+}
@@ -0,0 +1,66 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package security
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"strings"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+)
+
+// syslogTag identifies audit messages in syslog
+const syslogTag = "uag-audit"
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "daemon": syslog.LOG_DAEMON,
+	"auth": syslog.LOG_AUTH, "authpriv": syslog.LOG_AUTHPRIV, "syslog": syslog.LOG_SYSLOG,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3, "local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+var syslogSeverities = map[string]syslog.Priority{
+	"emerg": syslog.LOG_EMERG, "alert": syslog.LOG_ALERT, "crit": syslog.LOG_CRIT,
+	"err": syslog.LOG_ERR, "warning": syslog.LOG_WARNING, "notice": syslog.LOG_NOTICE,
+	"info": syslog.LOG_INFO, "debug": syslog.LOG_DEBUG,
+}
+
+// newSyslogSink connects to the syslog endpoint in sink:
+// syslog://host:port (UDP), syslog+tcp://host:port, syslog+udp://host:port,
+// or syslog:// for the local daemon. The returned writer redials on the next
+// write after the connection drops.
+func newSyslogSink(sink string, cfg config.AuditConfig) (io.Writer, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog sink %q: %w", sink, err)
+	}
+
+	var network string
+	switch u.Scheme {
+	case "syslog", "syslog+udp":
+		network = "udp"
+	case "syslog+tcp":
+		network = "tcp"
+	default:
+		return nil, fmt.Errorf("invalid syslog sink %q (expected syslog://, syslog+udp:// or syslog+tcp://)", sink)
+	}
+	if u.Host == "" {
+		network = "" // Local syslog daemon (unix socket)
+	}
+
+	facility, ok := syslogFacilities[strings.ToLower(cfg.SyslogFacility)]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog facility %q", cfg.SyslogFacility)
+	}
+	severity, ok := syslogSeverities[strings.ToLower(cfg.SyslogSeverity)]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog severity %q", cfg.SyslogSeverity)
+	}
+
+	return syslog.Dial(network, u.Host, facility|severity, syslogTag)
+}
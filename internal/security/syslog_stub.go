@@ -0,0 +1,16 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package security
+
+import (
+	"errors"
+	"io"
+
+	"github.com/SkynetNext/unified-access-gateway/internal/config"
+)
+
+// newSyslogSink is unavailable where log/syslog is not supported
+func newSyslogSink(sink string, cfg config.AuditConfig) (io.Writer, error) {
+	return nil, errors.New("syslog audit sink is not supported on this platform")
+}
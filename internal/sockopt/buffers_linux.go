@@ -0,0 +1,38 @@
+//go:build linux
+// +build linux
+
+package sockopt
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// BuffersSupported reports whether socket buffer sizes are applied on this platform
+const BuffersSupported = true
+
+// Buffers returns a control setting SO_RCVBUF and SO_SNDBUF to recv and send
+// bytes (0 = kernel default), or nil when neither is set. The kernel doubles
+// the value for bookkeeping and caps it at net.core.rmem_max / wmem_max.
+// Accepted sockets inherit the sizes of their listen socket, which must be set
+// before listen for the TCP window scale to account for them.
+func Buffers(recv, send int) Control {
+	if recv <= 0 && send <= 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		if err := c.Control(func(fd uintptr) {
+			if recv > 0 {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, recv)
+			}
+			if sockErr == nil && send > 0 {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF, send)
+			}
+		}); err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
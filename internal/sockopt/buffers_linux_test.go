@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package sockopt
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// bufferSizes reads SO_RCVBUF and SO_SNDBUF of c
+func bufferSizes(t *testing.T, c syscall.Conn) (recv, send int) {
+	t.Helper()
+	raw, err := c.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recvErr, sendErr error
+	raw.Control(func(fd uintptr) {
+		recv, recvErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF)
+		send, sendErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF)
+	})
+	if recvErr != nil || sendErr != nil {
+		t.Fatalf("getsockopt: %v, %v", recvErr, sendErr)
+	}
+	return recv, send
+}
+
+func TestBuffersSetsSocketSizes(t *testing.T) {
+	if Buffers(0, 0) != nil {
+		t.Fatal("Buffers(0, 0) returned a control")
+	}
+
+	// Below net.core.rmem_max / wmem_max, whose default is 208 KiB; the
+	// kernel reports double the requested size
+	const recvSize, sendSize = 96 << 10, 64 << 10
+	control := Buffers(recvSize, sendSize)
+
+	lc := net.ListenConfig{Control: control}
+	ln, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	d := net.Dialer{Control: control}
+	client, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name string
+		conn syscall.Conn
+	}{
+		{"listen socket", ln.(*net.TCPListener)},
+		{"accepted socket", server.(*net.TCPConn)},
+		{"dialed socket", client.(*net.TCPConn)},
+	} {
+		recv, send := bufferSizes(t, tc.conn)
+		if recv < recvSize {
+			t.Errorf("%s: SO_RCVBUF = %d, want at least %d", tc.name, recv, recvSize)
+		}
+		if send < sendSize {
+			t.Errorf("%s: SO_SNDBUF = %d, want at least %d", tc.name, send, sendSize)
+		}
+	}
+
+	// Only the receive size: the send buffer keeps the kernel default
+	ln2, err := (&net.ListenConfig{Control: Buffers(recvSize, 0)}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln2.Close()
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer plain.Close()
+	recv, send := bufferSizes(t, ln2.(*net.TCPListener))
+	_, defaultSend := bufferSizes(t, plain.(*net.TCPListener))
+	if recv < recvSize {
+		t.Errorf("recv only: SO_RCVBUF = %d, want at least %d", recv, recvSize)
+	}
+	if send != defaultSend {
+		t.Errorf("recv only: SO_SNDBUF = %d, want the default %d", send, defaultSend)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package sockopt
+
+// BuffersSupported is false: sockets keep the platform's default buffer sizes
+const BuffersSupported = false
+
+// Buffers returns nil: socket buffer sizes are only applied on Linux
+func Buffers(recv, send int) Control {
+	return nil
+}
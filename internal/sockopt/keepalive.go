@@ -0,0 +1,55 @@
+package sockopt
+
+import (
+	"net"
+	"time"
+)
+
+// Keep-alive used when server.keepalive.* is unset
+const (
+	DefaultKeepAliveIdle     = 30 * time.Second
+	DefaultKeepAliveInterval = 10 * time.Second
+)
+
+// SetKeepAlive enables TCP keep-alive on c: the first probe is sent after idle
+// without traffic, then one every interval until the kernel's probe count
+// (net.ipv4.tcp_keepalive_probes) declares the peer dead and fails reads and
+// writes. 0 uses the default and a negative idle disables keep-alive.
+// Wrappers exposing Unwrap() net.Conn are looked through; connections that are
+// not TCP are left unchanged.
+func SetKeepAlive(c net.Conn, idle, interval time.Duration) error {
+	tc, ok := tcpConn(c)
+	if !ok {
+		return nil
+	}
+	if idle < 0 {
+		return tc.SetKeepAlive(false)
+	}
+	if idle == 0 {
+		idle = DefaultKeepAliveIdle
+	}
+	if interval <= 0 {
+		interval = DefaultKeepAliveInterval
+	}
+	if err := tc.SetKeepAlive(true); err != nil {
+		return err
+	}
+	if err := tc.SetKeepAlivePeriod(idle); err != nil {
+		return err
+	}
+	return setKeepAliveInterval(tc, interval)
+}
+
+// tcpConn returns the *net.TCPConn under c's wrappers
+func tcpConn(c net.Conn) (*net.TCPConn, bool) {
+	for {
+		if tc, ok := c.(*net.TCPConn); ok {
+			return tc, true
+		}
+		u, ok := c.(interface{ Unwrap() net.Conn })
+		if !ok {
+			return nil, false
+		}
+		c = u.Unwrap()
+	}
+}
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package sockopt
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// KeepAliveIntervalSupported reports whether the probe interval is applied on this platform
+const KeepAliveIntervalSupported = true
+
+// setKeepAliveInterval sets TCP_KEEPINTVL, rounded up to whole seconds
+func setKeepAliveInterval(c *net.TCPConn, interval time.Duration) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	secs := int((interval + time.Second - 1) / time.Second)
+	var sockErr error
+	if err := rc.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, secs)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
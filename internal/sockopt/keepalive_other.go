@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package sockopt
+
+import (
+	"net"
+	"time"
+)
+
+// KeepAliveIntervalSupported is false: probes follow the platform's interval
+const KeepAliveIntervalSupported = false
+
+// setKeepAliveInterval does nothing: the probe interval is only set on Linux
+func setKeepAliveInterval(c *net.TCPConn, interval time.Duration) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+// Package sockopt sets socket options through the Control hook of
+// net.ListenConfig and net.Dialer, or on established connections
+package sockopt
+
+import "syscall"
+
+// Control is the signature of net.ListenConfig.Control and net.Dialer.Control
+type Control func(network, address string, c syscall.RawConn) error
+
+// Chain runs each non-nil control in order, stopping at the first error.
+// It returns nil when none is set.
+func Chain(controls ...Control) Control {
+	set := make([]Control, 0, len(controls))
+	for _, control := range controls {
+		if control != nil {
+			set = append(set, control)
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		for _, control := range set {
+			if err := control(network, address, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
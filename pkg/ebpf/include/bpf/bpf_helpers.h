@@ -32,6 +32,8 @@ static long (*bpf_sk_redirect_hash)(void *ctx, void *map, void *key,
 static long (*bpf_sock_hash_update)(void *ctx, void *map, void *key,
                                     __u64 flags) = (void *)70;
 static __u64 (*bpf_get_socket_cookie_ops)(void *ctx) = (void *)46;
+static long (*bpf_sock_ops_cb_flags_set)(void *bpf_sock,
+                                         int argval) = (void *)59;
 
 /* BPF map types */
 enum bpf_map_type {
@@ -149,6 +151,13 @@ enum {
   BPF_F_LOCK = 4,
 };
 
+/* Flags for bpf_sock_ops_cb_flags_set */
+enum {
+  BPF_SOCK_OPS_RTO_CB_FLAG = (1 << 0),
+  BPF_SOCK_OPS_RETRANS_CB_FLAG = (1 << 1),
+  BPF_SOCK_OPS_STATE_CB_FLAG = (1 << 2),
+};
+
 /* Flags for BPF_SK_REDIRECT */
 enum {
   BPF_F_INGRESS = (1ULL << 0),
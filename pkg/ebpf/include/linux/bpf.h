@@ -139,6 +139,9 @@ struct bpf_sock_ops {
 #ifndef AF_INET
 #define AF_INET 2 /* Internet IP Protocol */
 #endif
+#ifndef AF_INET6
+#define AF_INET6 10 /* IP version 6 */
+#endif
 
 /* TCP states (from linux/tcp.h) */
 #ifndef BPF_TCP_CLOSE
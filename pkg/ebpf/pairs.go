@@ -0,0 +1,149 @@
+//go:build linux
+// +build linux
+
+package ebpf
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/SkynetNext/unified-access-gateway/pkg/xlog"
+	"github.com/cilium/ebpf"
+)
+
+const (
+	// pairTTL is the age after which a registered pair is checked for a leak
+	pairTTL = 10 * time.Minute
+	// pairReapInterval is how often registered pairs are scanned
+	pairReapInterval = time.Minute
+)
+
+// sockPair is a client/backend pair written to sock_pair_map
+type sockPair struct {
+	backend    uint64
+	registered time.Time
+}
+
+// addPair writes both directions of a pair to sock_pair_map and tracks it.
+// Holding pairsMu keeps the reaper from deleting a pair mid-registration.
+func (m *SockMapManager) addPair(client, backend uint64) error {
+	m.pairsMu.Lock()
+	defer m.pairsMu.Unlock()
+
+	if err := m.objs.SockPairMap.Update(client, backend, ebpf.UpdateAny); err != nil {
+		return err
+	}
+	if err := m.objs.SockPairMap.Update(backend, client, ebpf.UpdateAny); err != nil {
+		m.objs.SockPairMap.Delete(&client)
+		return err
+	}
+	m.pairs[client] = sockPair{backend: backend, registered: time.Now()}
+	m.pairsChanged()
+	return nil
+}
+
+// removePair deletes a tracked pair. It reports false if the pair was
+// already reaped, so callers do not double count.
+func (m *SockMapManager) removePair(client, backend uint64) bool {
+	m.pairsMu.Lock()
+	defer m.pairsMu.Unlock()
+
+	p, ok := m.pairs[client]
+	if !ok || p.backend != backend {
+		return false
+	}
+	m.deletePairLocked(client, backend)
+	m.pairsChanged()
+	return true
+}
+
+func (m *SockMapManager) deletePairLocked(client, backend uint64) {
+	m.objs.SockPairMap.Delete(&client)
+	m.objs.SockPairMap.Delete(&backend)
+	delete(m.pairs, client)
+}
+
+// flushPairs removes every registered pair and returns how many there were
+func (m *SockMapManager) flushPairs() int {
+	m.pairsMu.Lock()
+	defer m.pairsMu.Unlock()
+	n := len(m.pairs)
+	for client, p := range m.pairs {
+		m.deletePairLocked(client, p.backend)
+	}
+	if n > 0 {
+		m.pairsChanged()
+	}
+	return n
+}
+
+// pairsChanged publishes the occupancy; pairsMu must be held
+func (m *SockMapManager) pairsChanged() {
+	atomic.StoreInt64(&m.counters.active, int64(len(m.pairs)))
+	if m.onPairs != nil {
+		m.onPairs(len(m.pairs))
+	}
+}
+
+// RegisteredPairs returns the number of pairs currently in sock_pair_map
+func (m *SockMapManager) RegisteredPairs() int {
+	if m == nil {
+		return 0
+	}
+	m.pairsMu.Lock()
+	defer m.pairsMu.Unlock()
+	return len(m.pairs)
+}
+
+// ObservePairs sets a callback invoked with the pair count whenever it changes
+func (m *SockMapManager) ObservePairs(fn func(pairs int)) {
+	if m == nil {
+		return
+	}
+	m.pairsMu.Lock()
+	defer m.pairsMu.Unlock()
+	m.onPairs = fn
+	m.pairsChanged()
+}
+
+// reapPairs periodically removes pairs whose UnregisterSocketPair never ran
+func (m *SockMapManager) reapPairs() {
+	ticker := time.NewTicker(pairReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if n := m.reapStale(time.Now()); n > 0 {
+				xlog.Warnf("eBPF SockMap reaped %d leaked socket pairs", n)
+			}
+		case <-m.stopReaper:
+			return
+		}
+	}
+}
+
+// reapStale deletes pairs older than pairTTL whose sockets have left sock_map
+// (closed). If the kernel cannot look up sock_map from userspace, age alone
+// decides; a live pair reaped that way just falls back to the userspace copy.
+func (m *SockMapManager) reapStale(now time.Time) int {
+	m.pairsMu.Lock()
+	defer m.pairsMu.Unlock()
+
+	reaped := 0
+	for client, p := range m.pairs {
+		if now.Sub(p.registered) < pairTTL {
+			continue
+		}
+		clientOK, known1 := m.lookupSock(client)
+		backendOK, known2 := m.lookupSock(p.backend)
+		if known1 && known2 && clientOK && backendOK {
+			continue
+		}
+		m.deletePairLocked(client, p.backend)
+		reaped++
+	}
+	if reaped > 0 {
+		m.pairsChanged()
+	}
+	return reaped
+}
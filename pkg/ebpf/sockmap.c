@@ -15,7 +15,7 @@ struct {
   __uint(type, BPF_MAP_TYPE_SOCKHASH);
   __uint(max_entries, 65535);
   __uint(key_size, sizeof(__u64));   // Socket cookie
-  __uint(value_size, sizeof(__u32)); // Socket reference
+  __uint(value_size, sizeof(__u64)); // Socket reference (u64 allows userspace lookups)
 } sock_map SEC(".maps");
 
 // Map to store socket pair relationships
@@ -51,8 +51,11 @@ int sock_stream_verdict(struct __sk_buff *skb) {
     return SK_PASS;
   }
 
-  // Redirect to peer socket (kernel-level forwarding)
-  return bpf_sk_redirect_hash(skb, &sock_map, peer_cookie, BPF_F_INGRESS);
+  // Redirect to peer socket (kernel-level forwarding). If the peer is not in
+  // sock_map the helper fails with SK_DROP and no redirect is set; returning
+  // SK_PASS then hands the data to userspace instead of dropping it.
+  bpf_sk_redirect_hash(skb, &sock_map, peer_cookie, BPF_F_INGRESS);
+  return SK_PASS;
 }
 
 // Sockops program: intercept socket operations
@@ -64,8 +67,9 @@ int sock_ops_handler(struct bpf_sock_ops *skops) {
   switch (op) {
   case BPF_SOCK_OPS_PASSIVE_ESTABLISHED_CB:
   case BPF_SOCK_OPS_ACTIVE_ESTABLISHED_CB:
-    // Only handle IPv4 TCP connections
-    if (skops->family != AF_INET) {
+    // Handle IPv4 and IPv6 TCP connections (dual-stack listeners report
+    // AF_INET6 for IPv4-mapped peers)
+    if (skops->family != AF_INET && skops->family != AF_INET6) {
       break;
     }
 
@@ -74,11 +78,15 @@ int sock_ops_handler(struct bpf_sock_ops *skops) {
 
     // Add socket to sockmap
     bpf_sock_hash_update(skops, &sock_map, &cookie, BPF_NOEXIST);
+
+    // Request BPF_SOCK_OPS_STATE_CB so the entries are cleaned up on close
+    bpf_sock_ops_cb_flags_set(skops, BPF_SOCK_OPS_STATE_CB_FLAG);
     break;
 
   case BPF_SOCK_OPS_STATE_CB:
     // Socket state changed (e.g., closed)
-    if (skops->args[1] == BPF_TCP_CLOSE && skops->family == AF_INET) {
+    if (skops->args[1] == BPF_TCP_CLOSE &&
+        (skops->family == AF_INET || skops->family == AF_INET6)) {
       cookie = bpf_get_socket_cookie_ops(skops);
       // Remove from maps (cleanup)
       bpf_map_delete_elem(&sock_map, &cookie);
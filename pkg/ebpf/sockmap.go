@@ -9,7 +9,11 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"unsafe"
 
@@ -17,6 +21,7 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -cc clang -target bpf -cflags "-O2 -g -Wall -Werror -D__TARGET_ARCH_x86_64" bpf sockmap.c -- -I./include
@@ -33,22 +38,82 @@ type UnwrappableConn interface {
 
 // unwrapConn extracts the underlying net.Conn from wrapped connections
 // Uses interface instead of reflection for better performance
+// Wrappers may be nested (e.g., SniffConn around a listener-tracked conn)
 func unwrapConn(conn net.Conn) net.Conn {
-	if unwrappable, ok := conn.(UnwrappableConn); ok {
-		return unwrappable.Unwrap()
+	for {
+		unwrappable, ok := conn.(UnwrappableConn)
+		if !ok {
+			return conn
+		}
+		conn = unwrappable.Unwrap()
 	}
-	return conn
 }
 
+// KillSwitchEnv names the environment variable that disables eBPF
+// acceleration ("false" or "0") without rebuilding, even when the kernel
+// supports it. Acceleration can still be turned on later with Enable.
+const KillSwitchEnv = "EBPF_ENABLED"
+
 // SockMapManager manages eBPF sockmap for socket redirection
 type SockMapManager struct {
+	mu         sync.Mutex // Serializes Enable, Disable, AttachToCgroup and Close
 	objs       *bpfObjects
 	cgroupLink link.Link
-	enabled    bool
+	cgroupPath string // Path requested from AttachToCgroup, reused by Enable
+	attachedTo string // Cgroup sockops is attached to ("" = detached)
+	closed     bool
+	enabled    atomic.Bool
+	counters   sockMapCounters
+
+	pairsMu    sync.Mutex
+	pairs      map[uint64]sockPair // client cookie -> pair
+	onPairs    func(pairs int)
+	stopReaper chan struct{}
 }
 
-// NewSockMapManager creates a new sockmap manager
+// NewSockMapManager creates a new sockmap manager. The manager is returned
+// disabled (never nil) when eBPF is unsupported, fails to load, or is turned
+// off with EBPF_ENABLED=false.
 func NewSockMapManager() (*SockMapManager, error) {
+	m := &SockMapManager{pairs: make(map[uint64]sockPair)}
+	if disabledByEnv() {
+		xlog.Infof("eBPF disabled by %s, using the userspace proxy", KillSwitchEnv)
+		return m, nil
+	}
+	objs := loadObjects()
+	if objs == nil {
+		return m, nil
+	}
+	m.start(objs)
+	m.enabled.Store(true)
+	xlog.Infof("eBPF SockMap loaded successfully")
+	return m, nil
+}
+
+// disabledByEnv reports whether the EBPF_ENABLED kill switch is set
+func disabledByEnv() bool {
+	v := os.Getenv(KillSwitchEnv)
+	if v == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		xlog.Warnf("Ignoring invalid %s=%q", KillSwitchEnv, v)
+		return false
+	}
+	return !enabled
+}
+
+// start takes ownership of loaded objects and starts the pair reaper
+func (m *SockMapManager) start(objs *bpfObjects) {
+	m.objs = objs
+	m.stopReaper = make(chan struct{})
+	go m.reapPairs()
+}
+
+// loadObjects checks eBPF support and loads the sockmap programs. It returns
+// nil, after logging why, if eBPF is unavailable.
+func loadObjects() *bpfObjects {
 	// Allow the current process to lock memory for eBPF resources.
 	if err := rlimit.RemoveMemlock(); err != nil {
 		xlog.Warnf("Failed to remove memlock limit: %v", err)
@@ -58,14 +123,14 @@ func NewSockMapManager() (*SockMapManager, error) {
 	if !isEBPFSupported() {
 		xlog.Infof("eBPF not supported on this system (insufficient permissions or MEMLOCK limit too low), falling back to userspace proxy")
 		xlog.Infof("To enable eBPF: run with CAP_BPF capability or as root, and ensure MEMLOCK limit is sufficient")
-		return &SockMapManager{enabled: false}, nil
+		return nil
 	}
 
 	// Check BTF support (required for some eBPF map types)
 	if _, err := os.Stat("/sys/kernel/btf/vmlinux"); err != nil {
 		xlog.Warnf("BTF not available on this kernel (check /sys/kernel/btf/vmlinux): %v", err)
 		xlog.Infof("eBPF SockMap requires BTF support. Falling back to userspace proxy.")
-		return &SockMapManager{enabled: false}, nil
+		return nil
 	}
 
 	// Load pre-compiled eBPF objects
@@ -130,16 +195,9 @@ func NewSockMapManager() (*SockMapManager, error) {
 		}
 
 		xlog.Infof("Falling back to userspace proxy.")
-		return &SockMapManager{enabled: false}, nil
-	}
-
-	mgr := &SockMapManager{
-		objs:    objs,
-		enabled: true,
+		return nil
 	}
-
-	xlog.Infof("eBPF SockMap loaded successfully")
-	return mgr, nil
+	return objs
 }
 
 // readKernelLogs reads recent kernel logs related to BPF
@@ -169,167 +227,313 @@ func readKernelLogs() string {
 	return ""
 }
 
-// findCgroupPath attempts to find the correct cgroup path
-// In Kubernetes with systemd cgroup driver, we need to find the root cgroup
-// that matches the current process's cgroup hierarchy
-func findCgroupPath() string {
-	// Read current process cgroup to determine the hierarchy
-	// Format: <id>:<controller>:<path>
+// cgroupCandidate is a path tried for the sockops attach and where it came from
+type cgroupCandidate struct {
+	path   string
+	source string
+}
+
+// cgroupCandidates lists the paths to try, most preferred first: the cgroup v2
+// root, cgroup2 mounts from /proc/self/mountinfo (e.g. the hybrid-mode
+// /sys/fs/cgroup/unified), this process's own cgroup (which still covers every
+// socket the gateway creates), then the legacy fallbacks. A cgroup v1
+// hierarchy like /sys/fs/cgroup/systemd stays on the list so its rejection is
+// logged rather than the path silently disappearing.
+func cgroupCandidates() []cgroupCandidate {
+	var candidates []cgroupCandidate
+	seen := make(map[string]bool)
+	add := func(path, source string) {
+		if path == "" || seen[path] {
+			return
+		}
+		seen[path] = true
+		candidates = append(candidates, cgroupCandidate{path: path, source: source})
+	}
+
+	add("/sys/fs/cgroup", "default cgroup v2 root")
+	for _, mount := range cgroup2Mounts() {
+		add(mount, "cgroup2 mount")
+	}
+	// Format: <id>:<controllers>:<path>; cgroup v2 is the "0::" line
 	// Example: 0::/kubepods.slice/kubepods-burstable.slice/...
-	cgroupData, err := os.ReadFile("/proc/self/cgroup")
-	if err == nil {
-		lines := strings.Split(strings.TrimSpace(string(cgroupData)), "\n")
-		for _, line := range lines {
-			parts := strings.Split(line, ":")
-			if len(parts) >= 3 {
-				cgroupPath := parts[2]
-				// For cgroup v2 (empty controller), path starts with /
-				// For cgroup v1 with systemd, path is like /system.slice/...
-				if strings.HasPrefix(cgroupPath, "/") {
-					// Extract root: for systemd, it's usually /sys/fs/cgroup or /sys/fs/cgroup/systemd
-					// For cgroup v2, root is /sys/fs/cgroup
-					// For cgroup v1 with systemd, root is /sys/fs/cgroup/systemd
-					if strings.Contains(cgroupPath, "kubepods") || strings.Contains(cgroupPath, "system.slice") {
-						// Kubernetes Pod: try to find root cgroup
-						// Check if cgroup v2 (unified)
-						if fd, err := syscall.Open("/sys/fs/cgroup", syscall.O_RDONLY, 0); err == nil {
-							syscall.Close(fd)
-							return "/sys/fs/cgroup"
-						}
-						// Fallback to systemd cgroup v1
-						if fd, err := syscall.Open("/sys/fs/cgroup/systemd", syscall.O_RDONLY, 0); err == nil {
-							syscall.Close(fd)
-							return "/sys/fs/cgroup/systemd"
-						}
-					}
-				}
+	if data, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if rel, ok := strings.CutPrefix(line, "0::"); ok && rel != "/" {
+				add(filepath.Join("/sys/fs/cgroup", rel), "own cgroup from /proc/self/cgroup")
 			}
 		}
 	}
+	add("/sys/fs/cgroup/unified", "legacy hybrid path")
+	add("/sys/fs/cgroup/systemd", "legacy systemd path")
+	return candidates
+}
 
-	// Fallback: try common paths
-	paths := []string{
-		"/sys/fs/cgroup",         // cgroup v2 root (K8s with systemd, unified)
-		"/sys/fs/cgroup/unified", // cgroup v2 unified (if separate mount)
-		"/sys/fs/cgroup/systemd", // systemd slice (cgroup v1, K8s with systemd)
+// cgroup2Mounts returns the mount points of cgroup2 filesystems
+func cgroup2Mounts() []string {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil
 	}
-
-	for _, path := range paths {
-		fd, err := syscall.Open(path, syscall.O_RDONLY, 0)
-		if err == nil {
-			syscall.Close(fd)
-			return path
+	var mounts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		// Fields: id parent major:minor root mountpoint options... - fstype source superoptions
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			if f == "-" && i+1 < len(fields) && len(fields) > 4 {
+				if fields[i+1] == "cgroup2" {
+					mounts = append(mounts, fields[4])
+				}
+				break
+			}
 		}
 	}
+	return mounts
+}
 
-	// Default fallback
-	return "/sys/fs/cgroup"
+// checkCgroup2 verifies that path is a directory on a cgroup v2 (unified)
+// filesystem. sockops programs can only be attached to cgroup v2; opening a v1
+// or plain directory succeeds but the attach fails or never sees a socket.
+func checkCgroup2(path string) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return fmt.Errorf("statfs: %w", err)
+	}
+	if st.Type != unix.CGROUP2_SUPER_MAGIC {
+		return fmt.Errorf("not a cgroup v2 filesystem (magic %#x)", st.Type)
+	}
+	if _, err := os.Stat(filepath.Join(path, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("no cgroup.controllers: %w", err)
+	}
+	return nil
 }
 
-// AttachToCgroup attaches sockops program to cgroup
+// AttachToCgroup attaches the sockops program to a cgroup v2 directory. An
+// explicit path is validated and used as is; "" or "/sys/fs/cgroup" walks
+// the auto-detected candidates, keeping the first one that passes validation
+// and accepts the attach. Each rejected candidate is logged with its reason.
 func (m *SockMapManager) AttachToCgroup(cgroupPath string) error {
-	if !m.enabled {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.enabled.Load() {
 		return errors.New("eBPF not enabled")
 	}
+	if err := m.attachLocked(cgroupPath); err != nil {
+		return err
+	}
+	m.cgroupPath = cgroupPath
+	return nil
+}
+
+// attachLocked attaches sockops, replacing an existing attachment; mu must be held
+func (m *SockMapManager) attachLocked(cgroupPath string) error {
+	m.detachLocked()
 
-	// Auto-detect cgroup path if not specified or default path doesn't work
+	candidates := []cgroupCandidate{{path: cgroupPath, source: "configured"}}
 	if cgroupPath == "" || cgroupPath == "/sys/fs/cgroup" {
-		detectedPath := findCgroupPath()
-		if detectedPath != cgroupPath {
-			xlog.Debugf("Auto-detected cgroup path: %s", detectedPath)
-			cgroupPath = detectedPath
+		candidates = cgroupCandidates()
+	}
+
+	var rejected []string
+	for _, c := range candidates {
+		l, err := m.tryAttach(c.path)
+		if err != nil {
+			xlog.Infof("eBPF cgroup candidate %s (%s) rejected: %v", c.path, c.source, err)
+			rejected = append(rejected, fmt.Sprintf("%s: %v", c.path, err))
+			continue
 		}
+		m.cgroupLink = l
+		m.attachedTo = c.path
+		xlog.Infof("eBPF sockops attached to cgroup: %s (%s)", c.path, c.source)
+		return nil
 	}
+	return fmt.Errorf("no usable cgroup v2 path (%s)", strings.Join(rejected, "; "))
+}
 
-	// Open cgroup
-	cgroupFd, err := syscall.Open(cgroupPath, syscall.O_RDONLY, 0)
-	if err != nil {
-		return fmt.Errorf("opening cgroup %s: %w", cgroupPath, err)
+// detachLocked closes the sockops attachment, if any; mu must be held
+func (m *SockMapManager) detachLocked() {
+	if m.cgroupLink != nil {
+		m.cgroupLink.Close()
+		m.cgroupLink = nil
 	}
-	defer syscall.Close(cgroupFd)
+	m.attachedTo = ""
+}
 
-	// Attach sockops program
+// CgroupPath returns the cgroup sockops is attached to, or "" when detached
+func (m *SockMapManager) CgroupPath() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attachedTo
+}
+
+// tryAttach validates path and attaches sockops to it. The attach itself is
+// the final check: a successful link is kept rather than detached and redone.
+func (m *SockMapManager) tryAttach(path string) (link.Link, error) {
+	if err := checkCgroup2(path); err != nil {
+		return nil, err
+	}
 	l, err := link.AttachCgroup(link.CgroupOptions{
-		Path:    cgroupPath,
+		Path:    path,
 		Attach:  ebpf.AttachCGroupSockOps,
 		Program: m.objs.SockOpsHandler,
 	})
 	if err != nil {
-		return fmt.Errorf("attaching sockops to cgroup: %w", err)
+		return nil, fmt.Errorf("attaching sockops: %w", err)
 	}
-
-	m.cgroupLink = l
-	xlog.Infof("eBPF sockops attached to cgroup: %s", cgroupPath)
-	return nil
+	return l, nil
 }
 
-// RegisterSocketPair registers a client-backend socket pair for redirection
+// RegisterSocketPair registers a client-backend socket pair for redirection.
+// It returns ErrDisabled when acceleration is off; other errors wrap the
+// failure cause (see FailureReason).
 func (m *SockMapManager) RegisterSocketPair(clientConn, backendConn net.Conn) error {
-	if !m.enabled {
-		return nil // Silently skip if eBPF not enabled
+	if !m.IsEnabled() {
+		return ErrDisabled
+	}
+	if err := m.registerSocketPair(clientConn, backendConn); err != nil {
+		atomic.AddUint64(&m.counters.failures, 1)
+		return err
 	}
+	atomic.AddUint64(&m.counters.registered, 1)
+	return nil
+}
 
+func (m *SockMapManager) registerSocketPair(clientConn, backendConn net.Conn) error {
 	// Extract socket cookies
 	clientCookie, err := getSocketCookie(clientConn)
 	if err != nil {
-		return fmt.Errorf("getting client socket cookie: %w", err)
+		return fmt.Errorf("%w: client: %w", ErrNoSocketCookie, err)
 	}
 
 	backendCookie, err := getSocketCookie(backendConn)
 	if err != nil {
-		return fmt.Errorf("getting backend socket cookie: %w", err)
+		return fmt.Errorf("%w: backend: %w", ErrNoSocketCookie, err)
 	}
 
-	// Update sock_pair_map: client -> backend
-	if err := m.objs.SockPairMap.Update(clientCookie, backendCookie, ebpf.UpdateAny); err != nil {
-		return fmt.Errorf("updating sock_pair_map (client->backend): %w", err)
+	// sockops only inserts sockets it saw established (IPv4/IPv6 TCP inside the
+	// attached cgroup). A pair entry without both sockets in sock_map cannot be
+	// redirected, so leave the pair to the userspace proxy.
+	if !m.inSockMap(clientCookie) {
+		return fmt.Errorf("%w: client socket %s (unsupported address family or outside cgroup)", ErrNotInSockMap, clientConn.LocalAddr())
+	}
+	if !m.inSockMap(backendCookie) {
+		return fmt.Errorf("%w: backend socket %s (unsupported address family or outside cgroup)", ErrNotInSockMap, backendConn.LocalAddr())
 	}
 
-	// Update sock_pair_map: backend -> client (bidirectional)
-	if err := m.objs.SockPairMap.Update(backendCookie, clientCookie, ebpf.UpdateAny); err != nil {
-		return fmt.Errorf("updating sock_pair_map (backend->client): %w", err)
+	// Update sock_pair_map in both directions
+	if err := m.addPair(clientCookie, backendCookie); err != nil {
+		return fmt.Errorf("%w: %w", ErrPairMapUpdate, err)
 	}
 
 	xlog.Debugf("Registered socket pair: client=%d <-> backend=%d", clientCookie, backendCookie)
 	return nil
 }
 
-// UnregisterSocketPair removes a socket pair from the map
-func (m *SockMapManager) UnregisterSocketPair(clientConn, backendConn net.Conn) error {
-	if !m.enabled {
-		return nil
+// inSockMap reports whether sockops added the socket to sock_map.
+// If the kernel cannot look up SOCKHASH from userspace, sockops is trusted.
+func (m *SockMapManager) inSockMap(cookie uint64) bool {
+	present, known := m.lookupSock(cookie)
+	return present || !known
+}
+
+// lookupSock checks sock_map membership; known is false if the lookup is unsupported
+func (m *SockMapManager) lookupSock(cookie uint64) (present, known bool) {
+	var ref uint64
+	err := m.objs.SockMap.Lookup(&cookie, &ref)
+	if err == nil {
+		return true, true
 	}
+	if errors.Is(err, ebpf.ErrKeyNotExist) {
+		return false, true
+	}
+	xlog.Debugf("sock_map lookup unsupported: %v", err)
+	return false, false
+}
+
+// UnregisterSocketPair removes a socket pair registered by RegisterSocketPair.
+// It still runs after Disable, so pairs registered before are cleaned up.
+func (m *SockMapManager) UnregisterSocketPair(clientConn, backendConn net.Conn) error {
 
 	clientCookie, _ := getSocketCookie(clientConn)
 	backendCookie, _ := getSocketCookie(backendConn)
 
-	m.objs.SockPairMap.Delete(&clientCookie)
-	m.objs.SockPairMap.Delete(&backendCookie)
+	// Already gone if the reaper got there first
+	m.removePair(clientCookie, backendCookie)
+	return nil
+}
 
+// Enable turns acceleration on after Disable or the EBPF_ENABLED kill switch.
+// It loads the eBPF objects if they were never loaded and attaches sockops to
+// cgroupPath, or to the cgroup used before when cgroupPath is "" (see
+// AttachToCgroup for auto-detection).
+func (m *SockMapManager) Enable(cgroupPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errors.New("sockmap manager closed")
+	}
+	if cgroupPath == "" {
+		cgroupPath = m.cgroupPath
+	}
+	if m.enabled.Load() && m.attachedTo != "" && cgroupPath == m.cgroupPath {
+		return nil
+	}
+	if m.objs == nil {
+		objs := loadObjects()
+		if objs == nil {
+			return errors.New("eBPF not available on this system (see log)")
+		}
+		m.start(objs)
+	}
+	if err := m.attachLocked(cgroupPath); err != nil {
+		return err
+	}
+	m.cgroupPath = cgroupPath
+	m.enabled.Store(true)
+	xlog.Infof("eBPF SockMap acceleration enabled")
 	return nil
 }
 
+// Disable stops new registrations, detaches sockops and removes every
+// registered pair, so the verdict program passes all data up to the
+// userspace copy that still runs for each connection. The objects stay
+// loaded for a later Enable.
+func (m *SockMapManager) Disable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.enabled.Swap(false) {
+		return
+	}
+	m.detachLocked()
+	flushed := m.flushPairs()
+	xlog.Infof("eBPF SockMap acceleration disabled (%d pairs returned to the userspace proxy)", flushed)
+}
+
 // Close cleans up eBPF resources
 func (m *SockMapManager) Close() error {
-	if !m.enabled {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	m.enabled.Store(false)
+	if m.objs == nil {
 		return nil
 	}
+	close(m.stopReaper)
 
 	if m.cgroupLink != nil {
 		m.cgroupLink.Close()
 	}
-
-	if m.objs != nil {
-		m.objs.Close()
-	}
+	m.objs.Close()
 
 	xlog.Infof("eBPF SockMap manager closed")
 	return nil
 }
 
-// IsEnabled returns whether eBPF acceleration is enabled
+// IsEnabled returns whether eBPF acceleration is enabled. Safe to call on a nil manager.
 func (m *SockMapManager) IsEnabled() bool {
-	return m.enabled
+	return m != nil && m.enabled.Load()
 }
 
 // getSocketCookie extracts the kernel socket cookie from a net.Conn
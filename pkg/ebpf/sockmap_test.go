@@ -0,0 +1,151 @@
+//go:build linux
+// +build linux
+
+package ebpf
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+// newTestManager returns an enabled manager whose sock_map and sock_pair_map
+// are created from userspace, without loading or attaching the programs, so
+// sockets only enter sock_map when the test inserts them. It skips the test
+// when the process may not create BPF maps.
+func newTestManager(t *testing.T) *SockMapManager {
+	t.Helper()
+	rlimit.RemoveMemlock()
+	sockMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name: "sock_map", Type: ebpf.SockHash, KeySize: 8, ValueSize: 8, MaxEntries: 64,
+	})
+	if err != nil {
+		t.Skipf("cannot create BPF maps (needs CAP_BPF or root): %v", err)
+	}
+	t.Cleanup(func() { sockMap.Close() })
+	pairMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name: "sock_pair_map", Type: ebpf.Hash, KeySize: 8, ValueSize: 8, MaxEntries: 64,
+	})
+	if err != nil {
+		t.Skipf("cannot create BPF maps (needs CAP_BPF or root): %v", err)
+	}
+	t.Cleanup(func() { pairMap.Close() })
+
+	objs := &bpfObjects{}
+	objs.SockMap = sockMap
+	objs.SockPairMap = pairMap
+	m := &SockMapManager{objs: objs, pairs: make(map[uint64]sockPair)}
+	m.enabled.Store(true)
+	return m
+}
+
+// tcpPair returns both ends of an established TCP connection on addr
+func tcpPair(t *testing.T, network, addr string) (client, server *net.TCPConn) {
+	t.Helper()
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		t.Skipf("%s loopback unavailable: %v", network, err)
+	}
+	defer ln.Close()
+	c, err := net.Dial(network, ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	s, err := ln.Accept()
+	if err != nil {
+		c.Close()
+		t.Fatalf("accept: %v", err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+	})
+	return c.(*net.TCPConn), s.(*net.TCPConn)
+}
+
+// addToSockMap inserts c into sock_map keyed by its cookie, as sockops does
+func addToSockMap(t *testing.T, m *SockMapManager, c *net.TCPConn) {
+	t.Helper()
+	cookie, err := getSocketCookie(c)
+	if err != nil {
+		t.Fatalf("socket cookie: %v", err)
+	}
+	raw, err := c.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updateErr error
+	raw.Control(func(fd uintptr) {
+		updateErr = m.objs.SockMap.Update(cookie, uint64(fd), ebpf.UpdateAny)
+	})
+	if updateErr != nil {
+		t.Fatalf("sock_map update: %v", updateErr)
+	}
+}
+
+// pairMapLen counts the entries of sock_pair_map
+func pairMapLen(m *SockMapManager) int {
+	var k, v uint64
+	n := 0
+	iter := m.objs.SockPairMap.Iterate()
+	for iter.Next(&k, &v) {
+		n++
+	}
+	return n
+}
+
+func TestRegisterSocketPairRequiresSockMap(t *testing.T) {
+	for _, tc := range []struct{ network, addr string }{
+		{"tcp4", "127.0.0.1:0"},
+		{"tcp6", "[::1]:0"},
+	} {
+		t.Run(tc.network, func(t *testing.T) {
+			m := newTestManager(t)
+			client, _ := tcpPair(t, tc.network, tc.addr)
+			backend, _ := tcpPair(t, tc.network, tc.addr)
+
+			// Neither socket is in sock_map: nothing is written
+			if err := m.RegisterSocketPair(client, backend); !errors.Is(err, ErrNotInSockMap) {
+				t.Fatalf("err = %v, want ErrNotInSockMap", err)
+			}
+			if n := pairMapLen(m); n != 0 {
+				t.Fatalf("sock_pair_map has %d entries after a rejected pair", n)
+			}
+
+			// Only the client is in sock_map: still rejected
+			addToSockMap(t, m, client)
+			if err := m.RegisterSocketPair(client, backend); !errors.Is(err, ErrNotInSockMap) {
+				t.Fatalf("with only the client in sock_map: err = %v, want ErrNotInSockMap", err)
+			}
+			if n := pairMapLen(m); n != 0 {
+				t.Fatalf("sock_pair_map has %d entries after a rejected pair", n)
+			}
+
+			// Both in sock_map: registered in both directions
+			addToSockMap(t, m, backend)
+			if err := m.RegisterSocketPair(client, backend); err != nil {
+				t.Fatalf("RegisterSocketPair: %v", err)
+			}
+			clientCookie, _ := getSocketCookie(client)
+			backendCookie, _ := getSocketCookie(backend)
+			var peer uint64
+			if err := m.objs.SockPairMap.Lookup(clientCookie, &peer); err != nil || peer != backendCookie {
+				t.Errorf("client entry = %d, %v; want backend cookie %d", peer, err, backendCookie)
+			}
+			if err := m.objs.SockPairMap.Lookup(backendCookie, &peer); err != nil || peer != clientCookie {
+				t.Errorf("backend entry = %d, %v; want client cookie %d", peer, err, clientCookie)
+			}
+			if got := m.RegisteredPairs(); got != 1 {
+				t.Errorf("RegisteredPairs() = %d, want 1", got)
+			}
+
+			m.UnregisterSocketPair(client, backend)
+			if n := pairMapLen(m); n != 0 {
+				t.Errorf("sock_pair_map has %d entries after unregistering", n)
+			}
+		})
+	}
+}
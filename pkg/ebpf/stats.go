@@ -0,0 +1,59 @@
+package ebpf
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// RegisterSocketPair errors. Failures wrap one of the causes below; match
+// them with errors.Is or label them with FailureReason.
+var (
+	ErrDisabled       = errors.New("eBPF acceleration disabled")
+	ErrNoSocketCookie = errors.New("socket cookie unavailable")
+	ErrNotInSockMap   = errors.New("socket not in sock_map")
+	ErrPairMapUpdate  = errors.New("sock_pair_map update failed")
+)
+
+// FailureReason returns a short metric label for a RegisterSocketPair error
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrNoSocketCookie):
+		return "socket_cookie"
+	case errors.Is(err, ErrNotInSockMap):
+		return "not_in_sockmap"
+	case errors.Is(err, ErrPairMapUpdate):
+		return "pair_map_update"
+	default:
+		return "other"
+	}
+}
+
+// SockMapStats is a point-in-time view of sockmap redirection
+type SockMapStats struct {
+	Enabled          bool   `json:"enabled"`
+	CgroupPath       string `json:"cgroup_path,omitempty"` // Where sockops is attached
+	ActivePairs      int64  `json:"active_pairs"`
+	RegisteredTotal  uint64 `json:"registered_total"`
+	RegisterFailures uint64 `json:"register_failures_total"`
+}
+
+// sockMapCounters are updated atomically; active tracks the registered pair table
+type sockMapCounters struct {
+	active     int64
+	registered uint64
+	failures   uint64
+}
+
+// Stats returns the current redirection counters. Safe to call on a nil manager.
+func (m *SockMapManager) Stats() SockMapStats {
+	if m == nil {
+		return SockMapStats{}
+	}
+	return SockMapStats{
+		Enabled:          m.IsEnabled(),
+		CgroupPath:       m.CgroupPath(),
+		ActivePairs:      atomic.LoadInt64(&m.counters.active),
+		RegisteredTotal:  atomic.LoadUint64(&m.counters.registered),
+		RegisterFailures: atomic.LoadUint64(&m.counters.failures),
+	}
+}
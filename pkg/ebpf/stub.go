@@ -23,12 +23,12 @@ func (o *bpfObjects) Close() error {
 
 // SockMapManager stub for non-Linux platforms
 type SockMapManager struct {
-	enabled bool
+	counters sockMapCounters
 }
 
 // NewSockMapManager returns a disabled manager on non-Linux platforms
 func NewSockMapManager() (*SockMapManager, error) {
-	return &SockMapManager{enabled: false}, nil
+	return &SockMapManager{}, nil
 }
 
 // AttachToCgroup is a no-op on non-Linux platforms
@@ -36,9 +36,9 @@ func (m *SockMapManager) AttachToCgroup(cgroupPath string) error {
 	return errors.New("eBPF not supported on this platform")
 }
 
-// RegisterSocketPair is a no-op on non-Linux platforms
+// RegisterSocketPair always returns ErrDisabled on non-Linux platforms
 func (m *SockMapManager) RegisterSocketPair(clientConn, backendConn net.Conn) error {
-	return nil // Silently skip
+	return ErrDisabled
 }
 
 // UnregisterSocketPair is a no-op on non-Linux platforms
@@ -46,6 +46,27 @@ func (m *SockMapManager) UnregisterSocketPair(clientConn, backendConn net.Conn)
 	return nil
 }
 
+// RegisteredPairs always returns 0 on non-Linux platforms
+func (m *SockMapManager) RegisteredPairs() int {
+	return 0
+}
+
+// ObservePairs is a no-op on non-Linux platforms
+func (m *SockMapManager) ObservePairs(fn func(pairs int)) {}
+
+// Enable always fails on non-Linux platforms
+func (m *SockMapManager) Enable(cgroupPath string) error {
+	return errors.New("eBPF not supported on this platform")
+}
+
+// Disable is a no-op on non-Linux platforms
+func (m *SockMapManager) Disable() {}
+
+// CgroupPath always returns "" on non-Linux platforms
+func (m *SockMapManager) CgroupPath() string {
+	return ""
+}
+
 // Close is a no-op on non-Linux platforms
 func (m *SockMapManager) Close() error {
 	return nil
@@ -55,4 +76,3 @@ func (m *SockMapManager) Close() error {
 func (m *SockMapManager) IsEnabled() bool {
 	return false
 }
-